@@ -4,15 +4,21 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
-var DB *sql.DB
-
-func InitDB() {
-	var err error
-	DB, err = sql.Open("sqlite3", "./courses.db")
+// OpenDB opens a database and brings its schema up to date. Every tenant has a database of its
+// own holding all of these tables, so a tenant's rows are never stored next to another's.
+func OpenDB(path string) *sql.DB {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		log.Fatalf("Failed to create the database directory: %v", err)
+	}
+	// writers wait for each other rather than failing, and transactions take the write lock when
+	// they begin so that two of them never deadlock upgrading their locks
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000&_txlock=immediate")
 	if err != nil {
 		log.Fatalf("Failed to connect to the database: %v", err)
 	}
@@ -26,22 +32,42 @@ func InitDB() {
 			email TEXT UNIQUE NOT NULL,
 			password TEXT NOT NULL,
 			role TEXT NOT NULL,
-			bio TEXT
+			bio TEXT,
+			deleted_at DATETIME
 		)`,
 		`CREATE TABLE IF NOT EXISTS courses (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			title TEXT NOT NULL,
 			description TEXT NOT NULL,
 			duration TEXT NOT NULL,
-			price REAL NOT NULL,
+			price_amount INTEGER NOT NULL DEFAULT 0,
+			price_currency TEXT NOT NULL DEFAULT 'USD',
 			instructor TEXT NOT NULL,
-			category TEXT NOT NULL
+			category TEXT NOT NULL,
+			deleted_at DATETIME,
+			rating_count INTEGER NOT NULL DEFAULT 0,
+			rating_sum INTEGER NOT NULL DEFAULT 0,
+			rating_1 INTEGER NOT NULL DEFAULT 0,
+			rating_2 INTEGER NOT NULL DEFAULT 0,
+			rating_3 INTEGER NOT NULL DEFAULT 0,
+			rating_4 INTEGER NOT NULL DEFAULT 0,
+			rating_5 INTEGER NOT NULL DEFAULT 0,
+			status TEXT NOT NULL DEFAULT 'draft',
+			publish_at DATETIME,
+			status_note TEXT
 		)`,
 		`CREATE TABLE IF NOT EXISTS enrollments (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			user_id INTEGER NOT NULL,
 			course_id INTEGER NOT NULL,
 			completed BOOLEAN DEFAULT FALSE,
+			completed_at DATETIME,
+			course_version INTEGER NOT NULL DEFAULT 0,
+			enrolled_at DATETIME,
+			revoked_at DATETIME,
+			revoke_reason TEXT NOT NULL DEFAULT '',
+			subscription_id INTEGER,
+			seat_pool_id INTEGER,
 			FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
 			FOREIGN KEY (course_id) REFERENCES courses (id) ON DELETE CASCADE
 		)`,
@@ -52,6 +78,18 @@ func InitDB() {
 			content TEXT NOT NULL,
 			video_url TEXT,
 			"order" INTEGER NOT NULL,
+			section_id INTEGER,
+			release_after_days INTEGER,
+			release_at DATETIME,
+			requires_previous BOOLEAN NOT NULL DEFAULT FALSE,
+			deleted_at DATETIME,
+			FOREIGN KEY (course_id) REFERENCES courses (id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS sections (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			course_id INTEGER NOT NULL,
+			title TEXT NOT NULL,
+			"order" INTEGER NOT NULL,
 			FOREIGN KEY (course_id) REFERENCES courses (id) ON DELETE CASCADE
 		)`,
 		`CREATE TABLE IF NOT EXISTS progress (
@@ -59,6 +97,7 @@ func InitDB() {
 			enrollment_id INTEGER NOT NULL,
 			lesson_id INTEGER NOT NULL,
 			completed BOOLEAN DEFAULT FALSE,
+			updated_at DATETIME,
 			FOREIGN KEY (enrollment_id) REFERENCES enrollments (id) ON DELETE CASCADE,
 			FOREIGN KEY (lesson_id) REFERENCES lessons (id) ON DELETE CASCADE
 		)`,
@@ -68,16 +107,739 @@ func InitDB() {
 			user_id INTEGER NOT NULL,
 			rating INTEGER NOT NULL CHECK(rating >= 1 AND rating <= 5),
 			comment TEXT,
+			status TEXT NOT NULL DEFAULT 'approved',
+			moderation_note TEXT,
+			created_at DATETIME,
+			reply_user_id INTEGER,
+			reply_body TEXT,
+			reply_at DATETIME,
+			FOREIGN KEY (course_id) REFERENCES courses (id) ON DELETE CASCADE,
+			FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS audit_logs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			actor_id INTEGER NOT NULL DEFAULT 0,
+			action TEXT NOT NULL,
+			entity_type TEXT NOT NULL,
+			entity_id INTEGER NOT NULL,
+			before_json TEXT,
+			after_json TEXT,
+			changes_json TEXT,
+			request_id TEXT,
+			created_at DATETIME NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS review_flags (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			review_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			reason TEXT,
+			created_at DATETIME NOT NULL,
+			UNIQUE (review_id, user_id),
+			FOREIGN KEY (review_id) REFERENCES reviews (id) ON DELETE CASCADE,
+			FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS course_versions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			course_id INTEGER NOT NULL,
+			version INTEGER NOT NULL,
+			snapshot_json TEXT NOT NULL,
+			created_at DATETIME NOT NULL,
+			UNIQUE (course_id, version),
+			FOREIGN KEY (course_id) REFERENCES courses (id) ON DELETE CASCADE
+		)`,
+		// published versions are immutable
+		`CREATE TRIGGER IF NOT EXISTS course_versions_no_update BEFORE UPDATE ON course_versions
+		BEGIN
+			SELECT RAISE(ABORT, 'course_versions are immutable');
+		END`,
+		`CREATE TABLE IF NOT EXISTS course_prerequisites (
+			course_id INTEGER NOT NULL,
+			prerequisite_id INTEGER NOT NULL,
+			required BOOLEAN NOT NULL DEFAULT TRUE,
+			PRIMARY KEY (course_id, prerequisite_id),
+			FOREIGN KEY (course_id) REFERENCES courses (id) ON DELETE CASCADE,
+			FOREIGN KEY (prerequisite_id) REFERENCES courses (id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS learning_paths (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			title TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE TABLE IF NOT EXISTS learning_path_courses (
+			path_id INTEGER NOT NULL,
+			course_id INTEGER NOT NULL,
+			position INTEGER NOT NULL,
+			PRIMARY KEY (path_id, course_id),
+			FOREIGN KEY (path_id) REFERENCES learning_paths (id) ON DELETE CASCADE,
+			FOREIGN KEY (course_id) REFERENCES courses (id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS question_banks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			course_id INTEGER NOT NULL,
+			title TEXT NOT NULL,
+			FOREIGN KEY (course_id) REFERENCES courses (id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS questions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			bank_id INTEGER NOT NULL,
+			type TEXT NOT NULL,
+			prompt TEXT NOT NULL,
+			choices_json TEXT,
+			answer_json TEXT NOT NULL,
+			points REAL NOT NULL DEFAULT 1,
+			deleted_at DATETIME,
+			FOREIGN KEY (bank_id) REFERENCES question_banks (id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS quizzes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			lesson_id INTEGER NOT NULL UNIQUE,
+			bank_id INTEGER NOT NULL,
+			title TEXT NOT NULL,
+			question_count INTEGER NOT NULL DEFAULT 0,
+			shuffle BOOLEAN NOT NULL DEFAULT FALSE,
+			time_limit_seconds INTEGER NOT NULL DEFAULT 0,
+			max_attempts INTEGER NOT NULL DEFAULT 0,
+			passing_score REAL NOT NULL DEFAULT 0,
+			FOREIGN KEY (lesson_id) REFERENCES lessons (id) ON DELETE CASCADE,
+			FOREIGN KEY (bank_id) REFERENCES question_banks (id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS quiz_attempts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			quiz_id INTEGER NOT NULL,
+			enrollment_id INTEGER NOT NULL,
+			question_ids_json TEXT NOT NULL,
+			answers_json TEXT,
+			started_at DATETIME NOT NULL,
+			deadline_at DATETIME,
+			submitted_at DATETIME,
+			score REAL NOT NULL DEFAULT 0,
+			passed BOOLEAN NOT NULL DEFAULT FALSE,
+			FOREIGN KEY (quiz_id) REFERENCES quizzes (id) ON DELETE CASCADE,
+			FOREIGN KEY (enrollment_id) REFERENCES enrollments (id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_quiz_attempts_enrollment ON quiz_attempts (enrollment_id, quiz_id)`,
+		`CREATE TABLE IF NOT EXISTS assignments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			course_id INTEGER NOT NULL,
+			lesson_id INTEGER,
+			title TEXT NOT NULL,
+			instructions TEXT NOT NULL DEFAULT '',
+			due_at DATETIME,
+			rubric_json TEXT,
+			max_points REAL NOT NULL,
+			passing_score REAL NOT NULL DEFAULT 0,
+			required BOOLEAN NOT NULL DEFAULT FALSE,
+			allow_resubmit BOOLEAN NOT NULL DEFAULT FALSE,
+			max_submissions INTEGER NOT NULL DEFAULT 0,
+			accept_late BOOLEAN NOT NULL DEFAULT FALSE,
+			late_penalty_percent REAL NOT NULL DEFAULT 0,
 			FOREIGN KEY (course_id) REFERENCES courses (id) ON DELETE CASCADE,
+			FOREIGN KEY (lesson_id) REFERENCES lessons (id) ON DELETE SET NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS submissions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			assignment_id INTEGER NOT NULL,
+			enrollment_id INTEGER NOT NULL,
+			number INTEGER NOT NULL,
+			text TEXT NOT NULL DEFAULT '',
+			submitted_at DATETIME NOT NULL,
+			days_late INTEGER NOT NULL DEFAULT 0,
+			status TEXT NOT NULL,
+			rubric_scores_json TEXT,
+			points REAL,
+			penalty_percent REAL NOT NULL DEFAULT 0,
+			score REAL,
+			passed BOOLEAN NOT NULL DEFAULT FALSE,
+			feedback TEXT NOT NULL DEFAULT '',
+			graded_by INTEGER,
+			graded_at DATETIME,
+			UNIQUE (assignment_id, enrollment_id, number),
+			FOREIGN KEY (assignment_id) REFERENCES assignments (id) ON DELETE CASCADE,
+			FOREIGN KEY (enrollment_id) REFERENCES enrollments (id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS submission_files (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			submission_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			content_type TEXT NOT NULL,
+			size INTEGER NOT NULL,
+			storage_key TEXT NOT NULL UNIQUE,
+			FOREIGN KEY (submission_id) REFERENCES submissions (id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_submissions_enrollment ON submissions (enrollment_id, assignment_id)`,
+		`CREATE TABLE IF NOT EXISTS grade_weights (
+			course_id INTEGER PRIMARY KEY,
+			lessons REAL NOT NULL,
+			quizzes REAL NOT NULL,
+			assignments REAL NOT NULL,
+			FOREIGN KEY (course_id) REFERENCES courses (id) ON DELETE CASCADE
+		)`,
+		// certificates outlive the enrollment, user and course they were issued for
+		`CREATE TABLE IF NOT EXISTS certificates (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			enrollment_id INTEGER NOT NULL UNIQUE,
+			user_id INTEGER NOT NULL,
+			course_id INTEGER NOT NULL,
+			code TEXT NOT NULL UNIQUE,
+			student_name TEXT NOT NULL,
+			course_title TEXT NOT NULL,
+			instructor TEXT NOT NULL,
+			completed_at DATETIME NOT NULL,
+			issued_at DATETIME NOT NULL,
+			storage_key TEXT NOT NULL,
+			revoked_at DATETIME,
+			revoked_reason TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE TABLE IF NOT EXISTS cart_items (
+			user_id INTEGER NOT NULL,
+			course_id INTEGER NOT NULL,
+			added_at DATETIME NOT NULL,
+			PRIMARY KEY (user_id, course_id),
+			FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+			FOREIGN KEY (course_id) REFERENCES courses (id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS orders (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			status TEXT NOT NULL,
+			currency TEXT NOT NULL DEFAULT 'USD',
+			total_amount INTEGER NOT NULL DEFAULT 0,
+			discount_amount INTEGER NOT NULL DEFAULT 0,
+			payment_method TEXT NOT NULL,
+			payment_id TEXT NOT NULL DEFAULT '',
+			action_url TEXT NOT NULL DEFAULT '',
+			failure_reason TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL,
+			paid_at DATETIME,
+			FOREIGN KEY (user_id) REFERENCES users (id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_orders_user ON orders (user_id)`,
+		// order items keep the title and price paid even if the course changes later
+		`CREATE TABLE IF NOT EXISTS order_items (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			order_id INTEGER NOT NULL,
+			course_id INTEGER NOT NULL,
+			title TEXT NOT NULL,
+			price_amount INTEGER NOT NULL DEFAULT 0,
+			discount_amount INTEGER NOT NULL DEFAULT 0,
+			enrollment_id INTEGER,
+			FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS coupons (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			code TEXT NOT NULL UNIQUE,
+			kind TEXT NOT NULL,
+			percent REAL NOT NULL DEFAULT 0,
+			amount_off INTEGER,
+			amount_off_currency TEXT NOT NULL DEFAULT '',
+			course_id INTEGER,
+			max_redemptions INTEGER NOT NULL DEFAULT 0,
+			max_per_user INTEGER NOT NULL DEFAULT 0,
+			starts_at DATETIME,
+			ends_at DATETIME,
+			stackable BOOLEAN NOT NULL DEFAULT FALSE,
+			active BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at DATETIME NOT NULL,
+			FOREIGN KEY (course_id) REFERENCES courses (id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS cart_coupons (
+			user_id INTEGER NOT NULL,
+			coupon_id INTEGER NOT NULL,
+			added_at DATETIME NOT NULL,
+			PRIMARY KEY (user_id, coupon_id),
+			FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+			FOREIGN KEY (coupon_id) REFERENCES coupons (id) ON DELETE CASCADE
+		)`,
+		// a redemption is made with its order, failed and cancelled orders give it back
+		`CREATE TABLE IF NOT EXISTS coupon_redemptions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			coupon_id INTEGER NOT NULL,
+			order_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			discount_amount INTEGER NOT NULL DEFAULT 0,
+			redeemed_at DATETIME NOT NULL,
+			UNIQUE (coupon_id, order_id),
+			FOREIGN KEY (coupon_id) REFERENCES coupons (id),
+			FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE
+		)`,
+		// prices of a course in other currencies than its base price
+		`CREATE TABLE IF NOT EXISTS course_prices (
+			course_id INTEGER NOT NULL,
+			currency TEXT NOT NULL,
+			amount INTEGER NOT NULL,
+			PRIMARY KEY (course_id, currency),
+			FOREIGN KEY (course_id) REFERENCES courses (id) ON DELETE CASCADE
+		)`,
+		// refunds of courses bought in orders, amounts are in the currency of the order
+		`CREATE TABLE IF NOT EXISTS refunds (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			order_id INTEGER NOT NULL,
+			order_item_id INTEGER NOT NULL,
+			course_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			status TEXT NOT NULL,
+			amount INTEGER NOT NULL,
+			reason TEXT NOT NULL DEFAULT '',
+			progress REAL NOT NULL DEFAULT 0,
+			policy_note TEXT NOT NULL DEFAULT '',
+			reviewer_id INTEGER,
+			review_note TEXT NOT NULL DEFAULT '',
+			gateway_refund_id TEXT NOT NULL DEFAULT '',
+			requested_at DATETIME NOT NULL,
+			reviewed_at DATETIME,
+			FOREIGN KEY (order_id) REFERENCES orders (id),
+			FOREIGN KEY (order_item_id) REFERENCES order_items (id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_refunds_order ON refunds (order_id)`,
+		`CREATE TABLE IF NOT EXISTS billing_details (
+			user_id INTEGER PRIMARY KEY,
+			company TEXT NOT NULL DEFAULT '',
+			address TEXT NOT NULL DEFAULT '',
+			tax_id TEXT NOT NULL DEFAULT '',
+			email TEXT NOT NULL DEFAULT '',
+			updated_at DATETIME NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+		)`,
+		// invoices are numbered without gaps and keep a copy of everything printed on them,
+		// parties, lines and taxes as JSON
+		`CREATE TABLE IF NOT EXISTS invoices (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			sequence INTEGER NOT NULL UNIQUE,
+			order_id INTEGER NOT NULL UNIQUE,
+			user_id INTEGER NOT NULL,
+			currency TEXT NOT NULL,
+			seller TEXT NOT NULL,
+			buyer TEXT NOT NULL,
+			lines TEXT NOT NULL,
+			taxes TEXT NOT NULL,
+			subtotal_amount INTEGER NOT NULL,
+			tax_amount INTEGER NOT NULL,
+			total_amount INTEGER NOT NULL,
+			payment_method TEXT NOT NULL,
+			payment_id TEXT NOT NULL DEFAULT '',
+			paid_at DATETIME NOT NULL,
+			issued_at DATETIME NOT NULL,
+			emailed_at DATETIME,
+			FOREIGN KEY (order_id) REFERENCES orders (id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_invoices_user ON invoices (user_id)`,
+		`CREATE TABLE IF NOT EXISTS subscription_plans (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			interval TEXT NOT NULL,
+			price_amount INTEGER NOT NULL,
+			currency TEXT NOT NULL,
+			categories TEXT NOT NULL,
+			active BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at DATETIME NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS subscriptions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			plan_id INTEGER NOT NULL,
+			status TEXT NOT NULL,
+			payment_method TEXT NOT NULL,
+			current_period_start DATETIME NOT NULL,
+			current_period_end DATETIME NOT NULL,
+			cancel_at_period_end BOOLEAN NOT NULL DEFAULT FALSE,
+			cancelled_at DATETIME,
+			grace_until DATETIME,
+			next_attempt_at DATETIME,
+			ended_at DATETIME,
+			failure_reason TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES users (id),
+			FOREIGN KEY (plan_id) REFERENCES subscription_plans (id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_subscriptions_user ON subscriptions (user_id)`,
+		`CREATE TABLE IF NOT EXISTS subscription_payments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			subscription_id INTEGER NOT NULL,
+			amount INTEGER NOT NULL,
+			currency TEXT NOT NULL,
+			status TEXT NOT NULL,
+			payment_id TEXT NOT NULL DEFAULT '',
+			failure_reason TEXT NOT NULL DEFAULT '',
+			period_start DATETIME NOT NULL,
+			period_end DATETIME NOT NULL,
+			attempted_at DATETIME NOT NULL,
+			FOREIGN KEY (subscription_id) REFERENCES subscriptions (id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_subscription_payments_subscription ON subscription_payments (subscription_id)`,
+		`CREATE TABLE IF NOT EXISTS organizations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			created_at DATETIME NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS organization_members (
+			organization_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			role TEXT NOT NULL,
+			joined_at DATETIME NOT NULL,
+			PRIMARY KEY (organization_id, user_id),
+			FOREIGN KEY (organization_id) REFERENCES organizations (id),
+			FOREIGN KEY (user_id) REFERENCES users (id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_organization_members_user ON organization_members (user_id)`,
+		`CREATE TABLE IF NOT EXISTS organization_invitations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			organization_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			role TEXT NOT NULL,
+			status TEXT NOT NULL,
+			invited_by INTEGER NOT NULL,
+			created_at DATETIME NOT NULL,
+			responded_at DATETIME,
+			FOREIGN KEY (organization_id) REFERENCES organizations (id),
+			FOREIGN KEY (user_id) REFERENCES users (id)
+		)`,
+		// a user has at most one pending invitation per organization
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_organization_invitations_pending ON organization_invitations (organization_id, user_id) WHERE status = 'pending'`,
+		`CREATE INDEX IF NOT EXISTS idx_organization_invitations_user ON organization_invitations (user_id)`,
+		`CREATE TABLE IF NOT EXISTS seat_pools (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			organization_id INTEGER NOT NULL,
+			course_id INTEGER NOT NULL,
+			seats INTEGER NOT NULL,
+			created_at DATETIME NOT NULL,
+			UNIQUE (organization_id, course_id),
+			FOREIGN KEY (organization_id) REFERENCES organizations (id),
+			FOREIGN KEY (course_id) REFERENCES courses (id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS seat_purchases (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			organization_id INTEGER NOT NULL,
+			pool_id INTEGER NOT NULL,
+			course_id INTEGER NOT NULL,
+			seats INTEGER NOT NULL,
+			unit_price INTEGER NOT NULL,
+			total INTEGER NOT NULL,
+			currency TEXT NOT NULL,
+			payment_id TEXT NOT NULL,
+			purchased_by INTEGER NOT NULL,
+			purchased_at DATETIME NOT NULL,
+			FOREIGN KEY (organization_id) REFERENCES organizations (id),
+			FOREIGN KEY (pool_id) REFERENCES seat_pools (id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_seat_purchases_organization ON seat_purchases (organization_id)`,
+		`CREATE TABLE IF NOT EXISTS notification_preferences (
+			user_id INTEGER PRIMARY KEY,
+			enrollment BOOLEAN NOT NULL,
+			completion BOOLEAN NOT NULL,
+			reviews BOOLEAN NOT NULL,
+			updated_at DATETIME NOT NULL,
 			FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
 		)`,
+		// notification emails are written here and sent by the mailer, so they survive restarts
+		// and are retried while the mail server fails
+		`CREATE TABLE IF NOT EXISTS outbox_emails (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			kind TEXT NOT NULL,
+			user_id INTEGER NOT NULL,
+			recipient TEXT NOT NULL,
+			subject TEXT NOT NULL,
+			text_body TEXT NOT NULL,
+			html_body TEXT NOT NULL DEFAULT '',
+			status TEXT NOT NULL DEFAULT 'pending',
+			attempts INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT NOT NULL DEFAULT '',
+			next_attempt_at DATETIME NOT NULL,
+			created_at DATETIME NOT NULL,
+			sent_at DATETIME
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_outbox_emails_due ON outbox_emails (status, next_attempt_at)`,
+		// signed in sessions, only a hash of each token is stored
+		`CREATE TABLE IF NOT EXISTS sessions (
+			token_hash TEXT PRIMARY KEY,
+			user_id INTEGER NOT NULL,
+			created_at DATETIME NOT NULL,
+			expires_at DATETIME NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions (user_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_logs_entity ON audit_logs (entity_type, entity_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs (created_at)`,
+		// the audit log is append-only
+		`CREATE TRIGGER IF NOT EXISTS audit_logs_no_update BEFORE UPDATE ON audit_logs
+		BEGIN
+			SELECT RAISE(ABORT, 'audit_logs is append-only');
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS audit_logs_no_delete BEFORE DELETE ON audit_logs
+		BEGIN
+			SELECT RAISE(ABORT, 'audit_logs is append-only');
+		END`,
 	}
 
 	for _, query := range tables {
-		if _, err := DB.Exec(query); err != nil {
+		if _, err := db.Exec(query); err != nil {
+			log.Fatalf("Failed to execute query: %v", err)
+		}
+	}
+
+	// Columns added after the initial schema; CREATE TABLE IF NOT EXISTS
+	// leaves existing tables untouched, so add them to older databases here
+	columns := []struct {
+		table, column, definition string
+	}{
+		{"users", "deleted_at", "DATETIME"},
+		{"courses", "deleted_at", "DATETIME"},
+		{"lessons", "deleted_at", "DATETIME"},
+		{"lessons", "section_id", "INTEGER"},
+		{"lessons", "release_after_days", "INTEGER"},
+		{"lessons", "release_at", "DATETIME"},
+		{"lessons", "requires_previous", "BOOLEAN NOT NULL DEFAULT FALSE"},
+		{"enrollments", "enrolled_at", "DATETIME"},
+		{"enrollments", "completed_at", "DATETIME"},
+		{"enrollments", "course_version", "INTEGER NOT NULL DEFAULT 0"},
+		{"enrollments", "revoked_at", "DATETIME"},
+		{"enrollments", "revoke_reason", "TEXT NOT NULL DEFAULT ''"},
+		{"enrollments", "subscription_id", "INTEGER"},
+		{"enrollments", "seat_pool_id", "INTEGER"},
+		{"progress", "updated_at", "DATETIME"},
+		{"courses", "rating_count", "INTEGER NOT NULL DEFAULT 0"},
+		{"courses", "rating_sum", "INTEGER NOT NULL DEFAULT 0"},
+		{"courses", "rating_1", "INTEGER NOT NULL DEFAULT 0"},
+		{"courses", "rating_2", "INTEGER NOT NULL DEFAULT 0"},
+		{"courses", "rating_3", "INTEGER NOT NULL DEFAULT 0"},
+		{"courses", "rating_4", "INTEGER NOT NULL DEFAULT 0"},
+		{"courses", "rating_5", "INTEGER NOT NULL DEFAULT 0"},
+		// courses created before the publishing workflow were already public
+		{"courses", "status", "TEXT NOT NULL DEFAULT 'published'"},
+		{"courses", "publish_at", "DATETIME"},
+		{"courses", "status_note", "TEXT"},
+		// reviews written before moderation existed were already public
+		{"reviews", "status", "TEXT NOT NULL DEFAULT 'approved'"},
+		{"reviews", "moderation_note", "TEXT"},
+		{"reviews", "created_at", "DATETIME"},
+		{"reviews", "reply_user_id", "INTEGER"},
+		{"reviews", "reply_body", "TEXT"},
+		{"reviews", "reply_at", "DATETIME"},
+		// amounts are integer minor units with a currency, see migrateMoney
+		{"courses", "price_amount", "INTEGER NOT NULL DEFAULT 0"},
+		{"courses", "price_currency", "TEXT NOT NULL DEFAULT 'USD'"},
+		{"orders", "currency", "TEXT NOT NULL DEFAULT 'USD'"},
+		{"orders", "total_amount", "INTEGER NOT NULL DEFAULT 0"},
+		{"orders", "discount_amount", "INTEGER NOT NULL DEFAULT 0"},
+		{"order_items", "price_amount", "INTEGER NOT NULL DEFAULT 0"},
+		{"order_items", "discount_amount", "INTEGER NOT NULL DEFAULT 0"},
+		{"coupons", "percent", "REAL NOT NULL DEFAULT 0"},
+		{"coupons", "amount_off", "INTEGER"},
+		{"coupons", "amount_off_currency", "TEXT NOT NULL DEFAULT ''"},
+		{"coupon_redemptions", "discount_amount", "INTEGER NOT NULL DEFAULT 0"},
+	}
+
+	for _, col := range columns {
+		if err := addColumn(db, col.table, col.column, col.definition); err != nil {
+			log.Fatalf("Failed to migrate %s.%s: %v", col.table, col.column, err)
+		}
+	}
+	if err := migrateMoney(db); err != nil {
+		log.Fatalf("Failed to migrate amounts: %v", err)
+	}
+
+	if err := migrateSchema(db); err != nil {
+		log.Fatalf("Failed to migrate the database: %v", err)
+	}
+
+	// A user is enrolled in a course at most once, has one progress row per lesson and
+	// reviews a course once. Duplicates in older databases are merged by mergeDuplicates.
+	constraints := []string{
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_enrollments_user_course ON enrollments (user_id, course_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_enrollments_seat_pool ON enrollments (seat_pool_id)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_progress_enrollment_lesson ON progress (enrollment_id, lesson_id)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_reviews_user_course ON reviews (user_id, course_id)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_quiz_attempts_open ON quiz_attempts (quiz_id, enrollment_id) WHERE submitted_at IS NULL`,
+		// rating aggregates of approved reviews are kept up to date by the repository, reconcile them once at startup
+		`UPDATE courses SET
+			rating_count = (SELECT COUNT(*) FROM reviews WHERE course_id = courses.id AND status = 'approved'),
+			rating_sum = (SELECT COALESCE(SUM(rating), 0) FROM reviews WHERE course_id = courses.id AND status = 'approved'),
+			rating_1 = (SELECT COUNT(*) FROM reviews WHERE course_id = courses.id AND status = 'approved' AND rating = 1),
+			rating_2 = (SELECT COUNT(*) FROM reviews WHERE course_id = courses.id AND status = 'approved' AND rating = 2),
+			rating_3 = (SELECT COUNT(*) FROM reviews WHERE course_id = courses.id AND status = 'approved' AND rating = 3),
+			rating_4 = (SELECT COUNT(*) FROM reviews WHERE course_id = courses.id AND status = 'approved' AND rating = 4),
+			rating_5 = (SELECT COUNT(*) FROM reviews WHERE course_id = courses.id AND status = 'approved' AND rating = 5)`,
+		// lesson and section order is kept gap free per course, older databases may hold gaps and duplicates
+		`UPDATE sections SET "order" = ranked.position FROM (
+			SELECT id, ROW_NUMBER() OVER (PARTITION BY course_id ORDER BY "order", id) AS position FROM sections
+		) AS ranked WHERE sections.id = ranked.id`,
+		`UPDATE lessons SET "order" = ranked.position FROM (
+			SELECT l.id, ROW_NUMBER() OVER (PARTITION BY l.course_id ORDER BY COALESCE(s."order", 0), l."order", l.id) AS position
+			FROM lessons l LEFT JOIN sections s ON s.id = l.section_id
+			WHERE l.deleted_at IS NULL
+		) AS ranked WHERE lessons.id = ranked.id`,
+	}
+
+	for _, query := range constraints {
+		if _, err := db.Exec(query); err != nil {
 			log.Fatalf("Failed to execute query: %v", err)
 		}
 	}
 
-	fmt.Println("Database connected and tables initialized.")
+	fmt.Printf("Database %s connected and tables initialized.\n", path)
+	return db
+}
+
+// addColumn adds a column to a table unless it already exists
+func addColumn(db *sql.DB, table, column, definition string) error {
+	exists, err := hasColumn(db, table, column)
+	if err != nil || exists {
+		return err
+	}
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// hasColumn reports whether a table has a column
+func hasColumn(db *sql.DB, table, column string) (bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, colType    string
+			defaultValue     sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// migrateMoney moves the REAL amounts of older databases into the integer minor unit columns
+// and drops them. Every amount used to be in US dollars.
+func migrateMoney(db *sql.DB) error {
+	conversions := []struct {
+		table, column string
+		updates       []string
+	}{
+		{"courses", "price", []string{"UPDATE courses SET price_amount = CAST(ROUND(price * 100) AS INTEGER), price_currency = 'USD'"}},
+		{"orders", "total", []string{"UPDATE orders SET total_amount = CAST(ROUND(total * 100) AS INTEGER), currency = 'USD'"}},
+		{"orders", "discount", []string{"UPDATE orders SET discount_amount = CAST(ROUND(discount * 100) AS INTEGER)"}},
+		{"order_items", "price", []string{"UPDATE order_items SET price_amount = CAST(ROUND(price * 100) AS INTEGER)"}},
+		{"order_items", "discount", []string{"UPDATE order_items SET discount_amount = CAST(ROUND(discount * 100) AS INTEGER)"}},
+		{"coupon_redemptions", "discount", []string{"UPDATE coupon_redemptions SET discount_amount = CAST(ROUND(discount * 100) AS INTEGER)"}},
+		{"coupons", "amount", []string{
+			"UPDATE coupons SET percent = amount WHERE kind = 'percent'",
+			"UPDATE coupons SET amount_off = CAST(ROUND(amount * 100) AS INTEGER), amount_off_currency = 'USD' WHERE kind = 'fixed'",
+		}},
+	}
+
+	for _, conversion := range conversions {
+		exists, err := hasColumn(db, conversion.table, conversion.column)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		statements := append(conversion.updates, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", conversion.table, conversion.column))
+		for _, statement := range statements {
+			if _, err := tx.Exec(statement); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("%s.%s: %w", conversion.table, conversion.column, err)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrations change the data of older databases and run once per database, in order.
+// PRAGMA user_version holds how many of them a database has been through.
+var migrations = []func(tx *sql.Tx) error{
+	mergeDuplicates,
+}
+
+// migrateSchema runs the migrations a database has not been through yet, each in a transaction
+// of its own together with the version it brings the database to
+func migrateSchema(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	for ; version < len(migrations); version++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if err := migrations[version](tx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", version+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeDuplicates clears the duplicates older databases may hold before the unique indexes on
+// enrollments, progress, reviews and open quiz attempts are added. The progress of duplicate
+// enrollments moves to the first enrollment of the user in the course, keeping completed lessons,
+// and the emptied duplicates are removed. Duplicates that cannot be merged without losing data,
+// enrollments with quiz attempts, submissions, certificates or orders and second reviews of a
+// course, stop the migration so that they can be resolved by hand.
+func mergeDuplicates(tx *sql.Tx) error {
+	const duplicateEnrollments = `SELECT id FROM enrollments WHERE id NOT IN (SELECT MIN(id) FROM enrollments GROUP BY user_id, course_id)`
+	checks := []struct {
+		query, problem string
+	}{
+		{`SELECT COUNT(*) FROM enrollments WHERE id IN (` + duplicateEnrollments + `) AND (
+			id IN (SELECT enrollment_id FROM quiz_attempts) OR id IN (SELECT enrollment_id FROM submissions) OR
+			id IN (SELECT enrollment_id FROM certificates) OR id IN (SELECT enrollment_id FROM order_items WHERE enrollment_id IS NOT NULL))`,
+			"duplicate enrollments have quiz attempts, submissions, certificates or orders"},
+		{`SELECT COUNT(*) FROM reviews WHERE id NOT IN (SELECT MAX(id) FROM reviews GROUP BY user_id, course_id)`,
+			"reviews are a second review of a course by the same user"},
+	}
+	for _, check := range checks {
+		var count int
+		if err := tx.QueryRow(check.query).Scan(&count); err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("%d %s, resolve them by hand and restart", count, check.problem)
+		}
+	}
+
+	statements := []string{
+		`UPDATE progress SET enrollment_id = (
+			SELECT MIN(e2.id) FROM enrollments e1
+			JOIN enrollments e2 ON e2.user_id = e1.user_id AND e2.course_id = e1.course_id
+			WHERE e1.id = progress.enrollment_id
+		) WHERE enrollment_id IN (` + duplicateEnrollments + `)`,
+		`DELETE FROM enrollments WHERE id IN (` + duplicateEnrollments + `)`,
+		`DELETE FROM progress WHERE id != (
+			SELECT p2.id FROM progress p2
+			WHERE p2.enrollment_id = progress.enrollment_id AND p2.lesson_id = progress.lesson_id
+			ORDER BY p2.completed DESC, p2.id DESC LIMIT 1
+		)`,
+		// a student has one open attempt at a quiz, close all but the latest with a score of 0
+		`UPDATE quiz_attempts SET submitted_at = started_at WHERE submitted_at IS NULL
+			AND id NOT IN (SELECT MAX(id) FROM quiz_attempts WHERE submitted_at IS NULL GROUP BY quiz_id, enrollment_id)`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
 }
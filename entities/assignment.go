@@ -0,0 +1,110 @@
+package entities
+
+import "time"
+
+// submission statuses
+const (
+	SubmissionSubmitted = "submitted"
+	SubmissionGraded    = "graded"
+)
+
+//an assignment of a course, optionally tied to a lesson
+type Assignment struct {
+	ID                 uint              `json:"id"`
+	CourseID           uint              `json:"course_id"`
+	LessonID           *uint             `json:"lesson_id,omitempty"` // released with this lesson
+	Title              string            `json:"title"`
+	Instructions       string            `json:"instructions"`
+	DueAt              *time.Time        `json:"due_at,omitempty"`
+	Rubric             []RubricCriterion `json:"rubric"`
+	MaxPoints          float64           `json:"max_points"`           // the sum of the rubric points when there is a rubric, 100 by default otherwise
+	PassingScore       float64           `json:"passing_score"`        // percent of max_points needed to pass
+	Required           bool              `json:"required"`             // must be passed to complete the course
+	AllowResubmit      bool              `json:"allow_resubmit"`       // a new submission replaces the previous one
+	MaxSubmissions     uint              `json:"max_submissions"`      // with allow_resubmit, 0 for unlimited
+	AcceptLate         bool              `json:"accept_late"`          // accept submissions after due_at
+	LatePenaltyPercent float64           `json:"late_penalty_percent"` // taken off the score per started day late
+}
+
+//a line of an assignment rubric
+type RubricCriterion struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	Points      float64 `json:"points"`
+}
+
+//the points given for a rubric criterion
+type RubricScore struct {
+	Criterion string  `json:"criterion"`
+	Points    float64 `json:"points"`
+	Comment   string  `json:"comment,omitempty"`
+}
+
+//a student's work on an assignment, only the latest submission of an enrollment counts
+type Submission struct {
+	ID             uint             `json:"id"`
+	AssignmentID   uint             `json:"assignment_id"`
+	EnrollmentID   uint             `json:"enrollment_id"`
+	Number         uint             `json:"number"` // 1 for the first submission, 2 for the first resubmission, ...
+	Text           string           `json:"text"`
+	Files          []SubmissionFile `json:"files"`
+	SubmittedAt    time.Time        `json:"submitted_at"`
+	DaysLate       uint             `json:"days_late"`
+	Status         string           `json:"status"` // submitted or graded
+	RubricScores   []RubricScore    `json:"rubric_scores,omitempty"`
+	Points         *float64         `json:"points,omitempty"` // before the late penalty
+	PenaltyPercent float64          `json:"penalty_percent"`
+	Score          *float64         `json:"score,omitempty"` // points after the late penalty
+	Passed         bool             `json:"passed"`
+	Feedback       string           `json:"feedback,omitempty"`
+	GradedBy       *uint            `json:"graded_by,omitempty"`
+	GradedAt       *time.Time       `json:"graded_at,omitempty"`
+}
+
+//a file uploaded with a submission
+type SubmissionFile struct {
+	ID           uint   `json:"id"`
+	SubmissionID uint   `json:"submission_id"`
+	Name         string `json:"name"`
+	ContentType  string `json:"content_type"`
+	Size         int64  `json:"size"`
+	StorageKey   string `json:"-"`
+}
+
+//the grading of a submission, points is used by assignments without a rubric
+type SubmissionGrade struct {
+	RubricScores []RubricScore `json:"rubric_scores"`
+	Points       *float64      `json:"points"`
+	Feedback     string        `json:"feedback"`
+}
+
+//the grades of an enrollment across the quizzes and assignments of its course
+type EnrollmentGrades struct {
+	EnrollmentID uint              `json:"enrollment_id"`
+	CourseID     uint              `json:"course_id"`
+	Quizzes      []QuizGrade       `json:"quizzes"`
+	Assignments  []AssignmentGrade `json:"assignments"`
+}
+
+//the best attempt of an enrollment at a quiz
+type QuizGrade struct {
+	QuizID    uint     `json:"quiz_id"`
+	LessonID  uint     `json:"lesson_id"`
+	Title     string   `json:"title"`
+	Attempts  int      `json:"attempts"`
+	BestScore *float64 `json:"best_score,omitempty"` // percent, missing without a submitted attempt
+	Passed    bool     `json:"passed"`
+}
+
+//the latest submission of an enrollment to an assignment
+type AssignmentGrade struct {
+	AssignmentID uint       `json:"assignment_id"`
+	Title        string     `json:"title"`
+	Required     bool       `json:"required"`
+	DueAt        *time.Time `json:"due_at,omitempty"`
+	MaxPoints    float64    `json:"max_points"`
+	Status       string     `json:"status"` // missing, submitted or graded
+	SubmissionID *uint      `json:"submission_id,omitempty"`
+	Score        *float64   `json:"score,omitempty"`
+	Passed       bool       `json:"passed"`
+}
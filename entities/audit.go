@@ -0,0 +1,51 @@
+package entities
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// audit actions
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	AuditPurge   = "purge" // a soft deleted row was hard deleted
+)
+
+//audit logs record every mutation made through the use cases, they are never updated or deleted
+type AuditLog struct {
+	ID         uint            `json:"id"`
+	ActorID    uint            `json:"actor_id"` // 0 when the request did not identify a user
+	Action     string          `json:"action"`   // create, update, delete, restore or purge
+	EntityType string          `json:"entity_type"`
+	EntityID   uint            `json:"entity_id"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	Changes    json.RawMessage `json:"changes,omitempty"` // field -> {before, after} for updates
+	RequestID  string          `json:"request_id"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+//filter for querying audit logs, zero values are ignored
+type AuditFilter struct {
+	EntityType string
+	EntityID   uint
+	ActorID    uint
+	From       time.Time
+	To         time.Time
+	Limit      int
+}
+
+//the rows hard deleted by a purge
+type PurgedRows struct {
+	LessonIDs []uint
+	CourseIDs []uint
+	UserIDs   []uint
+}
+
+// Count is the number of rows purged
+func (p PurgedRows) Count() int64 {
+	return int64(len(p.LessonIDs) + len(p.CourseIDs) + len(p.UserIDs))
+}
@@ -0,0 +1,33 @@
+package entities
+
+import "time"
+
+//a certificate of completion, names are copied at issue time so later edits do not change it
+type Certificate struct {
+	ID            uint       `json:"id"`
+	EnrollmentID  uint       `json:"enrollment_id"`
+	UserID        uint       `json:"user_id"`
+	CourseID      uint       `json:"course_id"`
+	Code          string     `json:"code"` // public verification code
+	StudentName   string     `json:"student_name"`
+	CourseTitle   string     `json:"course_title"`
+	Instructor    string     `json:"instructor"`
+	CompletedAt   time.Time  `json:"completed_at"`
+	IssuedAt      time.Time  `json:"issued_at"`
+	StorageKey    string     `json:"-"` // the rendered PDF
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	RevokedReason string     `json:"revoked_reason,omitempty"`
+}
+
+//the public answer to a certificate verification
+type CertificateVerification struct {
+	Valid         bool       `json:"valid"` // false once revoked
+	Code          string     `json:"code"`
+	StudentName   string     `json:"student_name"`
+	CourseTitle   string     `json:"course_title"`
+	Instructor    string     `json:"instructor"`
+	CompletedAt   time.Time  `json:"completed_at"`
+	IssuedAt      time.Time  `json:"issued_at"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	RevokedReason string     `json:"revoked_reason,omitempty"`
+}
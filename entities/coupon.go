@@ -0,0 +1,66 @@
+package entities
+
+import "time"
+
+// coupon kinds
+const (
+	CouponPercent = "percent" // takes a percentage off the price
+	CouponFixed   = "fixed"   // takes an amount off the price, converted to the currency of the order
+)
+
+//a discount code, it applies to one course or to every course when CourseID is nil
+type Coupon struct {
+	ID             uint       `json:"id"`
+	Code           string     `json:"code"` // stored upper case, matched case-insensitively
+	Kind           string     `json:"kind"`
+	Percent        float64    `json:"percent,omitempty"`    // percent coupons
+	AmountOff      *Money     `json:"amount_off,omitempty"` // fixed coupons
+	CourseID       *uint      `json:"course_id,omitempty"`
+	MaxRedemptions int        `json:"max_redemptions"` // across all users, 0 for no limit
+	MaxPerUser     int        `json:"max_per_user"`    // 0 for no limit
+	StartsAt       *time.Time `json:"starts_at,omitempty"`
+	EndsAt         *time.Time `json:"ends_at,omitempty"`
+	Stackable      bool       `json:"stackable"` // whether it combines with other stackable coupons
+	Active         bool       `json:"active"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+//a coupon applied to a cart or an order with the discount it gives
+type AppliedCoupon struct {
+	CouponID uint   `json:"coupon_id"`
+	Code     string `json:"code"`
+	Discount Money  `json:"discount"`
+}
+
+//a use of a coupon by an order, it counts against the limits unless the order failed or was cancelled
+type CouponRedemption struct {
+	ID          uint      `json:"id"`
+	CouponID    uint      `json:"coupon_id"`
+	OrderID     uint      `json:"order_id"`
+	UserID      uint      `json:"user_id"`
+	OrderStatus string    `json:"order_status"`
+	Discount    Money     `json:"discount"`
+	RedeemedAt  time.Time `json:"redeemed_at"`
+}
+
+//how a coupon has been used, totals only count paid orders and have one amount per currency
+type CouponReport struct {
+	Coupon        Coupon             `json:"coupon"`
+	Redemptions   int                `json:"redemptions"`
+	Pending       int                `json:"pending"` // orders still waiting for their payment
+	UniqueUsers   int                `json:"unique_users"`
+	TotalDiscount []Money            `json:"total_discount"`
+	Revenue       []Money            `json:"revenue"` // paid for the orders that used it
+	History       []CouponRedemption `json:"history"`
+}
+
+// Live reports whether the coupon can be used at the given time, ignoring its limits
+func (c Coupon) Live(now time.Time) bool {
+	if !c.Active {
+		return false
+	}
+	if c.StartsAt != nil && now.Before(*c.StartsAt) {
+		return false
+	}
+	return c.EndsAt == nil || now.Before(*c.EndsAt)
+}
@@ -1,5 +1,7 @@
 package entities
 
+import "time"
+
 //this is entites or models or domain
 
 
@@ -8,9 +10,10 @@ type User struct {
 	FirstName string `json:"first_name"`
 	LastName string `json:"last_name"`
 	Email string `json:"email"`
-	Password string `json:"password"` // hashed password
-	Role string `json:"role"` // student or instructor
+	Password string `json:"password,omitempty"` // sent by clients only, stored as a bcrypt hash
+	Role string `json:"role"` // student, instructor or admin
 	Bio string `json:"bio"` // instructor bio optional
+	DeletedAt *time.Time `json:"deleted_at,omitempty"` // set when soft deleted
 }
 
 
@@ -20,9 +23,41 @@ type Course struct {
 	Title string `json:"title"`
 	Description string `json:"description"`
 	Duration string `json:"duration"`
-	Price float64 `json:"price"`
+	Price Money `json:"price"` // base price, prices in other currencies are set per course or converted from it
 	Instructor string `json:"instructor"` 
 	Category string `json:"category"` // programming, design, business, etc
+	DeletedAt *time.Time `json:"deleted_at,omitempty"` // set when soft deleted
+	Rating RatingSummary `json:"rating"` // maintained by the repository as reviews change
+	Status string `json:"status"` // draft, in_review, published or archived
+	PublishAt *time.Time `json:"publish_at,omitempty"` // when a published course becomes visible in the catalog
+	StatusNote string `json:"status_note,omitempty"` // why the last review of the course was rejected
+}
+
+// course publishing states
+const (
+	CourseDraft     = "draft"
+	CourseInReview  = "in_review"
+	CoursePublished = "published"
+	CourseArchived  = "archived"
+)
+
+// Visible reports whether students can see the course in the catalog at the given time
+func (c Course) Visible(now time.Time) bool {
+	return c.DeletedAt == nil && c.Status == CoursePublished && (c.PublishAt == nil || !c.PublishAt.After(now))
+}
+
+//filter for listing courses
+type CourseFilter struct {
+	IncludeDeleted bool
+	PublishedOnly  bool   // only courses visible in the catalog
+	Instructor     string // with PublishedOnly, also courses taught by this instructor whatever their status
+}
+
+//aggregate of the reviews of a course
+type RatingSummary struct {
+	Average   float64 `json:"average"`
+	Count     int     `json:"count"`
+	Histogram [5]int  `json:"histogram"` // number of 1 to 5 star ratings
 }
 
 //enrollment is the join table between users and courses
@@ -30,7 +65,21 @@ type Enrollment struct {
 	ID uint `json:"id" gorm:"primary_key"`
 	UserID uint `json:"user_id"`
 	CourseID uint `json:"course_id"`
-	Completed bool `json:"completed"`
+	Completed bool `json:"completed"` // derived from lesson progress, see CourseUseCase.refreshCompletion
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	EnrolledAt *time.Time `json:"enrolled_at,omitempty"` // unknown for enrollments made before it was recorded
+	CourseVersion uint `json:"course_version"` // published version the student follows, 0 follows the live course
+	RevokedAt *time.Time `json:"revoked_at,omitempty"` // set when a refund took the course away, the enrollment and its progress are kept
+	RevokeReason string `json:"revoke_reason,omitempty"`
+	SubscriptionID *uint `json:"subscription_id,omitempty"` // set when enrolled through a subscription, the course is open while a subscription covers it
+	SeatPoolID *uint `json:"seat_pool_id,omitempty"` // set when enrolled with a seat an organization bought, the seat is given back when the member leaves
+	PercentComplete *float64 `json:"percent_complete,omitempty"` // computed by GetEnrollmentByID, not stored
+	Warnings []string `json:"warnings,omitempty"` // recommended prerequisites not completed yet, not stored
+}
+
+// Revoked reports whether the enrollment was taken away, e.g. by a refund
+func (e Enrollment) Revoked() bool {
+	return e.RevokedAt != nil
 }
 
 
@@ -41,7 +90,14 @@ type Lesson struct {
 	Title string `json:"title"`
 	Content string `json:"content"`
 	VideoURL string `json:"video_url"`
-	Order uint `json:"order"` 
+	Order uint `json:"order"` // 1, 2, ... across the whole course, managed by the curriculum
+	SectionID *uint `json:"section_id"` // nil for lessons outside any section
+	ReleaseAfterDays *uint `json:"release_after_days,omitempty"` // drip: released this many days after enrollment
+	ReleaseAt *time.Time `json:"release_at,omitempty"` // drip: released on a fixed date
+	RequiresPrevious bool `json:"requires_previous,omitempty"` // drip: released once the previous lesson is completed
+	Locked bool `json:"locked,omitempty"` // computed for the viewer, locked lessons come without content and video_url
+	AvailableAt *time.Time `json:"available_at,omitempty"` // computed for the viewer, when a date based lock ends
+	DeletedAt *time.Time `json:"deleted_at,omitempty"` // set when soft deleted
 }
 
 //progress is the join table between enrollments and lessons
@@ -50,6 +106,29 @@ type Progress struct {
     EnrollmentID uint `json:"enrollment_id"`
     LessonID  uint `json:"lesson_id"`
     Completed bool `json:"completed"`
+    UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+//progress of an enrollment on one lesson of its course
+type LessonProgress struct {
+    LessonID  uint       `json:"lesson_id"`
+    Title     string     `json:"title"`
+    Order     uint       `json:"order"`
+    Completed bool       `json:"completed"`
+    UpdatedAt *time.Time `json:"updated_at,omitempty"` // nil when the lesson was never started
+    Locked    bool       `json:"locked"` // not released yet, see Lesson
+    AvailableAt *time.Time `json:"available_at,omitempty"`
+}
+
+//progress of an enrollment across its whole course
+type EnrollmentProgress struct {
+    EnrollmentID    uint             `json:"enrollment_id"`
+    CourseID        uint             `json:"course_id"`
+    Completed       bool             `json:"completed"`
+    PercentComplete float64          `json:"percent_complete"`
+    NextLesson      *LessonProgress  `json:"next_lesson"` // first lesson not completed yet, nil when done
+    LastActivityAt  *time.Time       `json:"last_activity_at"`
+    Lessons         []LessonProgress `json:"lessons"`
 }
 
 //reviews are associated with courses
@@ -59,5 +138,9 @@ type Review struct {
     UserID   uint   `json:"user_id"`
     Rating   int    `json:"rating"` // e.g., 1-5 stars
     Comment  string `json:"comment"`
+    Status   string `json:"status"` // pending, approved, rejected or flagged, only approved reviews are public
+    ModerationNote string `json:"moderation_note,omitempty"` // why the review was rejected or flagged
+    Reply    *ReviewReply `json:"reply,omitempty"`
+    CreatedAt *time.Time `json:"created_at,omitempty"`
 }
 
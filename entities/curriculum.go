@@ -0,0 +1,34 @@
+package entities
+
+//sections group the lessons of a course into modules
+type Section struct {
+	ID       uint   `json:"id"`
+	CourseID uint   `json:"course_id"`
+	Title    string `json:"title"`
+	Order    uint   `json:"order"` // 1, 2, ... within the course
+}
+
+//a section with its lessons in order
+type CurriculumSection struct {
+	Section
+	Lessons []Lesson `json:"lessons"`
+}
+
+//the lessons of a course, those outside any section come first
+type Curriculum struct {
+	CourseID uint                `json:"course_id"`
+	Lessons  []Lesson            `json:"lessons"`
+	Sections []CurriculumSection `json:"sections"`
+}
+
+//new order of a whole course: lesson IDs outside any section, then every section with its lesson IDs
+type CurriculumOrder struct {
+	Lessons  []uint         `json:"lessons"`
+	Sections []SectionOrder `json:"sections"`
+}
+
+//one section of a CurriculumOrder
+type SectionOrder struct {
+	ID      uint   `json:"id"`
+	Lessons []uint `json:"lessons"`
+}
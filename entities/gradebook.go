@@ -0,0 +1,55 @@
+package entities
+
+// gradebook column types
+const (
+	GradeLesson     = "lesson"
+	GradeQuiz       = "quiz"
+	GradeAssignment = "assignment"
+)
+
+//how much each category counts towards the total grade of a course. Weights are relative,
+//categories without any items are left out and the others scaled up.
+type GradeWeights struct {
+	CourseID    uint    `json:"course_id"`
+	Lessons     float64 `json:"lessons"`
+	Quizzes     float64 `json:"quizzes"`
+	Assignments float64 `json:"assignments"`
+}
+
+//a lesson, quiz or assignment of the gradebook
+type GradebookColumn struct {
+	Type      string  `json:"type"` // lesson, quiz or assignment
+	ID        uint    `json:"id"`
+	Title     string  `json:"title"`
+	MaxPoints float64 `json:"max_points,omitempty"` // assignments only
+}
+
+//the grades of a student, cells follow the gradebook columns
+type GradebookRow struct {
+	EnrollmentID       uint       `json:"enrollment_id"`
+	UserID             uint       `json:"user_id"`
+	StudentName        string     `json:"student_name"`
+	Email              string     `json:"email"`
+	Cells              []*float64 `json:"cells"` // percent, nil when not attempted or not in the student's course version
+	LessonsPercent     float64    `json:"lessons_percent"`
+	QuizzesPercent     float64    `json:"quizzes_percent"`
+	AssignmentsPercent float64    `json:"assignments_percent"`
+	Total              float64    `json:"total"` // weighted percent
+}
+
+//students × lessons and assessments of a course
+type Gradebook struct {
+	CourseID uint              `json:"course_id"`
+	Weights  GradeWeights      `json:"weights"`
+	Columns  []GradebookColumn `json:"columns"`
+	Rows     []GradebookRow    `json:"rows"`
+}
+
+//the gradebook of one student with the details behind every cell
+type StudentGradebook struct {
+	GradebookRow
+	Weights     GradeWeights      `json:"weights"`
+	Lessons     []LessonProgress  `json:"lessons"`
+	Quizzes     []QuizGrade       `json:"quizzes"`
+	Assignments []AssignmentGrade `json:"assignments"`
+}
@@ -0,0 +1,71 @@
+package entities
+
+import (
+	"fmt"
+	"time"
+)
+
+//who an invoice is from or made out to
+type InvoiceParty struct {
+	Name    string `json:"name"`
+	Company string `json:"company,omitempty"`
+	Address string `json:"address,omitempty"`
+	TaxID   string `json:"tax_id,omitempty"` // VAT or other tax registration number
+	Email   string `json:"email,omitempty"`
+}
+
+//the details a user wants on their invoices, business customers add their company and tax ID
+type BillingDetails struct {
+	UserID    uint      `json:"user_id"`
+	Company   string    `json:"company"`
+	Address   string    `json:"address"`
+	TaxID     string    `json:"tax_id"`
+	Email     string    `json:"email"` // invoices are sent here instead of the account email
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+//a course billed on an invoice, prices include tax so the net amount is what remains once it is taken out
+type InvoiceLine struct {
+	CourseID    uint    `json:"course_id"`
+	Description string  `json:"description"`
+	Price       Money   `json:"price"`
+	Discount    Money   `json:"discount"`
+	Net         Money   `json:"net"`
+	TaxRate     float64 `json:"tax_rate"` // percent
+	Tax         Money   `json:"tax"`
+	Total       Money   `json:"total"` // paid, net plus tax
+}
+
+//the tax of an invoice at one rate
+type TaxLine struct {
+	Name string  `json:"name"`
+	Rate float64 `json:"rate"` // percent
+	Net  Money   `json:"net"`
+	Tax  Money   `json:"tax"`
+}
+
+//an invoice for a paid order, everything on it is copied when it is issued so it never changes
+type Invoice struct {
+	ID            uint          `json:"id"`
+	Number        string        `json:"number"` // sequential, INV-000001
+	OrderID       uint          `json:"order_id"`
+	UserID        uint          `json:"user_id"`
+	Currency      string        `json:"currency"`
+	Seller        InvoiceParty  `json:"seller"`
+	Buyer         InvoiceParty  `json:"buyer"`
+	Lines         []InvoiceLine `json:"lines"`
+	Taxes         []TaxLine     `json:"taxes"`
+	Subtotal      Money         `json:"subtotal"` // net of all lines
+	Tax           Money         `json:"tax"`
+	Total         Money         `json:"total"`
+	PaymentMethod string        `json:"payment_method"`
+	PaymentID     string        `json:"payment_id,omitempty"`
+	PaidAt        time.Time     `json:"paid_at"`
+	IssuedAt      time.Time     `json:"issued_at"`
+	EmailedAt     *time.Time    `json:"emailed_at,omitempty"` // last time it was sent to the buyer
+}
+
+// InvoiceNumber writes the sequence number of an invoice as INV-000001
+func InvoiceNumber(sequence uint) string {
+	return fmt.Sprintf("INV-%06d", sequence)
+}
@@ -0,0 +1,27 @@
+package entities
+
+import "time"
+
+// review moderation states
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+	ReviewFlagged  = "flagged"
+)
+
+//the instructor's public answer to a review
+type ReviewReply struct {
+	UserID    uint      `json:"user_id"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+//a user reporting a review, each user flags a review at most once
+type ReviewFlag struct {
+	ID        uint      `json:"id"`
+	ReviewID  uint      `json:"review_id"`
+	UserID    uint      `json:"user_id"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,274 @@
+package entities
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is the currency of amounts given as plain numbers, and of every price
+// stored before prices had a currency
+const DefaultCurrency = "USD"
+
+// digits after the decimal point of the supported ISO 4217 currencies
+var currencyExponents = map[string]int{
+	"AUD": 2, "BDT": 2, "CAD": 2, "CHF": 2, "EUR": 2, "GBP": 2, "INR": 2, "SGD": 2, "USD": 2,
+	"JPY": 0, "KRW": 0,
+	"BHD": 3, "KWD": 3,
+}
+
+//an exact amount of money in the minor unit of its currency, 1999 USD is $19.99
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"` // ISO 4217 code
+}
+
+//the price of a course in one currency, either set for the course or converted from its base price
+type CoursePrice struct {
+	CourseID  uint   `json:"course_id"`
+	Price     Money  `json:"price"`
+	Converted bool   `json:"converted"` // worked out from the exchange rates rather than set
+	Display   string `json:"display,omitempty"`
+}
+
+// ValidCurrency reports whether the currency is supported
+func ValidCurrency(currency string) bool {
+	_, ok := currencyExponents[currency]
+	return ok
+}
+
+// CurrencyExponent returns the number of digits after the decimal point of a currency
+func CurrencyExponent(currency string) int {
+	return currencyExponents[currency]
+}
+
+// NewMoney returns an amount given in minor units
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// ParseMoney reads a decimal amount such as "19.99" in the given currency. Amounts with more
+// digits than the currency has are rejected rather than rounded.
+func ParseMoney(amount, currency string) (Money, error) {
+	return parseMoney(amount, currency, false)
+}
+
+func parseMoney(amount, currency string, round bool) (Money, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if !ValidCurrency(currency) {
+		return Money{}, fmt.Errorf("unsupported currency %q", currency)
+	}
+	value, ok := new(big.Rat).SetString(strings.TrimSpace(amount))
+	if !ok {
+		return Money{}, fmt.Errorf("invalid amount %q", amount)
+	}
+	value.Mul(value, new(big.Rat).SetInt(pow10(CurrencyExponent(currency))))
+	if !value.IsInt() && !round {
+		return Money{}, fmt.Errorf("%s has at most %d decimals", currency, CurrencyExponent(currency))
+	}
+	minor := RoundRat(value)
+	if !minor.IsInt64() {
+		return Money{}, fmt.Errorf("amount %s is too large", amount)
+	}
+	return Money{Amount: minor.Int64(), Currency: currency}, nil
+}
+
+// RoundRat rounds to the nearest integer, halves away from zero
+func RoundRat(value *big.Rat) *big.Int {
+	quotient, remainder := new(big.Int).QuoRem(value.Num(), value.Denom(), new(big.Int))
+	// |remainder| / denom >= 1/2
+	if new(big.Int).Mul(new(big.Int).Abs(remainder), big.NewInt(2)).Cmp(value.Denom()) >= 0 {
+		if value.Sign() < 0 {
+			quotient.Sub(quotient, big.NewInt(1))
+		} else {
+			quotient.Add(quotient, big.NewInt(1))
+		}
+	}
+	return quotient
+}
+
+// IsZero reports whether the amount is zero
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// IsPositive reports whether the amount is greater than zero
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+// Add returns the sum of two amounts of the same currency
+func (m Money) Add(other Money) Money {
+	m.mustMatch(other)
+	return Money{Amount: m.Amount + other.Amount, Currency: m.Currency}
+}
+
+// Sub returns the difference of two amounts of the same currency
+func (m Money) Sub(other Money) Money {
+	m.mustMatch(other)
+	return Money{Amount: m.Amount - other.Amount, Currency: m.Currency}
+}
+
+// Times returns the amount multiplied by a whole number
+func (m Money) Times(n int64) Money {
+	return Money{Amount: m.Amount * n, Currency: m.Currency}
+}
+
+// Min returns the smaller of two amounts of the same currency
+func (m Money) Min(other Money) Money {
+	m.mustMatch(other)
+	if other.Amount < m.Amount {
+		return other
+	}
+	return m
+}
+
+// mixing currencies is a programming error, amounts are converted before they are combined
+func (m Money) mustMatch(other Money) {
+	if m.Currency != other.Currency {
+		panic(fmt.Sprintf("money: %s and %s amounts cannot be combined", m.Currency, other.Currency))
+	}
+}
+
+// Decimal returns the amount in major units, e.g. "19.99"
+func (m Money) Decimal() string {
+	exponent := CurrencyExponent(m.Currency)
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	digits := strconv.FormatInt(amount, 10)
+	if exponent == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exponent] + "." + digits[len(digits)-exponent:]
+}
+
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+// UnmarshalJSON reads {"amount": 1999, "currency": "USD"}, a decimal string such as "19.99 USD",
+// or a plain number such as 19.99 in DefaultCurrency, which is how prices used to be sent
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	switch {
+	case bytes.Equal(data, []byte("null")):
+		return nil
+	case len(data) > 0 && data[0] == '{':
+		var value struct {
+			Amount   *int64 `json:"amount"`
+			Currency string `json:"currency"`
+		}
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		if value.Amount == nil {
+			return fmt.Errorf("money needs an amount in minor units")
+		}
+		currency := strings.ToUpper(value.Currency)
+		if currency == "" {
+			currency = DefaultCurrency
+		}
+		if !ValidCurrency(currency) {
+			return fmt.Errorf("unsupported currency %q", value.Currency)
+		}
+		*m = Money{Amount: *value.Amount, Currency: currency}
+		return nil
+	case len(data) > 0 && data[0] == '"':
+		var text string
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+		amount, currency, found := strings.Cut(strings.TrimSpace(text), " ")
+		if !found {
+			currency = DefaultCurrency
+		}
+		parsed, err := ParseMoney(amount, currency)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	default:
+		// older clients and stored snapshots sent floats, round them to the currency
+		parsed, err := parseMoney(string(data), DefaultCurrency, true)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	}
+}
+
+func pow10(exponent int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exponent)), nil)
+}
+
+// how amounts are written in a locale
+type moneyLocale struct {
+	group, decimal string
+	symbolAfter    bool // "19,99 €" rather than "€19.99"
+}
+
+var moneyLocales = map[string]moneyLocale{
+	"en-US": {group: ",", decimal: "."},
+	"en-GB": {group: ",", decimal: "."},
+	"en-IN": {group: ",", decimal: "."},
+	"bn-BD": {group: ",", decimal: "."},
+	"ja-JP": {group: ",", decimal: "."},
+	"de-DE": {group: ".", decimal: ",", symbolAfter: true},
+	"fr-FR": {group: "\u202f", decimal: ",", symbolAfter: true},
+	"es-ES": {group: ".", decimal: ",", symbolAfter: true},
+}
+
+// locale used for a bare language such as "de"
+var moneyLanguages = map[string]string{"en": "en-US", "bn": "bn-BD", "ja": "ja-JP", "de": "de-DE", "fr": "fr-FR", "es": "es-ES"}
+
+var currencySymbols = map[string]string{
+	"USD": "$", "EUR": "€", "GBP": "£", "BDT": "৳", "INR": "₹", "JPY": "¥", "KRW": "₩",
+}
+
+// Format writes the amount for display in a locale such as "de-DE" or "fr", unknown locales
+// fall back to en-US. Currencies without a symbol are written with their code.
+func (m Money) Format(locale string) string {
+	format, ok := moneyLocales[locale]
+	if !ok {
+		language, _, _ := strings.Cut(locale, "-")
+		format, ok = moneyLocales[moneyLanguages[strings.ToLower(language)]]
+	}
+	if !ok {
+		format = moneyLocales["en-US"]
+	}
+
+	decimal := m.Decimal()
+	sign := ""
+	if strings.HasPrefix(decimal, "-") {
+		sign, decimal = "-", decimal[1:]
+	}
+	whole, fraction, _ := strings.Cut(decimal, ".")
+	for i := len(whole) - 3; i > 0; i -= 3 {
+		whole = whole[:i] + format.group + whole[i:]
+	}
+	number := whole
+	if fraction != "" {
+		number += format.decimal + fraction
+	}
+
+	symbol, ok := currencySymbols[m.Currency]
+	switch {
+	case !ok:
+		return sign + number + " " + m.Currency
+	case format.symbolAfter:
+		return sign + number + "\u00a0" + symbol
+	default:
+		return sign + symbol + number
+	}
+}
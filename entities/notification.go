@@ -0,0 +1,58 @@
+package entities
+
+import "time"
+
+// notification kinds, each is an email template
+const (
+	NotificationWelcome    = "welcome"                 // sent to every new user
+	NotificationEnrollment = "enrollment"              // confirms an enrollment to the student
+	NotificationCompletion = "course_completed"        // congratulates a student who completed a course
+	NotificationReview     = "new_review"              // tells instructors a review of their course was published
+	NotificationInvitation = "organization_invitation" // invites a user to join an organization
+)
+
+// outbox email statuses
+const (
+	OutboxPending = "pending" // waiting to be sent, or to be retried after a failure
+	OutboxSent    = "sent"
+	OutboxFailed  = "failed" // gave up after too many attempts
+)
+
+//the notifications a user wants, users who never set them get all of them and welcome emails and invitations are always sent
+type NotificationPreferences struct {
+	UserID     uint       `json:"user_id"`
+	Enrollment bool       `json:"enrollment"`
+	Completion bool       `json:"course_completed"`
+	Reviews    bool       `json:"new_review"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+}
+
+// Wants reports whether the user wants notifications of a kind
+func (p NotificationPreferences) Wants(kind string) bool {
+	switch kind {
+	case NotificationEnrollment:
+		return p.Enrollment
+	case NotificationCompletion:
+		return p.Completion
+	case NotificationReview:
+		return p.Reviews
+	}
+	return true
+}
+
+//an email in the outbox, sent by the mailer in the background so that it survives a restart
+type OutboxEmail struct {
+	ID            uint       `json:"id"`
+	Kind          string     `json:"kind"`
+	UserID        uint       `json:"user_id"`
+	To            string     `json:"to"`
+	Subject       string     `json:"subject"`
+	Text          string     `json:"text"`
+	HTML          string     `json:"html,omitempty"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error,omitempty"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+}
@@ -0,0 +1,79 @@
+package entities
+
+import "time"
+
+// order statuses
+const (
+	OrderPending        = "pending"         // created, the payment has not been answered yet
+	OrderRequiresAction = "requires_action" // the payment waits for the customer, e.g. a 3DS challenge
+	OrderPaid           = "paid"
+	OrderFailed         = "failed"
+	OrderCancelled      = "cancelled"
+	OrderRefunded       = "refunded" // every course of the order has been refunded
+)
+
+//a course waiting in a user's cart, title and price are the course's current ones
+type CartItem struct {
+	CourseID uint      `json:"course_id"`
+	Title    string    `json:"title"`
+	Price    Money     `json:"price"` // in the currency of the cart
+	Discount Money     `json:"discount"`
+	AddedAt  time.Time `json:"added_at"`
+}
+
+//the courses a user is about to buy
+type Cart struct {
+	UserID   uint            `json:"user_id"`
+	Currency string          `json:"currency"`
+	Items    []CartItem      `json:"items"`
+	Coupons  []AppliedCoupon `json:"coupons"`
+	Subtotal Money           `json:"subtotal"`
+	Discount Money           `json:"discount"`
+	Total    Money           `json:"total"`
+	Display  *PriceDisplay   `json:"display,omitempty"`
+	Warnings []string        `json:"warnings,omitempty"` // coupons that no longer apply, checkout fails until they are removed
+}
+
+//a purchase of the courses in a cart, items keep the price paid at checkout
+type Order struct {
+	ID            uint            `json:"id"`
+	UserID        uint            `json:"user_id"`
+	Status        string          `json:"status"`
+	Currency      string          `json:"currency"`
+	Items         []OrderItem     `json:"items"`
+	Coupons       []AppliedCoupon `json:"coupons"`
+	Subtotal      Money           `json:"subtotal"`
+	Discount      Money           `json:"discount"`
+	Total         Money           `json:"total"` // charged, subtotal less discount
+	Refunded      Money           `json:"refunded"`
+	Display       *PriceDisplay   `json:"display,omitempty"`
+	PaymentMethod string          `json:"payment_method"`
+	PaymentID     string          `json:"payment_id,omitempty"` // the gateway's reference
+	ActionURL     string          `json:"action_url,omitempty"` // where the customer completes a payment that requires action
+	FailureReason string          `json:"failure_reason,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	PaidAt        *time.Time      `json:"paid_at,omitempty"`
+}
+
+//a course bought in an order, the enrollment is set once the order is paid
+type OrderItem struct {
+	ID           uint   `json:"id"`
+	OrderID      uint   `json:"order_id"`
+	CourseID     uint   `json:"course_id"`
+	Title        string `json:"title"`
+	Price        Money  `json:"price"`
+	Discount     Money  `json:"discount"`
+	EnrollmentID *uint  `json:"enrollment_id,omitempty"`
+}
+
+//the totals of a cart or order written for the caller's locale
+type PriceDisplay struct {
+	Subtotal string `json:"subtotal"`
+	Discount string `json:"discount"`
+	Total    string `json:"total"`
+}
+
+// Open reports whether the order is still waiting for its payment
+func (o Order) Open() bool {
+	return o.Status == OrderPending || o.Status == OrderRequiresAction
+}
@@ -0,0 +1,121 @@
+package entities
+
+import "time"
+
+// organization member roles
+const (
+	OrgAdmin  = "admin" // manages the members and seats of the organization
+	OrgMember = "member"
+)
+
+// organization invitation statuses
+const (
+	InvitationPending   = "pending"
+	InvitationAccepted  = "accepted"
+	InvitationDeclined  = "declined"
+	InvitationCancelled = "cancelled" // withdrawn by an organization admin
+)
+
+// bulk enrollment outcomes, one per member
+const (
+	BulkEnrolled        = "enrolled"         // a new enrollment holding a seat
+	BulkSeatAssigned    = "seat_assigned"    // an enrollment that had lost its access now holds a seat
+	BulkAlreadyEnrolled = "already_enrolled" // the member already has the course, no seat is used
+	BulkFailed          = "failed"
+)
+
+//a company buying seats in courses for its members
+type Organization struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name" binding:"required"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+//a user belonging to an organization
+type OrganizationMember struct {
+	OrganizationID uint      `json:"organization_id"`
+	UserID         uint      `json:"user_id" binding:"required"`
+	Role           string    `json:"role"` // admin or member
+	JoinedAt       time.Time `json:"joined_at"`
+	Name           string    `json:"name,omitempty"`  // read with the user, not stored
+	Email          string    `json:"email,omitempty"` // read with the user, not stored
+}
+
+//an invitation for a user to join an organization, the user becomes a member by accepting it
+type OrganizationInvitation struct {
+	ID             uint       `json:"id"`
+	OrganizationID uint       `json:"organization_id"`
+	UserID         uint       `json:"user_id" binding:"required"`
+	Role           string     `json:"role"` // the role the user gets by accepting, admin or member
+	Status         string     `json:"status"`
+	InvitedBy      uint       `json:"invited_by"`
+	CreatedAt      time.Time  `json:"created_at"`
+	RespondedAt    *time.Time `json:"responded_at,omitempty"`
+}
+
+//the seats an organization has bought in a course, a seat enrolls one member at a time
+type SeatPool struct {
+	ID             uint      `json:"id"`
+	OrganizationID uint      `json:"organization_id"`
+	CourseID       uint      `json:"course_id"`
+	Seats          int       `json:"seats"`     // bought in total
+	Used           int       `json:"used"`      // held by enrollments that are not revoked, not stored
+	Available      int       `json:"available"` // not stored
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+//seats bought in one payment
+type SeatPurchase struct {
+	ID             uint      `json:"id"`
+	OrganizationID uint      `json:"organization_id"`
+	PoolID         uint      `json:"pool_id"`
+	CourseID       uint      `json:"course_id"`
+	Seats          int       `json:"seats"`
+	UnitPrice      Money     `json:"unit_price"`
+	Total          Money     `json:"total"`
+	PaymentID      string    `json:"payment_id"` // the gateway's reference
+	PurchasedBy    uint      `json:"purchased_by"`
+	PurchasedAt    time.Time `json:"purchased_at"`
+}
+
+//the outcome of enrolling one member in a bulk enrollment
+type BulkEnrollmentResult struct {
+	UserID       uint   `json:"user_id"`
+	Status       string `json:"status"`
+	EnrollmentID *uint  `json:"enrollment_id,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+//a member's progress in a course of the organization
+type MemberProgress struct {
+	UserID          uint       `json:"user_id"`
+	Name            string     `json:"name"`
+	Email           string     `json:"email"`
+	EnrollmentID    *uint      `json:"enrollment_id,omitempty"` // nil when the member is not enrolled
+	HoldsSeat       bool       `json:"holds_seat"`              // enrolled with one of the organization's seats
+	PercentComplete float64    `json:"percent_complete"`
+	Completed       bool       `json:"completed"`
+	CompletedAt     *time.Time `json:"completed_at,omitempty"`
+	LastActivityAt  *time.Time `json:"last_activity_at,omitempty"`
+}
+
+//the progress of an organization's members in a course it bought seats in
+type CourseProgressReport struct {
+	CourseID       uint             `json:"course_id"`
+	Title          string           `json:"title"`
+	Seats          int              `json:"seats"`
+	Used           int              `json:"used"`
+	Enrolled       int              `json:"enrolled"`        // members enrolled, with a seat or not
+	Completed      int              `json:"completed"`       // members who completed the course
+	AveragePercent float64          `json:"average_percent"` // over the enrolled members
+	Members        []MemberProgress `json:"members"`
+}
+
+//the progress of an organization's members in the courses it bought seats in
+type OrganizationProgress struct {
+	OrganizationID uint                   `json:"organization_id"`
+	Name           string                 `json:"name"`
+	Members        int                    `json:"members"`
+	Courses        []CourseProgressReport `json:"courses"`
+	GeneratedAt    time.Time              `json:"generated_at"`
+}
@@ -0,0 +1,38 @@
+package entities
+
+//a course that has to, or should, be completed before enrolling into another one
+type Prerequisite struct {
+	CourseID       uint   `json:"course_id"`
+	PrerequisiteID uint   `json:"prerequisite_id"`
+	Required       bool   `json:"required"` // required ones block enrollment, the others only warn
+	Title          string `json:"title"`    // title of the prerequisite course
+}
+
+//ordered bundle of courses
+type LearningPath struct {
+	ID          uint   `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	CourseIDs   []uint `json:"course_ids"` // in the order they should be taken
+}
+
+//state of one course of a learning path for a user
+type PathCourseProgress struct {
+	CourseID  uint   `json:"course_id"`
+	Title     string `json:"title"`
+	Position  uint   `json:"position"`
+	Enrolled  bool   `json:"enrolled"`
+	Completed bool   `json:"completed"`
+}
+
+//progress of a user across a learning path
+type LearningPathProgress struct {
+	PathID           uint                 `json:"path_id"`
+	UserID           uint                 `json:"user_id"`
+	CompletedCourses int                  `json:"completed_courses"`
+	TotalCourses     int                  `json:"total_courses"`
+	PercentComplete  float64              `json:"percent_complete"`
+	Completed        bool                 `json:"completed"`
+	NextCourse       *PathCourseProgress  `json:"next_course"` // first course not completed yet
+	Courses          []PathCourseProgress `json:"courses"`
+}
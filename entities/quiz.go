@@ -0,0 +1,88 @@
+package entities
+
+import "time"
+
+// question types
+const (
+	QuestionSingleChoice   = "single_choice"
+	QuestionMultipleChoice = "multiple_choice"
+	QuestionTrueFalse      = "true_false"
+	QuestionNumeric        = "numeric"
+	QuestionShortAnswer    = "short_answer"
+)
+
+//a named set of questions of a course that quizzes draw from
+type QuestionBank struct {
+	ID       uint   `json:"id"`
+	CourseID uint   `json:"course_id"`
+	Title    string `json:"title"`
+}
+
+//a question with its answer key, only the course instructor and admins see the key
+type Question struct {
+	ID        uint       `json:"id"`
+	BankID    uint       `json:"bank_id"`
+	Type      string     `json:"type"`
+	Prompt    string     `json:"prompt"`
+	Choices   []string   `json:"choices,omitempty"` // single_choice and multiple_choice
+	Points    float64    `json:"points"`            // defaults to 1
+	Answer    AnswerKey  `json:"answer"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+//the correct answer of a question, the field used depends on the question type
+type AnswerKey struct {
+	Choices   []int    `json:"choices,omitempty"`   // indexes into Question.Choices
+	Bool      *bool    `json:"bool,omitempty"`      // true_false
+	Number    *float64 `json:"number,omitempty"`    // numeric
+	Tolerance float64  `json:"tolerance,omitempty"` // numeric, accepted distance from Number
+	Texts     []string `json:"texts,omitempty"`     // short_answer, compared case-insensitively
+}
+
+//a question as shown to students, without the answer key
+type QuizQuestion struct {
+	ID      uint     `json:"id"`
+	Type    string   `json:"type"`
+	Prompt  string   `json:"prompt"`
+	Choices []string `json:"choices,omitempty"`
+	Points  float64  `json:"points"`
+}
+
+//a quiz attached to a lesson, students must pass it to complete the lesson
+type Quiz struct {
+	ID               uint    `json:"id"`
+	LessonID         uint    `json:"lesson_id"`
+	BankID           uint    `json:"bank_id"`
+	Title            string  `json:"title"`
+	QuestionCount    uint    `json:"question_count"`     // questions drawn at random per attempt, 0 uses the whole bank
+	Shuffle          bool    `json:"shuffle"`            // randomize the question order of each attempt
+	TimeLimitSeconds uint    `json:"time_limit_seconds"` // 0 for no limit
+	MaxAttempts      uint    `json:"max_attempts"`       // 0 for unlimited
+	PassingScore     float64 `json:"passing_score"`      // percent needed to pass
+}
+
+//an answer given in an attempt, the field used depends on the question type
+type AttemptAnswer struct {
+	QuestionID uint     `json:"question_id"`
+	Choices    []int    `json:"choices,omitempty"`
+	Bool       *bool    `json:"bool,omitempty"`
+	Number     *float64 `json:"number,omitempty"`
+	Text       string   `json:"text,omitempty"`
+	Correct    bool     `json:"correct"` // set when the attempt is graded
+	Points     float64  `json:"points"`  // points earned
+}
+
+//one try of a student at a quiz
+type QuizAttempt struct {
+	ID           uint            `json:"id"`
+	QuizID       uint            `json:"quiz_id"`
+	EnrollmentID uint            `json:"enrollment_id"`
+	QuestionIDs  []uint          `json:"-"`         // in the order they were drawn
+	Questions    []QuizQuestion  `json:"questions"` // filled from QuestionIDs
+	Answers      []AttemptAnswer `json:"answers"`
+	StartedAt    time.Time       `json:"started_at"`
+	DeadlineAt   *time.Time      `json:"deadline_at,omitempty"`
+	SubmittedAt  *time.Time      `json:"submitted_at,omitempty"`
+	Score        float64         `json:"score"` // percent
+	Passed       bool            `json:"passed"`
+}
@@ -0,0 +1,35 @@
+package entities
+
+import "time"
+
+// refund statuses
+const (
+	RefundRequested  = "requested"  // waiting for an admin
+	RefundProcessing = "processing" // approved, the gateway is refunding the payment
+	RefundRefunded   = "refunded"
+	RefundRejected   = "rejected"
+)
+
+//a request to refund a course bought in an order, a refund revokes the enrollment it paid for
+type Refund struct {
+	ID              uint       `json:"id"`
+	OrderID         uint       `json:"order_id"`
+	OrderItemID     uint       `json:"order_item_id"`
+	CourseID        uint       `json:"course_id"`
+	UserID          uint       `json:"user_id"` // the buyer
+	Status          string     `json:"status"`
+	Amount          Money      `json:"amount"`                // asked for, the paid price of the course until an admin approves less
+	Reason          string     `json:"reason"`                // given by the buyer
+	Progress        float64    `json:"progress"`              // percent of the course completed when it was requested
+	PolicyNote      string     `json:"policy_note,omitempty"` // why the request falls outside the refund policy, only admins can file those
+	ReviewerID      *uint      `json:"reviewer_id,omitempty"`
+	ReviewNote      string     `json:"review_note,omitempty"`
+	GatewayRefundID string     `json:"gateway_refund_id,omitempty"`
+	RequestedAt     time.Time  `json:"requested_at"`
+	ReviewedAt      *time.Time `json:"reviewed_at,omitempty"`
+}
+
+// Open reports whether the refund has not been decided yet
+func (r Refund) Open() bool {
+	return r.Status == RefundRequested || r.Status == RefundProcessing
+}
@@ -0,0 +1,17 @@
+package entities
+
+import "time"
+
+//a signed in session of a user, the token is only returned when signing in
+type Session struct {
+	Token     string    `json:"token,omitempty"`
+	UserID    uint      `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+//the credentials a user signs in with
+type Credentials struct {
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
@@ -0,0 +1,104 @@
+package entities
+
+import (
+	"strings"
+	"time"
+)
+
+// subscription plan billing intervals
+const (
+	IntervalMonth = "month"
+	IntervalYear  = "year"
+)
+
+// subscription statuses
+const (
+	SubscriptionActive    = "active"
+	SubscriptionPastDue   = "past_due"  // the renewal failed, access continues until the grace period ends
+	SubscriptionCancelled = "cancelled" // ended at the end of the period it was cancelled in
+	SubscriptionExpired   = "expired"   // the grace period ended without a successful renewal
+)
+
+// subscription payment statuses
+const (
+	SubscriptionPaymentSucceeded = "succeeded"
+	SubscriptionPaymentFailed    = "failed"
+)
+
+//an all-access plan, subscribers can enroll in every course of its categories
+type SubscriptionPlan struct {
+	ID          uint      `json:"id"`
+	Name        string    `json:"name" binding:"required"`
+	Description string    `json:"description"`
+	Interval    string    `json:"interval" binding:"required"` // month or year
+	Price       Money     `json:"price" binding:"required"`    // charged every interval
+	Categories  []string  `json:"categories" binding:"required"`
+	Active      bool      `json:"active"` // inactive plans keep their subscribers but take no new ones
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+//a user's subscription to a plan, renewed at the end of every period
+type Subscription struct {
+	ID                 uint       `json:"id"`
+	UserID             uint       `json:"user_id"`
+	PlanID             uint       `json:"plan_id"`
+	Status             string     `json:"status"`
+	PaymentMethod      string     `json:"payment_method"`
+	CurrentPeriodStart time.Time  `json:"current_period_start"`
+	CurrentPeriodEnd   time.Time  `json:"current_period_end"` // when it is renewed next
+	CancelAtPeriodEnd  bool       `json:"cancel_at_period_end"`
+	CancelledAt        *time.Time `json:"cancelled_at,omitempty"`    // when the cancellation was asked for
+	GraceUntil         *time.Time `json:"grace_until,omitempty"`     // set while past due, renewal is retried until then
+	NextAttemptAt      *time.Time `json:"next_attempt_at,omitempty"` // when a past due renewal is retried
+	EndedAt            *time.Time `json:"ended_at,omitempty"`
+	FailureReason      string     `json:"failure_reason,omitempty"` // of the last failed renewal
+	CreatedAt          time.Time  `json:"created_at"`
+}
+
+//a charge for one period of a subscription
+type SubscriptionPayment struct {
+	ID             uint      `json:"id"`
+	SubscriptionID uint      `json:"subscription_id"`
+	Amount         Money     `json:"amount"`
+	Status         string    `json:"status"`
+	PaymentID      string    `json:"payment_id,omitempty"` // the gateway's reference
+	FailureReason  string    `json:"failure_reason,omitempty"`
+	PeriodStart    time.Time `json:"period_start"`
+	PeriodEnd      time.Time `json:"period_end"`
+	AttemptedAt    time.Time `json:"attempted_at"`
+}
+
+// GrantsAccess reports whether the subscription gives access to its plan's courses at a time.
+// Active subscriptions do until they are renewed or end, past due ones until the grace period ends.
+func (s Subscription) GrantsAccess(now time.Time) bool {
+	switch s.Status {
+	case SubscriptionActive:
+		return true
+	case SubscriptionPastDue:
+		return s.GraceUntil != nil && now.Before(*s.GraceUntil)
+	}
+	return false
+}
+
+// Ended reports whether the subscription is over and can no longer be renewed
+func (s Subscription) Ended() bool {
+	return s.Status == SubscriptionCancelled || s.Status == SubscriptionExpired
+}
+
+// Covers reports whether the plan gives access to a course, categories match case-insensitively
+func (p SubscriptionPlan) Covers(course Course) bool {
+	for _, category := range p.Categories {
+		if strings.EqualFold(strings.TrimSpace(category), strings.TrimSpace(course.Category)) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextPeriodEnd returns when a period of the plan starting at start ends
+func (p SubscriptionPlan) NextPeriodEnd(start time.Time) time.Time {
+	if p.Interval == IntervalYear {
+		return start.AddDate(1, 0, 0)
+	}
+	return start.AddDate(0, 1, 0)
+}
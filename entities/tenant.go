@@ -0,0 +1,12 @@
+package entities
+
+// DefaultTenantID is the tenant of requests that name none, it keeps the data of the single
+// school the deployment hosted before tenants existed
+const DefaultTenantID = "default"
+
+//a school hosted on the deployment, its data is kept apart from every other school's
+type Tenant struct {
+	ID   string `json:"id"`   // the subdomain and X-Tenant-ID header value that select it
+	Name string `json:"name"` // printed as the seller on invoices and the sender of emails
+	URL  string `json:"url"`  // where its site is reached, for links on certificates
+}
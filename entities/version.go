@@ -0,0 +1,34 @@
+package entities
+
+import (
+	"encoding/json"
+	"time"
+)
+
+//immutable snapshot of a course and its lessons, taken every time the course is published
+type CourseVersion struct {
+	ID        uint      `json:"id"`
+	CourseID  uint      `json:"course_id"`
+	Version   uint      `json:"version"` // 1, 2, ... per course
+	Course    Course    `json:"course"`
+	Sections  []Section `json:"sections"`
+	Lessons   []Lesson  `json:"lessons"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+//differences between two versions of a course
+type CourseVersionDiff struct {
+	CourseID       uint            `json:"course_id"`
+	From           uint            `json:"from"`
+	To             uint            `json:"to"`
+	Course         json.RawMessage `json:"course,omitempty"` // field -> {before, after}
+	LessonsAdded   []Lesson        `json:"lessons_added"`
+	LessonsRemoved []Lesson        `json:"lessons_removed"`
+	LessonsChanged []LessonChange  `json:"lessons_changed"`
+}
+
+//changed fields of a lesson present in both versions
+type LessonChange struct {
+	LessonID uint            `json:"lesson_id"`
+	Changes  json.RawMessage `json:"changes"` // field -> {before, after}
+}
@@ -0,0 +1,61 @@
+package certificate
+
+import (
+	"bytes"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+	"github.com/NaheedRayan/mini_rest_api_shikho/infrastructure/pdf"
+)
+
+// landscape A4 in points
+const (
+	pageWidth  = 842.0
+	pageHeight = 595.0
+	maxWidth   = 720.0 // text is shrunk to fit within this width
+)
+
+// PDFRenderer draws certificates as single page PDFs using the standard Helvetica fonts,
+// so no font files need to be embedded
+type PDFRenderer struct {
+	// VerifyURL is printed below the verification code, the code is appended to it
+	VerifyURL string
+}
+
+// NewPDFRenderer returns a renderer printing verifyURL + code as the verification link
+func NewPDFRenderer(verifyURL string) *PDFRenderer {
+	return &PDFRenderer{VerifyURL: verifyURL}
+}
+
+// Render implements usecases.CertificateRenderer
+func (r *PDFRenderer) Render(certificate entities.Certificate) ([]byte, error) {
+	var content bytes.Buffer
+	// double border
+	content.WriteString("0.15 0.25 0.45 RG 3 w 24 24 794 547 re S 1 w 34 34 774 527 re S\n")
+	content.WriteString("0.1 0.1 0.1 rg\n")
+
+	centered(&content, pdf.Bold, 36, 460, "Certificate of Completion")
+	centered(&content, pdf.Regular, 14, 405, "This certifies that")
+	centered(&content, pdf.Bold, 30, 360, certificate.StudentName)
+	centered(&content, pdf.Regular, 14, 320, "has successfully completed the course")
+	centered(&content, pdf.Bold, 24, 280, certificate.CourseTitle)
+	if certificate.Instructor != "" {
+		centered(&content, pdf.Regular, 14, 245, "taught by "+certificate.Instructor)
+	}
+	centered(&content, pdf.Regular, 12, 185, "Completed on "+certificate.CompletedAt.Format("January 2, 2006"))
+	centered(&content, pdf.Regular, 10, 80, "Verification code: "+certificate.Code)
+	if r.VerifyURL != "" {
+		centered(&content, pdf.Regular, 10, 64, r.VerifyURL+certificate.Code)
+	}
+
+	return pdf.Build(content.Bytes(), pageWidth, pageHeight, "Certificate "+certificate.Code), nil
+}
+
+// centered writes a line of text centered on the page, shrinking the font size until it fits
+func centered(content *bytes.Buffer, font *pdf.Font, size, y float64, text string) {
+	width := font.Width(text, size)
+	for width > maxWidth && size > 8 {
+		size--
+		width = font.Width(text, size)
+	}
+	pdf.Text(content, font, size, (pageWidth-width)/2, y, text)
+}
@@ -0,0 +1,32 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// SimulatedClock runs with the real time shifted by an offset that can only grow, so billing
+// renewals, grace periods and expiries can be tried without waiting for them
+type SimulatedClock struct {
+	mu     sync.Mutex
+	offset time.Duration
+}
+
+// NewSimulatedClock returns a clock showing the real time until it is advanced
+func NewSimulatedClock() *SimulatedClock {
+	return &SimulatedClock{}
+}
+
+// Now implements usecases.Clock
+func (c *SimulatedClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Now().Add(c.offset)
+}
+
+// Advance implements usecases.AdjustableClock
+func (c *SimulatedClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.offset += d
+}
@@ -0,0 +1,97 @@
+package currency
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+// RateTable converts money with fixed exchange rates read from a JSON file:
+//
+//	{"base": "USD", "rates": {"EUR": "0.92", "BDT": "119.50"}}
+//
+// A rate is how much of the currency one unit of the base buys. Rates are decimal strings so
+// they are read exactly, and conversions between two quoted currencies go through the base.
+type RateTable struct {
+	base  string
+	rates map[string]*big.Rat
+}
+
+// LoadRateTable reads a rate table from a file
+func LoadRateTable(path string) (*RateTable, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Base  string            `json:"base"`
+		Rates map[string]string `json:"rates"`
+	}
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if !entities.ValidCurrency(file.Base) {
+		return nil, fmt.Errorf("%s: unsupported base currency %q", path, file.Base)
+	}
+
+	table := &RateTable{base: file.Base, rates: map[string]*big.Rat{file.Base: big.NewRat(1, 1)}}
+	for currency, value := range file.Rates {
+		if !entities.ValidCurrency(currency) {
+			return nil, fmt.Errorf("%s: unsupported currency %q", path, currency)
+		}
+		rate, ok := new(big.Rat).SetString(value)
+		if !ok || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("%s: invalid rate %q for %s", path, value, currency)
+		}
+		table.rates[currency] = rate
+	}
+	return table, nil
+}
+
+// Convert implements usecases.ExchangeRates, the result is rounded half away from zero
+// to the minor unit of the target currency
+func (t *RateTable) Convert(amount entities.Money, currency string) (entities.Money, error) {
+	if amount.Currency == currency {
+		return amount, nil
+	}
+	from, ok := t.rates[amount.Currency]
+	if !ok {
+		return entities.Money{}, fmt.Errorf("no exchange rate for %s", amount.Currency)
+	}
+	to, ok := t.rates[currency]
+	if !ok {
+		return entities.Money{}, fmt.Errorf("no exchange rate for %s", currency)
+	}
+
+	// minor units -> major units -> base -> target -> target minor units
+	value := new(big.Rat).SetInt64(amount.Amount)
+	value.Quo(value, new(big.Rat).SetInt(pow10(entities.CurrencyExponent(amount.Currency))))
+	value.Quo(value, from)
+	value.Mul(value, to)
+	value.Mul(value, new(big.Rat).SetInt(pow10(entities.CurrencyExponent(currency))))
+	minor := entities.RoundRat(value)
+	if !minor.IsInt64() {
+		return entities.Money{}, fmt.Errorf("%s is too large to convert", amount)
+	}
+	return entities.NewMoney(minor.Int64(), currency), nil
+}
+
+// Currencies implements usecases.ExchangeRates, the base comes first and the rest in code order
+func (t *RateTable) Currencies() []string {
+	currencies := make([]string, 0, len(t.rates))
+	for currency := range t.rates {
+		if currency != t.base {
+			currencies = append(currencies, currency)
+		}
+	}
+	sort.Strings(currencies)
+	return append([]string{t.base}, currencies...)
+}
+
+func pow10(exponent int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exponent)), nil)
+}
@@ -0,0 +1,275 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+//----------------------------------------------------------------assignments----------------------------------------------------------------
+
+const assignmentColumns = "id, course_id, lesson_id, title, instructions, due_at, rubric_json, max_points, passing_score, required, allow_resubmit, max_submissions, accept_late, late_penalty_percent"
+
+func scanAssignment(row scanner) (entities.Assignment, error) {
+	assignment := entities.Assignment{Rubric: []entities.RubricCriterion{}}
+	var lessonID sql.NullInt64
+	var dueAt sql.NullTime
+	var rubric sql.NullString
+	err := row.Scan(&assignment.ID, &assignment.CourseID, &lessonID, &assignment.Title, &assignment.Instructions, &dueAt, &rubric,
+		&assignment.MaxPoints, &assignment.PassingScore, &assignment.Required, &assignment.AllowResubmit, &assignment.MaxSubmissions,
+		&assignment.AcceptLate, &assignment.LatePenaltyPercent)
+	if err != nil {
+		return entities.Assignment{}, err
+	}
+	if rubric.Valid {
+		if err := json.Unmarshal([]byte(rubric.String), &assignment.Rubric); err != nil {
+			return entities.Assignment{}, err
+		}
+	}
+	assignment.LessonID = uintPtr(lessonID)
+	assignment.DueAt = timePtr(dueAt)
+	return assignment, nil
+}
+
+// Create an assignment
+func (r *CourseRepository) AddAssignment(assignment entities.Assignment) (entities.Assignment, error) {
+	rubric, err := json.Marshal(assignment.Rubric)
+	if err != nil {
+		return entities.Assignment{}, err
+	}
+	query := `INSERT INTO assignments (course_id, lesson_id, title, instructions, due_at, rubric_json, max_points, passing_score,
+		required, allow_resubmit, max_submissions, accept_late, late_penalty_percent) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := r.DB.Exec(query, assignment.CourseID, assignment.LessonID, assignment.Title, assignment.Instructions, assignment.DueAt,
+		string(rubric), assignment.MaxPoints, assignment.PassingScore, assignment.Required, assignment.AllowResubmit,
+		assignment.MaxSubmissions, assignment.AcceptLate, assignment.LatePenaltyPercent)
+	if err != nil {
+		return entities.Assignment{}, err
+	}
+	id, _ := result.LastInsertId()
+	return r.GetAssignmentByID(int(id))
+}
+
+// Get an assignment by ID
+func (r *CourseRepository) GetAssignmentByID(id int) (entities.Assignment, error) {
+	return scanAssignment(r.DB.QueryRow("SELECT "+assignmentColumns+" FROM assignments WHERE id = ?", id))
+}
+
+// Get the assignments of a course, by due date with undated ones last
+func (r *CourseRepository) GetAssignmentsByCourseID(courseID int) ([]entities.Assignment, error) {
+	query := "SELECT " + assignmentColumns + " FROM assignments WHERE course_id = ? ORDER BY due_at IS NULL, due_at, id"
+	rows, err := r.DB.Query(query, courseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var assignments []entities.Assignment
+	for rows.Next() {
+		assignment, err := scanAssignment(rows)
+		if err != nil {
+			return nil, err
+		}
+		assignments = append(assignments, assignment)
+	}
+	return assignments, rows.Err()
+}
+
+// Update an assignment, it stays in its course
+func (r *CourseRepository) UpdateAssignment(assignment entities.Assignment) (entities.Assignment, error) {
+	rubric, err := json.Marshal(assignment.Rubric)
+	if err != nil {
+		return entities.Assignment{}, err
+	}
+	query := `UPDATE assignments SET lesson_id = ?, title = ?, instructions = ?, due_at = ?, rubric_json = ?, max_points = ?, passing_score = ?,
+		required = ?, allow_resubmit = ?, max_submissions = ?, accept_late = ?, late_penalty_percent = ? WHERE id = ?`
+	result, err := r.DB.Exec(query, assignment.LessonID, assignment.Title, assignment.Instructions, assignment.DueAt, string(rubric),
+		assignment.MaxPoints, assignment.PassingScore, assignment.Required, assignment.AllowResubmit, assignment.MaxSubmissions,
+		assignment.AcceptLate, assignment.LatePenaltyPercent, assignment.ID)
+	if err != nil {
+		return entities.Assignment{}, err
+	}
+	if err := requireAffected(result); err != nil {
+		return entities.Assignment{}, err
+	}
+	return r.GetAssignmentByID(int(assignment.ID))
+}
+
+// Delete an assignment
+func (r *CourseRepository) DeleteAssignment(id int) error {
+	result, err := r.DB.Exec("DELETE FROM assignments WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+//----------------------------------------------------------------submissions----------------------------------------------------------------
+
+const submissionColumns = "id, assignment_id, enrollment_id, number, text, submitted_at, days_late, status, rubric_scores_json, points, penalty_percent, score, passed, feedback, graded_by, graded_at"
+
+func scanSubmission(row scanner) (entities.Submission, error) {
+	submission := entities.Submission{Files: []entities.SubmissionFile{}}
+	var rubricScores sql.NullString
+	var points, score sql.NullFloat64
+	var gradedBy sql.NullInt64
+	var gradedAt sql.NullTime
+	err := row.Scan(&submission.ID, &submission.AssignmentID, &submission.EnrollmentID, &submission.Number, &submission.Text,
+		&submission.SubmittedAt, &submission.DaysLate, &submission.Status, &rubricScores, &points, &submission.PenaltyPercent,
+		&score, &submission.Passed, &submission.Feedback, &gradedBy, &gradedAt)
+	if err != nil {
+		return entities.Submission{}, err
+	}
+	if rubricScores.Valid {
+		if err := json.Unmarshal([]byte(rubricScores.String), &submission.RubricScores); err != nil {
+			return entities.Submission{}, err
+		}
+	}
+	if points.Valid {
+		submission.Points = &points.Float64
+	}
+	if score.Valid {
+		submission.Score = &score.Float64
+	}
+	submission.GradedBy = uintPtr(gradedBy)
+	submission.GradedAt = timePtr(gradedAt)
+	return submission, nil
+}
+
+// submissionAllowed is true when a submission numbered after the enrollment's previous ones is
+// allowed by the assignment, its arguments are the number, the enrollment ID and the number twice
+const submissionAllowed = "? = (SELECT COUNT(*) FROM submissions WHERE assignment_id = a.id AND enrollment_id = ?) + 1 " +
+	"AND (a.allow_resubmit OR ? = 1) AND (a.max_submissions = 0 OR ? <= a.max_submissions)"
+
+// Store a submission with its files. Numbers are unique per assignment and enrollment. Checking
+// the assignment allows another submission and inserting it happen in the same statement,
+// sql.ErrNoRows when it does not or a concurrent submission took the number.
+func (r *CourseRepository) AddSubmission(submission entities.Submission) (entities.Submission, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return entities.Submission{}, err
+	}
+	defer tx.Rollback()
+
+	query := "INSERT INTO submissions (assignment_id, enrollment_id, number, text, submitted_at, days_late, status) " +
+		"SELECT a.id, ?, ?, ?, ?, ?, ? FROM assignments a WHERE a.id = ? AND " + submissionAllowed
+	result, err := tx.Exec(query, submission.EnrollmentID, submission.Number, submission.Text, submission.SubmittedAt,
+		submission.DaysLate, submission.Status, submission.AssignmentID,
+		submission.Number, submission.EnrollmentID, submission.Number, submission.Number)
+	if err != nil {
+		return entities.Submission{}, err
+	}
+	if err := requireAffected(result); err != nil {
+		return entities.Submission{}, err
+	}
+	id, _ := result.LastInsertId()
+	for _, file := range submission.Files {
+		query := "INSERT INTO submission_files (submission_id, name, content_type, size, storage_key) VALUES (?, ?, ?, ?, ?)"
+		if _, err := tx.Exec(query, id, file.Name, file.ContentType, file.Size, file.StorageKey); err != nil {
+			return entities.Submission{}, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return entities.Submission{}, err
+	}
+	return r.GetSubmissionByID(int(id))
+}
+
+// Get a submission by ID with its files
+func (r *CourseRepository) GetSubmissionByID(id int) (entities.Submission, error) {
+	submissions, err := r.querySubmissions("id = ?", id)
+	if err != nil {
+		return entities.Submission{}, err
+	}
+	if len(submissions) == 0 {
+		return entities.Submission{}, sql.ErrNoRows
+	}
+	return submissions[0], nil
+}
+
+// Get the submissions to an assignment, of one enrollment when enrollmentID is not 0, oldest first
+func (r *CourseRepository) GetSubmissions(assignmentID, enrollmentID int) ([]entities.Submission, error) {
+	if enrollmentID != 0 {
+		return r.querySubmissions("assignment_id = ? AND enrollment_id = ?", assignmentID, enrollmentID)
+	}
+	return r.querySubmissions("assignment_id = ?", assignmentID)
+}
+
+// Get every submission of an enrollment, oldest first
+func (r *CourseRepository) GetSubmissionsByEnrollment(enrollmentID int) ([]entities.Submission, error) {
+	return r.querySubmissions("enrollment_id = ?", enrollmentID)
+}
+
+func (r *CourseRepository) querySubmissions(where string, args ...interface{}) ([]entities.Submission, error) {
+	rows, err := r.DB.Query("SELECT "+submissionColumns+" FROM submissions WHERE "+where+" ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+	var submissions []entities.Submission
+	byID := map[uint]int{}
+	for rows.Next() {
+		submission, err := scanSubmission(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		byID[submission.ID] = len(submissions)
+		submissions = append(submissions, submission)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(submissions) == 0 {
+		return submissions, err
+	}
+
+	ids := make([]interface{}, 0, len(submissions))
+	for _, submission := range submissions {
+		ids = append(ids, submission.ID)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	fileRows, err := r.DB.Query("SELECT "+submissionFileColumns+" FROM submission_files WHERE submission_id IN ("+placeholders+") ORDER BY id", ids...)
+	if err != nil {
+		return nil, err
+	}
+	defer fileRows.Close()
+	for fileRows.Next() {
+		file, err := scanSubmissionFile(fileRows)
+		if err != nil {
+			return nil, err
+		}
+		submission := &submissions[byID[file.SubmissionID]]
+		submission.Files = append(submission.Files, file)
+	}
+	return submissions, fileRows.Err()
+}
+
+// Store the grade of a submission
+func (r *CourseRepository) GradeSubmission(submission entities.Submission) error {
+	rubricScores, err := json.Marshal(submission.RubricScores)
+	if err != nil {
+		return err
+	}
+	query := `UPDATE submissions SET status = ?, rubric_scores_json = ?, points = ?, penalty_percent = ?, score = ?, passed = ?,
+		feedback = ?, graded_by = ?, graded_at = ? WHERE id = ?`
+	result, err := r.DB.Exec(query, submission.Status, string(rubricScores), submission.Points, submission.PenaltyPercent,
+		submission.Score, submission.Passed, submission.Feedback, submission.GradedBy, submission.GradedAt, submission.ID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+//----------------------------------------------------------------submission files----------------------------------------------------------------
+
+const submissionFileColumns = "id, submission_id, name, content_type, size, storage_key"
+
+func scanSubmissionFile(row scanner) (entities.SubmissionFile, error) {
+	var file entities.SubmissionFile
+	err := row.Scan(&file.ID, &file.SubmissionID, &file.Name, &file.ContentType, &file.Size, &file.StorageKey)
+	return file, err
+}
+
+// Get a submission file by ID
+func (r *CourseRepository) GetSubmissionFile(id int) (entities.SubmissionFile, error) {
+	return scanSubmissionFile(r.DB.QueryRow("SELECT "+submissionFileColumns+" FROM submission_files WHERE id = ?", id))
+}
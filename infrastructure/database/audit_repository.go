@@ -0,0 +1,93 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+//----------------------------------------------------------------audit----------------------------------------------------------------
+
+// Append an entry to the audit log
+func (r *CourseRepository) AddAuditLog(entry entities.AuditLog) (entities.AuditLog, error) {
+	query := "INSERT INTO audit_logs (actor_id, action, entity_type, entity_id, before_json, after_json, changes_json, request_id, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	result, err := r.DB.Exec(query, entry.ActorID, entry.Action, entry.EntityType, entry.EntityID,
+		nullJSON(entry.Before), nullJSON(entry.After), nullJSON(entry.Changes), entry.RequestID, entry.CreatedAt.UTC())
+	if err != nil {
+		return entities.AuditLog{}, err
+	}
+	id, _ := result.LastInsertId()
+	entry.ID = uint(id)
+	return entry, nil
+}
+
+// Get audit log entries matching the filter, newest first
+func (r *CourseRepository) GetAuditLogs(filter entities.AuditFilter) ([]entities.AuditLog, error) {
+	query := "SELECT id, actor_id, action, entity_type, entity_id, before_json, after_json, changes_json, request_id, created_at FROM audit_logs WHERE 1 = 1"
+	var args []interface{}
+	if filter.EntityType != "" {
+		query += " AND entity_type = ?"
+		args = append(args, filter.EntityType)
+	}
+	if filter.EntityID != 0 {
+		query += " AND entity_id = ?"
+		args = append(args, filter.EntityID)
+	}
+	if filter.ActorID != 0 {
+		query += " AND actor_id = ?"
+		args = append(args, filter.ActorID)
+	}
+	if !filter.From.IsZero() {
+		query += " AND created_at >= ?"
+		args = append(args, filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		query += " AND created_at <= ?"
+		args = append(args, filter.To.UTC())
+	}
+	query += " ORDER BY id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var logs []entities.AuditLog
+	for rows.Next() {
+		var entry entities.AuditLog
+		var before, after, changes, requestID sql.NullString
+		var createdAt time.Time
+		err := rows.Scan(&entry.ID, &entry.ActorID, &entry.Action, &entry.EntityType, &entry.EntityID, &before, &after, &changes, &requestID, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+		entry.Before = rawJSON(before)
+		entry.After = rawJSON(after)
+		entry.Changes = rawJSON(changes)
+		entry.RequestID = requestID.String
+		entry.CreatedAt = createdAt
+		logs = append(logs, entry)
+	}
+	return logs, rows.Err()
+}
+
+func nullJSON(raw json.RawMessage) sql.NullString {
+	if len(raw) == 0 {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: string(raw), Valid: true}
+}
+
+func rawJSON(s sql.NullString) json.RawMessage {
+	if !s.Valid {
+		return nil
+	}
+	return json.RawMessage(s.String)
+}
@@ -0,0 +1,63 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+//----------------------------------------------------------------certificates----------------------------------------------------------------
+
+const certificateColumns = "id, enrollment_id, user_id, course_id, code, student_name, course_title, instructor, completed_at, issued_at, storage_key, revoked_at, revoked_reason"
+
+func scanCertificate(row scanner) (entities.Certificate, error) {
+	var certificate entities.Certificate
+	var revokedAt sql.NullTime
+	err := row.Scan(&certificate.ID, &certificate.EnrollmentID, &certificate.UserID, &certificate.CourseID, &certificate.Code,
+		&certificate.StudentName, &certificate.CourseTitle, &certificate.Instructor, &certificate.CompletedAt, &certificate.IssuedAt,
+		&certificate.StorageKey, &revokedAt, &certificate.RevokedReason)
+	if err != nil {
+		return entities.Certificate{}, err
+	}
+	certificate.RevokedAt = timePtr(revokedAt)
+	return certificate, nil
+}
+
+// Store an issued certificate, an enrollment gets one certificate
+func (r *CourseRepository) AddCertificate(certificate entities.Certificate) (entities.Certificate, error) {
+	query := `INSERT INTO certificates (enrollment_id, user_id, course_id, code, student_name, course_title, instructor, completed_at, issued_at, storage_key)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := r.DB.Exec(query, certificate.EnrollmentID, certificate.UserID, certificate.CourseID, certificate.Code, certificate.StudentName,
+		certificate.CourseTitle, certificate.Instructor, certificate.CompletedAt, certificate.IssuedAt, certificate.StorageKey)
+	if err != nil {
+		return entities.Certificate{}, err
+	}
+	id, _ := result.LastInsertId()
+	certificate.ID = uint(id)
+	return certificate, nil
+}
+
+// Get a certificate by ID
+func (r *CourseRepository) GetCertificateByID(id int) (entities.Certificate, error) {
+	return scanCertificate(r.DB.QueryRow("SELECT "+certificateColumns+" FROM certificates WHERE id = ?", id))
+}
+
+// Get the certificate of an enrollment
+func (r *CourseRepository) GetCertificateByEnrollmentID(enrollmentID int) (entities.Certificate, error) {
+	return scanCertificate(r.DB.QueryRow("SELECT "+certificateColumns+" FROM certificates WHERE enrollment_id = ?", enrollmentID))
+}
+
+// Get a certificate by its verification code
+func (r *CourseRepository) GetCertificateByCode(code string) (entities.Certificate, error) {
+	return scanCertificate(r.DB.QueryRow("SELECT "+certificateColumns+" FROM certificates WHERE code = ?", code))
+}
+
+// Revoke a certificate, revoking it again returns sql.ErrNoRows
+func (r *CourseRepository) RevokeCertificate(id int, at time.Time, reason string) error {
+	result, err := r.DB.Exec("UPDATE certificates SET revoked_at = ?, revoked_reason = ? WHERE id = ? AND revoked_at IS NULL", at, reason, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
@@ -0,0 +1,183 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+//----------------------------------------------------------------coupons----------------------------------------------------------------
+
+const couponColumns = "id, code, kind, percent, amount_off, amount_off_currency, course_id, max_redemptions, max_per_user, starts_at, ends_at, stackable, active, created_at"
+
+func scanCoupon(row scanner) (entities.Coupon, error) {
+	var coupon entities.Coupon
+	var amountOff, courseID sql.NullInt64
+	var amountOffCurrency string
+	var startsAt, endsAt sql.NullTime
+	err := row.Scan(&coupon.ID, &coupon.Code, &coupon.Kind, &coupon.Percent, &amountOff, &amountOffCurrency, &courseID, &coupon.MaxRedemptions,
+		&coupon.MaxPerUser, &startsAt, &endsAt, &coupon.Stackable, &coupon.Active, &coupon.CreatedAt)
+	if err != nil {
+		return entities.Coupon{}, err
+	}
+	if amountOff.Valid {
+		coupon.AmountOff = &entities.Money{Amount: amountOff.Int64, Currency: amountOffCurrency}
+	}
+	coupon.CourseID = uintPtr(courseID)
+	coupon.StartsAt = timePtr(startsAt)
+	coupon.EndsAt = timePtr(endsAt)
+	return coupon, nil
+}
+
+func scanCoupons(rows *sql.Rows) ([]entities.Coupon, error) {
+	defer rows.Close()
+	coupons := []entities.Coupon{}
+	for rows.Next() {
+		coupon, err := scanCoupon(rows)
+		if err != nil {
+			return nil, err
+		}
+		coupons = append(coupons, coupon)
+	}
+	return coupons, rows.Err()
+}
+
+// Create a coupon
+func (r *CourseRepository) AddCoupon(coupon entities.Coupon) (entities.Coupon, error) {
+	amountOff, amountOffCurrency := couponAmountOff(coupon)
+	query := `INSERT INTO coupons (code, kind, percent, amount_off, amount_off_currency, course_id, max_redemptions, max_per_user, starts_at, ends_at, stackable, active, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := r.DB.Exec(query, coupon.Code, coupon.Kind, coupon.Percent, amountOff, amountOffCurrency, coupon.CourseID, coupon.MaxRedemptions,
+		coupon.MaxPerUser, coupon.StartsAt, coupon.EndsAt, coupon.Stackable, coupon.Active, coupon.CreatedAt)
+	if err != nil {
+		return entities.Coupon{}, err
+	}
+	id, _ := result.LastInsertId()
+	coupon.ID = uint(id)
+	return coupon, nil
+}
+
+// Get all coupons, newest first
+func (r *CourseRepository) GetAllCoupons() ([]entities.Coupon, error) {
+	rows, err := r.DB.Query("SELECT " + couponColumns + " FROM coupons ORDER BY id DESC")
+	if err != nil {
+		return nil, err
+	}
+	return scanCoupons(rows)
+}
+
+// Get a coupon by ID
+func (r *CourseRepository) GetCouponByID(id int) (entities.Coupon, error) {
+	return scanCoupon(r.DB.QueryRow("SELECT "+couponColumns+" FROM coupons WHERE id = ?", id))
+}
+
+// Get a coupon by its code, codes are stored upper case
+func (r *CourseRepository) GetCouponByCode(code string) (entities.Coupon, error) {
+	return scanCoupon(r.DB.QueryRow("SELECT "+couponColumns+" FROM coupons WHERE code = ?", code))
+}
+
+// Update a coupon
+func (r *CourseRepository) UpdateCoupon(coupon entities.Coupon) error {
+	amountOff, amountOffCurrency := couponAmountOff(coupon)
+	query := `UPDATE coupons SET code = ?, kind = ?, percent = ?, amount_off = ?, amount_off_currency = ?, course_id = ?, max_redemptions = ?,
+		max_per_user = ?, starts_at = ?, ends_at = ?, stackable = ?, active = ? WHERE id = ?`
+	result, err := r.DB.Exec(query, coupon.Code, coupon.Kind, coupon.Percent, amountOff, amountOffCurrency, coupon.CourseID, coupon.MaxRedemptions,
+		coupon.MaxPerUser, coupon.StartsAt, coupon.EndsAt, coupon.Stackable, coupon.Active, coupon.ID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// couponAmountOff splits the amount of a fixed coupon into its columns
+func couponAmountOff(coupon entities.Coupon) (amount *int64, currency string) {
+	if coupon.AmountOff == nil {
+		return nil, ""
+	}
+	return &coupon.AmountOff.Amount, coupon.AmountOff.Currency
+}
+
+// Delete a coupon and take it out of every cart
+func (r *CourseRepository) DeleteCoupon(id int) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM cart_coupons WHERE coupon_id = ?", id); err != nil {
+		return err
+	}
+	result, err := tx.Exec("DELETE FROM coupons WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Count the redemptions of a coupon that hold a use, overall and by one user
+func (r *CourseRepository) CountCouponRedemptions(couponID, userID int) (total int, byUser int, err error) {
+	query := `SELECT COUNT(*), COALESCE(SUM(cr.user_id = ?), 0) FROM coupon_redemptions cr
+		JOIN orders o ON o.id = cr.order_id
+		WHERE cr.coupon_id = ? AND o.status IN (?, ?, ?)`
+	err = r.DB.QueryRow(query, userID, couponID, entities.OrderPending, entities.OrderRequiresAction, entities.OrderPaid).Scan(&total, &byUser)
+	return total, byUser, err
+}
+
+// Get every redemption of a coupon with the status of its order, newest first
+func (r *CourseRepository) GetCouponRedemptions(couponID int) ([]entities.CouponRedemption, error) {
+	query := `SELECT cr.id, cr.coupon_id, cr.order_id, cr.user_id, o.status, cr.discount_amount, o.currency, cr.redeemed_at FROM coupon_redemptions cr
+		JOIN orders o ON o.id = cr.order_id
+		WHERE cr.coupon_id = ? ORDER BY cr.id DESC`
+	rows, err := r.DB.Query(query, couponID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	redemptions := []entities.CouponRedemption{}
+	for rows.Next() {
+		var redemption entities.CouponRedemption
+		err := rows.Scan(&redemption.ID, &redemption.CouponID, &redemption.OrderID, &redemption.UserID, &redemption.OrderStatus,
+			&redemption.Discount.Amount, &redemption.Discount.Currency, &redemption.RedeemedAt)
+		if err != nil {
+			return nil, err
+		}
+		redemptions = append(redemptions, redemption)
+	}
+	return redemptions, rows.Err()
+}
+
+//----------------------------------------------------------------cart coupons----------------------------------------------------------------
+
+// Get the coupons applied to a user's cart in the order they were applied
+func (r *CourseRepository) GetCartCoupons(userID int) ([]entities.Coupon, error) {
+	query := `SELECT c.id, c.code, c.kind, c.percent, c.amount_off, c.amount_off_currency, c.course_id, c.max_redemptions, c.max_per_user,
+		c.starts_at, c.ends_at, c.stackable, c.active, c.created_at FROM cart_coupons cc
+		JOIN coupons c ON c.id = cc.coupon_id
+		WHERE cc.user_id = ? ORDER BY cc.added_at, c.id`
+	rows, err := r.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanCoupons(rows)
+}
+
+// Apply a coupon to a user's cart, applying it again is a no-op
+func (r *CourseRepository) AddCartCoupon(userID, couponID int, at time.Time) error {
+	_, err := r.DB.Exec("INSERT INTO cart_coupons (user_id, coupon_id, added_at) VALUES (?, ?, ?) ON CONFLICT DO NOTHING", userID, couponID, at)
+	return err
+}
+
+// Take a coupon off a user's cart
+func (r *CourseRepository) RemoveCartCoupon(userID, couponID int) error {
+	result, err := r.DB.Exec("DELETE FROM cart_coupons WHERE user_id = ? AND coupon_id = ?", userID, couponID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
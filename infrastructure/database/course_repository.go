@@ -2,18 +2,22 @@ package database
 
 import (
 	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+	"time"
 
 	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
 )
 
 type CourseRepository struct {
-	DB *sql.DB
+	DB querier // the connection pool, or the transaction of an Atomic call
 }
 
 
 func NewCourseRepository(db *sql.DB) *CourseRepository {
 	return &CourseRepository{
-		DB: db,
+		DB: pool{db: db},
 	}
 }
 
@@ -31,22 +35,25 @@ func (r *CourseRepository) CreateUser(user entities.User) (entities.User, error)
 	return user, nil
 }
 
-// Get a user by ID
-func (r *CourseRepository) GetUserByID(id int) (entities.User, error) {
-	query := "SELECT id, first_name, last_name, email, password, role, bio FROM users WHERE id = ?"
+// Get a user by ID, soft deleted users are only returned when includeDeleted is set. Users are
+// read without their password hash, see GetUserCredentials
+func (r *CourseRepository) GetUserByID(id int, includeDeleted bool) (entities.User, error) {
+	query := "SELECT id, first_name, last_name, email, role, bio, deleted_at FROM users WHERE id = ?" + notDeleted(includeDeleted)
 	row := r.DB.QueryRow(query, id)
 
 	var user entities.User
-	err := row.Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.Password, &user.Role, &user.Bio)
+	var deletedAt sql.NullTime
+	err := row.Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.Role, &user.Bio, &deletedAt)
 	if err != nil {
 		return entities.User{}, err
 	}
+	user.DeletedAt = timePtr(deletedAt)
 	return user, nil
 }
 
-// Get all users
-func (r *CourseRepository) GetAllUsers() ([]entities.User, error) {
-	query := "SELECT id, first_name, last_name, email, password, role, bio FROM users"
+// Get all users, soft deleted users are only returned when includeDeleted is set
+func (r *CourseRepository) GetAllUsers(includeDeleted bool) ([]entities.User, error) {
+	query := "SELECT id, first_name, last_name, email, role, bio, deleted_at FROM users WHERE 1 = 1" + notDeleted(includeDeleted)
 	rows, err := r.DB.Query(query)
 	if err != nil {
 		return nil, err
@@ -56,28 +63,36 @@ func (r *CourseRepository) GetAllUsers() ([]entities.User, error) {
 	var users []entities.User
 	for rows.Next() {
 		var user entities.User
-		err := rows.Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.Password, &user.Role, &user.Bio)
+		var deletedAt sql.NullTime
+		err := rows.Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.Role, &user.Bio, &deletedAt)
 		if err != nil {
 			return nil, err
 		}
+		user.DeletedAt = timePtr(deletedAt)
 		users = append(users, user)
 	}
 	return users, nil
 }
 
 
-// Update a user
+// Update a user, the password hash is kept when the user has none
 func (r *CourseRepository) UpdateUser(user entities.User) (entities.User ,error) {
-	query := "UPDATE users SET first_name = ?, last_name = ?, email = ?, password = ?, role = ?, bio = ? WHERE id = ?"
-	_, err := r.DB.Exec(query, user.FirstName, user.LastName, user.Email, user.Password, user.Role, user.Bio, user.ID)
-	return user, err
+	query := "UPDATE users SET first_name = ?, last_name = ?, email = ?, password = COALESCE(NULLIF(?, ''), password), role = ?, bio = ? WHERE id = ? AND deleted_at IS NULL"
+	result, err := r.DB.Exec(query, user.FirstName, user.LastName, user.Email, user.Password, user.Role, user.Bio, user.ID)
+	if err != nil {
+		return user, err
+	}
+	return user, requireAffected(result)
 }
 
-// Delete a user
+// Soft delete a user, the row is kept until it is purged
 func (r *CourseRepository) DeleteUser(id int) (error) {
-	query := "DELETE FROM users WHERE id = ?"
-	_, err := r.DB.Exec(query, id)
-	return err
+	return r.softDelete("users", id)
+}
+
+// Restore a soft deleted user
+func (r *CourseRepository) RestoreUser(id int) error {
+	return r.restore("users", id)
 }
 
 
@@ -85,8 +100,8 @@ func (r *CourseRepository) DeleteUser(id int) (error) {
 
 // create a new course
 func (r *CourseRepository) AddCourse(course entities.Course) (entities.Course, error) {
-	query := "INSERT INTO courses (title, description, duration, price, instructor, category) VALUES (?, ?, ?, ?, ?, ?)"
-	result, err := r.DB.Exec(query, course.Title, course.Description, course.Duration, course.Price, course.Instructor, course.Category)
+	query := "INSERT INTO courses (title, description, duration, price_amount, price_currency, instructor, category, status, publish_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	result, err := r.DB.Exec(query, course.Title, course.Description, course.Duration, course.Price.Amount, course.Price.Currency, course.Instructor, course.Category, course.Status, course.PublishAt)
 	if err != nil {
 		return entities.Course{}, err
 	}
@@ -95,23 +110,51 @@ func (r *CourseRepository) AddCourse(course entities.Course) (entities.Course, e
 	return course, nil
 }
 
-// Get a course by ID
-func (r *CourseRepository) GetCourseByID(id int) (entities.Course, error) {
-	query := "SELECT id, title, description, duration, price, instructor, category FROM courses WHERE id = ?"
-	row := r.DB.QueryRow(query, id)
+// columns read by scanCourse
+const courseColumns = "id, title, description, duration, price_amount, price_currency, instructor, category, deleted_at, " +
+	"rating_count, rating_sum, rating_1, rating_2, rating_3, rating_4, rating_5, status, publish_at, status_note"
 
+func scanCourse(row scanner) (entities.Course, error) {
 	var course entities.Course
-	err := row.Scan(&course.ID, &course.Title, &course.Description, &course.Duration, &course.Price, &course.Instructor, &course.Category)
+	var deletedAt, publishAt sql.NullTime
+	var statusNote sql.NullString
+	var sum int
+	histogram := &course.Rating.Histogram
+	err := row.Scan(&course.ID, &course.Title, &course.Description, &course.Duration, &course.Price.Amount, &course.Price.Currency, &course.Instructor, &course.Category, &deletedAt,
+		&course.Rating.Count, &sum, &histogram[0], &histogram[1], &histogram[2], &histogram[3], &histogram[4],
+		&course.Status, &publishAt, &statusNote)
 	if err != nil {
 		return entities.Course{}, err
 	}
+	course.DeletedAt = timePtr(deletedAt)
+	course.PublishAt = timePtr(publishAt)
+	course.StatusNote = statusNote.String
+	if course.Rating.Count > 0 {
+		course.Rating.Average = math.Round(float64(sum)*100/float64(course.Rating.Count)) / 100
+	}
 	return course, nil
 }
 
-// Get all courses
-func (r *CourseRepository) GetAllCourses() ([]entities.Course, error) {
-	query := "SELECT id, title, description, duration, price, instructor, category FROM courses"
-	rows, err := r.DB.Query(query)
+// Get a course by ID, soft deleted courses are only returned when includeDeleted is set
+func (r *CourseRepository) GetCourseByID(id int, includeDeleted bool) (entities.Course, error) {
+	query := "SELECT " + courseColumns + " FROM courses WHERE id = ?" + notDeleted(includeDeleted)
+	return scanCourse(r.DB.QueryRow(query, id))
+}
+
+// Get all courses matching the filter
+func (r *CourseRepository) GetAllCourses(filter entities.CourseFilter) ([]entities.Course, error) {
+	query := "SELECT " + courseColumns + " FROM courses WHERE 1 = 1" + notDeleted(filter.IncludeDeleted)
+	var args []interface{}
+	if filter.PublishedOnly {
+		visible := "status = ? AND (publish_at IS NULL OR publish_at <= ?)"
+		args = append(args, entities.CoursePublished, time.Now().UTC())
+		if filter.Instructor != "" {
+			visible = "(" + visible + ") OR instructor = ? COLLATE NOCASE"
+			args = append(args, filter.Instructor)
+		}
+		query += " AND (" + visible + ")"
+	}
+	rows, err := r.DB.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -119,82 +162,100 @@ func (r *CourseRepository) GetAllCourses() ([]entities.Course, error) {
 
 	var courses []entities.Course
 	for rows.Next() {
-		var course entities.Course
-		err := rows.Scan(&course.ID, &course.Title, &course.Description, &course.Duration, &course.Price, &course.Instructor, &course.Category)
+		course, err := scanCourse(rows)
 		if err != nil {
 			return nil, err
 		}
 		courses = append(courses, course)
 	}
-	return courses, nil
+	return courses, rows.Err()
 }
 
 // Update a course
 func (r *CourseRepository) UpdateCourse(course entities.Course) (entities.Course ,error) {
-	query := "UPDATE courses SET title = ?, description = ?, duration = ?, price = ?, instructor = ?, category = ? WHERE id = ?"
-	_, err := r.DB.Exec(query, course.Title, course.Description, course.Duration, course.Price, course.Instructor, course.Category, course.ID)
-	return course, err
+	query := "UPDATE courses SET title = ?, description = ?, duration = ?, price_amount = ?, price_currency = ?, instructor = ?, category = ? WHERE id = ? AND deleted_at IS NULL"
+	result, err := r.DB.Exec(query, course.Title, course.Description, course.Duration, course.Price.Amount, course.Price.Currency, course.Instructor, course.Category, course.ID)
+	if err != nil {
+		return course, err
+	}
+	return course, requireAffected(result)
 }
 
-// Delete a course
+// Move a course to another publishing state
+func (r *CourseRepository) SetCourseStatus(id int, status string, publishAt *time.Time, note string) error {
+	query := "UPDATE courses SET status = ?, publish_at = ?, status_note = ? WHERE id = ? AND deleted_at IS NULL"
+	result, err := r.DB.Exec(query, status, publishAt, note, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// Soft delete a course, enrollments and reviews stay intact
 func (r *CourseRepository) DeleteCourse(id int) (error) {
-	query := "DELETE FROM courses WHERE id = ?"
-	_, err := r.DB.Exec(query, id)
-	return err
+	return r.softDelete("courses", id)
+}
+
+// Restore a soft deleted course
+func (r *CourseRepository) RestoreCourse(id int) error {
+	return r.restore("courses", id)
 }
 
 //----------------------------------------------------------------enrollment----------------------------------------------------------------
 
 // Create a new enrollment
 func (r *CourseRepository) AddEnrollment(enrollment entities.Enrollment) (entities.Enrollment, error) {
-	query := "INSERT INTO enrollments (user_id, course_id, completed) VALUES (?, ?, ?)"
-	result, err := r.DB.Exec(query, enrollment.UserID, enrollment.CourseID, enrollment.Completed)
+	query := "INSERT INTO enrollments (user_id, course_id, completed, completed_at, course_version, enrolled_at, subscription_id, seat_pool_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+	args := []interface{}{enrollment.UserID, enrollment.CourseID, enrollment.Completed, enrollment.CompletedAt, enrollment.CourseVersion, enrollment.EnrolledAt,
+		enrollment.SubscriptionID, enrollment.SeatPoolID}
+	if enrollment.SeatPoolID != nil {
+		// taking a seat and checking one is free happen in the same statement, sql.ErrNoRows when none is
+		query = "INSERT INTO enrollments (user_id, course_id, completed, completed_at, course_version, enrolled_at, subscription_id, seat_pool_id) " +
+			"SELECT ?, ?, ?, ?, ?, ?, ?, ? WHERE " + seatAvailable
+		args = append(args, *enrollment.SeatPoolID, *enrollment.SeatPoolID)
+	}
+	result, err := r.DB.Exec(query, args...)
 	if err != nil {
 		return entities.Enrollment{}, err
 	}
+	if enrollment.SeatPoolID != nil {
+		if err := requireAffected(result); err != nil {
+			return entities.Enrollment{}, err
+		}
+	}
 	id, _ := result.LastInsertId()
 	enrollment.ID = uint(id)
 	return enrollment, nil
 }
 
-// Get all enrollments
-func (r *CourseRepository) GetAllEnrollments() ([]entities.Enrollment, error) {
-	query := "SELECT id, user_id, course_id, completed FROM enrollments"
-	rows, err := r.DB.Query(query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+// columns read by scanEnrollment
+const enrollmentColumns = "id, user_id, course_id, completed, completed_at, course_version, enrolled_at, revoked_at, revoke_reason, subscription_id, seat_pool_id"
 
-	var enrollments []entities.Enrollment
-	for rows.Next() {
-		var enrollment entities.Enrollment
-		err := rows.Scan(&enrollment.ID, &enrollment.UserID, &enrollment.CourseID, &enrollment.Completed)
-		if err != nil {
-			return nil, err
-		}
-		enrollments = append(enrollments, enrollment)
-	}
-	return enrollments, nil
+// scanner is implemented by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
 }
 
-// Get an enrollment by ID
-func (r *CourseRepository) GetEnrollmentByID(id int) (entities.Enrollment, error) {
-	query := "SELECT id, user_id, course_id, completed FROM enrollments WHERE id = ?"
-	row := r.DB.QueryRow(query, id)
-
+func scanEnrollment(row scanner) (entities.Enrollment, error) {
 	var enrollment entities.Enrollment
-	err := row.Scan(&enrollment.ID, &enrollment.UserID, &enrollment.CourseID, &enrollment.Completed)
+	var completedAt, enrolledAt, revokedAt sql.NullTime
+	var subscriptionID, seatPoolID sql.NullInt64
+	err := row.Scan(&enrollment.ID, &enrollment.UserID, &enrollment.CourseID, &enrollment.Completed, &completedAt, &enrollment.CourseVersion, &enrolledAt,
+		&revokedAt, &enrollment.RevokeReason, &subscriptionID, &seatPoolID)
 	if err != nil {
 		return entities.Enrollment{}, err
 	}
+	enrollment.CompletedAt = timePtr(completedAt)
+	enrollment.EnrolledAt = timePtr(enrolledAt)
+	enrollment.RevokedAt = timePtr(revokedAt)
+	enrollment.SubscriptionID = uintPtr(subscriptionID)
+	enrollment.SeatPoolID = uintPtr(seatPoolID)
 	return enrollment, nil
 }
 
-// get all enrollments by user ID
-func (r *CourseRepository) GetEnrollmentsByUserID(userID int) ([]entities.Enrollment, error) {
-	query := "SELECT id, user_id, course_id, completed FROM enrollments WHERE user_id = ?"
-	rows, err := r.DB.Query(query, userID)
+// query enrollments with the given WHERE clause
+func (r *CourseRepository) queryEnrollments(where string, args ...interface{}) ([]entities.Enrollment, error) {
+	rows, err := r.DB.Query("SELECT "+enrollmentColumns+" FROM enrollments "+where, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -202,20 +263,46 @@ func (r *CourseRepository) GetEnrollmentsByUserID(userID int) ([]entities.Enroll
 
 	var enrollments []entities.Enrollment
 	for rows.Next() {
-		var enrollment entities.Enrollment
-		err := rows.Scan(&enrollment.ID, &enrollment.UserID, &enrollment.CourseID, &enrollment.Completed)
+		enrollment, err := scanEnrollment(rows)
 		if err != nil {
 			return nil, err
 		}
 		enrollments = append(enrollments, enrollment)
 	}
-	return enrollments, nil
-}			
+	return enrollments, rows.Err()
+}
+
+// Get all enrollments
+func (r *CourseRepository) GetAllEnrollments() ([]entities.Enrollment, error) {
+	return r.queryEnrollments("")
+}
+
+// Get an enrollment by ID
+func (r *CourseRepository) GetEnrollmentByID(id int) (entities.Enrollment, error) {
+	row := r.DB.QueryRow("SELECT "+enrollmentColumns+" FROM enrollments WHERE id = ?", id)
+	return scanEnrollment(row)
+}
+
+// Get the enrollment of a user in a course
+func (r *CourseRepository) GetEnrollmentByUserAndCourse(userID, courseID int) (entities.Enrollment, error) {
+	row := r.DB.QueryRow("SELECT "+enrollmentColumns+" FROM enrollments WHERE user_id = ? AND course_id = ?", userID, courseID)
+	return scanEnrollment(row)
+}
+
+// get all enrollments by user ID
+func (r *CourseRepository) GetEnrollmentsByUserID(userID int) ([]entities.Enrollment, error) {
+	return r.queryEnrollments("WHERE user_id = ?", userID)
+}
+
+// get all enrollments of a course
+func (r *CourseRepository) GetEnrollmentsByCourseID(courseID int) ([]entities.Enrollment, error) {
+	return r.queryEnrollments("WHERE course_id = ?", courseID)
+}
 
 // Update an enrollment
 func (r *CourseRepository) UpdateEnrollment(enrollment entities.Enrollment) (entities.Enrollment, error) {
-	query := "UPDATE enrollments SET user_id = ?, course_id = ?, completed = ? WHERE id = ?"
-	_, err := r.DB.Exec(query, enrollment.UserID, enrollment.CourseID, enrollment.Completed, enrollment.ID)
+	query := "UPDATE enrollments SET user_id = ?, course_id = ?, completed = ?, completed_at = ?, course_version = ?, subscription_id = ? WHERE id = ?"
+	_, err := r.DB.Exec(query, enrollment.UserID, enrollment.CourseID, enrollment.Completed, enrollment.CompletedAt, enrollment.CourseVersion, enrollment.SubscriptionID, enrollment.ID)
 	if err != nil {
 		return entities.Enrollment{}, err
 	}
@@ -231,10 +318,12 @@ func (r *CourseRepository) DeleteEnrollment(id int) error {
 
 //----------------------------------------------------------------lesson----------------------------------------------------------------
 
-// Create a new lesson
+// Create a new lesson after every other lesson of its course, see RenumberCurriculum
 func (r *CourseRepository) AddLesson(lesson entities.Lesson) (entities.Lesson, error) {
-	query := "INSERT INTO lessons (course_id, title, content, video_url, `order`) VALUES (?, ?, ?, ?, ?)"
-	result, err := r.DB.Exec(query, lesson.CourseID, lesson.Title, lesson.Content, lesson.VideoURL, lesson.Order)
+	query := "INSERT INTO lessons (course_id, title, content, video_url, `order`, section_id, release_after_days, release_at, requires_previous) " +
+		"SELECT ?, ?, ?, ?, COALESCE(MAX(`order`), 0) + 1, ?, ?, ?, ? FROM lessons WHERE course_id = ?"
+	result, err := r.DB.Exec(query, lesson.CourseID, lesson.Title, lesson.Content, lesson.VideoURL, lesson.SectionID,
+		lesson.ReleaseAfterDays, lesson.ReleaseAt, lesson.RequiresPrevious, lesson.CourseID)
 	if err != nil {
 		return entities.Lesson{}, err
 	}
@@ -245,9 +334,9 @@ func (r *CourseRepository) AddLesson(lesson entities.Lesson) (entities.Lesson, e
 
 
 
-// Get all lessons by course ID
-func (r *CourseRepository) GetLessonsByCourseID(courseID int) ([]entities.Lesson, error) {
-	query := "SELECT id, course_id, title, content, video_url, `order` FROM lessons WHERE course_id = ? ORDER BY `order`"
+// Get all lessons by course ID, soft deleted lessons are only returned when includeDeleted is set
+func (r *CourseRepository) GetLessonsByCourseID(courseID int, includeDeleted bool) ([]entities.Lesson, error) {
+	query := "SELECT id, course_id, title, content, video_url, `order`, section_id, release_after_days, release_at, requires_previous, deleted_at FROM lessons WHERE course_id = ?" + notDeleted(includeDeleted) + " ORDER BY `order`"
 	rows, err := r.DB.Query(query, courseID)
 	if err != nil {
 		return nil, err
@@ -257,18 +346,26 @@ func (r *CourseRepository) GetLessonsByCourseID(courseID int) ([]entities.Lesson
 	var lessons []entities.Lesson
 	for rows.Next() {
 		var lesson entities.Lesson
-		err := rows.Scan(&lesson.ID, &lesson.CourseID, &lesson.Title, &lesson.Content, &lesson.VideoURL, &lesson.Order)
+		var deletedAt sql.NullTime
+		var sectionID, releaseAfterDays sql.NullInt64
+		var releaseAt sql.NullTime
+		err := rows.Scan(&lesson.ID, &lesson.CourseID, &lesson.Title, &lesson.Content, &lesson.VideoURL, &lesson.Order, &sectionID,
+			&releaseAfterDays, &releaseAt, &lesson.RequiresPrevious, &deletedAt)
 		if err != nil {
 			return nil, err
 		}
+		lesson.SectionID = uintPtr(sectionID)
+		lesson.ReleaseAfterDays = uintPtr(releaseAfterDays)
+		lesson.ReleaseAt = timePtr(releaseAt)
+		lesson.DeletedAt = timePtr(deletedAt)
 		lessons = append(lessons, lesson)
 	}
 	return lessons, nil
 }
 
 //get all lessons by course ID 
-func (r *CourseRepository) GetLessonsByID(id int) ([]entities.Lesson, error) {
-	query := "SELECT id, course_id, title, content, video_url, `order` FROM lessons WHERE id = ?"
+func (r *CourseRepository) GetLessonsByID(id int, includeDeleted bool) ([]entities.Lesson, error) {
+	query := "SELECT id, course_id, title, content, video_url, `order`, section_id, release_after_days, release_at, requires_previous, deleted_at FROM lessons WHERE id = ?" + notDeleted(includeDeleted)
 	rows, err := r.DB.Query(query, id)
 	if err != nil {
 		return nil, err
@@ -277,10 +374,18 @@ func (r *CourseRepository) GetLessonsByID(id int) ([]entities.Lesson, error) {
 	var lessons []entities.Lesson
 	for rows.Next() {
 		var lesson entities.Lesson
-		err := rows.Scan(&lesson.ID, &lesson.CourseID, &lesson.Title, &lesson.Content, &lesson.VideoURL, &lesson.Order)
+		var deletedAt sql.NullTime
+		var sectionID, releaseAfterDays sql.NullInt64
+		var releaseAt sql.NullTime
+		err := rows.Scan(&lesson.ID, &lesson.CourseID, &lesson.Title, &lesson.Content, &lesson.VideoURL, &lesson.Order, &sectionID,
+			&releaseAfterDays, &releaseAt, &lesson.RequiresPrevious, &deletedAt)
 		if err != nil {
 			return nil, err
 		}
+		lesson.SectionID = uintPtr(sectionID)
+		lesson.ReleaseAfterDays = uintPtr(releaseAfterDays)
+		lesson.ReleaseAt = timePtr(releaseAt)
+		lesson.DeletedAt = timePtr(deletedAt)
 		lessons = append(lessons, lesson)
 	}
 	return lessons, nil
@@ -288,76 +393,144 @@ func (r *CourseRepository) GetLessonsByID(id int) ([]entities.Lesson, error) {
 
 // Update a lesson
 func (r *CourseRepository) UpdateLesson(lesson entities.Lesson) (entities.Lesson, error) {
-	query := "UPDATE lessons SET course_id = ?, title = ?, content = ?, video_url = ?, `order` = ? WHERE id = ?"
-	_, err := r.DB.Exec(query, lesson.CourseID, lesson.Title, lesson.Content, lesson.VideoURL, lesson.Order, lesson.ID)
+	query := "UPDATE lessons SET course_id = ?, title = ?, content = ?, video_url = ?, `order` = ?, section_id = ?, " +
+		"release_after_days = ?, release_at = ?, requires_previous = ? WHERE id = ? AND deleted_at IS NULL"
+	_, err := r.DB.Exec(query, lesson.CourseID, lesson.Title, lesson.Content, lesson.VideoURL, lesson.Order, lesson.SectionID,
+		lesson.ReleaseAfterDays, lesson.ReleaseAt, lesson.RequiresPrevious, lesson.ID)
 	if err != nil {
 		return entities.Lesson{}, err
 	}
 	return lesson, nil
 }
 
-// Delete a lesson
+// Soft delete a lesson, progress rows stay intact
 func (r *CourseRepository) DeleteLesson(id int) error {
-	query := "DELETE FROM lessons WHERE id = ?"
-	_, err := r.DB.Exec(query, id)
-	return err
+	return r.softDelete("lessons", id)
+}
+
+// Restore a soft deleted lesson
+func (r *CourseRepository) RestoreLesson(id int) error {
+	return r.restore("lessons", id)
 }
 
 //----------------------------------------------------------------progress----------------------------------------------------------------
 
 // Create a new progress
 func (r *CourseRepository) AddProgress(progress entities.Progress) (entities.Progress, error) {
-	query := "INSERT INTO progress (enrollment_id, lesson_id, completed) VALUES (?, ?, ?)"
-	result, err := r.DB.Exec(query, progress.EnrollmentID, progress.LessonID, progress.Completed)
+	now := time.Now().UTC()
+	query := "INSERT INTO progress (enrollment_id, lesson_id, completed, updated_at) VALUES (?, ?, ?, ?)"
+	result, err := r.DB.Exec(query, progress.EnrollmentID, progress.LessonID, progress.Completed, now)
 	if err != nil {
 		return entities.Progress{}, err
 	}
 	id, _ := result.LastInsertId()
 	progress.ID = uint(id)
+	progress.UpdatedAt = &now
 	return progress, nil
 }
 
-// Update lesson progress for a user
-func (r *CourseRepository) UpdateProgress(progress entities.Progress) (entities.Progress, error) {
-	query := "UPDATE progress SET completed = ? WHERE enrollment_id = ? AND lesson_id = ?"
-	_, err := r.DB.Exec(query, progress.Completed, progress.EnrollmentID, progress.LessonID)
+// Insert or update lesson progress for a user, there is at most one row per enrollment and lesson
+func (r *CourseRepository) UpsertProgress(progress entities.Progress) (entities.Progress, error) {
+	query := `INSERT INTO progress (enrollment_id, lesson_id, completed, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (enrollment_id, lesson_id) DO UPDATE SET completed = excluded.completed, updated_at = excluded.updated_at`
+	_, err := r.DB.Exec(query, progress.EnrollmentID, progress.LessonID, progress.Completed, time.Now().UTC())
 	if err != nil {
 		return entities.Progress{}, err
 	}
-	return progress, nil
+	return r.GetProgressByEnrollmentAndLesson(int(progress.EnrollmentID), int(progress.LessonID))
 }
 
 // Get progress by enrollment ID and lesson ID
 func (r *CourseRepository) GetProgressByEnrollmentAndLesson(enrollmentID, lessonID int) (entities.Progress, error) {
-	query := "SELECT id, enrollment_id, lesson_id, completed FROM progress WHERE enrollment_id = ? AND lesson_id = ?"
+	query := "SELECT id, enrollment_id, lesson_id, completed, updated_at FROM progress WHERE enrollment_id = ? AND lesson_id = ?"
 	row := r.DB.QueryRow(query, enrollmentID, lessonID)
 
 	var progress entities.Progress
-	err := row.Scan(&progress.ID, &progress.EnrollmentID, &progress.LessonID, &progress.Completed)
+	var updatedAt sql.NullTime
+	err := row.Scan(&progress.ID, &progress.EnrollmentID, &progress.LessonID, &progress.Completed, &updatedAt)
 	if err != nil {
 		return entities.Progress{}, err
 	}
+	progress.UpdatedAt = timePtr(updatedAt)
 	return progress, nil
 }
 
+// Get every progress row of an enrollment
+func (r *CourseRepository) GetProgressByEnrollment(enrollmentID int) ([]entities.Progress, error) {
+	query := "SELECT id, enrollment_id, lesson_id, completed, updated_at FROM progress WHERE enrollment_id = ?"
+	rows, err := r.DB.Query(query, enrollmentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var progresses []entities.Progress
+	for rows.Next() {
+		var progress entities.Progress
+		var updatedAt sql.NullTime
+		err := rows.Scan(&progress.ID, &progress.EnrollmentID, &progress.LessonID, &progress.Completed, &updatedAt)
+		if err != nil {
+			return nil, err
+		}
+		progress.UpdatedAt = timePtr(updatedAt)
+		progresses = append(progresses, progress)
+	}
+	return progresses, rows.Err()
+}
+
 //----------------------------------------------------------------review----------------------------------------------------------------
 
-// Add a new review
+// Add a new review, an approved review counts in the course rating aggregates
 func (r *CourseRepository) AddReview(review entities.Review) (entities.Review, error) {
-	query := "INSERT INTO reviews (course_id, user_id, rating, comment) VALUES (?, ?, ?, ?)"
-	result, err := r.DB.Exec(query, review.CourseID, review.UserID, review.Rating, review.Comment)
+	tx, err := r.DB.Begin()
 	if err != nil {
 		return entities.Review{}, err
 	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	query := "INSERT INTO reviews (course_id, user_id, rating, comment, status, moderation_note, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)"
+	result, err := tx.Exec(query, review.CourseID, review.UserID, review.Rating, review.Comment, review.Status, review.ModerationNote, now)
+	if err != nil {
+		return entities.Review{}, err
+	}
+	if review.Status == entities.ReviewApproved {
+		if err := adjustRating(tx, review.CourseID, review.Rating, 1); err != nil {
+			return entities.Review{}, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return entities.Review{}, err
+	}
 	id, _ := result.LastInsertId()
 	review.ID = uint(id)
+	review.CreatedAt = &now
 	return review, nil
 }
 
-// Get all reviews for a course
-func (r *CourseRepository) GetReviewsByCourseID(courseID int) ([]entities.Review, error) {
-	query := "SELECT id, course_id, user_id, rating, comment FROM reviews WHERE course_id = ?"
-	rows, err := r.DB.Query(query, courseID)
+// columns read by scanReview
+const reviewColumns = "id, course_id, user_id, rating, comment, status, moderation_note, created_at, reply_user_id, reply_body, reply_at"
+
+func scanReview(row scanner) (entities.Review, error) {
+	var review entities.Review
+	var note, replyBody sql.NullString
+	var createdAt, replyAt sql.NullTime
+	var replyUserID sql.NullInt64
+	err := row.Scan(&review.ID, &review.CourseID, &review.UserID, &review.Rating, &review.Comment, &review.Status, &note, &createdAt, &replyUserID, &replyBody, &replyAt)
+	if err != nil {
+		return entities.Review{}, err
+	}
+	review.ModerationNote = note.String
+	review.CreatedAt = timePtr(createdAt)
+	if replyBody.Valid {
+		review.Reply = &entities.ReviewReply{UserID: uint(replyUserID.Int64), Body: replyBody.String, CreatedAt: replyAt.Time}
+	}
+	return review, nil
+}
+
+// query reviews with the given WHERE clause
+func (r *CourseRepository) queryReviews(where string, args ...interface{}) ([]entities.Review, error) {
+	rows, err := r.DB.Query("SELECT "+reviewColumns+" FROM reviews "+where, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -365,20 +538,318 @@ func (r *CourseRepository) GetReviewsByCourseID(courseID int) ([]entities.Review
 
 	var reviews []entities.Review
 	for rows.Next() {
-		var review entities.Review
-		err := rows.Scan(&review.ID, &review.CourseID, &review.UserID, &review.Rating, &review.Comment)
+		review, err := scanReview(rows)
 		if err != nil {
 			return nil, err
 		}
 		reviews = append(reviews, review)
 	}
-	return reviews, nil
+	return reviews, rows.Err()
+}
+
+// Get all approved reviews for a course
+func (r *CourseRepository) GetReviewsByCourseID(courseID int) ([]entities.Review, error) {
+	return r.queryReviews("WHERE course_id = ? AND status = ?", courseID, entities.ReviewApproved)
+}
+
+// Get reviews in any of the given moderation states, oldest first
+func (r *CourseRepository) GetReviewsByStatus(statuses []string) ([]entities.Review, error) {
+	if len(statuses) == 0 {
+		return nil, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(statuses)), ", ")
+	args := make([]interface{}, len(statuses))
+	for i, status := range statuses {
+		args[i] = status
+	}
+	return r.queryReviews("WHERE status IN ("+placeholders+") ORDER BY id", args...)
+}
+
+// Get a review by ID
+func (r *CourseRepository) GetReviewByID(id int) (entities.Review, error) {
+	query := "SELECT " + reviewColumns + " FROM reviews WHERE id = ?"
+	return scanReview(r.DB.QueryRow(query, id))
+}
+
+// Get the review a user wrote for a course
+func (r *CourseRepository) GetReviewByUserAndCourse(userID, courseID int) (entities.Review, error) {
+	query := "SELECT " + reviewColumns + " FROM reviews WHERE user_id = ? AND course_id = ?"
+	return scanReview(r.DB.QueryRow(query, userID, courseID))
+}
+
+// Update the rating, comment and moderation state of a review and keep the course
+// rating aggregates in line with it
+func (r *CourseRepository) UpdateReview(review entities.Review) (entities.Review, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return entities.Review{}, err
+	}
+	defer tx.Rollback()
+
+	before, err := scanReview(tx.QueryRow("SELECT "+reviewColumns+" FROM reviews WHERE id = ?", review.ID))
+	if err != nil {
+		return entities.Review{}, err
+	}
+	query := "UPDATE reviews SET rating = ?, comment = ?, status = ?, moderation_note = ? WHERE id = ?"
+	_, err = tx.Exec(query, review.Rating, review.Comment, review.Status, review.ModerationNote, review.ID)
+	if err != nil {
+		return entities.Review{}, err
+	}
+	if before.Status == entities.ReviewApproved {
+		if err := adjustRating(tx, before.CourseID, before.Rating, -1); err != nil {
+			return entities.Review{}, err
+		}
+	}
+	if review.Status == entities.ReviewApproved {
+		if err := adjustRating(tx, before.CourseID, review.Rating, 1); err != nil {
+			return entities.Review{}, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return entities.Review{}, err
+	}
+	before.Rating = review.Rating
+	before.Comment = review.Comment
+	before.Status = review.Status
+	before.ModerationNote = review.ModerationNote
+	return before, nil
 }
 
-// Delete a review
+// Delete a review and remove its rating from the course aggregates
 func (r *CourseRepository) DeleteReview(id int) error {
-	query := "DELETE FROM reviews WHERE id = ?"
-	_, err := r.DB.Exec(query, id)
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	review, err := scanReview(tx.QueryRow("SELECT "+reviewColumns+" FROM reviews WHERE id = ?", id))
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM reviews WHERE id = ?", id); err != nil {
+		return err
+	}
+	if review.Status == entities.ReviewApproved {
+		if err := adjustRating(tx, review.CourseID, review.Rating, -1); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// adjustRating adds (delta 1) or removes (delta -1) one rating from a course's aggregates
+func adjustRating(tx querier, courseID uint, rating int, delta int) error {
+	if rating < 1 || rating > 5 {
+		return fmt.Errorf("rating must be between 1 and 5")
+	}
+	column := fmt.Sprintf("rating_%d", rating)
+	query := "UPDATE courses SET rating_count = rating_count + ?, rating_sum = rating_sum + ?, " + column + " = " + column + " + ? WHERE id = ?"
+	_, err := tx.Exec(query, delta, delta*rating, delta, courseID)
 	return err
 }
 
+//----------------------------------------------------------------soft delete----------------------------------------------------------------
+
+// Mark a row as deleted, returns sql.ErrNoRows if it is missing or already deleted
+func (r *CourseRepository) softDelete(table string, id int) error {
+	query := "UPDATE " + table + " SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL"
+	result, err := r.DB.Exec(query, time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// Clear the deleted mark of a row, returns sql.ErrNoRows if it is missing or not deleted
+func (r *CourseRepository) restore(table string, id int) error {
+	query := "UPDATE " + table + " SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL"
+	result, err := r.DB.Exec(query, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// Hard delete lessons, courses and users soft deleted before the given time, returns their IDs.
+// Foreign keys are not enforced, so the rows that depend on them are deleted here as the schema's
+// ON DELETE clauses describe: the lessons of a purged course go with it, and so do enrollments,
+// progress, reviews, quizzes, assignments and the rest of the learning data. Orders, invoices,
+// refunds, subscriptions, seat purchases and certificates are records of what happened, they are kept.
+func (r *CourseRepository) PurgeDeleted(before time.Time) (entities.PurgedRows, error) {
+	var purged entities.PurgedRows
+	var err error
+	gone := "deleted_at IS NOT NULL AND deleted_at < ?"
+	if purged.CourseIDs, err = r.queryIDs("SELECT id FROM courses WHERE "+gone+" ORDER BY id", before.UTC()); err != nil {
+		return purged, err
+	}
+	if purged.UserIDs, err = r.queryIDs("SELECT id FROM users WHERE "+gone+" ORDER BY id", before.UTC()); err != nil {
+		return purged, err
+	}
+	courses, users := idSet{"course_id", purged.CourseIDs}, idSet{"user_id", purged.UserIDs}
+	condition, args := idCondition(courses)
+	if purged.LessonIDs, err = r.queryIDs("SELECT id FROM lessons WHERE ("+gone+") OR "+condition+" ORDER BY id", append([]interface{}{before.UTC()}, args...)...); err != nil {
+		return purged, err
+	}
+	lessons := idSet{"lesson_id", purged.LessonIDs}
+
+	enrollments, err := r.idsWhere("enrollments", users, courses)
+	if err != nil {
+		return purged, err
+	}
+	reviews, err := r.idsWhere("reviews", users, courses)
+	if err != nil {
+		return purged, err
+	}
+	quizzes, err := r.idsWhere("quizzes", lessons)
+	if err != nil {
+		return purged, err
+	}
+	banks, err := r.idsWhere("question_banks", courses)
+	if err != nil {
+		return purged, err
+	}
+	assignments, err := r.idsWhere("assignments", courses)
+	if err != nil {
+		return purged, err
+	}
+	coupons, err := r.idsWhere("coupons", courses)
+	if err != nil {
+		return purged, err
+	}
+	submissions, err := r.idsWhere("submissions", idSet{"enrollment_id", enrollments}, idSet{"assignment_id", assignments})
+	if err != nil {
+		return purged, err
+	}
+
+	if len(purged.LessonIDs) > 0 {
+		condition, args := idCondition(lessons)
+		if _, err := r.DB.Exec("UPDATE assignments SET lesson_id = NULL WHERE "+condition, args...); err != nil {
+			return purged, err
+		}
+	}
+	deletes := []struct {
+		table string
+		sets  []idSet
+	}{
+		{"submission_files", []idSet{{"submission_id", submissions}}},
+		{"submissions", []idSet{{"id", submissions}}},
+		{"quiz_attempts", []idSet{{"enrollment_id", enrollments}, {"quiz_id", quizzes}}},
+		{"progress", []idSet{{"enrollment_id", enrollments}, lessons}},
+		{"enrollments", []idSet{{"id", enrollments}}},
+		{"review_flags", []idSet{{"review_id", reviews}, users}},
+		{"reviews", []idSet{{"id", reviews}}},
+		{"quizzes", []idSet{{"id", quizzes}}},
+		{"questions", []idSet{{"bank_id", banks}}},
+		{"question_banks", []idSet{{"id", banks}}},
+		{"assignments", []idSet{{"id", assignments}}},
+		{"sections", []idSet{courses}},
+		{"course_versions", []idSet{courses}},
+		{"course_prerequisites", []idSet{courses, {"prerequisite_id", purged.CourseIDs}}},
+		{"learning_path_courses", []idSet{courses}},
+		{"grade_weights", []idSet{courses}},
+		{"course_prices", []idSet{courses}},
+		{"cart_items", []idSet{users, courses}},
+		{"cart_coupons", []idSet{users, {"coupon_id", coupons}}},
+		{"coupons", []idSet{{"id", coupons}}},
+		{"billing_details", []idSet{users}},
+		{"notification_preferences", []idSet{users}},
+		{"organization_members", []idSet{users}},
+		{"organization_invitations", []idSet{users, {"invited_by", purged.UserIDs}}},
+		{"outbox_emails", []idSet{users}},
+		{"sessions", []idSet{users}},
+		{"lessons", []idSet{{"id", purged.LessonIDs}}},
+		{"courses", []idSet{{"id", purged.CourseIDs}}},
+		{"users", []idSet{{"id", purged.UserIDs}}},
+	}
+	for _, target := range deletes {
+		condition, args := idCondition(target.sets...)
+		if _, err := r.DB.Exec("DELETE FROM "+target.table+" WHERE "+condition, args...); err != nil {
+			return purged, err
+		}
+	}
+	return purged, nil
+}
+
+// idSet is a column and the IDs it is matched against
+type idSet struct {
+	column string
+	ids    []uint
+}
+
+// idCondition matches rows whose column is one of the IDs of any of the sets, no rows when they are empty
+func idCondition(sets ...idSet) (string, []interface{}) {
+	conditions, args := []string{}, []interface{}{}
+	for _, set := range sets {
+		if len(set.ids) == 0 {
+			continue
+		}
+		conditions = append(conditions, set.column+" IN ("+strings.TrimSuffix(strings.Repeat("?, ", len(set.ids)), ", ")+")")
+		for _, id := range set.ids {
+			args = append(args, id)
+		}
+	}
+	if len(conditions) == 0 {
+		return "0", nil
+	}
+	return "(" + strings.Join(conditions, " OR ") + ")", args
+}
+
+// idsWhere returns the IDs of the rows of table matching any of the sets
+func (r *CourseRepository) idsWhere(table string, sets ...idSet) ([]uint, error) {
+	condition, args := idCondition(sets...)
+	return r.queryIDs("SELECT id FROM "+table+" WHERE "+condition, args...)
+}
+
+// notDeleted returns the filter excluding soft deleted rows
+func notDeleted(includeDeleted bool) string {
+	if includeDeleted {
+		return ""
+	}
+	return " AND deleted_at IS NULL"
+}
+
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func uintPtr(n sql.NullInt64) *uint {
+	if !n.Valid {
+		return nil
+	}
+	v := uint(n.Int64)
+	return &v
+}
+
+// queryIDs returns the IDs a query selects
+func (r *CourseRepository) queryIDs(query string, args ...interface{}) ([]uint, error) {
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []uint{}
+	for rows.Next() {
+		var id uint
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func requireAffected(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
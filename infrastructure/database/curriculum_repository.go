@@ -0,0 +1,161 @@
+package database
+
+import (
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+//----------------------------------------------------------------curriculum----------------------------------------------------------------
+
+// renumbering rewrites "order" as 1, 2, ... without gaps. Lessons are ranked by the position of
+// their section, lessons outside any section first, then by their current order.
+const (
+	renumberSections = `UPDATE sections SET "order" = ranked.position FROM (
+		SELECT id, ROW_NUMBER() OVER (ORDER BY "order", id) AS position FROM sections WHERE course_id = ?
+	) AS ranked WHERE sections.id = ranked.id`
+	renumberLessons = `UPDATE lessons SET "order" = ranked.position FROM (
+		SELECT l.id, ROW_NUMBER() OVER (ORDER BY COALESCE(s."order", 0), l."order", l.id) AS position
+		FROM lessons l LEFT JOIN sections s ON s.id = l.section_id
+		WHERE l.course_id = ? AND l.deleted_at IS NULL
+	) AS ranked WHERE lessons.id = ranked.id`
+)
+
+// Create a new section at the end of its course
+func (r *CourseRepository) AddSection(section entities.Section) (entities.Section, error) {
+	query := `INSERT INTO sections (course_id, title, "order")
+		SELECT ?, ?, COALESCE(MAX("order"), 0) + 1 FROM sections WHERE course_id = ?`
+	result, err := r.DB.Exec(query, section.CourseID, section.Title, section.CourseID)
+	if err != nil {
+		return entities.Section{}, err
+	}
+	id, _ := result.LastInsertId()
+	return r.GetSectionByID(int(id))
+}
+
+// Get a section by ID
+func (r *CourseRepository) GetSectionByID(id int) (entities.Section, error) {
+	var section entities.Section
+	query := `SELECT id, course_id, title, "order" FROM sections WHERE id = ?`
+	err := r.DB.QueryRow(query, id).Scan(&section.ID, &section.CourseID, &section.Title, &section.Order)
+	return section, err
+}
+
+// Get the sections of a course in order
+func (r *CourseRepository) GetSectionsByCourseID(courseID int) ([]entities.Section, error) {
+	query := `SELECT id, course_id, title, "order" FROM sections WHERE course_id = ? ORDER BY "order"`
+	rows, err := r.DB.Query(query, courseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sections []entities.Section
+	for rows.Next() {
+		var section entities.Section
+		if err := rows.Scan(&section.ID, &section.CourseID, &section.Title, &section.Order); err != nil {
+			return nil, err
+		}
+		sections = append(sections, section)
+	}
+	return sections, rows.Err()
+}
+
+// Rename a section
+func (r *CourseRepository) UpdateSection(section entities.Section) (entities.Section, error) {
+	result, err := r.DB.Exec("UPDATE sections SET title = ? WHERE id = ?", section.Title, section.ID)
+	if err != nil {
+		return entities.Section{}, err
+	}
+	if err := requireAffected(result); err != nil {
+		return entities.Section{}, err
+	}
+	return r.GetSectionByID(int(section.ID))
+}
+
+// Delete a section, soft deleted lessons still pointing at it move out of it
+func (r *CourseRepository) DeleteSection(id int) error {
+	section, err := r.GetSectionByID(id)
+	if err != nil {
+		return err
+	}
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE lessons SET section_id = NULL WHERE section_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM sections WHERE id = ?", id); err != nil {
+		return err
+	}
+	if err := renumberCurriculum(tx, int(section.CourseID)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Rewrite the order of the sections and live lessons of a course without gaps
+func (r *CourseRepository) RenumberCurriculum(courseID int) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := renumberCurriculum(tx, courseID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func renumberCurriculum(tx querier, courseID int) error {
+	if _, err := tx.Exec(renumberSections, courseID); err != nil {
+		return err
+	}
+	_, err := tx.Exec(renumberLessons, courseID)
+	return err
+}
+
+// Rewrite the whole curriculum of a course in one transaction. The order must list every
+// section and live lesson of the course, the use case validates it.
+func (r *CourseRepository) ReorderCurriculum(courseID int, order entities.CurriculumOrder) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	position := 0
+	moveLesson := func(lessonID uint, sectionID *uint) error {
+		position++
+		query := `UPDATE lessons SET section_id = ?, "order" = ? WHERE id = ? AND course_id = ? AND deleted_at IS NULL`
+		result, err := tx.Exec(query, sectionID, position, lessonID, courseID)
+		if err != nil {
+			return err
+		}
+		return requireAffected(result)
+	}
+
+	for _, lessonID := range order.Lessons {
+		if err := moveLesson(lessonID, nil); err != nil {
+			return err
+		}
+	}
+	for i, section := range order.Sections {
+		sectionID := section.ID
+		result, err := tx.Exec(`UPDATE sections SET "order" = ? WHERE id = ? AND course_id = ?`, i+1, sectionID, courseID)
+		if err != nil {
+			return err
+		}
+		if err := requireAffected(result); err != nil {
+			return err
+		}
+		for _, lessonID := range section.Lessons {
+			if err := moveLesson(lessonID, &sectionID); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
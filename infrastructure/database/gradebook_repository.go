@@ -0,0 +1,114 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+//----------------------------------------------------------------grade weights----------------------------------------------------------------
+
+// Get the grade weights of a course, sql.ErrNoRows when they were never set
+func (r *CourseRepository) GetGradeWeights(courseID int) (entities.GradeWeights, error) {
+	var weights entities.GradeWeights
+	err := r.DB.QueryRow("SELECT course_id, lessons, quizzes, assignments FROM grade_weights WHERE course_id = ?", courseID).
+		Scan(&weights.CourseID, &weights.Lessons, &weights.Quizzes, &weights.Assignments)
+	return weights, err
+}
+
+// Set the grade weights of a course
+func (r *CourseRepository) SetGradeWeights(weights entities.GradeWeights) error {
+	query := `INSERT INTO grade_weights (course_id, lessons, quizzes, assignments) VALUES (?, ?, ?, ?)
+		ON CONFLICT (course_id) DO UPDATE SET lessons = excluded.lessons, quizzes = excluded.quizzes, assignments = excluded.assignments`
+	_, err := r.DB.Exec(query, weights.CourseID, weights.Lessons, weights.Quizzes, weights.Assignments)
+	return err
+}
+
+//----------------------------------------------------------------gradebook----------------------------------------------------------------
+
+// the enrollments of a course, of one enrollment when enrollmentID is not 0. Its arguments are
+// the course ID and, when filtered, the enrollment ID.
+func courseEnrollments(enrollmentID int) string {
+	if enrollmentID != 0 {
+		return "enrollment_id IN (SELECT id FROM enrollments WHERE course_id = ? AND id = ?)"
+	}
+	return "enrollment_id IN (SELECT id FROM enrollments WHERE course_id = ?)"
+}
+
+func courseEnrollmentArgs(courseID, enrollmentID int) []interface{} {
+	if enrollmentID != 0 {
+		return []interface{}{courseID, enrollmentID}
+	}
+	return []interface{}{courseID}
+}
+
+// Get the users enrolled in a course, deleted ones included
+func (r *CourseRepository) GetCourseStudents(courseID int) ([]entities.User, error) {
+	query := `SELECT u.id, u.first_name, u.last_name, u.email, u.role, u.bio, u.deleted_at
+		FROM users u JOIN enrollments e ON e.user_id = u.id WHERE e.course_id = ? ORDER BY u.id`
+	rows, err := r.DB.Query(query, courseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []entities.User
+	for rows.Next() {
+		var user entities.User
+		var deletedAt sql.NullTime
+		if err := rows.Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.Role, &user.Bio, &deletedAt); err != nil {
+			return nil, err
+		}
+		user.DeletedAt = timePtr(deletedAt)
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// Get the progress rows of the enrollments of a course, of one enrollment when enrollmentID is not 0
+func (r *CourseRepository) GetCourseProgress(courseID, enrollmentID int) ([]entities.Progress, error) {
+	query := "SELECT id, enrollment_id, lesson_id, completed, updated_at FROM progress WHERE " + courseEnrollments(enrollmentID)
+	rows, err := r.DB.Query(query, courseEnrollmentArgs(courseID, enrollmentID)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var progresses []entities.Progress
+	for rows.Next() {
+		var progress entities.Progress
+		var updatedAt sql.NullTime
+		err := rows.Scan(&progress.ID, &progress.EnrollmentID, &progress.LessonID, &progress.Completed, &updatedAt)
+		if err != nil {
+			return nil, err
+		}
+		progress.UpdatedAt = timePtr(updatedAt)
+		progresses = append(progresses, progress)
+	}
+	return progresses, rows.Err()
+}
+
+// Get the quiz attempts of the enrollments of a course, of one enrollment when enrollmentID is not 0, oldest first
+func (r *CourseRepository) GetCourseQuizAttempts(courseID, enrollmentID int) ([]entities.QuizAttempt, error) {
+	query := "SELECT " + attemptColumns + " FROM quiz_attempts WHERE " + courseEnrollments(enrollmentID) + " ORDER BY id"
+	rows, err := r.DB.Query(query, courseEnrollmentArgs(courseID, enrollmentID)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attempts []entities.QuizAttempt
+	for rows.Next() {
+		attempt, err := scanAttempt(rows)
+		if err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, attempt)
+	}
+	return attempts, rows.Err()
+}
+
+// Get the submissions of the enrollments of a course, of one enrollment when enrollmentID is not 0, oldest first
+func (r *CourseRepository) GetCourseSubmissions(courseID, enrollmentID int) ([]entities.Submission, error) {
+	return r.querySubmissions(courseEnrollments(enrollmentID), courseEnrollmentArgs(courseID, enrollmentID)...)
+}
@@ -0,0 +1,126 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+//----------------------------------------------------------------invoices----------------------------------------------------------------
+
+const invoiceColumns = "id, sequence, order_id, user_id, currency, seller, buyer, lines, taxes, subtotal_amount, tax_amount, total_amount, " +
+	"payment_method, payment_id, paid_at, issued_at, emailed_at"
+
+func scanInvoice(row scanner) (entities.Invoice, error) {
+	var invoice entities.Invoice
+	var sequence uint
+	var seller, buyer, lines, taxes string
+	var emailedAt sql.NullTime
+	err := row.Scan(&invoice.ID, &sequence, &invoice.OrderID, &invoice.UserID, &invoice.Currency, &seller, &buyer, &lines, &taxes,
+		&invoice.Subtotal.Amount, &invoice.Tax.Amount, &invoice.Total.Amount, &invoice.PaymentMethod, &invoice.PaymentID,
+		&invoice.PaidAt, &invoice.IssuedAt, &emailedAt)
+	if err != nil {
+		return entities.Invoice{}, err
+	}
+	for _, field := range []struct {
+		raw    string
+		target interface{}
+	}{{seller, &invoice.Seller}, {buyer, &invoice.Buyer}, {lines, &invoice.Lines}, {taxes, &invoice.Taxes}} {
+		if err := json.Unmarshal([]byte(field.raw), field.target); err != nil {
+			return entities.Invoice{}, err
+		}
+	}
+	invoice.Number = entities.InvoiceNumber(sequence)
+	invoice.Subtotal.Currency = invoice.Currency
+	invoice.Tax.Currency = invoice.Currency
+	invoice.Total.Currency = invoice.Currency
+	invoice.EmailedAt = timePtr(emailedAt)
+	return invoice, nil
+}
+
+func (r *CourseRepository) queryInvoices(where string, args ...interface{}) ([]entities.Invoice, error) {
+	rows, err := r.DB.Query("SELECT "+invoiceColumns+" FROM invoices "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invoices := []entities.Invoice{}
+	for rows.Next() {
+		invoice, err := scanInvoice(rows)
+		if err != nil {
+			return nil, err
+		}
+		invoices = append(invoices, invoice)
+	}
+	return invoices, rows.Err()
+}
+
+// Store an invoice under the next invoice number, the number is taken in the insert itself
+// so concurrent invoices cannot share one or leave a gap. An order gets one invoice.
+func (r *CourseRepository) AddInvoice(invoice entities.Invoice) (entities.Invoice, error) {
+	encoded := make([][]byte, 0, 4)
+	for _, value := range []interface{}{invoice.Seller, invoice.Buyer, invoice.Lines, invoice.Taxes} {
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return entities.Invoice{}, err
+		}
+		encoded = append(encoded, raw)
+	}
+	query := `INSERT INTO invoices (sequence, order_id, user_id, currency, seller, buyer, lines, taxes, subtotal_amount, tax_amount, total_amount,
+		payment_method, payment_id, paid_at, issued_at)
+		SELECT COALESCE(MAX(sequence), 0) + 1, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ? FROM invoices`
+	result, err := r.DB.Exec(query, invoice.OrderID, invoice.UserID, invoice.Currency, string(encoded[0]), string(encoded[1]), string(encoded[2]),
+		string(encoded[3]), invoice.Subtotal.Amount, invoice.Tax.Amount, invoice.Total.Amount, invoice.PaymentMethod, invoice.PaymentID,
+		invoice.PaidAt, invoice.IssuedAt)
+	if err != nil {
+		return entities.Invoice{}, err
+	}
+	id, _ := result.LastInsertId()
+	return r.GetInvoiceByID(int(id))
+}
+
+// Get an invoice by ID
+func (r *CourseRepository) GetInvoiceByID(id int) (entities.Invoice, error) {
+	return scanInvoice(r.DB.QueryRow("SELECT "+invoiceColumns+" FROM invoices WHERE id = ?", id))
+}
+
+// Get the invoice of an order
+func (r *CourseRepository) GetInvoiceByOrderID(orderID int) (entities.Invoice, error) {
+	return scanInvoice(r.DB.QueryRow("SELECT "+invoiceColumns+" FROM invoices WHERE order_id = ?", orderID))
+}
+
+// Get the invoices of a user, newest first
+func (r *CourseRepository) GetInvoicesByUserID(userID int) ([]entities.Invoice, error) {
+	return r.queryInvoices("WHERE user_id = ? ORDER BY sequence DESC", userID)
+}
+
+// Record when an invoice was last emailed
+func (r *CourseRepository) SetInvoiceEmailed(id int, at time.Time) error {
+	result, err := r.DB.Exec("UPDATE invoices SET emailed_at = ? WHERE id = ?", at, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+//----------------------------------------------------------------billing details----------------------------------------------------------------
+
+// Get the billing details of a user
+func (r *CourseRepository) GetBillingDetails(userID int) (entities.BillingDetails, error) {
+	var details entities.BillingDetails
+	err := r.DB.QueryRow("SELECT user_id, company, address, tax_id, email, updated_at FROM billing_details WHERE user_id = ?", userID).
+		Scan(&details.UserID, &details.Company, &details.Address, &details.TaxID, &details.Email, &details.UpdatedAt)
+	return details, err
+}
+
+// Create or replace the billing details of a user
+func (r *CourseRepository) SetBillingDetails(details entities.BillingDetails) error {
+	query := `INSERT INTO billing_details (user_id, company, address, tax_id, email, updated_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET company = excluded.company, address = excluded.address, tax_id = excluded.tax_id,
+		email = excluded.email, updated_at = excluded.updated_at`
+	_, err := r.DB.Exec(query, details.UserID, details.Company, details.Address, details.TaxID, details.Email, details.UpdatedAt)
+	return err
+}
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+//----------------------------------------------------------------moderation----------------------------------------------------------------
+
+// Set or replace the instructor reply of a review
+func (r *CourseRepository) SetReviewReply(reviewID int, reply entities.ReviewReply) error {
+	query := "UPDATE reviews SET reply_user_id = ?, reply_body = ?, reply_at = ? WHERE id = ?"
+	result, err := r.DB.Exec(query, reply.UserID, reply.Body, reply.CreatedAt.UTC(), reviewID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// Record a user flagging a review
+func (r *CourseRepository) AddReviewFlag(flag entities.ReviewFlag) (entities.ReviewFlag, error) {
+	query := "INSERT INTO review_flags (review_id, user_id, reason, created_at) VALUES (?, ?, ?, ?)"
+	result, err := r.DB.Exec(query, flag.ReviewID, flag.UserID, flag.Reason, flag.CreatedAt.UTC())
+	if err != nil {
+		return entities.ReviewFlag{}, err
+	}
+	id, _ := result.LastInsertId()
+	flag.ID = uint(id)
+	return flag, nil
+}
+
+// Get the flag a user raised on a review
+func (r *CourseRepository) GetReviewFlag(reviewID, userID int) (entities.ReviewFlag, error) {
+	query := "SELECT id, review_id, user_id, reason, created_at FROM review_flags WHERE review_id = ? AND user_id = ?"
+	var flag entities.ReviewFlag
+	err := r.DB.QueryRow(query, reviewID, userID).Scan(&flag.ID, &flag.ReviewID, &flag.UserID, &flag.Reason, &flag.CreatedAt)
+	if err != nil {
+		return entities.ReviewFlag{}, err
+	}
+	return flag, nil
+}
+
+// Get every flag raised on a review
+func (r *CourseRepository) GetReviewFlags(reviewID int) ([]entities.ReviewFlag, error) {
+	query := "SELECT id, review_id, user_id, reason, created_at FROM review_flags WHERE review_id = ? ORDER BY id"
+	rows, err := r.DB.Query(query, reviewID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var flags []entities.ReviewFlag
+	for rows.Next() {
+		var flag entities.ReviewFlag
+		if err := rows.Scan(&flag.ID, &flag.ReviewID, &flag.UserID, &flag.Reason, &flag.CreatedAt); err != nil {
+			return nil, err
+		}
+		flags = append(flags, flag)
+	}
+	return flags, rows.Err()
+}
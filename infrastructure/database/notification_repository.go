@@ -0,0 +1,128 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+//----------------------------------------------------------------notification preferences----------------------------------------------------------------
+
+// Get the notification preferences of a user, sql.ErrNoRows when they never set any
+func (r *CourseRepository) GetNotificationPreferences(userID int) (entities.NotificationPreferences, error) {
+	var preferences entities.NotificationPreferences
+	var updatedAt time.Time
+	err := r.DB.QueryRow("SELECT user_id, enrollment, completion, reviews, updated_at FROM notification_preferences WHERE user_id = ?", userID).
+		Scan(&preferences.UserID, &preferences.Enrollment, &preferences.Completion, &preferences.Reviews, &updatedAt)
+	preferences.UpdatedAt = &updatedAt
+	return preferences, err
+}
+
+// Create or replace the notification preferences of a user
+func (r *CourseRepository) SetNotificationPreferences(preferences entities.NotificationPreferences) error {
+	query := `INSERT INTO notification_preferences (user_id, enrollment, completion, reviews, updated_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET enrollment = excluded.enrollment, completion = excluded.completion, reviews = excluded.reviews,
+		updated_at = excluded.updated_at`
+	_, err := r.DB.Exec(query, preferences.UserID, preferences.Enrollment, preferences.Completion, preferences.Reviews, preferences.UpdatedAt)
+	return err
+}
+
+// Get the instructors teaching under a course's instructor name
+func (r *CourseRepository) GetInstructorsByName(name string) ([]entities.User, error) {
+	query := `SELECT id, first_name, last_name, email, role, bio FROM users
+		WHERE role = 'instructor' AND deleted_at IS NULL AND TRIM(first_name || ' ' || last_name) = TRIM(?) COLLATE NOCASE ORDER BY id`
+	rows, err := r.DB.Query(query, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []entities.User{}
+	for rows.Next() {
+		var user entities.User
+		if err := rows.Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.Role, &user.Bio); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+//----------------------------------------------------------------outbox----------------------------------------------------------------
+
+const outboxColumns = "id, kind, user_id, recipient, subject, text_body, html_body, status, attempts, last_error, next_attempt_at, created_at, sent_at"
+
+func scanOutboxEmail(row scanner) (entities.OutboxEmail, error) {
+	var email entities.OutboxEmail
+	var sentAt sql.NullTime
+	err := row.Scan(&email.ID, &email.Kind, &email.UserID, &email.To, &email.Subject, &email.Text, &email.HTML, &email.Status,
+		&email.Attempts, &email.LastError, &email.NextAttemptAt, &email.CreatedAt, &sentAt)
+	email.SentAt = timePtr(sentAt)
+	return email, err
+}
+
+// Put an email in the outbox
+func (r *CourseRepository) AddOutboxEmail(email entities.OutboxEmail) (entities.OutboxEmail, error) {
+	query := `INSERT INTO outbox_emails (kind, user_id, recipient, subject, text_body, html_body, status, attempts, last_error, next_attempt_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := r.DB.Exec(query, email.Kind, email.UserID, email.To, email.Subject, email.Text, email.HTML, email.Status, email.Attempts,
+		email.LastError, email.NextAttemptAt, email.CreatedAt)
+	if err != nil {
+		return entities.OutboxEmail{}, err
+	}
+	id, _ := result.LastInsertId()
+	email.ID = uint(id)
+	return email, nil
+}
+
+// Get an outbox email by ID
+func (r *CourseRepository) GetOutboxEmailByID(id int) (entities.OutboxEmail, error) {
+	return scanOutboxEmail(r.DB.QueryRow("SELECT "+outboxColumns+" FROM outbox_emails WHERE id = ?", id))
+}
+
+// Get the outbox emails with a status, newest first, every status when it is empty
+func (r *CourseRepository) GetOutboxEmails(status string, limit int) ([]entities.OutboxEmail, error) {
+	query := "SELECT " + outboxColumns + " FROM outbox_emails"
+	args := []interface{}{}
+	if status != "" {
+		query += " WHERE status = ?"
+		args = append(args, status)
+	}
+	args = append(args, limit)
+	return r.queryOutboxEmails(query+" ORDER BY id DESC LIMIT ?", args...)
+}
+
+// Get the pending outbox emails due to be sent at now, oldest first
+func (r *CourseRepository) GetDueOutboxEmails(now time.Time, limit int) ([]entities.OutboxEmail, error) {
+	return r.queryOutboxEmails("SELECT "+outboxColumns+" FROM outbox_emails WHERE status = ? AND next_attempt_at <= ? ORDER BY id LIMIT ?",
+		entities.OutboxPending, now, limit)
+}
+
+func (r *CourseRepository) queryOutboxEmails(query string, args ...interface{}) ([]entities.OutboxEmail, error) {
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	emails := []entities.OutboxEmail{}
+	for rows.Next() {
+		email, err := scanOutboxEmail(rows)
+		if err != nil {
+			return nil, err
+		}
+		emails = append(emails, email)
+	}
+	return emails, rows.Err()
+}
+
+// Record the outcome of sending an outbox email
+func (r *CourseRepository) UpdateOutboxEmail(email entities.OutboxEmail) error {
+	query := "UPDATE outbox_emails SET status = ?, attempts = ?, last_error = ?, next_attempt_at = ?, sent_at = ? WHERE id = ?"
+	result, err := r.DB.Exec(query, email.Status, email.Attempts, email.LastError, email.NextAttemptAt, email.SentAt, email.ID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
@@ -0,0 +1,267 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+//----------------------------------------------------------------cart----------------------------------------------------------------
+
+// Get the courses in a user's cart with their current title and base price
+func (r *CourseRepository) GetCartItems(userID int) ([]entities.CartItem, error) {
+	query := `SELECT c.id, c.title, c.price_amount, c.price_currency, ci.added_at FROM cart_items ci
+		JOIN courses c ON c.id = ci.course_id
+		WHERE ci.user_id = ? AND c.deleted_at IS NULL ORDER BY ci.added_at, c.id`
+	rows, err := r.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []entities.CartItem{}
+	for rows.Next() {
+		var item entities.CartItem
+		if err := rows.Scan(&item.CourseID, &item.Title, &item.Price.Amount, &item.Price.Currency, &item.AddedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// Put a course in a user's cart, adding it again is a no-op
+func (r *CourseRepository) AddCartItem(userID, courseID int, at time.Time) error {
+	_, err := r.DB.Exec("INSERT INTO cart_items (user_id, course_id, added_at) VALUES (?, ?, ?) ON CONFLICT DO NOTHING", userID, courseID, at)
+	return err
+}
+
+// Take a course out of a user's cart
+func (r *CourseRepository) RemoveCartItem(userID, courseID int) error {
+	result, err := r.DB.Exec("DELETE FROM cart_items WHERE user_id = ? AND course_id = ?", userID, courseID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+//----------------------------------------------------------------orders----------------------------------------------------------------
+
+const orderColumns = "id, user_id, status, currency, total_amount, discount_amount, payment_method, payment_id, action_url, failure_reason, created_at, paid_at, " +
+	"(SELECT COALESCE(SUM(amount), 0) FROM refunds WHERE refunds.order_id = orders.id AND refunds.status = 'refunded')"
+
+func scanOrder(row scanner) (entities.Order, error) {
+	var order entities.Order
+	var paidAt sql.NullTime
+	err := row.Scan(&order.ID, &order.UserID, &order.Status, &order.Currency, &order.Total.Amount, &order.Discount.Amount, &order.PaymentMethod,
+		&order.PaymentID, &order.ActionURL, &order.FailureReason, &order.CreatedAt, &paidAt, &order.Refunded.Amount)
+	if err != nil {
+		return entities.Order{}, err
+	}
+	order.PaidAt = timePtr(paidAt)
+	order.Total.Currency = order.Currency
+	order.Discount.Currency = order.Currency
+	order.Refunded.Currency = order.Currency
+	order.Subtotal = order.Total.Add(order.Discount)
+	return order, nil
+}
+
+// Create an order with its items and the redemptions of its coupons
+func (r *CourseRepository) AddOrder(order entities.Order) (entities.Order, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return entities.Order{}, err
+	}
+	defer tx.Rollback()
+
+	query := "INSERT INTO orders (user_id, status, currency, total_amount, discount_amount, payment_method, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)"
+	result, err := tx.Exec(query, order.UserID, order.Status, order.Currency, order.Total.Amount, order.Discount.Amount, order.PaymentMethod, order.CreatedAt)
+	if err != nil {
+		return entities.Order{}, err
+	}
+	id, _ := result.LastInsertId()
+	for _, item := range order.Items {
+		query := "INSERT INTO order_items (order_id, course_id, title, price_amount, discount_amount) VALUES (?, ?, ?, ?, ?)"
+		if _, err := tx.Exec(query, id, item.CourseID, item.Title, item.Price.Amount, item.Discount.Amount); err != nil {
+			return entities.Order{}, err
+		}
+	}
+	for _, coupon := range order.Coupons {
+		query := "INSERT INTO coupon_redemptions (coupon_id, order_id, user_id, discount_amount, redeemed_at) VALUES (?, ?, ?, ?, ?)"
+		if _, err := tx.Exec(query, coupon.CouponID, id, order.UserID, coupon.Discount.Amount, order.CreatedAt); err != nil {
+			return entities.Order{}, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return entities.Order{}, err
+	}
+	return r.GetOrderByID(int(id))
+}
+
+// Get an order by ID with its items
+func (r *CourseRepository) GetOrderByID(id int) (entities.Order, error) {
+	order, err := scanOrder(r.DB.QueryRow("SELECT "+orderColumns+" FROM orders WHERE id = ?", id))
+	if err != nil {
+		return entities.Order{}, err
+	}
+	if err := r.loadOrderLines(&order); err != nil {
+		return entities.Order{}, err
+	}
+	return order, nil
+}
+
+// Get the orders of a user, newest first
+func (r *CourseRepository) GetOrdersByUserID(userID int) ([]entities.Order, error) {
+	rows, err := r.DB.Query("SELECT "+orderColumns+" FROM orders WHERE user_id = ? ORDER BY id DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	orders := []entities.Order{}
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range orders {
+		if err := r.loadOrderLines(&orders[i]); err != nil {
+			return nil, err
+		}
+	}
+	return orders, nil
+}
+
+// Get the order of a user that is still waiting for its payment
+func (r *CourseRepository) GetOpenOrder(userID int) (entities.Order, error) {
+	query := "SELECT " + orderColumns + " FROM orders WHERE user_id = ? AND status IN (?, ?) ORDER BY id DESC LIMIT 1"
+	order, err := scanOrder(r.DB.QueryRow(query, userID, entities.OrderPending, entities.OrderRequiresAction))
+	if err != nil {
+		return entities.Order{}, err
+	}
+	if err := r.loadOrderLines(&order); err != nil {
+		return entities.Order{}, err
+	}
+	return order, nil
+}
+
+// loadOrderLines reads the items and coupons of an order
+func (r *CourseRepository) loadOrderLines(order *entities.Order) error {
+	var err error
+	if order.Items, err = r.getOrderItems(int(order.ID), order.Currency); err != nil {
+		return err
+	}
+	query := `SELECT cr.coupon_id, c.code, cr.discount_amount FROM coupon_redemptions cr
+		JOIN coupons c ON c.id = cr.coupon_id WHERE cr.order_id = ? ORDER BY cr.id`
+	rows, err := r.DB.Query(query, order.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	order.Coupons = []entities.AppliedCoupon{}
+	for rows.Next() {
+		coupon := entities.AppliedCoupon{Discount: entities.Money{Currency: order.Currency}}
+		if err := rows.Scan(&coupon.CouponID, &coupon.Code, &coupon.Discount.Amount); err != nil {
+			return err
+		}
+		order.Coupons = append(order.Coupons, coupon)
+	}
+	return rows.Err()
+}
+
+func (r *CourseRepository) getOrderItems(orderID int, currency string) ([]entities.OrderItem, error) {
+	rows, err := r.DB.Query("SELECT id, order_id, course_id, title, price_amount, discount_amount, enrollment_id FROM order_items WHERE order_id = ? ORDER BY id", orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []entities.OrderItem{}
+	for rows.Next() {
+		item := entities.OrderItem{Price: entities.Money{Currency: currency}, Discount: entities.Money{Currency: currency}}
+		var enrollmentID sql.NullInt64
+		if err := rows.Scan(&item.ID, &item.OrderID, &item.CourseID, &item.Title, &item.Price.Amount, &item.Discount.Amount, &enrollmentID); err != nil {
+			return nil, err
+		}
+		item.EnrollmentID = uintPtr(enrollmentID)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// Update the payment state of an order that is still open, returns sql.ErrNoRows once it is closed
+func (r *CourseRepository) UpdateOrderPayment(order entities.Order) error {
+	query := "UPDATE orders SET status = ?, payment_id = ?, action_url = ?, failure_reason = ? WHERE id = ? AND status IN (?, ?)"
+	result, err := r.DB.Exec(query, order.Status, order.PaymentID, order.ActionURL, order.FailureReason, order.ID,
+		entities.OrderPending, entities.OrderRequiresAction)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// Mark an open order as paid and enroll its buyer in one transaction. Courses the buyer is
+// already enrolled in keep their enrollment, the new enrollments are returned.
+// Returns sql.ErrNoRows if the order is no longer open, so a repeated confirmation changes nothing.
+func (r *CourseRepository) FulfilOrder(orderID int, paymentID string, paidAt time.Time, enrollments []entities.Enrollment) ([]entities.Enrollment, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := "UPDATE orders SET status = ?, payment_id = ?, action_url = '', failure_reason = '', paid_at = ? WHERE id = ? AND status IN (?, ?)"
+	result, err := tx.Exec(query, entities.OrderPaid, paymentID, paidAt, orderID, entities.OrderPending, entities.OrderRequiresAction)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireAffected(result); err != nil {
+		return nil, err
+	}
+
+	created := []entities.Enrollment{}
+	for _, enrollment := range enrollments {
+		var id int64
+		err := tx.QueryRow("SELECT id FROM enrollments WHERE user_id = ? AND course_id = ?", enrollment.UserID, enrollment.CourseID).Scan(&id)
+		if err == sql.ErrNoRows {
+			query := "INSERT INTO enrollments (user_id, course_id, completed, completed_at, course_version, enrolled_at) VALUES (?, ?, ?, ?, ?, ?)"
+			result, err := tx.Exec(query, enrollment.UserID, enrollment.CourseID, false, nil, enrollment.CourseVersion, enrollment.EnrolledAt)
+			if err != nil {
+				return nil, err
+			}
+			id, _ = result.LastInsertId()
+			enrollment.ID = uint(id)
+			created = append(created, enrollment)
+		} else if err != nil {
+			return nil, err
+		} else {
+			// bought again after a refund or leaving an organization revoked it, or bought after enrolling through a subscription
+			query := "UPDATE enrollments SET revoked_at = NULL, revoke_reason = '', subscription_id = NULL, seat_pool_id = NULL WHERE id = ?"
+			if _, err := tx.Exec(query, id); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := tx.Exec("UPDATE order_items SET enrollment_id = ? WHERE order_id = ? AND course_id = ?", id, orderID, enrollment.CourseID); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec("DELETE FROM cart_items WHERE user_id = ? AND course_id = ?", enrollment.UserID, enrollment.CourseID); err != nil {
+			return nil, err
+		}
+	}
+	// coupons applied to the cart have been used by this order
+	if _, err := tx.Exec("DELETE FROM cart_coupons WHERE user_id = (SELECT user_id FROM orders WHERE id = ?)", orderID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
@@ -0,0 +1,398 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+//----------------------------------------------------------------organizations----------------------------------------------------------------
+
+func (r *CourseRepository) queryOrganizations(query string, args ...interface{}) ([]entities.Organization, error) {
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	organizations := []entities.Organization{}
+	for rows.Next() {
+		var organization entities.Organization
+		if err := rows.Scan(&organization.ID, &organization.Name, &organization.CreatedAt); err != nil {
+			return nil, err
+		}
+		organizations = append(organizations, organization)
+	}
+	return organizations, rows.Err()
+}
+
+// Create an organization with its first admin, in one transaction
+func (r *CourseRepository) AddOrganization(organization entities.Organization, admin entities.OrganizationMember) (entities.Organization, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return entities.Organization{}, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT INTO organizations (name, created_at) VALUES (?, ?)", organization.Name, organization.CreatedAt)
+	if err != nil {
+		return entities.Organization{}, err
+	}
+	id, _ := result.LastInsertId()
+	organization.ID = uint(id)
+	query := "INSERT INTO organization_members (organization_id, user_id, role, joined_at) VALUES (?, ?, ?, ?)"
+	if _, err := tx.Exec(query, organization.ID, admin.UserID, admin.Role, admin.JoinedAt); err != nil {
+		return entities.Organization{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return entities.Organization{}, err
+	}
+	return organization, nil
+}
+
+// Get an organization by ID
+func (r *CourseRepository) GetOrganizationByID(id int) (entities.Organization, error) {
+	var organization entities.Organization
+	err := r.DB.QueryRow("SELECT id, name, created_at FROM organizations WHERE id = ?", id).Scan(&organization.ID, &organization.Name, &organization.CreatedAt)
+	return organization, err
+}
+
+// Get all organizations
+func (r *CourseRepository) GetOrganizations() ([]entities.Organization, error) {
+	return r.queryOrganizations("SELECT id, name, created_at FROM organizations ORDER BY id")
+}
+
+// Get the organizations a user is a member of
+func (r *CourseRepository) GetOrganizationsByUserID(userID int) ([]entities.Organization, error) {
+	query := `SELECT o.id, o.name, o.created_at FROM organizations o
+		JOIN organization_members m ON m.organization_id = o.id WHERE m.user_id = ? ORDER BY o.id`
+	return r.queryOrganizations(query, userID)
+}
+
+// Update the name of an organization
+func (r *CourseRepository) UpdateOrganization(organization entities.Organization) error {
+	result, err := r.DB.Exec("UPDATE organizations SET name = ? WHERE id = ?", organization.Name, organization.ID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+//----------------------------------------------------------------organization members----------------------------------------------------------------
+
+// members are read with the name and email of their user
+const memberColumns = "m.organization_id, m.user_id, m.role, m.joined_at, u.first_name || ' ' || u.last_name, u.email"
+
+const memberFrom = " FROM organization_members m JOIN users u ON u.id = m.user_id"
+
+func scanMember(row scanner) (entities.OrganizationMember, error) {
+	var member entities.OrganizationMember
+	err := row.Scan(&member.OrganizationID, &member.UserID, &member.Role, &member.JoinedAt, &member.Name, &member.Email)
+	return member, err
+}
+
+// Add a user to an organization
+func (r *CourseRepository) AddOrganizationMember(member entities.OrganizationMember) error {
+	query := "INSERT INTO organization_members (organization_id, user_id, role, joined_at) VALUES (?, ?, ?, ?)"
+	_, err := r.DB.Exec(query, member.OrganizationID, member.UserID, member.Role, member.JoinedAt)
+	return err
+}
+
+// Get the membership of a user in an organization
+func (r *CourseRepository) GetOrganizationMember(organizationID, userID int) (entities.OrganizationMember, error) {
+	return scanMember(r.DB.QueryRow("SELECT "+memberColumns+memberFrom+" WHERE m.organization_id = ? AND m.user_id = ?", organizationID, userID))
+}
+
+// Get the members of an organization in the order they joined
+func (r *CourseRepository) GetOrganizationMembers(organizationID int) ([]entities.OrganizationMember, error) {
+	rows, err := r.DB.Query("SELECT "+memberColumns+memberFrom+" WHERE m.organization_id = ? ORDER BY m.joined_at, m.user_id", organizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []entities.OrganizationMember{}
+	for rows.Next() {
+		member, err := scanMember(rows)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+// Change the role of a member. Demoting the last admin fails with sql.ErrNoRows, so an
+// organization always has someone to manage it.
+func (r *CourseRepository) SetOrganizationMemberRole(organizationID, userID int, role string) error {
+	query := `UPDATE organization_members SET role = ? WHERE organization_id = ? AND user_id = ?
+		AND (? = 'admin' OR role != 'admin' OR (SELECT COUNT(*) FROM organization_members WHERE organization_id = ? AND role = 'admin') > 1)`
+	result, err := r.DB.Exec(query, role, organizationID, userID, role, organizationID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// Remove a member from an organization and give back the seats they held: their enrollments made
+// with the organization's seats are revoked, in one transaction. It returns the revoked enrollments.
+// Removing the last admin fails with sql.ErrNoRows.
+func (r *CourseRepository) RemoveOrganizationMember(organizationID, userID int, at time.Time, reason string) ([]uint, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `DELETE FROM organization_members WHERE organization_id = ? AND user_id = ?
+		AND (role != 'admin' OR (SELECT COUNT(*) FROM organization_members WHERE organization_id = ? AND role = 'admin') > 1)`
+	result, err := tx.Exec(query, organizationID, userID, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireAffected(result); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(`SELECT id FROM enrollments WHERE user_id = ? AND revoked_at IS NULL
+		AND seat_pool_id IN (SELECT id FROM seat_pools WHERE organization_id = ?)`, userID, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	revoked := []uint{}
+	for rows.Next() {
+		var id uint
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		revoked = append(revoked, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, id := range revoked {
+		if _, err := tx.Exec("UPDATE enrollments SET revoked_at = ?, revoke_reason = ? WHERE id = ?", at, reason, id); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return revoked, nil
+}
+
+//----------------------------------------------------------------organization invitations----------------------------------------------------------------
+
+const invitationColumns = "id, organization_id, user_id, role, status, invited_by, created_at, responded_at"
+
+func scanInvitation(row scanner) (entities.OrganizationInvitation, error) {
+	var invitation entities.OrganizationInvitation
+	var respondedAt sql.NullTime
+	err := row.Scan(&invitation.ID, &invitation.OrganizationID, &invitation.UserID, &invitation.Role, &invitation.Status,
+		&invitation.InvitedBy, &invitation.CreatedAt, &respondedAt)
+	if respondedAt.Valid {
+		invitation.RespondedAt = &respondedAt.Time
+	}
+	return invitation, err
+}
+
+func (r *CourseRepository) queryInvitations(where string, args ...interface{}) ([]entities.OrganizationInvitation, error) {
+	rows, err := r.DB.Query("SELECT "+invitationColumns+" FROM organization_invitations "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []entities.OrganizationInvitation{}
+	for rows.Next() {
+		invitation, err := scanInvitation(rows)
+		if err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, invitation)
+	}
+	return invitations, rows.Err()
+}
+
+// Create an invitation to join an organization
+func (r *CourseRepository) AddOrganizationInvitation(invitation entities.OrganizationInvitation) (entities.OrganizationInvitation, error) {
+	query := "INSERT INTO organization_invitations (organization_id, user_id, role, status, invited_by, created_at) VALUES (?, ?, ?, ?, ?, ?)"
+	result, err := r.DB.Exec(query, invitation.OrganizationID, invitation.UserID, invitation.Role, invitation.Status, invitation.InvitedBy, invitation.CreatedAt)
+	if err != nil {
+		return entities.OrganizationInvitation{}, err
+	}
+	id, _ := result.LastInsertId()
+	invitation.ID = uint(id)
+	return invitation, nil
+}
+
+// Get an invitation by ID
+func (r *CourseRepository) GetOrganizationInvitationByID(id int) (entities.OrganizationInvitation, error) {
+	return scanInvitation(r.DB.QueryRow("SELECT "+invitationColumns+" FROM organization_invitations WHERE id = ?", id))
+}
+
+// Get the pending invitation of a user to an organization
+func (r *CourseRepository) GetPendingOrganizationInvitation(organizationID, userID int) (entities.OrganizationInvitation, error) {
+	query := "SELECT " + invitationColumns + " FROM organization_invitations WHERE organization_id = ? AND user_id = ? AND status = ?"
+	return scanInvitation(r.DB.QueryRow(query, organizationID, userID, entities.InvitationPending))
+}
+
+// Get the invitations of an organization, newest first
+func (r *CourseRepository) GetOrganizationInvitations(organizationID int) ([]entities.OrganizationInvitation, error) {
+	return r.queryInvitations("WHERE organization_id = ? ORDER BY id DESC", organizationID)
+}
+
+// Get the invitations of a user with the given status, or all of them for an empty status, newest first
+func (r *CourseRepository) GetUserOrganizationInvitations(userID int, status string) ([]entities.OrganizationInvitation, error) {
+	if status == "" {
+		return r.queryInvitations("WHERE user_id = ? ORDER BY id DESC", userID)
+	}
+	return r.queryInvitations("WHERE user_id = ? AND status = ? ORDER BY id DESC", userID, status)
+}
+
+// Answer a pending invitation, sql.ErrNoRows when it is no longer pending so it is answered once
+func (r *CourseRepository) SetOrganizationInvitationStatus(id int, status string, at time.Time) error {
+	query := "UPDATE organization_invitations SET status = ?, responded_at = ? WHERE id = ? AND status = ?"
+	result, err := r.DB.Exec(query, status, at, id, entities.InvitationPending)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+//----------------------------------------------------------------seats----------------------------------------------------------------
+
+// seatAvailable is true while a pool has a seat no enrollment holds, its arguments are the pool ID twice
+const seatAvailable = "(SELECT COUNT(*) FROM enrollments WHERE seat_pool_id = ? AND revoked_at IS NULL) < (SELECT seats FROM seat_pools WHERE id = ?)"
+
+// pools are read with the number of seats in use
+const seatPoolColumns = "p.id, p.organization_id, p.course_id, p.seats, " +
+	"(SELECT COUNT(*) FROM enrollments e WHERE e.seat_pool_id = p.id AND e.revoked_at IS NULL), p.created_at"
+
+func scanSeatPool(row scanner) (entities.SeatPool, error) {
+	var pool entities.SeatPool
+	if err := row.Scan(&pool.ID, &pool.OrganizationID, &pool.CourseID, &pool.Seats, &pool.Used, &pool.CreatedAt); err != nil {
+		return entities.SeatPool{}, err
+	}
+	pool.Available = pool.Seats - pool.Used
+	return pool, nil
+}
+
+// Record a seat purchase, adding its seats to the organization's pool for the course or creating
+// the pool, in one transaction
+func (r *CourseRepository) AddSeatPurchase(purchase entities.SeatPurchase) (entities.SeatPurchase, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return entities.SeatPurchase{}, err
+	}
+	defer tx.Rollback()
+
+	query := "UPDATE seat_pools SET seats = seats + ? WHERE organization_id = ? AND course_id = ?"
+	result, err := tx.Exec(query, purchase.Seats, purchase.OrganizationID, purchase.CourseID)
+	if err != nil {
+		return entities.SeatPurchase{}, err
+	}
+	if requireAffected(result) == sql.ErrNoRows {
+		query = "INSERT INTO seat_pools (organization_id, course_id, seats, created_at) VALUES (?, ?, ?, ?)"
+		if _, err := tx.Exec(query, purchase.OrganizationID, purchase.CourseID, purchase.Seats, purchase.PurchasedAt); err != nil {
+			return entities.SeatPurchase{}, err
+		}
+	}
+	err = tx.QueryRow("SELECT id FROM seat_pools WHERE organization_id = ? AND course_id = ?", purchase.OrganizationID, purchase.CourseID).Scan(&purchase.PoolID)
+	if err != nil {
+		return entities.SeatPurchase{}, err
+	}
+	query = `INSERT INTO seat_purchases (organization_id, pool_id, course_id, seats, unit_price, total, currency, payment_id, purchased_by, purchased_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err = tx.Exec(query, purchase.OrganizationID, purchase.PoolID, purchase.CourseID, purchase.Seats, purchase.UnitPrice.Amount,
+		purchase.Total.Amount, purchase.Total.Currency, purchase.PaymentID, purchase.PurchasedBy, purchase.PurchasedAt)
+	if err != nil {
+		return entities.SeatPurchase{}, err
+	}
+	id, _ := result.LastInsertId()
+	purchase.ID = uint(id)
+	if err := tx.Commit(); err != nil {
+		return entities.SeatPurchase{}, err
+	}
+	return purchase, nil
+}
+
+// Get the seat purchases of an organization, oldest first
+func (r *CourseRepository) GetSeatPurchases(organizationID int) ([]entities.SeatPurchase, error) {
+	query := `SELECT id, organization_id, pool_id, course_id, seats, unit_price, total, currency, payment_id, purchased_by, purchased_at
+		FROM seat_purchases WHERE organization_id = ? ORDER BY id`
+	rows, err := r.DB.Query(query, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	purchases := []entities.SeatPurchase{}
+	for rows.Next() {
+		var purchase entities.SeatPurchase
+		err := rows.Scan(&purchase.ID, &purchase.OrganizationID, &purchase.PoolID, &purchase.CourseID, &purchase.Seats, &purchase.UnitPrice.Amount,
+			&purchase.Total.Amount, &purchase.Total.Currency, &purchase.PaymentID, &purchase.PurchasedBy, &purchase.PurchasedAt)
+		if err != nil {
+			return nil, err
+		}
+		purchase.UnitPrice.Currency = purchase.Total.Currency
+		purchases = append(purchases, purchase)
+	}
+	return purchases, rows.Err()
+}
+
+// Get a seat pool by ID
+func (r *CourseRepository) GetSeatPoolByID(id int) (entities.SeatPool, error) {
+	return scanSeatPool(r.DB.QueryRow("SELECT "+seatPoolColumns+" FROM seat_pools p WHERE p.id = ?", id))
+}
+
+// Get the seat pool of an organization for a course
+func (r *CourseRepository) GetSeatPool(organizationID, courseID int) (entities.SeatPool, error) {
+	return scanSeatPool(r.DB.QueryRow("SELECT "+seatPoolColumns+" FROM seat_pools p WHERE p.organization_id = ? AND p.course_id = ?", organizationID, courseID))
+}
+
+// Get the seat pools of an organization
+func (r *CourseRepository) GetSeatPools(organizationID int) ([]entities.SeatPool, error) {
+	rows, err := r.DB.Query("SELECT "+seatPoolColumns+" FROM seat_pools p WHERE p.organization_id = ? ORDER BY p.id", organizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pools := []entities.SeatPool{}
+	for rows.Next() {
+		pool, err := scanSeatPool(rows)
+		if err != nil {
+			return nil, err
+		}
+		pools = append(pools, pool)
+	}
+	return pools, rows.Err()
+}
+
+// Move an existing enrollment onto a seat of a pool, reinstating it if it was revoked and
+// detaching it from the subscription it was made through. sql.ErrNoRows when no seat is free.
+func (r *CourseRepository) AssignEnrollmentSeat(enrollmentID int, poolID uint) error {
+	query := "UPDATE enrollments SET seat_pool_id = ?, subscription_id = NULL, revoked_at = NULL, revoke_reason = '' WHERE id = ? AND " + seatAvailable
+	result, err := r.DB.Exec(query, poolID, enrollmentID, poolID, poolID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// Get the enrollments of the members of an organization in a course
+func (r *CourseRepository) GetOrganizationEnrollments(organizationID, courseID int) ([]entities.Enrollment, error) {
+	enrollments, err := r.queryEnrollments("WHERE course_id = ? AND user_id IN (SELECT user_id FROM organization_members WHERE organization_id = ?) ORDER BY id",
+		courseID, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if enrollments == nil {
+		enrollments = []entities.Enrollment{}
+	}
+	return enrollments, nil
+}
@@ -0,0 +1,240 @@
+package database
+
+import (
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+//----------------------------------------------------------------prerequisites----------------------------------------------------------------
+
+// Add a prerequisite to a course, or update whether it is required
+func (r *CourseRepository) SetPrerequisite(prerequisite entities.Prerequisite) error {
+	query := `INSERT INTO course_prerequisites (course_id, prerequisite_id, required) VALUES (?, ?, ?)
+		ON CONFLICT (course_id, prerequisite_id) DO UPDATE SET required = excluded.required`
+	_, err := r.DB.Exec(query, prerequisite.CourseID, prerequisite.PrerequisiteID, prerequisite.Required)
+	return err
+}
+
+// Remove a prerequisite from a course
+func (r *CourseRepository) DeletePrerequisite(courseID, prerequisiteID int) error {
+	result, err := r.DB.Exec("DELETE FROM course_prerequisites WHERE course_id = ? AND prerequisite_id = ?", courseID, prerequisiteID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// Get the prerequisites of a course, deleted prerequisite courses are left out
+func (r *CourseRepository) GetPrerequisites(courseID int) ([]entities.Prerequisite, error) {
+	query := `SELECT p.course_id, p.prerequisite_id, p.required, c.title FROM course_prerequisites p
+		JOIN courses c ON c.id = p.prerequisite_id
+		WHERE p.course_id = ? AND c.deleted_at IS NULL ORDER BY c.title`
+	rows, err := r.DB.Query(query, courseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var prerequisites []entities.Prerequisite
+	for rows.Next() {
+		var prerequisite entities.Prerequisite
+		if err := rows.Scan(&prerequisite.CourseID, &prerequisite.PrerequisiteID, &prerequisite.Required, &prerequisite.Title); err != nil {
+			return nil, err
+		}
+		prerequisites = append(prerequisites, prerequisite)
+	}
+	return prerequisites, rows.Err()
+}
+
+// Get every prerequisite relation as course ID -> prerequisite IDs
+func (r *CourseRepository) GetPrerequisiteGraph() (map[uint][]uint, error) {
+	rows, err := r.DB.Query("SELECT course_id, prerequisite_id FROM course_prerequisites")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	graph := map[uint][]uint{}
+	for rows.Next() {
+		var courseID, prerequisiteID uint
+		if err := rows.Scan(&courseID, &prerequisiteID); err != nil {
+			return nil, err
+		}
+		graph[courseID] = append(graph[courseID], prerequisiteID)
+	}
+	return graph, rows.Err()
+}
+
+//----------------------------------------------------------------learning paths----------------------------------------------------------------
+
+// Create a learning path with its courses
+func (r *CourseRepository) AddLearningPath(path entities.LearningPath) (entities.LearningPath, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return entities.LearningPath{}, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT INTO learning_paths (title, description) VALUES (?, ?)", path.Title, path.Description)
+	if err != nil {
+		return entities.LearningPath{}, err
+	}
+	id, _ := result.LastInsertId()
+	path.ID = uint(id)
+	if err := setPathCourses(tx, path); err != nil {
+		return entities.LearningPath{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return entities.LearningPath{}, err
+	}
+	return path, nil
+}
+
+// Get a learning path by ID
+func (r *CourseRepository) GetLearningPathByID(id int) (entities.LearningPath, error) {
+	var path entities.LearningPath
+	err := r.DB.QueryRow("SELECT id, title, description FROM learning_paths WHERE id = ?", id).Scan(&path.ID, &path.Title, &path.Description)
+	if err != nil {
+		return entities.LearningPath{}, err
+	}
+	courses, err := r.pathCourses("WHERE path_id = ?", id)
+	if err != nil {
+		return entities.LearningPath{}, err
+	}
+	path.CourseIDs = courses[path.ID]
+	if path.CourseIDs == nil {
+		path.CourseIDs = []uint{}
+	}
+	return path, nil
+}
+
+// Get every learning path
+func (r *CourseRepository) GetAllLearningPaths() ([]entities.LearningPath, error) {
+	rows, err := r.DB.Query("SELECT id, title, description FROM learning_paths ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var paths []entities.LearningPath
+	for rows.Next() {
+		var path entities.LearningPath
+		if err := rows.Scan(&path.ID, &path.Title, &path.Description); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	courses, err := r.pathCourses("")
+	if err != nil {
+		return nil, err
+	}
+	for i := range paths {
+		paths[i].CourseIDs = courses[paths[i].ID]
+		if paths[i].CourseIDs == nil {
+			paths[i].CourseIDs = []uint{}
+		}
+	}
+	return paths, nil
+}
+
+// Update the title, description and courses of a learning path
+func (r *CourseRepository) UpdateLearningPath(path entities.LearningPath) (entities.LearningPath, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return entities.LearningPath{}, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE learning_paths SET title = ?, description = ? WHERE id = ?", path.Title, path.Description, path.ID)
+	if err != nil {
+		return entities.LearningPath{}, err
+	}
+	if err := requireAffected(result); err != nil {
+		return entities.LearningPath{}, err
+	}
+	if _, err := tx.Exec("DELETE FROM learning_path_courses WHERE path_id = ?", path.ID); err != nil {
+		return entities.LearningPath{}, err
+	}
+	if err := setPathCourses(tx, path); err != nil {
+		return entities.LearningPath{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return entities.LearningPath{}, err
+	}
+	return path, nil
+}
+
+// Delete a learning path, its courses are kept
+func (r *CourseRepository) DeleteLearningPath(id int) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM learning_path_courses WHERE path_id = ?", id); err != nil {
+		return err
+	}
+	result, err := tx.Exec("DELETE FROM learning_paths WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func setPathCourses(tx querier, path entities.LearningPath) error {
+	for i, courseID := range path.CourseIDs {
+		_, err := tx.Exec("INSERT INTO learning_path_courses (path_id, course_id, position) VALUES (?, ?, ?)", path.ID, courseID, i+1)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pathCourses returns the course IDs of learning paths in order, as path ID -> course IDs
+func (r *CourseRepository) pathCourses(where string, args ...interface{}) (map[uint][]uint, error) {
+	rows, err := r.DB.Query("SELECT path_id, course_id FROM learning_path_courses "+where+" ORDER BY path_id, position", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	courses := map[uint][]uint{}
+	for rows.Next() {
+		var pathID, courseID uint
+		if err := rows.Scan(&pathID, &courseID); err != nil {
+			return nil, err
+		}
+		courses[pathID] = append(courses[pathID], courseID)
+	}
+	return courses, rows.Err()
+}
+
+// Get the live courses of a learning path in order
+func (r *CourseRepository) GetLearningPathCourses(pathID int) ([]entities.Course, error) {
+	query := "SELECT " + courseColumns + ` FROM courses
+		JOIN learning_path_courses lp ON lp.course_id = courses.id
+		WHERE lp.path_id = ? AND courses.deleted_at IS NULL ORDER BY lp.position`
+	rows, err := r.DB.Query(query, pathID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var courses []entities.Course
+	for rows.Next() {
+		course, err := scanCourse(rows)
+		if err != nil {
+			return nil, err
+		}
+		courses = append(courses, course)
+	}
+	return courses, rows.Err()
+}
@@ -0,0 +1,47 @@
+package database
+
+import "github.com/NaheedRayan/mini_rest_api_shikho/entities"
+
+//----------------------------------------------------------------course prices----------------------------------------------------------------
+
+// Get the prices set for a course in currencies other than its base price
+func (r *CourseRepository) GetCoursePrices(courseID int) ([]entities.Money, error) {
+	rows, err := r.DB.Query("SELECT amount, currency FROM course_prices WHERE course_id = ? ORDER BY currency", courseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prices := []entities.Money{}
+	for rows.Next() {
+		var price entities.Money
+		if err := rows.Scan(&price.Amount, &price.Currency); err != nil {
+			return nil, err
+		}
+		prices = append(prices, price)
+	}
+	return prices, rows.Err()
+}
+
+// Get the price set for a course in a currency
+func (r *CourseRepository) GetCoursePrice(courseID int, currency string) (entities.Money, error) {
+	price := entities.Money{Currency: currency}
+	err := r.DB.QueryRow("SELECT amount FROM course_prices WHERE course_id = ? AND currency = ?", courseID, currency).Scan(&price.Amount)
+	return price, err
+}
+
+// Set the price of a course in a currency, replacing the one set before
+func (r *CourseRepository) SetCoursePrice(courseID int, price entities.Money) error {
+	query := "INSERT INTO course_prices (course_id, currency, amount) VALUES (?, ?, ?) ON CONFLICT (course_id, currency) DO UPDATE SET amount = excluded.amount"
+	_, err := r.DB.Exec(query, courseID, price.Currency, price.Amount)
+	return err
+}
+
+// Remove the price of a course in a currency
+func (r *CourseRepository) DeleteCoursePrice(courseID int, currency string) error {
+	result, err := r.DB.Exec("DELETE FROM course_prices WHERE course_id = ? AND currency = ?", courseID, currency)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
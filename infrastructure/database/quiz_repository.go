@@ -0,0 +1,354 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+//----------------------------------------------------------------question banks----------------------------------------------------------------
+
+// Create a question bank
+func (r *CourseRepository) AddQuestionBank(bank entities.QuestionBank) (entities.QuestionBank, error) {
+	result, err := r.DB.Exec("INSERT INTO question_banks (course_id, title) VALUES (?, ?)", bank.CourseID, bank.Title)
+	if err != nil {
+		return entities.QuestionBank{}, err
+	}
+	id, _ := result.LastInsertId()
+	bank.ID = uint(id)
+	return bank, nil
+}
+
+// Get a question bank by ID
+func (r *CourseRepository) GetQuestionBankByID(id int) (entities.QuestionBank, error) {
+	var bank entities.QuestionBank
+	err := r.DB.QueryRow("SELECT id, course_id, title FROM question_banks WHERE id = ?", id).Scan(&bank.ID, &bank.CourseID, &bank.Title)
+	return bank, err
+}
+
+// Get the question banks of a course
+func (r *CourseRepository) GetQuestionBanksByCourseID(courseID int) ([]entities.QuestionBank, error) {
+	rows, err := r.DB.Query("SELECT id, course_id, title FROM question_banks WHERE course_id = ? ORDER BY id", courseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var banks []entities.QuestionBank
+	for rows.Next() {
+		var bank entities.QuestionBank
+		if err := rows.Scan(&bank.ID, &bank.CourseID, &bank.Title); err != nil {
+			return nil, err
+		}
+		banks = append(banks, bank)
+	}
+	return banks, rows.Err()
+}
+
+//----------------------------------------------------------------questions----------------------------------------------------------------
+
+const questionColumns = "id, bank_id, type, prompt, choices_json, answer_json, points, deleted_at"
+
+func scanQuestion(row scanner) (entities.Question, error) {
+	var question entities.Question
+	var choices sql.NullString
+	var answer string
+	var deletedAt sql.NullTime
+	err := row.Scan(&question.ID, &question.BankID, &question.Type, &question.Prompt, &choices, &answer, &question.Points, &deletedAt)
+	if err != nil {
+		return entities.Question{}, err
+	}
+	if choices.Valid {
+		if err := json.Unmarshal([]byte(choices.String), &question.Choices); err != nil {
+			return entities.Question{}, err
+		}
+	}
+	if err := json.Unmarshal([]byte(answer), &question.Answer); err != nil {
+		return entities.Question{}, err
+	}
+	question.DeletedAt = timePtr(deletedAt)
+	return question, nil
+}
+
+func (r *CourseRepository) queryQuestions(where string, args ...interface{}) ([]entities.Question, error) {
+	rows, err := r.DB.Query("SELECT "+questionColumns+" FROM questions "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var questions []entities.Question
+	for rows.Next() {
+		question, err := scanQuestion(rows)
+		if err != nil {
+			return nil, err
+		}
+		questions = append(questions, question)
+	}
+	return questions, rows.Err()
+}
+
+// encodeQuestion returns the stored JSON of the choices and answer key of a question
+func encodeQuestion(question entities.Question) (sql.NullString, string, error) {
+	var choices sql.NullString
+	if len(question.Choices) > 0 {
+		raw, err := json.Marshal(question.Choices)
+		if err != nil {
+			return choices, "", err
+		}
+		choices = sql.NullString{String: string(raw), Valid: true}
+	}
+	answer, err := json.Marshal(question.Answer)
+	if err != nil {
+		return choices, "", err
+	}
+	return choices, string(answer), nil
+}
+
+// Create a question
+func (r *CourseRepository) AddQuestion(question entities.Question) (entities.Question, error) {
+	choices, answer, err := encodeQuestion(question)
+	if err != nil {
+		return entities.Question{}, err
+	}
+	query := "INSERT INTO questions (bank_id, type, prompt, choices_json, answer_json, points) VALUES (?, ?, ?, ?, ?, ?)"
+	result, err := r.DB.Exec(query, question.BankID, question.Type, question.Prompt, choices, answer, question.Points)
+	if err != nil {
+		return entities.Question{}, err
+	}
+	id, _ := result.LastInsertId()
+	question.ID = uint(id)
+	return question, nil
+}
+
+// Get a question by ID, deleted questions included so old attempts can still be reviewed
+func (r *CourseRepository) GetQuestionByID(id int) (entities.Question, error) {
+	return scanQuestion(r.DB.QueryRow("SELECT "+questionColumns+" FROM questions WHERE id = ?", id))
+}
+
+// Get the live questions of a bank
+func (r *CourseRepository) GetQuestionsByBankID(bankID int) ([]entities.Question, error) {
+	return r.queryQuestions("WHERE bank_id = ? AND deleted_at IS NULL ORDER BY id", bankID)
+}
+
+// Get questions by ID, deleted ones included, in no particular order
+func (r *CourseRepository) GetQuestionsByIDs(ids []uint) ([]entities.Question, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	return r.queryQuestions("WHERE id IN ("+placeholders+")", args...)
+}
+
+// Update a live question
+func (r *CourseRepository) UpdateQuestion(question entities.Question) (entities.Question, error) {
+	choices, answer, err := encodeQuestion(question)
+	if err != nil {
+		return entities.Question{}, err
+	}
+	query := "UPDATE questions SET type = ?, prompt = ?, choices_json = ?, answer_json = ?, points = ? WHERE id = ? AND deleted_at IS NULL"
+	result, err := r.DB.Exec(query, question.Type, question.Prompt, choices, answer, question.Points, question.ID)
+	if err != nil {
+		return entities.Question{}, err
+	}
+	if err := requireAffected(result); err != nil {
+		return entities.Question{}, err
+	}
+	return question, nil
+}
+
+// Soft delete a question, attempts that used it keep showing it
+func (r *CourseRepository) DeleteQuestion(id int) error {
+	return r.softDelete("questions", id)
+}
+
+//----------------------------------------------------------------quizzes----------------------------------------------------------------
+
+const quizColumns = "id, lesson_id, bank_id, title, question_count, shuffle, time_limit_seconds, max_attempts, passing_score"
+
+func scanQuiz(row scanner) (entities.Quiz, error) {
+	var quiz entities.Quiz
+	err := row.Scan(&quiz.ID, &quiz.LessonID, &quiz.BankID, &quiz.Title, &quiz.QuestionCount, &quiz.Shuffle,
+		&quiz.TimeLimitSeconds, &quiz.MaxAttempts, &quiz.PassingScore)
+	return quiz, err
+}
+
+// Attach a quiz to a lesson
+func (r *CourseRepository) AddQuiz(quiz entities.Quiz) (entities.Quiz, error) {
+	query := "INSERT INTO quizzes (lesson_id, bank_id, title, question_count, shuffle, time_limit_seconds, max_attempts, passing_score) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+	result, err := r.DB.Exec(query, quiz.LessonID, quiz.BankID, quiz.Title, quiz.QuestionCount, quiz.Shuffle,
+		quiz.TimeLimitSeconds, quiz.MaxAttempts, quiz.PassingScore)
+	if err != nil {
+		return entities.Quiz{}, err
+	}
+	id, _ := result.LastInsertId()
+	quiz.ID = uint(id)
+	return quiz, nil
+}
+
+// Get a quiz by ID
+func (r *CourseRepository) GetQuizByID(id int) (entities.Quiz, error) {
+	return scanQuiz(r.DB.QueryRow("SELECT "+quizColumns+" FROM quizzes WHERE id = ?", id))
+}
+
+// Get the quiz of a lesson
+func (r *CourseRepository) GetQuizByLessonID(lessonID int) (entities.Quiz, error) {
+	return scanQuiz(r.DB.QueryRow("SELECT "+quizColumns+" FROM quizzes WHERE lesson_id = ?", lessonID))
+}
+
+// Get the quizzes on the lessons of a course, in lesson order
+func (r *CourseRepository) GetQuizzesByCourseID(courseID int) ([]entities.Quiz, error) {
+	query := `SELECT q.id, q.lesson_id, q.bank_id, q.title, q.question_count, q.shuffle, q.time_limit_seconds, q.max_attempts, q.passing_score
+		FROM quizzes q JOIN lessons l ON l.id = q.lesson_id
+		WHERE l.course_id = ? AND l.deleted_at IS NULL ORDER BY l."order", l.id`
+	rows, err := r.DB.Query(query, courseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var quizzes []entities.Quiz
+	for rows.Next() {
+		quiz, err := scanQuiz(rows)
+		if err != nil {
+			return nil, err
+		}
+		quizzes = append(quizzes, quiz)
+	}
+	return quizzes, rows.Err()
+}
+
+// Update the settings of a quiz, it stays on its lesson
+func (r *CourseRepository) UpdateQuiz(quiz entities.Quiz) (entities.Quiz, error) {
+	query := "UPDATE quizzes SET bank_id = ?, title = ?, question_count = ?, shuffle = ?, time_limit_seconds = ?, max_attempts = ?, passing_score = ? WHERE id = ?"
+	result, err := r.DB.Exec(query, quiz.BankID, quiz.Title, quiz.QuestionCount, quiz.Shuffle,
+		quiz.TimeLimitSeconds, quiz.MaxAttempts, quiz.PassingScore, quiz.ID)
+	if err != nil {
+		return entities.Quiz{}, err
+	}
+	if err := requireAffected(result); err != nil {
+		return entities.Quiz{}, err
+	}
+	return r.GetQuizByID(int(quiz.ID))
+}
+
+// Delete a quiz and its attempts
+func (r *CourseRepository) DeleteQuiz(id int) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM quiz_attempts WHERE quiz_id = ?", id); err != nil {
+		return err
+	}
+	result, err := tx.Exec("DELETE FROM quizzes WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+//----------------------------------------------------------------attempts----------------------------------------------------------------
+
+const attemptColumns = "id, quiz_id, enrollment_id, question_ids_json, answers_json, started_at, deadline_at, submitted_at, score, passed"
+
+func scanAttempt(row scanner) (entities.QuizAttempt, error) {
+	attempt := entities.QuizAttempt{Answers: []entities.AttemptAnswer{}}
+	var questionIDs string
+	var answers sql.NullString
+	var deadlineAt, submittedAt sql.NullTime
+	err := row.Scan(&attempt.ID, &attempt.QuizID, &attempt.EnrollmentID, &questionIDs, &answers,
+		&attempt.StartedAt, &deadlineAt, &submittedAt, &attempt.Score, &attempt.Passed)
+	if err != nil {
+		return entities.QuizAttempt{}, err
+	}
+	if err := json.Unmarshal([]byte(questionIDs), &attempt.QuestionIDs); err != nil {
+		return entities.QuizAttempt{}, err
+	}
+	if answers.Valid {
+		if err := json.Unmarshal([]byte(answers.String), &attempt.Answers); err != nil {
+			return entities.QuizAttempt{}, err
+		}
+	}
+	attempt.DeadlineAt = timePtr(deadlineAt)
+	attempt.SubmittedAt = timePtr(submittedAt)
+	return attempt, nil
+}
+
+// attemptAllowed is true while an enrollment has no open attempt at a quiz and attempts left,
+// its arguments are the enrollment ID twice
+const attemptAllowed = "NOT EXISTS (SELECT 1 FROM quiz_attempts WHERE quiz_id = q.id AND enrollment_id = ? AND submitted_at IS NULL) " +
+	"AND (q.max_attempts = 0 OR (SELECT COUNT(*) FROM quiz_attempts WHERE quiz_id = q.id AND enrollment_id = ?) < q.max_attempts)"
+
+// Start an attempt. Checking the enrollment may start one and inserting it happen in the same
+// statement, sql.ErrNoRows when an attempt is open or all attempts are used.
+func (r *CourseRepository) AddQuizAttempt(attempt entities.QuizAttempt) (entities.QuizAttempt, error) {
+	questionIDs, err := json.Marshal(attempt.QuestionIDs)
+	if err != nil {
+		return entities.QuizAttempt{}, err
+	}
+	query := "INSERT INTO quiz_attempts (quiz_id, enrollment_id, question_ids_json, started_at, deadline_at) " +
+		"SELECT q.id, ?, ?, ?, ? FROM quizzes q WHERE q.id = ? AND " + attemptAllowed
+	result, err := r.DB.Exec(query, attempt.EnrollmentID, string(questionIDs), attempt.StartedAt, attempt.DeadlineAt,
+		attempt.QuizID, attempt.EnrollmentID, attempt.EnrollmentID)
+	if err != nil {
+		return entities.QuizAttempt{}, err
+	}
+	if err := requireAffected(result); err != nil {
+		return entities.QuizAttempt{}, err
+	}
+	id, _ := result.LastInsertId()
+	attempt.ID = uint(id)
+	return attempt, nil
+}
+
+// Get an attempt by ID
+func (r *CourseRepository) GetQuizAttemptByID(id int) (entities.QuizAttempt, error) {
+	return scanAttempt(r.DB.QueryRow("SELECT "+attemptColumns+" FROM quiz_attempts WHERE id = ?", id))
+}
+
+// Get the attempts of an enrollment at a quiz, oldest first
+func (r *CourseRepository) GetQuizAttempts(quizID, enrollmentID int) ([]entities.QuizAttempt, error) {
+	query := "SELECT " + attemptColumns + " FROM quiz_attempts WHERE quiz_id = ? AND enrollment_id = ? ORDER BY id"
+	rows, err := r.DB.Query(query, quizID, enrollmentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attempts []entities.QuizAttempt
+	for rows.Next() {
+		attempt, err := scanAttempt(rows)
+		if err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, attempt)
+	}
+	return attempts, rows.Err()
+}
+
+// Store the graded answers of an attempt. An attempt is submitted once, a second
+// submission returns sql.ErrNoRows.
+func (r *CourseRepository) SubmitQuizAttempt(attempt entities.QuizAttempt) error {
+	answers, err := json.Marshal(attempt.Answers)
+	if err != nil {
+		return err
+	}
+	query := "UPDATE quiz_attempts SET answers_json = ?, submitted_at = ?, score = ?, passed = ? WHERE id = ? AND submitted_at IS NULL"
+	result, err := r.DB.Exec(query, string(answers), attempt.SubmittedAt, attempt.Score, attempt.Passed, attempt.ID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
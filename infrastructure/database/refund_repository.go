@@ -0,0 +1,131 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+//----------------------------------------------------------------refunds----------------------------------------------------------------
+
+const refundColumns = "r.id, r.order_id, r.order_item_id, r.course_id, r.user_id, r.status, r.amount, o.currency, r.reason, r.progress, r.policy_note, " +
+	"r.reviewer_id, r.review_note, r.gateway_refund_id, r.requested_at, r.reviewed_at"
+
+// refunds are read with the currency of their order
+const refundFrom = " FROM refunds r JOIN orders o ON o.id = r.order_id"
+
+func scanRefund(row scanner) (entities.Refund, error) {
+	var refund entities.Refund
+	var reviewerID sql.NullInt64
+	var reviewedAt sql.NullTime
+	err := row.Scan(&refund.ID, &refund.OrderID, &refund.OrderItemID, &refund.CourseID, &refund.UserID, &refund.Status, &refund.Amount.Amount,
+		&refund.Amount.Currency, &refund.Reason, &refund.Progress, &refund.PolicyNote, &reviewerID, &refund.ReviewNote, &refund.GatewayRefundID,
+		&refund.RequestedAt, &reviewedAt)
+	if err != nil {
+		return entities.Refund{}, err
+	}
+	refund.ReviewerID = uintPtr(reviewerID)
+	refund.ReviewedAt = timePtr(reviewedAt)
+	return refund, nil
+}
+
+func (r *CourseRepository) queryRefunds(where string, args ...interface{}) ([]entities.Refund, error) {
+	rows, err := r.DB.Query("SELECT "+refundColumns+refundFrom+" "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	refunds := []entities.Refund{}
+	for rows.Next() {
+		refund, err := scanRefund(rows)
+		if err != nil {
+			return nil, err
+		}
+		refunds = append(refunds, refund)
+	}
+	return refunds, rows.Err()
+}
+
+// Create a refund request
+func (r *CourseRepository) AddRefund(refund entities.Refund) (entities.Refund, error) {
+	query := `INSERT INTO refunds (order_id, order_item_id, course_id, user_id, status, amount, reason, progress, policy_note, requested_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := r.DB.Exec(query, refund.OrderID, refund.OrderItemID, refund.CourseID, refund.UserID, refund.Status, refund.Amount.Amount,
+		refund.Reason, refund.Progress, refund.PolicyNote, refund.RequestedAt)
+	if err != nil {
+		return entities.Refund{}, err
+	}
+	id, _ := result.LastInsertId()
+	refund.ID = uint(id)
+	return refund, nil
+}
+
+// Get a refund by ID
+func (r *CourseRepository) GetRefundByID(id int) (entities.Refund, error) {
+	return scanRefund(r.DB.QueryRow("SELECT "+refundColumns+refundFrom+" WHERE r.id = ?", id))
+}
+
+// Get the refunds of an order, oldest first
+func (r *CourseRepository) GetRefundsByOrderID(orderID int) ([]entities.Refund, error) {
+	return r.queryRefunds("WHERE r.order_id = ? ORDER BY r.id", orderID)
+}
+
+// Get the refunds with the given status, or all refunds for an empty status, oldest first
+func (r *CourseRepository) GetRefunds(status string) ([]entities.Refund, error) {
+	if status == "" {
+		return r.queryRefunds("ORDER BY r.id")
+	}
+	return r.queryRefunds("WHERE r.status = ? ORDER BY r.id", status)
+}
+
+// Move a refund from one status to another, sql.ErrNoRows when it is no longer in the expected
+// status so two admins cannot both act on it
+func (r *CourseRepository) UpdateRefundStatus(refund entities.Refund, from string) error {
+	query := "UPDATE refunds SET status = ?, amount = ?, reviewer_id = ?, review_note = ?, reviewed_at = ? WHERE id = ? AND status = ?"
+	result, err := r.DB.Exec(query, refund.Status, refund.Amount.Amount, refund.ReviewerID, refund.ReviewNote, refund.ReviewedAt, refund.ID, from)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// Set the gateway's ID of a processing refund once the gateway has paid it back
+func (r *CourseRepository) SetRefundGatewayID(id int, gatewayRefundID string) error {
+	query := "UPDATE refunds SET gateway_refund_id = ? WHERE id = ? AND status = ?"
+	result, err := r.DB.Exec(query, gatewayRefundID, id, entities.RefundProcessing)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// Complete a refund the gateway has paid back, revoking the enrollment it paid for and setting
+// the order status, in one transaction
+func (r *CourseRepository) CompleteRefund(refund entities.Refund, enrollmentID *uint, revokedAt time.Time, revokeReason, orderStatus string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := "UPDATE refunds SET status = ?, gateway_refund_id = ? WHERE id = ? AND status = ?"
+	result, err := tx.Exec(query, entities.RefundRefunded, refund.GatewayRefundID, refund.ID, entities.RefundProcessing)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result); err != nil {
+		return err
+	}
+	if enrollmentID != nil {
+		query := "UPDATE enrollments SET revoked_at = ?, revoke_reason = ? WHERE id = ? AND revoked_at IS NULL"
+		if _, err := tx.Exec(query, revokedAt, revokeReason, *enrollmentID); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("UPDATE orders SET status = ? WHERE id = ?", orderStatus, refund.OrderID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
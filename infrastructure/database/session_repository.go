@@ -0,0 +1,59 @@
+package database
+
+import (
+	"time"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+//----------------------------------------------------------------sessions----------------------------------------------------------------
+
+// Get a live user by email with their password hash, for signing in
+func (r *CourseRepository) GetUserCredentials(email string) (entities.User, error) {
+	query := "SELECT id, first_name, last_name, email, password, role, bio FROM users WHERE email = ? AND deleted_at IS NULL"
+	var user entities.User
+	err := r.DB.QueryRow(query, email).Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.Password, &user.Role, &user.Bio)
+	return user, err
+}
+
+// Replace the password hash of a user
+func (r *CourseRepository) SetUserPassword(id int, hash string) error {
+	result, err := r.DB.Exec("UPDATE users SET password = ? WHERE id = ?", hash, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// Store a session under the hash of its token
+func (r *CourseRepository) AddSession(tokenHash string, session entities.Session) error {
+	_, err := r.DB.Exec("INSERT INTO sessions (token_hash, user_id, created_at, expires_at) VALUES (?, ?, ?, ?)",
+		tokenHash, session.UserID, session.CreatedAt, session.ExpiresAt)
+	return err
+}
+
+// Get a session by the hash of its token
+func (r *CourseRepository) GetSession(tokenHash string) (entities.Session, error) {
+	var session entities.Session
+	err := r.DB.QueryRow("SELECT user_id, created_at, expires_at FROM sessions WHERE token_hash = ?", tokenHash).
+		Scan(&session.UserID, &session.CreatedAt, &session.ExpiresAt)
+	return session, err
+}
+
+// Delete a session by the hash of its token
+func (r *CourseRepository) DeleteSession(tokenHash string) error {
+	_, err := r.DB.Exec("DELETE FROM sessions WHERE token_hash = ?", tokenHash)
+	return err
+}
+
+// Delete the sessions of a user, the one with the given token hash is kept when it is not empty
+func (r *CourseRepository) DeleteUserSessions(userID int, keepTokenHash string) error {
+	_, err := r.DB.Exec("DELETE FROM sessions WHERE user_id = ? AND token_hash != ?", userID, keepTokenHash)
+	return err
+}
+
+// Delete the sessions that expired before now
+func (r *CourseRepository) DeleteExpiredSessions(now time.Time) error {
+	_, err := r.DB.Exec("DELETE FROM sessions WHERE expires_at < ?", now)
+	return err
+}
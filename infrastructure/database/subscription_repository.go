@@ -0,0 +1,230 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+//----------------------------------------------------------------subscription plans----------------------------------------------------------------
+
+const subscriptionPlanColumns = "id, name, description, interval, price_amount, currency, categories, active, created_at"
+
+func scanSubscriptionPlan(row scanner) (entities.SubscriptionPlan, error) {
+	var plan entities.SubscriptionPlan
+	var categories string
+	err := row.Scan(&plan.ID, &plan.Name, &plan.Description, &plan.Interval, &plan.Price.Amount, &plan.Price.Currency, &categories,
+		&plan.Active, &plan.CreatedAt)
+	if err != nil {
+		return entities.SubscriptionPlan{}, err
+	}
+	if err := json.Unmarshal([]byte(categories), &plan.Categories); err != nil {
+		return entities.SubscriptionPlan{}, err
+	}
+	return plan, nil
+}
+
+// Create a subscription plan
+func (r *CourseRepository) AddSubscriptionPlan(plan entities.SubscriptionPlan) (entities.SubscriptionPlan, error) {
+	categories, err := json.Marshal(plan.Categories)
+	if err != nil {
+		return entities.SubscriptionPlan{}, err
+	}
+	query := `INSERT INTO subscription_plans (name, description, interval, price_amount, currency, categories, active, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := r.DB.Exec(query, plan.Name, plan.Description, plan.Interval, plan.Price.Amount, plan.Price.Currency, string(categories),
+		plan.Active, plan.CreatedAt)
+	if err != nil {
+		return entities.SubscriptionPlan{}, err
+	}
+	id, _ := result.LastInsertId()
+	plan.ID = uint(id)
+	return plan, nil
+}
+
+// Get a subscription plan by ID
+func (r *CourseRepository) GetSubscriptionPlanByID(id int) (entities.SubscriptionPlan, error) {
+	return scanSubscriptionPlan(r.DB.QueryRow("SELECT "+subscriptionPlanColumns+" FROM subscription_plans WHERE id = ?", id))
+}
+
+// Get the subscription plans, only the active ones unless includeInactive
+func (r *CourseRepository) GetSubscriptionPlans(includeInactive bool) ([]entities.SubscriptionPlan, error) {
+	query := "SELECT " + subscriptionPlanColumns + " FROM subscription_plans"
+	if !includeInactive {
+		query += " WHERE active = TRUE"
+	}
+	rows, err := r.DB.Query(query + " ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	plans := []entities.SubscriptionPlan{}
+	for rows.Next() {
+		plan, err := scanSubscriptionPlan(rows)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, plan)
+	}
+	return plans, rows.Err()
+}
+
+// Update a subscription plan, subscribers are charged its new price from their next renewal
+func (r *CourseRepository) UpdateSubscriptionPlan(plan entities.SubscriptionPlan) error {
+	categories, err := json.Marshal(plan.Categories)
+	if err != nil {
+		return err
+	}
+	query := "UPDATE subscription_plans SET name = ?, description = ?, interval = ?, price_amount = ?, currency = ?, categories = ?, active = ? WHERE id = ?"
+	result, err := r.DB.Exec(query, plan.Name, plan.Description, plan.Interval, plan.Price.Amount, plan.Price.Currency, string(categories), plan.Active, plan.ID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+//----------------------------------------------------------------subscriptions----------------------------------------------------------------
+
+const subscriptionColumns = "id, user_id, plan_id, status, payment_method, current_period_start, current_period_end, cancel_at_period_end, " +
+	"cancelled_at, grace_until, next_attempt_at, ended_at, failure_reason, created_at"
+
+func scanSubscription(row scanner) (entities.Subscription, error) {
+	var subscription entities.Subscription
+	var cancelledAt, graceUntil, nextAttemptAt, endedAt sql.NullTime
+	err := row.Scan(&subscription.ID, &subscription.UserID, &subscription.PlanID, &subscription.Status, &subscription.PaymentMethod,
+		&subscription.CurrentPeriodStart, &subscription.CurrentPeriodEnd, &subscription.CancelAtPeriodEnd, &cancelledAt, &graceUntil, &nextAttemptAt, &endedAt,
+		&subscription.FailureReason, &subscription.CreatedAt)
+	if err != nil {
+		return entities.Subscription{}, err
+	}
+	subscription.CancelledAt = timePtr(cancelledAt)
+	subscription.GraceUntil = timePtr(graceUntil)
+	subscription.NextAttemptAt = timePtr(nextAttemptAt)
+	subscription.EndedAt = timePtr(endedAt)
+	return subscription, nil
+}
+
+func (r *CourseRepository) querySubscriptions(where string, args ...interface{}) ([]entities.Subscription, error) {
+	rows, err := r.DB.Query("SELECT "+subscriptionColumns+" FROM subscriptions "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subscriptions := []entities.Subscription{}
+	for rows.Next() {
+		subscription, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	return subscriptions, rows.Err()
+}
+
+// Create a subscription with the payment of its first period, in one transaction
+func (r *CourseRepository) AddSubscription(subscription entities.Subscription, payment entities.SubscriptionPayment) (entities.Subscription, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return entities.Subscription{}, err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO subscriptions (user_id, plan_id, status, payment_method, current_period_start, current_period_end, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`
+	result, err := tx.Exec(query, subscription.UserID, subscription.PlanID, subscription.Status, subscription.PaymentMethod,
+		subscription.CurrentPeriodStart, subscription.CurrentPeriodEnd, subscription.CreatedAt)
+	if err != nil {
+		return entities.Subscription{}, err
+	}
+	id, _ := result.LastInsertId()
+	subscription.ID = uint(id)
+	payment.SubscriptionID = subscription.ID
+	if err := addSubscriptionPayment(tx, payment); err != nil {
+		return entities.Subscription{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return entities.Subscription{}, err
+	}
+	return subscription, nil
+}
+
+// Get a subscription by ID
+func (r *CourseRepository) GetSubscriptionByID(id int) (entities.Subscription, error) {
+	return scanSubscription(r.DB.QueryRow("SELECT "+subscriptionColumns+" FROM subscriptions WHERE id = ?", id))
+}
+
+// Get the subscriptions of a user, newest first
+func (r *CourseRepository) GetSubscriptionsByUserID(userID int) ([]entities.Subscription, error) {
+	return r.querySubscriptions("WHERE user_id = ? ORDER BY id DESC", userID)
+}
+
+// Get the subscriptions with something due at a time: active ones whose period is over and past
+// due ones to retry or whose grace period is over
+func (r *CourseRepository) GetDueSubscriptions(now time.Time) ([]entities.Subscription, error) {
+	return r.querySubscriptions("WHERE (status = ? AND current_period_end <= ?) OR (status = ? AND (next_attempt_at <= ? OR grace_until <= ?)) ORDER BY id",
+		entities.SubscriptionActive, now, entities.SubscriptionPastDue, now, now)
+}
+
+// Store a subscription that was read with the given status and period end, recording the payment
+// made for it if any, in one transaction. sql.ErrNoRows when it has changed since, so a period
+// is never renewed twice.
+func (r *CourseRepository) UpdateSubscription(subscription entities.Subscription, fromStatus string, fromPeriodEnd time.Time, payment *entities.SubscriptionPayment) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE subscriptions SET status = ?, payment_method = ?, current_period_start = ?, current_period_end = ?, cancel_at_period_end = ?,
+		cancelled_at = ?, grace_until = ?, next_attempt_at = ?, ended_at = ?, failure_reason = ? WHERE id = ? AND status = ? AND current_period_end = ?`
+	result, err := tx.Exec(query, subscription.Status, subscription.PaymentMethod, subscription.CurrentPeriodStart, subscription.CurrentPeriodEnd,
+		subscription.CancelAtPeriodEnd, subscription.CancelledAt, subscription.GraceUntil, subscription.NextAttemptAt, subscription.EndedAt, subscription.FailureReason,
+		subscription.ID, fromStatus, fromPeriodEnd)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result); err != nil {
+		return err
+	}
+	if payment != nil {
+		if err := addSubscriptionPayment(tx, *payment); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func addSubscriptionPayment(tx querier, payment entities.SubscriptionPayment) error {
+	query := `INSERT INTO subscription_payments (subscription_id, amount, currency, status, payment_id, failure_reason, period_start, period_end, attempted_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := tx.Exec(query, payment.SubscriptionID, payment.Amount.Amount, payment.Amount.Currency, payment.Status, payment.PaymentID,
+		payment.FailureReason, payment.PeriodStart, payment.PeriodEnd, payment.AttemptedAt)
+	return err
+}
+
+// Get the payments of a subscription, oldest first
+func (r *CourseRepository) GetSubscriptionPayments(subscriptionID int) ([]entities.SubscriptionPayment, error) {
+	query := `SELECT id, subscription_id, amount, currency, status, payment_id, failure_reason, period_start, period_end, attempted_at
+		FROM subscription_payments WHERE subscription_id = ? ORDER BY id`
+	rows, err := r.DB.Query(query, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := []entities.SubscriptionPayment{}
+	for rows.Next() {
+		var payment entities.SubscriptionPayment
+		err := rows.Scan(&payment.ID, &payment.SubscriptionID, &payment.Amount.Amount, &payment.Amount.Currency, &payment.Status, &payment.PaymentID,
+			&payment.FailureReason, &payment.PeriodStart, &payment.PeriodEnd, &payment.AttemptedAt)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, payment)
+	}
+	return payments, rows.Err()
+}
@@ -0,0 +1,107 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/usecases"
+)
+
+// querier runs the repository's queries, on the connection pool or inside a transaction
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Begin() (transaction, error)
+}
+
+// transaction is a querier whose writes are kept or dropped together
+type transaction interface {
+	querier
+	Commit() error
+	Rollback() error
+}
+
+// pool runs queries on their own connection, Begin starts a transaction
+type pool struct {
+	db *sql.DB
+}
+
+func (p pool) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return p.db.Exec(query, args...)
+}
+
+func (p pool) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return p.db.Query(query, args...)
+}
+
+func (p pool) QueryRow(query string, args ...interface{}) *sql.Row {
+	return p.db.QueryRow(query, args...)
+}
+
+func (p pool) Begin() (transaction, error) {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &sqlTx{Tx: tx, savepoints: new(int)}, nil
+}
+
+// sqlTx is a database transaction, Begin inside it starts a savepoint so that the repository
+// methods that use a transaction of their own work the same inside Atomic
+type sqlTx struct {
+	*sql.Tx
+	savepoints *int
+}
+
+func (t *sqlTx) Begin() (transaction, error) {
+	*t.savepoints++
+	name := fmt.Sprintf("sp%d", *t.savepoints)
+	if _, err := t.Exec("SAVEPOINT " + name); err != nil {
+		return nil, err
+	}
+	return &savepoint{sqlTx: t, name: name}, nil
+}
+
+// savepoint is a transaction nested in another one, rolling it back only drops its own writes
+type savepoint struct {
+	*sqlTx
+	name string
+	done bool
+}
+
+func (s *savepoint) Commit() error {
+	if s.done {
+		return sql.ErrTxDone
+	}
+	s.done = true
+	_, err := s.Exec("RELEASE " + s.name)
+	return err
+}
+
+func (s *savepoint) Rollback() error {
+	if s.done {
+		return sql.ErrTxDone
+	}
+	s.done = true
+	if _, err := s.Exec("ROLLBACK TO " + s.name); err != nil {
+		return err
+	}
+	_, err := s.Exec("RELEASE " + s.name)
+	return err
+}
+
+// Run fn with a repository whose queries all belong to one transaction, committed when fn returns
+// nil and rolled back otherwise. Called inside another Atomic, it nests as a savepoint.
+func (r *CourseRepository) Atomic(fn func(repo usecases.CourseRepository) error) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(&CourseRepository{DB: tx}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
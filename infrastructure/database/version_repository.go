@@ -0,0 +1,97 @@
+package database
+
+import (
+	"encoding/json"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+//----------------------------------------------------------------versions----------------------------------------------------------------
+
+// the stored part of a course version
+type versionSnapshot struct {
+	Course   entities.Course    `json:"course"`
+	Sections []entities.Section `json:"sections"`
+	Lessons  []entities.Lesson  `json:"lessons"`
+}
+
+// Store a new version of a course, numbered one after the latest one
+func (r *CourseRepository) AddCourseVersion(version entities.CourseVersion) (entities.CourseVersion, error) {
+	snapshot, err := json.Marshal(versionSnapshot{Course: version.Course, Sections: version.Sections, Lessons: version.Lessons})
+	if err != nil {
+		return entities.CourseVersion{}, err
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return entities.CourseVersion{}, err
+	}
+	defer tx.Rollback()
+
+	var latest uint
+	err = tx.QueryRow("SELECT COALESCE(MAX(version), 0) FROM course_versions WHERE course_id = ?", version.CourseID).Scan(&latest)
+	if err != nil {
+		return entities.CourseVersion{}, err
+	}
+	version.Version = latest + 1
+
+	query := "INSERT INTO course_versions (course_id, version, snapshot_json, created_at) VALUES (?, ?, ?, ?)"
+	result, err := tx.Exec(query, version.CourseID, version.Version, string(snapshot), version.CreatedAt.UTC())
+	if err != nil {
+		return entities.CourseVersion{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return entities.CourseVersion{}, err
+	}
+	id, _ := result.LastInsertId()
+	version.ID = uint(id)
+	return version, nil
+}
+
+func scanCourseVersion(row scanner) (entities.CourseVersion, error) {
+	var version entities.CourseVersion
+	var snapshot string
+	if err := row.Scan(&version.ID, &version.CourseID, &version.Version, &snapshot, &version.CreatedAt); err != nil {
+		return entities.CourseVersion{}, err
+	}
+	var stored versionSnapshot
+	if err := json.Unmarshal([]byte(snapshot), &stored); err != nil {
+		return entities.CourseVersion{}, err
+	}
+	version.Course = stored.Course
+	version.Sections = stored.Sections
+	version.Lessons = stored.Lessons
+	return version, nil
+}
+
+// Get one version of a course
+func (r *CourseRepository) GetCourseVersion(courseID int, version uint) (entities.CourseVersion, error) {
+	query := "SELECT id, course_id, version, snapshot_json, created_at FROM course_versions WHERE course_id = ? AND version = ?"
+	return scanCourseVersion(r.DB.QueryRow(query, courseID, version))
+}
+
+// Get the latest version of a course
+func (r *CourseRepository) GetLatestCourseVersion(courseID int) (entities.CourseVersion, error) {
+	query := "SELECT id, course_id, version, snapshot_json, created_at FROM course_versions WHERE course_id = ? ORDER BY version DESC LIMIT 1"
+	return scanCourseVersion(r.DB.QueryRow(query, courseID))
+}
+
+// Get every version of a course, oldest first
+func (r *CourseRepository) GetCourseVersions(courseID int) ([]entities.CourseVersion, error) {
+	query := "SELECT id, course_id, version, snapshot_json, created_at FROM course_versions WHERE course_id = ? ORDER BY version"
+	rows, err := r.DB.Query(query, courseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []entities.CourseVersion
+	for rows.Next() {
+		version, err := scanCourseVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+	return versions, rows.Err()
+}
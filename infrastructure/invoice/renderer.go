@@ -0,0 +1,224 @@
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strings"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+	"github.com/NaheedRayan/mini_rest_api_shikho/infrastructure/pdf"
+)
+
+// portrait A4 in points
+const (
+	pageWidth  = 595.0
+	pageHeight = 842.0
+	left       = 50.0
+	right      = 545.0
+	bottom     = 170.0 // lines stop here to leave room for the totals
+)
+
+// right edges of the amount columns, the description fills the space before them
+var columns = []struct {
+	title string
+	edge  float64
+}{{"Price", 315}, {"Discount", 375}, {"Net", 435}, {"Tax", 485}, {"Total", right}}
+
+// Renderer draws invoices as single page PDFs and as HTML pages
+type Renderer struct {
+	// Locale amounts are written in on HTML invoices, PDFs write them as plain decimals
+	Locale string
+}
+
+// NewRenderer returns a renderer writing HTML amounts for locale, such as "en-US"
+func NewRenderer(locale string) *Renderer {
+	return &Renderer{Locale: locale}
+}
+
+// PDF implements usecases.InvoiceRenderer
+func (r *Renderer) PDF(invoice entities.Invoice) ([]byte, error) {
+	var content bytes.Buffer
+	content.WriteString("0.1 0.1 0.1 rg\n")
+
+	pdf.Text(&content, pdf.Bold, 24, left, 780, "Invoice")
+	alignRight(&content, pdf.Bold, 11, right, 790, invoice.Number)
+	alignRight(&content, pdf.Regular, 9, right, 776, "Issued "+invoice.IssuedAt.Format("January 2, 2006"))
+	alignRight(&content, pdf.Regular, 9, right, 764, "Paid "+invoice.PaidAt.Format("January 2, 2006"))
+
+	party(&content, left, 730, "From", invoice.Seller)
+	party(&content, 320, 730, "Bill to", invoice.Buyer)
+
+	// line table
+	y := 600.0
+	pdf.Text(&content, pdf.Bold, 9, left, y, "Description")
+	for _, column := range columns {
+		alignRight(&content, pdf.Bold, 9, column.edge, y, column.title)
+	}
+	fmt.Fprintf(&content, "0.6 0.6 0.6 RG 0.5 w %.0f %.2f m %.0f %.2f l S\n", left, y-6, right, y-6)
+	y -= 22
+	for i, line := range invoice.Lines {
+		if y < bottom {
+			pdf.Text(&content, pdf.Regular, 9, left, y, fmt.Sprintf("and %d more, see the HTML invoice", len(invoice.Lines)-i))
+			y -= 16
+			break
+		}
+		pdf.Text(&content, pdf.Regular, 9, left, y, fit(line.Description, pdf.Regular, 9, 190))
+		for j, amount := range []entities.Money{line.Price, line.Discount, line.Net, line.Tax, line.Total} {
+			alignRight(&content, pdf.Regular, 9, columns[j].edge, y, amount.Decimal())
+		}
+		y -= 16
+	}
+	fmt.Fprintf(&content, "%.0f %.2f m %.0f %.2f l S\n", left, y+8, right, y+8)
+
+	// totals
+	y -= 10
+	total := func(font *pdf.Font, label string, amount entities.Money) {
+		alignRight(&content, font, 10, 435, y, label)
+		alignRight(&content, font, 10, right, y, amount.String())
+		y -= 16
+	}
+	total(pdf.Regular, "Subtotal", invoice.Subtotal)
+	for _, tax := range invoice.Taxes {
+		total(pdf.Regular, fmt.Sprintf("%s %g%% on %s", tax.Name, tax.Rate, tax.Net.Decimal()), tax.Tax)
+	}
+	total(pdf.Bold, "Total paid", invoice.Total)
+
+	payment := "Paid by " + invoice.PaymentMethod
+	if invoice.PaymentID != "" {
+		payment += ", reference " + invoice.PaymentID
+	}
+	pdf.Text(&content, pdf.Regular, 8, left, 60, payment)
+	pdf.Text(&content, pdf.Regular, 8, left, 48, "Amounts are in "+invoice.Currency+", prices include tax.")
+
+	return pdf.Build(content.Bytes(), pageWidth, pageHeight, "Invoice "+invoice.Number), nil
+}
+
+// party writes the name, company, address and tax ID of one side of the invoice
+func party(content *bytes.Buffer, x, y float64, heading string, p entities.InvoiceParty) {
+	pdf.Text(content, pdf.Bold, 9, x, y, heading)
+	y -= 16
+	lines := []string{p.Name, p.Company}
+	lines = append(lines, strings.Split(p.Address, "\n")...)
+	if p.TaxID != "" {
+		lines = append(lines, "Tax ID: "+p.TaxID)
+	}
+	lines = append(lines, p.Email)
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			pdf.Text(content, pdf.Regular, 10, x, y, fit(line, pdf.Regular, 10, 220))
+			y -= 13
+		}
+	}
+}
+
+// alignRight writes a line of text ending at x
+func alignRight(content *bytes.Buffer, font *pdf.Font, size, x, y float64, text string) {
+	pdf.Text(content, font, size, x-font.Width(text, size), y, text)
+}
+
+// fit shortens text with an ellipsis until it is at most width wide
+func fit(text string, font *pdf.Font, size, width float64) string {
+	if font.Width(text, size) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && font.Width(string(runes)+"...", size) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return strings.TrimSpace(string(runes)) + "..."
+}
+
+// HTML implements usecases.InvoiceRenderer
+func (r *Renderer) HTML(invoice entities.Invoice) ([]byte, error) {
+	var out bytes.Buffer
+	err := htmlTemplate.Execute(&out, struct {
+		entities.Invoice
+		Locale string
+	}{invoice, r.Locale})
+	if err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+var htmlTemplate = template.Must(template.New("invoice").Funcs(template.FuncMap{
+	"lines": func(text string) []string { return strings.Split(text, "\n") },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Invoice {{.Number}}</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; color: #1a1a1a; max-width: 760px; margin: 40px auto; }
+header { display: flex; justify-content: space-between; align-items: baseline; }
+.parties { display: flex; gap: 80px; margin: 32px 0; }
+.parties h2 { font-size: 13px; margin: 0 0 6px; }
+.parties p { margin: 2px 0; }
+table { width: 100%; border-collapse: collapse; }
+th, td { padding: 6px 4px; border-bottom: 1px solid #ccc; }
+th { text-align: left; }
+.amount { text-align: right; white-space: nowrap; }
+.totals td { border: none; }
+.grand td { font-weight: bold; }
+footer { margin-top: 32px; font-size: 12px; color: #555; }
+</style>
+</head>
+<body>
+{{- $locale := .Locale}}
+<header>
+<h1>Invoice</h1>
+<div>
+<strong>{{.Number}}</strong><br>
+Issued {{.IssuedAt.Format "January 2, 2006"}}<br>
+Paid {{.PaidAt.Format "January 2, 2006"}}
+</div>
+</header>
+<section class="parties">
+<div>
+<h2>From</h2>
+{{template "party" .Seller}}
+</div>
+<div>
+<h2>Bill to</h2>
+{{template "party" .Buyer}}
+</div>
+</section>
+<table>
+<thead>
+<tr><th>Description</th><th class="amount">Price</th><th class="amount">Discount</th><th class="amount">Net</th><th class="amount">Tax</th><th class="amount">Total</th></tr>
+</thead>
+<tbody>
+{{- range .Lines}}
+<tr>
+<td>{{.Description}}</td>
+<td class="amount">{{.Price.Format $locale}}</td>
+<td class="amount">{{.Discount.Format $locale}}</td>
+<td class="amount">{{.Net.Format $locale}}</td>
+<td class="amount">{{.Tax.Format $locale}}{{if .TaxRate}} ({{.TaxRate}}%){{end}}</td>
+<td class="amount">{{.Total.Format $locale}}</td>
+</tr>
+{{- end}}
+</tbody>
+<tbody class="totals">
+<tr><td colspan="5" class="amount">Subtotal</td><td class="amount">{{.Subtotal.Format $locale}}</td></tr>
+{{- range .Taxes}}
+<tr><td colspan="5" class="amount">{{.Name}} {{.Rate}}% on {{.Net.Format $locale}}</td><td class="amount">{{.Tax.Format $locale}}</td></tr>
+{{- end}}
+<tr class="grand"><td colspan="5" class="amount">Total paid</td><td class="amount">{{.Total.Format $locale}}</td></tr>
+</tbody>
+</table>
+<footer>
+Paid by {{.PaymentMethod}}{{if .PaymentID}}, reference {{.PaymentID}}{{end}}.
+Amounts are in {{.Currency}}, prices include tax.
+</footer>
+</body>
+</html>
+{{define "party"}}
+{{- if .Name}}<p><strong>{{.Name}}</strong></p>{{end}}
+{{- if .Company}}<p>{{.Company}}</p>{{end}}
+{{- range lines .Address}}{{if .}}<p>{{.}}</p>{{end}}{{end}}
+{{- if .TaxID}}<p>Tax ID: {{.TaxID}}</p>{{end}}
+{{- if .Email}}<p>{{.Email}}</p>{{end}}
+{{- end}}
+`))
@@ -0,0 +1,59 @@
+package moderation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/usecases"
+)
+
+// DefaultBlockedWords is the word list used by NewDefaultFilter
+var DefaultBlockedWords = []string{
+	"asshole", "bastard", "bitch", "bullshit", "crap", "damn", "dick", "fuck", "fucking", "idiot", "moron", "shit", "stupid",
+}
+
+var linkPattern = regexp.MustCompile(`(?i)(https?://|www\.)\S+|\b[a-z0-9-]+\.(com|net|org|io|ru|xyz|info|biz|link)\b`)
+
+// WordFilter flags text containing blocked words and treats text with many links as spam
+type WordFilter struct {
+	blocked  map[string]bool
+	maxLinks int // more links than this is spam, any link at all is flagged
+}
+
+// NewWordFilter returns a filter for the given blocked words that rejects text with more than maxLinks links
+func NewWordFilter(words []string, maxLinks int) *WordFilter {
+	blocked := make(map[string]bool, len(words))
+	for _, word := range words {
+		blocked[strings.ToLower(word)] = true
+	}
+	return &WordFilter{blocked: blocked, maxLinks: maxLinks}
+}
+
+// NewDefaultFilter returns a WordFilter with the built-in word list that rejects more than one link
+func NewDefaultFilter() *WordFilter {
+	return NewWordFilter(DefaultBlockedWords, 1)
+}
+
+// Check implements usecases.ContentFilter
+func (f *WordFilter) Check(text string) usecases.FilterResult {
+	links := len(linkPattern.FindAllString(text, -1))
+	if links > f.maxLinks {
+		return usecases.FilterResult{Verdict: usecases.FilterReject, Reason: fmt.Sprintf("link spam: %d links", links)}
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		if f.blocked[word] {
+			return usecases.FilterResult{Verdict: usecases.FilterFlag, Reason: "contains blocked word " + word}
+		}
+	}
+
+	if links > 0 {
+		return usecases.FilterResult{Verdict: usecases.FilterFlag, Reason: "contains a link"}
+	}
+	return usecases.FilterResult{Verdict: usecases.FilterAllow}
+}
@@ -0,0 +1,115 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/usecases"
+)
+
+// FileNotifier writes every email as an .eml file into a directory instead of sending it,
+// any mail client opens them
+type FileNotifier struct {
+	dir   string
+	from  string
+	count atomic.Int64
+}
+
+// NewFileNotifier returns a notifier writing emails from the from address into dir,
+// the directory is created on the first email
+func NewFileNotifier(dir, from string) *FileNotifier {
+	return &FileNotifier{dir: dir, from: from}
+}
+
+// Send implements usecases.Notifier
+func (n *FileNotifier) Send(email usecases.Email) error {
+	message, err := compose(n.from, email)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(n.dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%d.eml", time.Now().UTC().Format("20060102T150405.000000000"), n.count.Add(1))
+	return os.WriteFile(filepath.Join(n.dir, name), message, 0o644)
+}
+
+// compose writes an email as a MIME message, the text and HTML bodies as alternatives
+// followed by the attachments
+func compose(from string, email usecases.Email) ([]byte, error) {
+	if strings.ContainsAny(email.To, "\r\n") || strings.ContainsAny(from, "\r\n") {
+		return nil, fmt.Errorf("invalid email address")
+	}
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "From: %s\r\n", from)
+	fmt.Fprintf(&out, "To: %s\r\n", email.To)
+	fmt.Fprintf(&out, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", email.Subject))
+	fmt.Fprintf(&out, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	out.WriteString("MIME-Version: 1.0\r\n")
+
+	mixed := multipart.NewWriter(&out)
+	fmt.Fprintf(&out, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", mixed.Boundary())
+
+	var body bytes.Buffer
+	alternative := multipart.NewWriter(&body)
+	parts := []struct{ contentType, content string }{{"text/plain", email.Text}}
+	if email.HTML != "" {
+		parts = append(parts, struct{ contentType, content string }{"text/html", email.HTML})
+	}
+	for _, part := range parts {
+		writer, err := alternative.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		writeBase64(writer, []byte(part.content))
+	}
+	if err := alternative.Close(); err != nil {
+		return nil, err
+	}
+	writer, err := mixed.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {fmt.Sprintf("multipart/alternative; boundary=%q", alternative.Boundary())},
+	})
+	if err != nil {
+		return nil, err
+	}
+	writer.Write(body.Bytes())
+
+	for _, attachment := range email.Attachments {
+		writer, err := mixed.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {attachment.ContentType},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		writeBase64(writer, attachment.Content)
+	}
+	if err := mixed.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// writeBase64 encodes content in lines of 76 characters
+func writeBase64(writer io.Writer, content []byte) {
+	encoded := base64.StdEncoding.EncodeToString(content)
+	for len(encoded) > 76 {
+		writer.Write([]byte(encoded[:76] + "\r\n"))
+		encoded = encoded[76:]
+	}
+	writer.Write([]byte(encoded + "\r\n"))
+}
@@ -0,0 +1,46 @@
+package notify
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/usecases"
+)
+
+// SMTPNotifier sends emails through an SMTP server, using STARTTLS when the server offers it
+type SMTPNotifier struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewSMTPNotifier returns a notifier sending emails from the from address through the server at
+// addr, such as smtp.example.com:587. The server is logged in to when a username is given, which
+// net/smtp only does over TLS or to localhost.
+func NewSMTPNotifier(addr, username, password, from string) *SMTPNotifier {
+	n := &SMTPNotifier{addr: addr, from: from}
+	if username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		n.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return n
+}
+
+// Send implements usecases.Notifier
+func (n *SMTPNotifier) Send(email usecases.Email) error {
+	sender, err := mail.ParseAddress(n.from)
+	if err != nil {
+		return fmt.Errorf("invalid sender address %q: %w", n.from, err)
+	}
+	recipient, err := mail.ParseAddress(email.To)
+	if err != nil {
+		return fmt.Errorf("invalid email address %q: %w", email.To, err)
+	}
+	message, err := compose(n.from, email)
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(n.addr, n.auth, sender.Address, []string{recipient.Address}, message)
+}
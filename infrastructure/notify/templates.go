@@ -0,0 +1,166 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/usecases"
+)
+
+// Templates words the notification emails of a school, each kind has a subject, a plain text
+// body and an HTML body
+type Templates struct {
+	site string
+	url  string
+}
+
+// NewTemplates returns the templates of the school named site whose links start at url
+func NewTemplates(site, url string) *Templates {
+	return &Templates{site: site, url: strings.TrimSuffix(url, "/")}
+}
+
+// templateData is what the templates see, the notification plus the school
+type templateData struct {
+	usecases.NotificationData
+	Site string
+	URL  string
+}
+
+// Name is the full name of the recipient
+func (d templateData) Name() string {
+	if name := strings.TrimSpace(d.User.FirstName + " " + d.User.LastName); name != "" {
+		return name
+	}
+	return "there"
+}
+
+// ReviewerName is the full name of the author of a review
+func (d templateData) ReviewerName() string {
+	return strings.TrimSpace(d.Reviewer.FirstName + " " + d.Reviewer.LastName)
+}
+
+// InviterName is the full name of the organization admin who sent an invitation
+func (d templateData) InviterName() string {
+	return strings.TrimSpace(d.Inviter.FirstName + " " + d.Inviter.LastName)
+}
+
+// Render implements usecases.EmailTemplates
+func (t *Templates) Render(kind string, data usecases.NotificationData) (usecases.Email, error) {
+	if htmlTemplates[kind] == nil {
+		return usecases.Email{}, fmt.Errorf("no email template for %q notifications", kind)
+	}
+	values := templateData{NotificationData: data, Site: t.site, URL: t.url}
+
+	var subject, text, html bytes.Buffer
+	if err := textTemplates.ExecuteTemplate(&subject, kind+".subject", values); err != nil {
+		return usecases.Email{}, err
+	}
+	if err := textTemplates.ExecuteTemplate(&text, kind+".text", values); err != nil {
+		return usecases.Email{}, err
+	}
+	if err := htmlTemplates[kind].ExecuteTemplate(&html, "layout", values); err != nil {
+		return usecases.Email{}, err
+	}
+	return usecases.Email{
+		To:      data.User.Email,
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
+
+var textTemplates = texttemplate.Must(texttemplate.New("text").Parse(`
+{{- define "welcome.subject"}}Welcome to {{.Site}}{{end}}
+{{- define "welcome.text"}}Hello {{.Name}},
+
+Welcome to {{.Site}}! Your account for {{.User.Email}} is ready.
+Browse the courses at {{.URL}}/courses and enroll in the ones you like.
+
+The {{.Site}} team
+{{end}}
+
+{{- define "enrollment.subject"}}You are enrolled in {{.Course.Title}}{{end}}
+{{- define "enrollment.text"}}Hello {{.Name}},
+
+You are enrolled in {{.Course.Title}}{{if .Course.Instructor}} by {{.Course.Instructor}}{{end}}.
+Start learning at {{.URL}}/enrollments/{{.Enrollment.ID}}/lessons.
+
+The {{.Site}} team
+{{end}}
+
+{{- define "course_completed.subject"}}You completed {{.Course.Title}}{{end}}
+{{- define "course_completed.text"}}Hello {{.Name}},
+
+Congratulations, you completed {{.Course.Title}}!
+Your certificate is at {{.URL}}/enrollments/{{.Enrollment.ID}}/certificate.
+
+The {{.Site}} team
+{{end}}
+
+{{- define "new_review.subject"}}New {{.Review.Rating}} star review of {{.Course.Title}}{{end}}
+{{- define "new_review.text"}}Hello {{.Name}},
+
+{{with .ReviewerName}}{{.}}{{else}}A student{{end}} rated {{.Course.Title}} {{.Review.Rating}} out of 5.
+{{- if .Review.Comment}}
+
+"{{.Review.Comment}}"
+{{- end}}
+
+Read the reviews of your course at {{.URL}}/reviews/course/{{.Course.ID}}.
+
+The {{.Site}} team
+{{end}}
+
+{{- define "organization_invitation.subject"}}Join {{.Organization.Name}} on {{.Site}}{{end}}
+{{- define "organization_invitation.text"}}Hello {{.Name}},
+
+{{with .InviterName}}{{.}}{{else}}An admin{{end}} invited you to join {{.Organization.Name}} as {{if eq .Invitation.Role "admin"}}an admin{{else}}a member{{end}}.
+See your invitations at {{.URL}}/users/{{.User.ID}}/invitations?status=pending and accept or decline them there.
+
+The {{.Site}} team
+{{end}}
+`))
+
+// the HTML body of every kind is its content in the same layout
+var htmlTemplates = map[string]*htmltemplate.Template{
+	"welcome": htmlTemplate(`<p>Welcome to {{.Site}}! Your account for {{.User.Email}} is ready.</p>
+<p><a class="button" href="{{.URL}}/courses">Browse the courses</a></p>`),
+	"enrollment": htmlTemplate(`<p>You are enrolled in <strong>{{.Course.Title}}</strong>{{if .Course.Instructor}} by {{.Course.Instructor}}{{end}}.</p>
+<p><a class="button" href="{{.URL}}/enrollments/{{.Enrollment.ID}}/lessons">Start learning</a></p>`),
+	"course_completed": htmlTemplate(`<p>Congratulations, you completed <strong>{{.Course.Title}}</strong>!</p>
+<p><a class="button" href="{{.URL}}/enrollments/{{.Enrollment.ID}}/certificate">Get your certificate</a></p>`),
+	"new_review": htmlTemplate(`<p>{{with .ReviewerName}}{{.}}{{else}}A student{{end}} rated <strong>{{.Course.Title}}</strong> {{.Review.Rating}} out of 5.</p>
+{{- if .Review.Comment}}
+<blockquote>{{.Review.Comment}}</blockquote>
+{{- end}}
+<p><a class="button" href="{{.URL}}/reviews/course/{{.Course.ID}}">Read the reviews</a></p>`),
+	"organization_invitation": htmlTemplate(`<p>{{with .InviterName}}{{.}}{{else}}An admin{{end}} invited you to join <strong>{{.Organization.Name}}</strong> as {{if eq .Invitation.Role "admin"}}an admin{{else}}a member{{end}}.</p>
+<p><a class="button" href="{{.URL}}/users/{{.User.ID}}/invitations?status=pending">See your invitations</a></p>`),
+}
+
+var htmlLayout = htmltemplate.Must(htmltemplate.New("layout").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<style>
+body { font-family: Helvetica, Arial, sans-serif; color: #1a1a1a; max-width: 600px; margin: 24px auto; line-height: 1.5; }
+a.button { display: inline-block; padding: 8px 16px; background: #1a56db; color: #fff; text-decoration: none; border-radius: 4px; }
+blockquote { margin: 16px 0; padding-left: 12px; border-left: 3px solid #ccc; color: #555; }
+footer { margin-top: 32px; font-size: 12px; color: #555; }
+</style>
+</head>
+<body>
+<p>Hello {{.Name}},</p>
+{{template "content" .}}
+<footer>The {{.Site}} team</footer>
+</body>
+</html>
+`))
+
+// htmlTemplate puts the content of a kind into the layout
+func htmlTemplate(content string) *htmltemplate.Template {
+	return htmltemplate.Must(htmltemplate.Must(htmlLayout.Clone()).New("content").Parse(content))
+}
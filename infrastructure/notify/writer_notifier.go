@@ -0,0 +1,46 @@
+package notify
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/usecases"
+)
+
+// WriterNotifier prints every email as readable text instead of sending it, for watching the
+// emails of a development server in its output
+type WriterNotifier struct {
+	mu   sync.Mutex
+	w    io.Writer
+	from string
+}
+
+// NewWriterNotifier returns a notifier printing emails from the from address to w
+func NewWriterNotifier(w io.Writer, from string) *WriterNotifier {
+	return &WriterNotifier{w: w, from: from}
+}
+
+// NewStdoutNotifier returns a notifier printing emails from the from address to the standard output
+func NewStdoutNotifier(from string) *WriterNotifier {
+	return NewWriterNotifier(os.Stdout, from)
+}
+
+// Send implements usecases.Notifier, the HTML body is left out
+func (n *WriterNotifier) Send(email usecases.Email) error {
+	var out strings.Builder
+	fmt.Fprintf(&out, "----- email %s -----\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(&out, "From: %s\nTo: %s\nSubject: %s\n", n.from, email.To, email.Subject)
+	for _, attachment := range email.Attachments {
+		fmt.Fprintf(&out, "Attachment: %s (%s, %d bytes)\n", attachment.Filename, attachment.ContentType, len(attachment.Content))
+	}
+	fmt.Fprintf(&out, "\n%s\n", strings.TrimRight(email.Text, "\n"))
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	_, err := io.WriteString(n.w, out.String())
+	return err
+}
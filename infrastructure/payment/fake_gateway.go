@@ -0,0 +1,181 @@
+package payment
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/usecases"
+)
+
+// SignatureHeader carries the HMAC-SHA256 of a webhook body, hex encoded
+const SignatureHeader = "X-Payment-Signature"
+
+// payment method tokens understood by FakeGateway
+const (
+	TokenSuccess    = "tok_success"     // paid immediately
+	TokenDecline    = "tok_decline"     // declined immediately
+	Token3DSSuccess = "tok_3ds_success" // requires a 3DS challenge, then succeeds by webhook
+	Token3DSDecline = "tok_3ds_decline" // requires a 3DS challenge, then fails by webhook
+)
+
+// FakeGateway is a local stand-in for a card processor. It answers charges according to the
+// payment method token and completes 3DS payments by calling the webhook URL after a delay.
+type FakeGateway struct {
+	webhookURL string
+	secret     []byte
+	delay      time.Duration
+	client     *http.Client
+	header     http.Header // sent with every webhook
+
+	mu      sync.Mutex
+	refunds map[string]string // gateway refund IDs by idempotency key
+}
+
+// NewFakeGateway returns a gateway that signs the webhooks it sends to webhookURL with secret
+func NewFakeGateway(webhookURL, secret string, delay time.Duration) *FakeGateway {
+	return &FakeGateway{
+		webhookURL: webhookURL,
+		secret:     []byte(secret),
+		delay:      delay,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		header:     http.Header{},
+		refunds:    map[string]string{},
+	}
+}
+
+// SetWebhookHeader adds a header to the webhooks it sends, e.g. the tenant header when the
+// webhook URL cannot carry the tenant's subdomain
+func (g *FakeGateway) SetWebhookHeader(key, value string) {
+	g.header.Set(key, value)
+}
+
+// Charge implements usecases.PaymentGateway
+func (g *FakeGateway) Charge(payment usecases.PaymentRequest) (usecases.PaymentResult, error) {
+	if !payment.Amount.IsPositive() {
+		return usecases.PaymentResult{}, fmt.Errorf("amount must be greater than zero")
+	}
+	result := usecases.PaymentResult{ID: newID("pay_")}
+	switch payment.Method {
+	case TokenSuccess:
+		result.Status = usecases.PaymentSucceeded
+	case TokenDecline:
+		result.Status = usecases.PaymentDeclined
+		result.FailureReason = "card declined"
+	case Token3DSSuccess, Token3DSDecline:
+		if payment.OffSession {
+			result.Status = usecases.PaymentDeclined
+			result.FailureReason = "authentication required"
+			break
+		}
+		result.Status = usecases.PaymentRequiresAction
+		result.ActionURL = "https://fake-gateway.local/3ds/" + result.ID
+		event := usecases.PaymentEvent{PaymentID: result.ID, OrderID: payment.OrderID, Status: usecases.PaymentSucceeded}
+		if payment.Method == Token3DSDecline {
+			event.Status = usecases.PaymentDeclined
+			event.FailureReason = "3DS authentication failed"
+		}
+		go g.deliver(event)
+	default:
+		return usecases.PaymentResult{}, fmt.Errorf("unknown payment method %q", payment.Method)
+	}
+	return result, nil
+}
+
+// Refund implements usecases.PaymentGateway, every refund of a payment it made succeeds and a
+// repeated idempotency key returns the refund made the first time
+func (g *FakeGateway) Refund(refund usecases.RefundRequest) (string, error) {
+	if !strings.HasPrefix(refund.PaymentID, "pay_") {
+		return "", fmt.Errorf("unknown payment %q", refund.PaymentID)
+	}
+	if !refund.Amount.IsPositive() {
+		return "", fmt.Errorf("amount must be greater than zero")
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if id, ok := g.refunds[refund.IdempotencyKey]; ok && refund.IdempotencyKey != "" {
+		return id, nil
+	}
+	id := newID("re_")
+	if refund.IdempotencyKey != "" {
+		g.refunds[refund.IdempotencyKey] = id
+	}
+	return id, nil
+}
+
+// ParseWebhook implements usecases.PaymentGateway
+func (g *FakeGateway) ParseWebhook(payload []byte, signature string) (usecases.PaymentEvent, error) {
+	expected, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(expected, g.sign(payload)) {
+		return usecases.PaymentEvent{}, errors.New("invalid webhook signature")
+	}
+	var event usecases.PaymentEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return usecases.PaymentEvent{}, err
+	}
+	return event, nil
+}
+
+// Sign returns the signature header value for a webhook body, for replaying events by hand
+func (g *FakeGateway) Sign(payload []byte) string {
+	return hex.EncodeToString(g.sign(payload))
+}
+
+func (g *FakeGateway) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, g.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// deliver posts an event to the webhook URL after the simulated customer action,
+// retrying like a real gateway until it is acknowledged
+func (g *FakeGateway) deliver(event usecases.PaymentEvent) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("payment webhook for %s: %v", event.PaymentID, err)
+		return
+	}
+	wait := g.delay
+	for attempt := 1; attempt <= 5; attempt++ {
+		time.Sleep(wait)
+		wait *= 2
+
+		req, err := http.NewRequest(http.MethodPost, g.webhookURL, bytes.NewReader(payload))
+		if err != nil {
+			log.Printf("payment webhook for %s: %v", event.PaymentID, err)
+			return
+		}
+		for key, values := range g.header {
+			req.Header[key] = values
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(SignatureHeader, g.Sign(payload))
+		resp, err := g.client.Do(req)
+		if err != nil {
+			log.Printf("payment webhook for %s, attempt %d: %v", event.PaymentID, attempt, err)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 300 {
+			return
+		}
+		log.Printf("payment webhook for %s, attempt %d: %s", event.PaymentID, attempt, resp.Status)
+	}
+}
+
+// newID returns a random reference like the gateway's, e.g. pay_... for payments and re_... for refunds
+func newID(prefix string) string {
+	buf := make([]byte, 12)
+	rand.Read(buf)
+	return prefix + hex.EncodeToString(buf)
+}
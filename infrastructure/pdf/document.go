@@ -0,0 +1,121 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Build assembles a one page document of the given size in points around a content stream.
+// The content draws text with the Regular (F1) and Bold (F2) fonts, which are the standard
+// Helvetica fonts so no font files need to be embedded.
+func Build(content []byte, width, height float64, title string) []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Contents 4 0 R /Resources << /Font << /F1 5 0 R /F2 6 0 R >> >> >>", width, height),
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Title (%s) /Producer (mini_rest_api_shikho) >>", escape(encode(title))),
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, len(objects), xref)
+	return out.Bytes()
+}
+
+// Text writes a line of text with its baseline starting at x, y
+func Text(content *bytes.Buffer, font *Font, size, x, y float64, text string) {
+	fmt.Fprintf(content, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font.name, size, x, y, escape(encode(text)))
+}
+
+// encode converts text to the single byte encoding of the standard fonts, characters
+// outside it become '?'
+func encode(text string) []byte {
+	encoded := make([]byte, 0, len(text))
+	for _, r := range text {
+		switch {
+		case r < 0x80 || (r >= 0xa0 && r <= 0xff):
+			encoded = append(encoded, byte(r))
+		case r == '€':
+			encoded = append(encoded, 0x80)
+		case r == '‘' || r == '’':
+			encoded = append(encoded, '\'')
+		case r == '“' || r == '”':
+			encoded = append(encoded, '"')
+		case r == '–' || r == '—':
+			encoded = append(encoded, '-')
+		default:
+			encoded = append(encoded, '?')
+		}
+	}
+	return encoded
+}
+
+// escape quotes a string for a PDF literal
+func escape(text []byte) string {
+	var b strings.Builder
+	for _, c := range text {
+		switch c {
+		case '(', ')', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\n', '\r':
+			b.WriteByte(' ')
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// Font is a standard font with the widths of its printable ASCII characters
+type Font struct {
+	name   string
+	widths [95]int // thousandths of the font size, for ' ' to '~'
+}
+
+// Width returns how wide text is drawn at a font size, in points
+func (f *Font) Width(text string, size float64) float64 {
+	total := 0
+	for _, c := range encode(text) {
+		if c >= ' ' && c <= '~' {
+			total += f.widths[c-' ']
+		} else {
+			total += 556
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// Regular is Helvetica
+var Regular = &Font{name: "F1", widths: [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}}
+
+// Bold is Helvetica-Bold
+var Bold = &Font{name: "F2", widths: [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}}
@@ -8,16 +8,23 @@ import (
 
 func SetupRouter(courseHandler *interfaces.CourseHandler) *gin.Engine {
 	router := gin.Default()
+	router.Use(interfaces.RequestID())
 
 	// Define routes
 
 
+	// Session routes
+	router.POST("/login", courseHandler.Login)
+	router.POST("/logout", courseHandler.Logout)
+	router.GET("/me", courseHandler.GetCurrentUser)
+
 	// User routes
 	router.POST("/user", courseHandler.CreateUser)
 	router.GET("/users", courseHandler.GetAllUsers)
 	router.GET("/user/:id", courseHandler.GetUserByID)
 	router.PUT("/user", courseHandler.UpdateUser)
 	router.DELETE("/user/:id", courseHandler.DeleteUser)
+	router.POST("/users/:id/restore", courseHandler.RestoreUser)
 	
 	// Course routes
 	router.POST("/course", courseHandler.CreateCourse)
@@ -25,14 +32,49 @@ func SetupRouter(courseHandler *interfaces.CourseHandler) *gin.Engine {
 	router.GET("/course/:id", courseHandler.GetCourseByID)
 	router.PUT("/course", courseHandler.UpdateCourse)
 	router.DELETE("/course/:id", courseHandler.DeleteCourse)
+	router.POST("/courses/:id/restore", courseHandler.RestoreCourse)
+
+	// Publishing routes
+	router.POST("/courses/:id/submit", courseHandler.SubmitCourse)
+	router.POST("/courses/:id/withdraw", courseHandler.WithdrawCourse)
+	router.POST("/courses/:id/approve", courseHandler.ApproveCourse)
+	router.POST("/courses/:id/reject", courseHandler.RejectCourse)
+	router.POST("/courses/:id/archive", courseHandler.ArchiveCourse)
+	router.POST("/courses/:id/reopen", courseHandler.ReopenCourse)
+
+	// Version routes
+	router.GET("/courses/:id/versions", courseHandler.GetCourseVersions)
+	router.GET("/courses/:id/versions/diff", courseHandler.DiffCourseVersions)
+	router.GET("/courses/:id/versions/:version", courseHandler.GetCourseVersion)
+
+	// Prerequisite routes
+	router.GET("/courses/:id/prerequisites", courseHandler.GetPrerequisites)
+	router.PUT("/courses/:id/prerequisites/:prerequisiteID", courseHandler.SetPrerequisite)
+	router.DELETE("/courses/:id/prerequisites/:prerequisiteID", courseHandler.DeletePrerequisite)
+
+	// Price routes
+	router.GET("/courses/:id/prices", courseHandler.GetCoursePrices)
+	router.PUT("/courses/:id/prices", courseHandler.SetCoursePrice)
+	router.DELETE("/courses/:id/prices/:currency", courseHandler.DeleteCoursePrice)
+
+	// Learning path routes
+	router.POST("/learning-path", courseHandler.AddLearningPath)
+	router.GET("/learning-paths", courseHandler.GetAllLearningPaths)
+	router.GET("/learning-path/:id", courseHandler.GetLearningPathByID)
+	router.PUT("/learning-path", courseHandler.UpdateLearningPath)
+	router.DELETE("/learning-path/:id", courseHandler.DeleteLearningPath)
+	router.GET("/learning-paths/:id/progress", courseHandler.GetLearningPathProgress)
 
 	// Enroll routes
 	router.POST("/enroll", courseHandler.AddEnrollment)
+	router.PUT("/enrollments", courseHandler.EnsureEnrollment)
 	router.GET("/enrolls", courseHandler.GetAllEnrollments)
 	router.GET("/enroll/:id", courseHandler.GetEnrollmentByID)
 	router.GET("/enrolls/user/:id", courseHandler.GetEnrollmentsByUserID)
 	router.PUT("/enroll", courseHandler.UpdateEnrollment)
 	router.DELETE("/enroll/:id", courseHandler.DeleteEnrollment)
+	router.GET("/enrollments/:id/lessons", courseHandler.GetEnrollmentLessons)
+	router.POST("/enrollments/:id/upgrade", courseHandler.UpgradeEnrollment)
 
 	// Lesson routes
 	router.POST("/lesson", courseHandler.AddLesson)
@@ -40,16 +82,157 @@ func SetupRouter(courseHandler *interfaces.CourseHandler) *gin.Engine {
 	router.GET("/lesson/:id", courseHandler.GetLessonsByID)
 	router.PUT("/lesson", courseHandler.UpdateLesson)
 	router.DELETE("/lesson/:id", courseHandler.DeleteLesson)
+	router.POST("/lessons/:id/restore", courseHandler.RestoreLesson)
+	router.POST("/lessons/:id/move", courseHandler.MoveLesson)
+
+	// Curriculum routes
+	router.GET("/courses/:id/curriculum", courseHandler.GetCurriculum)
+	router.POST("/courses/:id/curriculum/reorder", courseHandler.ReorderCurriculum)
+	router.POST("/section", courseHandler.AddSection)
+	router.PUT("/section", courseHandler.UpdateSection)
+	router.DELETE("/section/:id", courseHandler.DeleteSection)
+
+	// Quiz routes
+	router.POST("/question-bank", courseHandler.AddQuestionBank)
+	router.GET("/courses/:id/question-banks", courseHandler.GetQuestionBanks)
+	router.GET("/question-banks/:id/questions", courseHandler.GetBankQuestions)
+	router.POST("/question", courseHandler.AddQuestion)
+	router.PUT("/question", courseHandler.UpdateQuestion)
+	router.DELETE("/question/:id", courseHandler.DeleteQuestion)
+	router.POST("/quiz", courseHandler.AddQuiz)
+	router.PUT("/quiz", courseHandler.UpdateQuiz)
+	router.DELETE("/quiz/:id", courseHandler.DeleteQuiz)
+	router.GET("/lessons/:id/quiz", courseHandler.GetLessonQuiz)
+	router.POST("/quizzes/:id/attempts", courseHandler.StartQuizAttempt)
+	router.POST("/attempts/:id/submit", courseHandler.SubmitQuizAttempt)
+	router.GET("/attempts/:id", courseHandler.GetQuizAttempt)
+	router.GET("/enrollments/:id/quizzes/:quizID/attempts", courseHandler.GetQuizAttempts)
+
+	// Assignment routes
+	router.POST("/assignment", courseHandler.AddAssignment)
+	router.PUT("/assignment", courseHandler.UpdateAssignment)
+	router.DELETE("/assignment/:id", courseHandler.DeleteAssignment)
+	router.GET("/assignment/:id", courseHandler.GetAssignment)
+	router.GET("/courses/:id/assignments", courseHandler.GetCourseAssignments)
+	router.POST("/assignments/:id/submissions", courseHandler.SubmitAssignment)
+	router.GET("/assignments/:id/submissions", courseHandler.GetSubmissions)
+	router.GET("/submissions/:id", courseHandler.GetSubmission)
+	router.POST("/submissions/:id/grade", courseHandler.GradeSubmission)
+	router.GET("/submission-files/:id", courseHandler.DownloadSubmissionFile)
+	router.GET("/enrollments/:id/grades", courseHandler.GetEnrollmentGrades)
+
+	// Gradebook routes
+	router.GET("/courses/:id/gradebook", courseHandler.GetGradebook)
+	router.GET("/courses/:id/gradebook/export", courseHandler.ExportGradebook)
+	router.GET("/courses/:id/gradebook/students/:userID", courseHandler.GetStudentGradebook)
+	router.GET("/courses/:id/grade-weights", courseHandler.GetGradeWeights)
+	router.PUT("/courses/:id/grade-weights", courseHandler.SetGradeWeights)
+
+	// Certificate routes
+	router.GET("/certificates/:id", courseHandler.GetCertificate)
+	router.GET("/certificates/verify/:code", courseHandler.VerifyCertificate)
+	router.POST("/certificates/:id/revoke", courseHandler.RevokeCertificate)
+	router.GET("/enrollments/:id/certificate", courseHandler.GetEnrollmentCertificate)
+	router.POST("/enrollments/:id/certificate", courseHandler.IssueCertificate)
+
+	// Order routes
+	router.GET("/cart", courseHandler.GetCart)
+	router.POST("/cart/items", courseHandler.AddToCart)
+	router.DELETE("/cart/items/:courseID", courseHandler.RemoveFromCart)
+	router.POST("/cart/coupons", courseHandler.ApplyCoupon)
+	router.DELETE("/cart/coupons/:code", courseHandler.RemoveCoupon)
+	router.POST("/checkout", courseHandler.Checkout)
+	router.GET("/orders", courseHandler.GetOrders)
+	router.GET("/orders/:id", courseHandler.GetOrder)
+	router.POST("/orders/:id/cancel", courseHandler.CancelOrder)
+	router.POST("/payments/webhook", courseHandler.PaymentWebhook)
+
+	// Coupon routes
+	router.POST("/coupon", courseHandler.CreateCoupon)
+	router.GET("/coupons", courseHandler.GetAllCoupons)
+	router.GET("/coupon/:id", courseHandler.GetCoupon)
+	router.PUT("/coupon", courseHandler.UpdateCoupon)
+	router.DELETE("/coupon/:id", courseHandler.DeleteCoupon)
+	router.GET("/coupons/:id/redemptions", courseHandler.GetCouponReport)
+
+	// Refund routes
+	router.POST("/orders/:id/refunds", courseHandler.RequestRefund)
+	router.GET("/orders/:id/refunds", courseHandler.GetOrderRefunds)
+	router.GET("/refunds", courseHandler.GetRefunds)
+	router.POST("/refunds/:id/approve", courseHandler.ApproveRefund)
+	router.POST("/refunds/:id/reject", courseHandler.RejectRefund)
+
+	// Invoice routes
+	router.POST("/orders/:id/invoice", courseHandler.IssueInvoice)
+	router.GET("/users/:id/invoices", courseHandler.GetUserInvoices)
+	router.GET("/invoices/:id", courseHandler.GetInvoice)
+	router.POST("/invoices/:id/email", courseHandler.EmailInvoice)
+	router.GET("/users/:id/billing", courseHandler.GetBillingDetails)
+	router.PUT("/users/:id/billing", courseHandler.SetBillingDetails)
+
+	// Subscription routes
+	router.POST("/subscription-plan", courseHandler.CreateSubscriptionPlan)
+	router.GET("/subscription-plans", courseHandler.GetSubscriptionPlans)
+	router.GET("/subscription-plan/:id", courseHandler.GetSubscriptionPlan)
+	router.PUT("/subscription-plan", courseHandler.UpdateSubscriptionPlan)
+	router.POST("/subscriptions", courseHandler.Subscribe)
+	router.GET("/users/:id/subscriptions", courseHandler.GetUserSubscriptions)
+	router.GET("/subscriptions/:id", courseHandler.GetSubscription)
+	router.GET("/subscriptions/:id/payments", courseHandler.GetSubscriptionPayments)
+	router.POST("/subscriptions/:id/cancel", courseHandler.CancelSubscription)
+	router.POST("/subscriptions/:id/resume", courseHandler.ResumeSubscription)
+	router.PUT("/subscriptions/:id/payment-method", courseHandler.UpdateSubscriptionPaymentMethod)
+	router.GET("/billing/clock", courseHandler.GetBillingClock)
+	router.POST("/billing/clock/advance", courseHandler.AdvanceBillingClock)
+	router.POST("/billing/run", courseHandler.RunBilling)
+
+	// Organization routes
+	router.POST("/organizations", courseHandler.CreateOrganization)
+	router.GET("/organizations", courseHandler.GetOrganizations)
+	router.GET("/users/:id/organizations", courseHandler.GetUserOrganizations)
+	router.GET("/organizations/:id", courseHandler.GetOrganization)
+	router.PUT("/organizations/:id", courseHandler.RenameOrganization)
+	router.GET("/organizations/:id/members", courseHandler.GetOrganizationMembers)
+	router.PUT("/organizations/:id/members/:userID", courseHandler.SetOrganizationMemberRole)
+	router.DELETE("/organizations/:id/members/:userID", courseHandler.RemoveOrganizationMember)
+	router.POST("/organizations/:id/invitations", courseHandler.InviteOrganizationMember)
+	router.GET("/organizations/:id/invitations", courseHandler.GetOrganizationInvitations)
+	router.GET("/users/:id/invitations", courseHandler.GetUserInvitations)
+	router.POST("/invitations/:id/accept", courseHandler.AcceptOrganizationInvitation)
+	router.POST("/invitations/:id/decline", courseHandler.DeclineOrganizationInvitation)
+	router.POST("/invitations/:id/cancel", courseHandler.CancelOrganizationInvitation)
+	router.GET("/organizations/:id/seats", courseHandler.GetSeatPools)
+	router.POST("/organizations/:id/seats", courseHandler.PurchaseSeats)
+	router.GET("/organizations/:id/seat-purchases", courseHandler.GetSeatPurchases)
+	router.POST("/organizations/:id/seats/:courseID/enroll", courseHandler.EnrollMembers)
+	router.GET("/organizations/:id/progress", courseHandler.GetOrganizationProgress)
+
+	// Notification routes
+	router.GET("/users/:id/notifications", courseHandler.GetNotificationPreferences)
+	router.PUT("/users/:id/notifications", courseHandler.SetNotificationPreferences)
+	router.GET("/notifications/outbox", courseHandler.GetOutbox)
+	router.POST("/notifications/outbox/:id/retry", courseHandler.RetryOutboxEmail)
 
 	// Progress routes
 	router.POST("/progress", courseHandler.AddProgress)
 	router.PUT("/progress", courseHandler.UpdateProgress)
 	router.GET("/progress/:enrollmentID/:lessonID", courseHandler.GetProgressByEnrollmentAndLesson)
+	router.GET("/enrollments/:id/progress", courseHandler.GetEnrollmentProgress)
+	router.PUT("/enrollments/:id/lessons/:lessonID/complete", courseHandler.MarkLessonComplete)
 
 	// Review routes
 	router.POST("/review", courseHandler.AddReview)
+	router.PUT("/review", courseHandler.UpdateReview)
 	router.GET("/reviews/course/:id", courseHandler.GetReviewsByCourseID)
 	router.DELETE("/review/:id", courseHandler.DeleteReview)
+	router.POST("/reviews/:id/flag", courseHandler.FlagReview)
+	router.POST("/reviews/:id/reply", courseHandler.ReplyToReview)
+
+	// Admin routes
+	router.GET("/admin/audit", courseHandler.GetAuditLogs)
+	router.GET("/admin/reviews", courseHandler.GetModerationQueue)
+	router.POST("/admin/reviews/:id/moderate", courseHandler.ModerateReview)
+	router.GET("/admin/reviews/:id/flags", courseHandler.GetReviewFlags)
 
 
 
@@ -0,0 +1,60 @@
+package infrastructure
+
+import (
+	"log"
+	"time"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/usecases"
+)
+
+// StartPurgeScheduler hard deletes soft deleted rows older than retention, checking every interval
+func StartPurgeScheduler(uc *usecases.CourseUseCase, retention, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			purged, err := uc.PurgeDeleted(retention)
+			if err != nil {
+				log.Printf("Failed to purge deleted rows: %v", err)
+				continue
+			}
+			if purged > 0 {
+				log.Printf("Purged %d deleted rows", purged)
+			}
+		}
+	}()
+}
+
+// StartBillingScheduler renews subscriptions and ends lapsed ones, checking every interval
+func StartBillingScheduler(uc *usecases.CourseUseCase, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			processed, err := uc.RunBilling()
+			if err != nil {
+				log.Printf("Failed to run billing: %v", err)
+				continue
+			}
+			if processed > 0 {
+				log.Printf("Billed %d subscriptions", processed)
+			}
+		}
+	}()
+}
+
+// StartMailer sends the emails waiting in the outbox, checking every interval
+func StartMailer(uc *usecases.CourseUseCase, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if _, err := uc.DeliverOutbox(); err != nil {
+				log.Printf("Failed to deliver the outbox: %v", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,72 @@
+package tenant
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+// a tenant ID is used as a subdomain and a directory name, so it is a lowercase DNS label
+var validID = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// LoadTenants reads the tenants hosted on the deployment from a JSON file:
+//
+//	[{"id": "default", "name": "Shikho"}, {"id": "acme", "name": "Acme Academy", "url": "https://acme.example.com"}]
+//
+// The default tenant is always hosted, a missing file hosts it alone. Tenants without a URL are
+// reached on a subdomain of localhost.
+func LoadTenants(path string) ([]entities.Tenant, error) {
+	var tenants []entities.Tenant
+	content, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(content, &tenants); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	seen := map[string]bool{}
+	for i := range tenants {
+		tenant := &tenants[i]
+		if !validID.MatchString(tenant.ID) {
+			return nil, fmt.Errorf("%s: tenant ID %q must be lowercase letters, digits and hyphens", path, tenant.ID)
+		}
+		if seen[tenant.ID] {
+			return nil, fmt.Errorf("%s: tenant %q is listed twice", path, tenant.ID)
+		}
+		seen[tenant.ID] = true
+		if tenant.Name == "" {
+			tenant.Name = tenant.ID
+		}
+		if tenant.URL == "" {
+			tenant.URL = defaultURL(tenant.ID)
+		}
+	}
+	if !seen[entities.DefaultTenantID] {
+		tenants = append([]entities.Tenant{{ID: entities.DefaultTenantID, Name: "Shikho", URL: defaultURL(entities.DefaultTenantID)}}, tenants...)
+	}
+	return tenants, nil
+}
+
+// Path returns where a file of a tenant is kept. The default tenant keeps its files where the
+// single school kept them, the others under tenants/<id>/.
+func Path(tenant entities.Tenant, name string) string {
+	if tenant.ID == entities.DefaultTenantID {
+		return name
+	}
+	return filepath.Join("tenants", tenant.ID, name)
+}
+
+func defaultURL(id string) string {
+	if id == entities.DefaultTenantID {
+		return "http://localhost:8080"
+	}
+	return "http://" + id + ".localhost:8080"
+}
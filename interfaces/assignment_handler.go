@@ -0,0 +1,296 @@
+package interfaces
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+	"github.com/NaheedRayan/mini_rest_api_shikho/usecases"
+
+	"github.com/gin-gonic/gin"
+)
+
+//----------------------------------------------------------------assignments----------------------------------------------------------------
+
+// AddAssignment creates an assignment for a course
+func (h *CourseHandler) AddAssignment(c *gin.Context) {
+	var assignment entities.Assignment
+	if err := c.ShouldBindJSON(&assignment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	assignment, err := h.useCase(c).AddAssignment(assignment, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, assignment)
+}
+
+// UpdateAssignment edits an assignment
+func (h *CourseHandler) UpdateAssignment(c *gin.Context) {
+	var assignment entities.Assignment
+	if err := c.ShouldBindJSON(&assignment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	assignment, err := h.useCase(c).UpdateAssignment(assignment, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, assignment)
+}
+
+// DeleteAssignment removes an assignment without submissions
+func (h *CourseHandler) DeleteAssignment(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid assignment ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	if err := h.useCase(c).DeleteAssignment(id, user); err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Assignment deleted successfully"})
+}
+
+// GetAssignment returns an assignment
+func (h *CourseHandler) GetAssignment(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid assignment ID"})
+		return
+	}
+	filter, ok := h.courseFilter(c)
+	if !ok {
+		return
+	}
+
+	assignment, err := h.UseCase.GetAssignment(id, filter)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, assignment)
+}
+
+// GetCourseAssignments lists the assignments of a course
+func (h *CourseHandler) GetCourseAssignments(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+		return
+	}
+	filter, ok := h.courseFilter(c)
+	if !ok {
+		return
+	}
+
+	assignments, err := h.UseCase.GetCourseAssignments(id, filter)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, assignments)
+}
+
+//----------------------------------------------------------------submissions----------------------------------------------------------------
+
+// SubmitAssignment hands in an assignment, either as JSON {"enrollment_id": ..., "text": ...} or as a
+// multipart form with enrollment_id, text and any number of files fields
+func (h *CourseHandler) SubmitAssignment(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid assignment ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	var submission entities.Submission
+	var uploads []usecases.FileUpload
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		form, err := c.MultipartForm()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		enrollmentID, err := strconv.Atoi(c.PostForm("enrollment_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid enrollment ID"})
+			return
+		}
+		submission.EnrollmentID = uint(enrollmentID)
+		submission.Text = c.PostForm("text")
+		for _, header := range form.File["files"] {
+			file, err := header.Open()
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			defer file.Close()
+			uploads = append(uploads, usecases.FileUpload{
+				Name:        header.Filename,
+				ContentType: header.Header.Get("Content-Type"),
+				Size:        header.Size,
+				Content:     file,
+			})
+		}
+	} else if err := c.ShouldBindJSON(&submission); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	submission, err = h.useCase(c).SubmitAssignment(id, submission, uploads, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), errorBody(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, submission)
+}
+
+// GetSubmissions lists the submissions to an assignment, of one enrollment with ?enrollment_id=
+func (h *CourseHandler) GetSubmissions(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid assignment ID"})
+		return
+	}
+	enrollmentID := 0
+	if value := c.Query("enrollment_id"); value != "" {
+		if enrollmentID, err = strconv.Atoi(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid enrollment ID"})
+			return
+		}
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	submissions, err := h.UseCase.GetSubmissions(id, enrollmentID, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, submissions)
+}
+
+// GetSubmission returns a submission with its files and grade
+func (h *CourseHandler) GetSubmission(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid submission ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	submission, err := h.UseCase.GetSubmission(id, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, submission)
+}
+
+// GradeSubmission grades a submission with {"rubric_scores": [...]} or {"points": ...} and "feedback"
+func (h *CourseHandler) GradeSubmission(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid submission ID"})
+		return
+	}
+	var grade entities.SubmissionGrade
+	if err := c.ShouldBindJSON(&grade); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	submission, err := h.useCase(c).GradeSubmission(id, grade, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, submission)
+}
+
+// DownloadSubmissionFile sends a file of a submission
+func (h *CourseHandler) DownloadSubmissionFile(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	file, content, err := h.UseCase.OpenSubmissionFile(id, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+	defer content.Close()
+
+	c.DataFromReader(http.StatusOK, file.Size, file.ContentType, content, map[string]string{
+		"Content-Disposition": "attachment; filename=" + strconv.Quote(file.Name),
+	})
+}
+
+// GetEnrollmentGrades returns the quiz and assignment grades of an enrollment
+func (h *CourseHandler) GetEnrollmentGrades(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid enrollment ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	grades, err := h.UseCase.GetEnrollmentGrades(id, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, grades)
+}
@@ -0,0 +1,73 @@
+package interfaces
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+
+	"github.com/gin-gonic/gin"
+)
+
+//----------------------------------------------------------------audit----------------------------------------------------------------
+
+// GetAuditLogs lists audit log entries, filterable by entity_type, entity_id, actor_id,
+// from and to (RFC 3339) and limit. Only admins may read the audit log.
+func (h *CourseHandler) GetAuditLogs(c *gin.Context) {
+	if !h.requireAdmin(c) {
+		return
+	}
+
+	filter := entities.AuditFilter{EntityType: c.Query("entity_type")}
+	var err error
+	if filter.EntityID, err = queryUint(c, "entity_id"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid entity ID"})
+		return
+	}
+	if filter.ActorID, err = queryUint(c, "actor_id"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid actor ID"})
+		return
+	}
+	if filter.From, err = queryTime(c, "from"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from time, expected RFC 3339"})
+		return
+	}
+	if filter.To, err = queryTime(c, "to"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to time, expected RFC 3339"})
+		return
+	}
+	limit, err := queryUint(c, "limit")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+	filter.Limit = int(limit)
+
+	logs, err := h.UseCase.ListAuditLogs(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, logs)
+}
+
+// queryUint parses an optional unsigned query parameter, 0 when absent
+func queryUint(c *gin.Context, name string) (uint, error) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(value, 10, 32)
+	return uint(n), err
+}
+
+// queryTime parses an optional RFC 3339 query parameter, the zero time when absent
+func queryTime(c *gin.Context, name string) (time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,53 @@
+package interfaces
+
+import (
+	"net/http"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+
+	"github.com/gin-gonic/gin"
+)
+
+//----------------------------------------------------------------sessions----------------------------------------------------------------
+
+// Login signs a user in with {"email": ..., "password": ...} and returns a session token, sent
+// as "Authorization: Bearer <token>" on the requests made as that user
+func (h *CourseHandler) Login(c *gin.Context) {
+	var credentials entities.Credentials
+	if err := c.ShouldBindJSON(&credentials); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, err := h.UseCase.Login(credentials)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, session)
+}
+
+// Logout ends the session of the request's token
+func (h *CourseHandler) Logout(c *gin.Context) {
+	if _, ok := h.requireUser(c); !ok {
+		return
+	}
+
+	if err := h.UseCase.Logout(sessionToken(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// GetCurrentUser returns the signed in user
+func (h *CourseHandler) GetCurrentUser(c *gin.Context) {
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
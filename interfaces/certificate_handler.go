@@ -0,0 +1,129 @@
+package interfaces
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+//----------------------------------------------------------------certificates----------------------------------------------------------------
+
+// GetCertificate returns a certificate as JSON, or its PDF when the ID ends in .pdf
+func (h *CourseHandler) GetCertificate(c *gin.Context) {
+	param := c.Param("id")
+	pdf := strings.HasSuffix(param, ".pdf")
+	id, err := strconv.Atoi(strings.TrimSuffix(param, ".pdf"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid certificate ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	if !pdf {
+		certificate, err := h.UseCase.GetCertificate(id, user)
+		if err != nil {
+			c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, certificate)
+		return
+	}
+
+	certificate, content, err := h.UseCase.OpenCertificatePDF(id, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+	defer content.Close()
+
+	c.DataFromReader(http.StatusOK, -1, "application/pdf", content, map[string]string{
+		"Content-Disposition": fmt.Sprintf("inline; filename=\"certificate-%s.pdf\"", certificate.Code),
+	})
+}
+
+// VerifyCertificate publicly checks a certificate by its verification code
+func (h *CourseHandler) VerifyCertificate(c *gin.Context) {
+	verification, err := h.UseCase.VerifyCertificate(c.Param("code"))
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, verification)
+}
+
+// RevokeCertificate invalidates a certificate with {"reason": ...}
+func (h *CourseHandler) RevokeCertificate(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid certificate ID"})
+		return
+	}
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	certificate, err := h.useCase(c).RevokeCertificate(id, body.Reason, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), errorBody(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, certificate)
+}
+
+// GetEnrollmentCertificate returns the certificate of an enrollment
+func (h *CourseHandler) GetEnrollmentCertificate(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid enrollment ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	certificate, err := h.UseCase.GetEnrollmentCertificate(id, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, certificate)
+}
+
+// IssueCertificate issues the certificate of a completed enrollment that has none yet
+func (h *CourseHandler) IssueCertificate(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid enrollment ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	certificate, err := h.useCase(c).IssueCertificate(id, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, certificate)
+}
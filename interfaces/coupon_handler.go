@@ -0,0 +1,167 @@
+package interfaces
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+
+	"github.com/gin-gonic/gin"
+)
+
+//----------------------------------------------------------------coupons----------------------------------------------------------------
+
+// CreateCoupon adds a coupon (admins)
+func (h *CourseHandler) CreateCoupon(c *gin.Context) {
+	var coupon entities.Coupon
+	if err := c.ShouldBindJSON(&coupon); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.requireAdmin(c) {
+		return
+	}
+
+	coupon, err := h.useCase(c).CreateCoupon(coupon)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), errorBody(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, coupon)
+}
+
+// GetAllCoupons lists the coupons (admins)
+func (h *CourseHandler) GetAllCoupons(c *gin.Context) {
+	if !h.requireAdmin(c) {
+		return
+	}
+
+	coupons, err := h.UseCase.GetAllCoupons()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, coupons)
+}
+
+// GetCoupon returns a coupon (admins)
+func (h *CourseHandler) GetCoupon(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid coupon ID"})
+		return
+	}
+	if !h.requireAdmin(c) {
+		return
+	}
+
+	coupon, err := h.UseCase.GetCoupon(id)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, coupon)
+}
+
+// UpdateCoupon changes a coupon (admins)
+func (h *CourseHandler) UpdateCoupon(c *gin.Context) {
+	var coupon entities.Coupon
+	if err := c.ShouldBindJSON(&coupon); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.requireAdmin(c) {
+		return
+	}
+
+	coupon, err := h.useCase(c).UpdateCoupon(coupon)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), errorBody(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, coupon)
+}
+
+// DeleteCoupon removes a coupon that was never redeemed (admins)
+func (h *CourseHandler) DeleteCoupon(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid coupon ID"})
+		return
+	}
+	if !h.requireAdmin(c) {
+		return
+	}
+
+	if err := h.useCase(c).DeleteCoupon(id); err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Coupon deleted successfully"})
+}
+
+// GetCouponReport sums up the redemptions of a coupon (admins)
+func (h *CourseHandler) GetCouponReport(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid coupon ID"})
+		return
+	}
+	if !h.requireAdmin(c) {
+		return
+	}
+
+	report, err := h.UseCase.GetCouponReport(id)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+//----------------------------------------------------------------cart coupons----------------------------------------------------------------
+
+// ApplyCoupon applies a coupon to the current user's cart with {"code": ...}
+func (h *CourseHandler) ApplyCoupon(c *gin.Context) {
+	var body struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	cart, err := h.useCase(c).ApplyCoupon(body.Code, c.Query("currency"), user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	writeCart(c, cart)
+}
+
+// RemoveCoupon takes a coupon off the current user's cart
+func (h *CourseHandler) RemoveCoupon(c *gin.Context) {
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	cart, err := h.useCase(c).RemoveCoupon(c.Param("code"), c.Query("currency"), user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	writeCart(c, cart)
+}
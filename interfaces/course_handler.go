@@ -29,9 +29,9 @@ func (h *CourseHandler) CreateUser(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	user , err := h.UseCase.CreateUser(user)
+	user , err := h.useCase(c).CreateUser(user)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -39,7 +39,11 @@ func (h *CourseHandler) CreateUser(c *gin.Context) {
 }
 
 func (h *CourseHandler) GetAllUsers(c *gin.Context) {
-	users, err := h.UseCase.GetAllUsers()
+	includeDeleted, ok := h.includeDeleted(c)
+	if !ok {
+		return
+	}
+	users, err := h.UseCase.GetAllUsers(includeDeleted)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -55,9 +59,13 @@ func (h *CourseHandler) GetUserByID(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
 		return
 	}
-	user, err := h.UseCase.GetUserByID(id)
+	includeDeleted, ok := h.includeDeleted(c)
+	if !ok {
+		return
+	}
+	user, err := h.UseCase.GetUserByID(id, includeDeleted)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -71,9 +79,9 @@ func (h *CourseHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
-	user , err := h.UseCase.UpdateUser(user)
+	user , err := h.useCase(c).UpdateUser(user)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -88,15 +96,35 @@ func (h *CourseHandler) DeleteUser(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
 		return
 	}
-	err = h.UseCase.DeleteUser(id)
+	err = h.useCase(c).DeleteUser(id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
 }
 
+// RestoreUser brings back a soft deleted user (admins)
+func (h *CourseHandler) RestoreUser(c *gin.Context) {
+	if !h.requireAdmin(c) {
+		return
+	}
+	str_id := c.Param("id")
+	id, err := strconv.Atoi(str_id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	err = h.useCase(c).RestoreUser(id)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User restored successfully"})
+}
+
 //----------------------------------------------------------------course----------------------------------------------------------------
 func (h *CourseHandler) CreateCourse(c *gin.Context) {
 	var course entities.Course
@@ -105,7 +133,7 @@ func (h *CourseHandler) CreateCourse(c *gin.Context) {
 		return
 	}
 
-	course , err := h.UseCase.AddCourse(course)
+	course , err := h.useCase(c).AddCourse(course)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -115,7 +143,11 @@ func (h *CourseHandler) CreateCourse(c *gin.Context) {
 }
 
 func (h *CourseHandler) GetAllCourses(c *gin.Context) {
-	courses, err := h.UseCase.ListCourses()
+	filter, ok := h.courseFilter(c)
+	if !ok {
+		return
+	}
+	courses, err := h.UseCase.ListCourses(filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -131,9 +163,13 @@ func (h *CourseHandler) GetCourseByID(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
 		return
 	}
-	course, err := h.UseCase.GetCourseByID(id)
+	filter, ok := h.courseFilter(c)
+	if !ok {
+		return
+	}
+	course, err := h.UseCase.GetCourseByID(id, filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -146,10 +182,14 @@ func (h *CourseHandler) UpdateCourse(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
 
-	course , err := h.UseCase.UpdateCourse(course)
+	course , err := h.useCase(c).UpdateCourse(course, user)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -163,15 +203,39 @@ func (h *CourseHandler) DeleteCourse(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
 		return
 	}
-	err = h.UseCase.DeleteCourse(id)
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+	err = h.useCase(c).DeleteCourse(id, user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Course deleted successfully"})
 }
 
+// RestoreCourse brings back a soft deleted course (admins)
+func (h *CourseHandler) RestoreCourse(c *gin.Context) {
+	if !h.requireAdmin(c) {
+		return
+	}
+	str_id := c.Param("id")
+	id, err := strconv.Atoi(str_id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+		return
+	}
+	err = h.useCase(c).RestoreCourse(id)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Course restored successfully"})
+}
+
 
 //----------------------------------------------------------------enrollment----------------------------------------------------------------
 
@@ -182,13 +246,38 @@ func (h *CourseHandler) AddEnrollment(c *gin.Context) {
 		return
 	}
 
-	enrollment , err := h.UseCase.AddEnrollment(enrollment)
+	enrollment , err := h.useCase(c).AddEnrollment(enrollment)
 	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), errorBody(err))
+		return
+	}
+
+	body := gin.H{"message": "Enrollment created successfully"}
+	if len(enrollment.Warnings) > 0 {
+		body["warnings"] = enrollment.Warnings
+	}
+	c.JSON(http.StatusCreated, body)
+}
+
+// EnsureEnrollment enrolls a user into a course unless already enrolled, returning the enrollment either way
+func (h *CourseHandler) EnsureEnrollment(c *gin.Context) {
+	var enrollment entities.Enrollment
+	if err := c.ShouldBindJSON(&enrollment); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "Enrollment created successfully"})
+	enrollment, created, err := h.useCase(c).EnsureEnrollment(enrollment)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	if created {
+		c.JSON(http.StatusCreated, enrollment)
+		return
+	}
+	c.JSON(http.StatusOK, enrollment)
 }
 
 func (h *CourseHandler) GetAllEnrollments(c *gin.Context) {
@@ -208,9 +297,13 @@ func (h *CourseHandler) GetEnrollmentByID(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid enrollment ID"})
 		return
 	}
-	enrollment, err := h.UseCase.GetEnrollmentByID(id)
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+	enrollment, err := h.UseCase.GetEnrollmentByID(id, user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -239,10 +332,14 @@ func (h *CourseHandler) UpdateEnrollment(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
 
-	enrollment , err := h.UseCase.UpdateEnrollment(enrollment)
+	enrollment , err := h.useCase(c).UpdateEnrollment(enrollment, user)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -256,9 +353,13 @@ func (h *CourseHandler) DeleteEnrollment(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid enrollment ID"})
 		return
 	}
-	err = h.UseCase.DeleteEnrollment(id)
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+	err = h.useCase(c).DeleteEnrollment(id, user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -273,10 +374,14 @@ func (h *CourseHandler) AddLesson(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
 
-	lesson , err := h.UseCase.AddLesson(lesson)
+	lesson , err := h.useCase(c).AddLesson(lesson, user)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -290,9 +395,14 @@ func (h *CourseHandler) GetLessonsByCourseID(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
 		return
 	}
-	lessons, err := h.UseCase.GetLessonsByCourseID(id)
+	filter, ok := h.courseFilter(c)
+	if !ok {
+		return
+	}
+	viewer, _ := h.currentUser(c)
+	lessons, err := h.UseCase.GetLessonsByCourseID(id, filter, viewer)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -306,9 +416,14 @@ func (h *CourseHandler) GetLessonsByID(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid lesson ID"})
 		return
 	}
-	lessons, err := h.UseCase.GetLessonsByID(id)
+	filter, ok := h.courseFilter(c)
+	if !ok {
+		return
+	}
+	viewer, _ := h.currentUser(c)
+	lessons, err := h.UseCase.GetLessonsByID(id, filter, viewer)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -321,10 +436,14 @@ func (h *CourseHandler) UpdateLesson(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
 
-	lesson , err := h.UseCase.UpdateLesson(lesson)
+	lesson , err := h.useCase(c).UpdateLesson(lesson, user)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -338,15 +457,39 @@ func (h *CourseHandler) DeleteLesson(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid lesson ID"})
 		return
 	}
-	err = h.UseCase.DeleteLesson(id)
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+	err = h.useCase(c).DeleteLesson(id, user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Lesson deleted successfully"})
 }
 
+// RestoreLesson brings back a soft deleted lesson (admins)
+func (h *CourseHandler) RestoreLesson(c *gin.Context) {
+	if !h.requireAdmin(c) {
+		return
+	}
+	str_id := c.Param("id")
+	id, err := strconv.Atoi(str_id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid lesson ID"})
+		return
+	}
+	err = h.useCase(c).RestoreLesson(id)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Lesson restored successfully"})
+}
+
 //----------------------------------------------------------------progress----------------------------------------------------------------
 
 func (h *CourseHandler) AddProgress(c *gin.Context) {
@@ -355,10 +498,14 @@ func (h *CourseHandler) AddProgress(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
 
-	progress , err := h.UseCase.AddProgress(progress)
+	progress , err := h.useCase(c).AddProgress(progress, user)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err, http.StatusBadRequest), errorBody(err))
 		return
 	}
 
@@ -371,16 +518,46 @@ func (h *CourseHandler) UpdateProgress(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
 
-	progress , err := h.UseCase.UpdateProgress(progress)
+	progress , err := h.useCase(c).UpdateProgress(progress, user)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Progress updated successfully"})
 }
 
+// MarkLessonComplete idempotently marks a lesson completed for an enrollment
+func (h *CourseHandler) MarkLessonComplete(c *gin.Context) {
+	enrollmentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid enrollment ID"})
+		return
+	}
+	lessonID, err := strconv.Atoi(c.Param("lessonID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid lesson ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	progress, err := h.useCase(c).MarkLessonComplete(enrollmentID, lessonID, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, progress)
+}
+
 func (h *CourseHandler) GetProgressByEnrollmentAndLesson(c *gin.Context) {
 	str_enrollment_id := c.Param("enrollment_id")
 	enrollment_id, err := strconv.Atoi(str_enrollment_id)
@@ -405,6 +582,22 @@ func (h *CourseHandler) GetProgressByEnrollmentAndLesson(c *gin.Context) {
 	c.JSON(http.StatusOK, progress)
 }
 
+func (h *CourseHandler) GetEnrollmentProgress(c *gin.Context) {
+	str_id := c.Param("id")
+	id, err := strconv.Atoi(str_id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid enrollment ID"})
+		return
+	}
+	summary, err := h.UseCase.GetEnrollmentProgress(id)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
 //----------------------------------------------------------------review----------------------------------------------------------------
 
 func (h *CourseHandler) AddReview(c *gin.Context) {
@@ -413,14 +606,34 @@ func (h *CourseHandler) AddReview(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
 
-	review , err := h.UseCase.AddReview(review)
+	review , err := h.useCase(c).AddReview(review, user)
 	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), errorBody(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Review created successfully", "status": review.Status})
+}
+
+func (h *CourseHandler) UpdateReview(c *gin.Context) {
+	var review entities.Review
+	if err := c.ShouldBindJSON(&review); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "Review created successfully"})
+	review, err := h.useCase(c).UpdateReview(review)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, review)
 }
 
 func (h *CourseHandler) GetReviewsByCourseID(c *gin.Context) {
@@ -446,9 +659,9 @@ func (h *CourseHandler) DeleteReview(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
 		return
 	}
-	err = h.UseCase.DeleteReview(id)
+	err = h.useCase(c).DeleteReview(id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -0,0 +1,157 @@
+package interfaces
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+
+	"github.com/gin-gonic/gin"
+)
+
+//----------------------------------------------------------------curriculum----------------------------------------------------------------
+
+// AddSection adds a section at the end of a course
+func (h *CourseHandler) AddSection(c *gin.Context) {
+	var section entities.Section
+	if err := c.ShouldBindJSON(&section); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	section, err := h.useCase(c).AddSection(section, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, section)
+}
+
+// UpdateSection renames a section
+func (h *CourseHandler) UpdateSection(c *gin.Context) {
+	var section entities.Section
+	if err := c.ShouldBindJSON(&section); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	section, err := h.useCase(c).UpdateSection(section, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, section)
+}
+
+// DeleteSection deletes an empty section
+func (h *CourseHandler) DeleteSection(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid section ID"})
+		return
+	}
+
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	if err := h.useCase(c).DeleteSection(id, user); err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Section deleted successfully"})
+}
+
+// GetCurriculum returns the sections and lessons of a course in order
+func (h *CourseHandler) GetCurriculum(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+		return
+	}
+	filter, ok := h.courseFilter(c)
+	if !ok {
+		return
+	}
+
+	curriculum, err := h.UseCase.GetCurriculum(id, filter)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, curriculum)
+}
+
+// ReorderCurriculum rewrites the order of a whole course,
+// {"lessons": [...], "sections": [{"id": ..., "lessons": [...]}]}
+func (h *CourseHandler) ReorderCurriculum(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+		return
+	}
+	var order entities.CurriculumOrder
+	if err := c.ShouldBindJSON(&order); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	curriculum, err := h.useCase(c).ReorderCurriculum(id, order, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, curriculum)
+}
+
+// MoveLesson moves a lesson to {"section_id": ..., "position": ...}, a null section_id
+// moves it outside any section and a missing position appends it
+func (h *CourseHandler) MoveLesson(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid lesson ID"})
+		return
+	}
+	var body struct {
+		SectionID *uint `json:"section_id"`
+		Position  int   `json:"position"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	curriculum, err := h.useCase(c).MoveLesson(id, body.SectionID, body.Position, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, curriculum)
+}
@@ -0,0 +1,175 @@
+package interfaces
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+
+	"github.com/gin-gonic/gin"
+)
+
+//----------------------------------------------------------------gradebook----------------------------------------------------------------
+
+// CSV header prefixes of the gradebook column types
+var gradebookLabels = map[string]string{
+	entities.GradeLesson:     "Lesson",
+	entities.GradeQuiz:       "Quiz",
+	entities.GradeAssignment: "Assignment",
+}
+
+// GetGradebook returns the students of a course against its lessons, quizzes and assignments
+func (h *CourseHandler) GetGradebook(c *gin.Context) {
+	gradebook, ok := h.gradebook(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gradebook)
+}
+
+// ExportGradebook sends the gradebook of a course as a CSV file that spreadsheets open as is
+func (h *CourseHandler) ExportGradebook(c *gin.Context) {
+	gradebook, ok := h.gradebook(c)
+	if !ok {
+		return
+	}
+
+	header := []string{"Student", "Email", "Enrollment ID"}
+	for _, column := range gradebook.Columns {
+		header = append(header, fmt.Sprintf("%s: %s (%%)", gradebookLabels[column.Type], column.Title))
+	}
+	header = append(header, "Lessons (%)", "Quizzes (%)", "Assignments (%)", "Total (%)")
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"gradebook-course-%d.csv\"", gradebook.CourseID))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(http.StatusOK)
+	// the byte order mark makes spreadsheets read the file as UTF-8
+	c.Writer.WriteString("\ufeff")
+	writer := csv.NewWriter(c.Writer)
+	writer.Write(csvRecord(header))
+	for _, row := range gradebook.Rows {
+		record := []string{row.StudentName, row.Email, strconv.Itoa(int(row.EnrollmentID))}
+		for _, cell := range row.Cells {
+			if cell == nil {
+				record = append(record, "")
+			} else {
+				record = append(record, formatPercent(*cell))
+			}
+		}
+		record = append(record, formatPercent(row.LessonsPercent), formatPercent(row.QuizzesPercent),
+			formatPercent(row.AssignmentsPercent), formatPercent(row.Total))
+		writer.Write(csvRecord(record))
+	}
+	writer.Flush()
+}
+
+// GetStudentGradebook returns the grades of one student of a course with their details
+func (h *CourseHandler) GetStudentGradebook(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+		return
+	}
+	userID, err := strconv.Atoi(c.Param("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	student, err := h.useCase(c).GetStudentGradebook(id, userID, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, student)
+}
+
+// GetGradeWeights returns how the categories of a course count towards the total grade
+func (h *CourseHandler) GetGradeWeights(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	weights, err := h.useCase(c).GetGradeWeights(id, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, weights)
+}
+
+// SetGradeWeights sets {"lessons": ..., "quizzes": ..., "assignments": ...} for a course
+func (h *CourseHandler) SetGradeWeights(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+		return
+	}
+	var weights entities.GradeWeights
+	if err := c.ShouldBindJSON(&weights); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	weights.CourseID = uint(id)
+	weights, err = h.useCase(c).SetGradeWeights(weights, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, weights)
+}
+
+// gradebook loads the gradebook of the course in the path, writing the error response when it fails
+func (h *CourseHandler) gradebook(c *gin.Context) (entities.Gradebook, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+		return entities.Gradebook{}, false
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return entities.Gradebook{}, false
+	}
+
+	gradebook, err := h.useCase(c).GetGradebook(id, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return entities.Gradebook{}, false
+	}
+	return gradebook, true
+}
+
+func formatPercent(percent float64) string {
+	return strconv.FormatFloat(percent, 'f', -1, 64)
+}
+
+// csvRecord escapes fields spreadsheets would run as formulas
+func csvRecord(fields []string) []string {
+	for i, field := range fields {
+		if field != "" && strings.ContainsRune("=+-@\t\r", rune(field[0])) {
+			fields[i] = "'" + field
+		}
+	}
+	return fields
+}
@@ -0,0 +1,172 @@
+package interfaces
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+	"github.com/NaheedRayan/mini_rest_api_shikho/usecases"
+
+	"github.com/gin-gonic/gin"
+)
+
+//----------------------------------------------------------------invoices----------------------------------------------------------------
+
+// IssueInvoice issues the invoice of a paid order, or returns the one already issued (its buyer or an admin)
+func (h *CourseHandler) IssueInvoice(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	invoice, err := h.useCase(c).IssueInvoice(id, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, invoice)
+}
+
+// GetUserInvoices lists the invoices of a user, newest first (the user or an admin)
+func (h *CourseHandler) GetUserInvoices(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	invoices, err := h.UseCase.GetUserInvoices(id, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, invoices)
+}
+
+// GetInvoice returns an invoice as JSON, or as a document when the ID ends in .pdf or .html
+func (h *CourseHandler) GetInvoice(c *gin.Context) {
+	param := c.Param("id")
+	format := ""
+	for _, extension := range []string{usecases.InvoicePDF, usecases.InvoiceHTML} {
+		if strings.HasSuffix(param, "."+extension) {
+			format = extension
+			param = strings.TrimSuffix(param, "."+extension)
+		}
+	}
+	id, err := strconv.Atoi(param)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invoice ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	if format == "" {
+		invoice, err := h.UseCase.GetInvoice(id, user)
+		if err != nil {
+			c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, invoice)
+		return
+	}
+
+	invoice, document, err := h.UseCase.RenderInvoice(id, format, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+	if format == usecases.InvoiceHTML {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", document)
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=\"%s.pdf\"", invoice.Number))
+	c.Data(http.StatusOK, "application/pdf", document)
+}
+
+// EmailInvoice sends an invoice to its buyer again (its buyer or an admin)
+func (h *CourseHandler) EmailInvoice(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invoice ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	invoice, err := h.useCase(c).EmailInvoice(id, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, invoice)
+}
+
+//----------------------------------------------------------------billing details----------------------------------------------------------------
+
+// GetBillingDetails returns the details printed on a user's invoices (the user or an admin)
+func (h *CourseHandler) GetBillingDetails(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	details, err := h.UseCase.GetBillingDetails(id, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, details)
+}
+
+// SetBillingDetails replaces the details printed on a user's future invoices with
+// {"company": ..., "address": ..., "tax_id": ..., "email": ...} (the user or an admin)
+func (h *CourseHandler) SetBillingDetails(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	var details entities.BillingDetails
+	if err := c.ShouldBindJSON(&details); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	details.UserID = uint(id)
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	updated, err := h.useCase(c).SetBillingDetails(details, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
@@ -0,0 +1,134 @@
+package interfaces
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+//----------------------------------------------------------------moderation----------------------------------------------------------------
+
+// FlagReview lets the current user report a review
+func (h *CourseHandler) FlagReview(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	flag, err := h.useCase(c).FlagReview(id, user.ID, body.Reason)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), errorBody(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, flag)
+}
+
+// ReplyToReview sets the course instructor's reply on a review
+func (h *CourseHandler) ReplyToReview(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+	var body struct {
+		Body string `json:"body"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	review, err := h.useCase(c).ReplyToReview(id, user, body.Body)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, review)
+}
+
+// GetModerationQueue lists reviews waiting for an admin, ?status=pending,flagged by default
+func (h *CourseHandler) GetModerationQueue(c *gin.Context) {
+	if !h.requireAdmin(c) {
+		return
+	}
+	var statuses []string
+	if status := c.Query("status"); status != "" {
+		statuses = strings.Split(status, ",")
+	}
+
+	reviews, err := h.UseCase.ModerationQueue(statuses)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, reviews)
+}
+
+// ModerateReview approves, rejects or flags a review
+func (h *CourseHandler) ModerateReview(c *gin.Context) {
+	if !h.requireAdmin(c) {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return
+	}
+	var body struct {
+		Status string `json:"status" binding:"required"`
+		Note   string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	review, err := h.useCase(c).ModerateReview(id, body.Status, body.Note)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, review)
+}
+
+// GetReviewFlags lists the flags users raised on a review
+func (h *CourseHandler) GetReviewFlags(c *gin.Context) {
+	if !h.requireAdmin(c) {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return
+	}
+
+	flags, err := h.UseCase.GetReviewFlags(id)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, flags)
+}
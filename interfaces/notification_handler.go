@@ -0,0 +1,115 @@
+package interfaces
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+//----------------------------------------------------------------notification preferences----------------------------------------------------------------
+
+// GetNotificationPreferences returns the notifications a user wants (the user or an admin)
+func (h *CourseHandler) GetNotificationPreferences(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	preferences, err := h.UseCase.GetNotificationPreferences(id, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, preferences)
+}
+
+// SetNotificationPreferences turns notifications of a user on or off with
+// {"enrollment": ..., "course_completed": ..., "new_review": ...}, the ones left out stay as they are
+// (the user or an admin)
+func (h *CourseHandler) SetNotificationPreferences(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	var body struct {
+		Enrollment *bool `json:"enrollment"`
+		Completion *bool `json:"course_completed"`
+		Reviews    *bool `json:"new_review"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	preferences, err := h.UseCase.GetNotificationPreferences(id, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+	if body.Enrollment != nil {
+		preferences.Enrollment = *body.Enrollment
+	}
+	if body.Completion != nil {
+		preferences.Completion = *body.Completion
+	}
+	if body.Reviews != nil {
+		preferences.Reviews = *body.Reviews
+	}
+
+	updated, err := h.useCase(c).SetNotificationPreferences(preferences, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+//----------------------------------------------------------------outbox----------------------------------------------------------------
+
+// GetOutbox lists the latest notification emails, ?status=pending|sent|failed narrows them down (admins)
+func (h *CourseHandler) GetOutbox(c *gin.Context) {
+	if !h.requireAdmin(c) {
+		return
+	}
+
+	emails, err := h.UseCase.GetOutbox(c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, emails)
+}
+
+// RetryOutboxEmail queues an email that could not be sent again (admins)
+func (h *CourseHandler) RetryOutboxEmail(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email ID"})
+		return
+	}
+	if !h.requireAdmin(c) {
+		return
+	}
+
+	email, err := h.useCase(c).RetryOutboxEmail(id)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, email)
+}
@@ -0,0 +1,189 @@
+package interfaces
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+	"github.com/NaheedRayan/mini_rest_api_shikho/usecases"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PaymentSignatureHeader carries the payment gateway's signature of a webhook body
+const PaymentSignatureHeader = "X-Payment-Signature"
+
+//----------------------------------------------------------------cart----------------------------------------------------------------
+
+// GetCart returns the current user's cart priced in ?currency=, US dollars by default
+func (h *CourseHandler) GetCart(c *gin.Context) {
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	cart, err := h.UseCase.GetCart(c.Query("currency"), user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	writeCart(c, cart)
+}
+
+// AddToCart puts a course in the current user's cart with {"course_id": ...}
+func (h *CourseHandler) AddToCart(c *gin.Context) {
+	var body struct {
+		CourseID int `json:"course_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	cart, err := h.useCase(c).AddToCart(body.CourseID, c.Query("currency"), user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), errorBody(err))
+		return
+	}
+
+	writeCart(c, cart)
+}
+
+// RemoveFromCart takes a course out of the current user's cart
+func (h *CourseHandler) RemoveFromCart(c *gin.Context) {
+	courseID, err := strconv.Atoi(c.Param("courseID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	cart, err := h.useCase(c).RemoveFromCart(courseID, c.Query("currency"), user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	writeCart(c, cart)
+}
+
+//----------------------------------------------------------------orders----------------------------------------------------------------
+
+// Checkout buys the current user's cart with {"payment_method": ..., "currency": ...}, free orders can
+// leave out the payment method and the currency defaults to US dollars. A paid order is 201, one waiting for the customer to complete a 3DS challenge is 202 and a declined one is 402.
+func (h *CourseHandler) Checkout(c *gin.Context) {
+	var body struct {
+		PaymentMethod string `json:"payment_method"`
+		Currency      string `json:"currency"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	order, err := h.useCase(c).Checkout(body.PaymentMethod, body.Currency, user)
+	if errors.Is(err, usecases.ErrPaymentDeclined) {
+		c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error(), "order": withDisplay(c, order)})
+		return
+	}
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), errorBody(err))
+		return
+	}
+
+	if order.Status == entities.OrderRequiresAction {
+		c.JSON(http.StatusAccepted, withDisplay(c, order))
+		return
+	}
+	c.JSON(http.StatusCreated, withDisplay(c, order))
+}
+
+// GetOrders returns the current user's orders
+func (h *CourseHandler) GetOrders(c *gin.Context) {
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	orders, err := h.UseCase.GetOrders(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	for i := range orders {
+		orders[i] = withDisplay(c, orders[i])
+	}
+	c.JSON(http.StatusOK, orders)
+}
+
+// GetOrder returns an order to its buyer or an admin
+func (h *CourseHandler) GetOrder(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	order, err := h.UseCase.GetOrder(id, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, withDisplay(c, order))
+}
+
+// CancelOrder abandons an order that is still waiting for its payment
+func (h *CourseHandler) CancelOrder(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	order, err := h.useCase(c).CancelOrder(id, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, withDisplay(c, order))
+}
+
+// PaymentWebhook receives payment outcomes from the gateway, the body is verified against its signature
+func (h *CourseHandler) PaymentWebhook(c *gin.Context) {
+	payload, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.useCase(c).HandlePaymentWebhook(payload, c.GetHeader(PaymentSignatureHeader)); err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook processed successfully"})
+}
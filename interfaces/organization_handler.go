@@ -0,0 +1,461 @@
+package interfaces
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+
+	"github.com/gin-gonic/gin"
+)
+
+//----------------------------------------------------------------organizations----------------------------------------------------------------
+
+// CreateOrganization creates an organization with {"name": ...}, the current user becomes its admin
+func (h *CourseHandler) CreateOrganization(c *gin.Context) {
+	var organization entities.Organization
+	if err := c.ShouldBindJSON(&organization); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	organization, err := h.useCase(c).CreateOrganization(organization, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, organization)
+}
+
+// GetOrganizations lists the current user's organizations, every organization for admins
+func (h *CourseHandler) GetOrganizations(c *gin.Context) {
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	organizations, err := h.UseCase.GetOrganizations(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, organizations)
+}
+
+// GetUserOrganizations lists the organizations of a user (the user or an admin)
+func (h *CourseHandler) GetUserOrganizations(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	organizations, err := h.UseCase.GetUserOrganizations(id, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, organizations)
+}
+
+// GetOrganization returns an organization (its members or an admin)
+func (h *CourseHandler) GetOrganization(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	organization, err := h.UseCase.GetOrganization(id, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, organization)
+}
+
+// RenameOrganization changes the name of an organization with {"name": ...} (organization admins)
+func (h *CourseHandler) RenameOrganization(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+	var body struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	organization, err := h.useCase(c).RenameOrganization(id, body.Name, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, organization)
+}
+
+//----------------------------------------------------------------organization members----------------------------------------------------------------
+
+// GetOrganizationMembers lists the members of an organization (organization admins)
+func (h *CourseHandler) GetOrganizationMembers(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	members, err := h.UseCase.GetOrganizationMembers(id, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+// SetOrganizationMemberRole changes the role of a member with {"role": "member"|"admin"} (organization admins)
+func (h *CourseHandler) SetOrganizationMemberRole(c *gin.Context) {
+	id, userID, ok := organizationMemberParams(c)
+	if !ok {
+		return
+	}
+	var body struct {
+		Role string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	member, err := h.useCase(c).SetOrganizationMemberRole(id, userID, body.Role, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, member)
+}
+
+// RemoveOrganizationMember takes a member out of an organization and gives back their seats,
+// organization admins remove anyone and members can remove themselves
+func (h *CourseHandler) RemoveOrganizationMember(c *gin.Context) {
+	id, userID, ok := organizationMemberParams(c)
+	if !ok {
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	revoked, err := h.useCase(c).RemoveOrganizationMember(id, userID, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully", "revoked_enrollments": revoked})
+}
+
+// organizationMemberParams reads the organization and user IDs of a member route
+func organizationMemberParams(c *gin.Context) (int, int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return 0, 0, false
+	}
+	userID, err := strconv.Atoi(c.Param("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return 0, 0, false
+	}
+	return id, userID, true
+}
+
+//----------------------------------------------------------------organization invitations----------------------------------------------------------------
+
+// InviteOrganizationMember invites a user with {"user_id": ..., "role": "member"|"admin"} (organization admins)
+func (h *CourseHandler) InviteOrganizationMember(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+	var invitation entities.OrganizationInvitation
+	if err := c.ShouldBindJSON(&invitation); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	invitation, err = h.useCase(c).InviteOrganizationMember(id, invitation, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), errorBody(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, invitation)
+}
+
+// GetOrganizationInvitations lists the invitations of an organization (organization admins)
+func (h *CourseHandler) GetOrganizationInvitations(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	invitations, err := h.UseCase.GetOrganizationInvitations(id, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, invitations)
+}
+
+// GetUserInvitations lists the invitations of a user, ?status=pending for the open ones (the user or an admin)
+func (h *CourseHandler) GetUserInvitations(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	invitations, err := h.UseCase.GetUserInvitations(id, c.Query("status"), user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, invitations)
+}
+
+// AcceptOrganizationInvitation joins the organization of an invitation (the invited user)
+func (h *CourseHandler) AcceptOrganizationInvitation(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	member, err := h.useCase(c).AcceptOrganizationInvitation(id, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), errorBody(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, member)
+}
+
+// DeclineOrganizationInvitation turns an invitation down (the invited user)
+func (h *CourseHandler) DeclineOrganizationInvitation(c *gin.Context) {
+	h.answerInvitation(c, h.useCase(c).DeclineOrganizationInvitation)
+}
+
+// CancelOrganizationInvitation withdraws a pending invitation (organization admins)
+func (h *CourseHandler) CancelOrganizationInvitation(c *gin.Context) {
+	h.answerInvitation(c, h.useCase(c).CancelOrganizationInvitation)
+}
+
+func (h *CourseHandler) answerInvitation(c *gin.Context, answer func(id int, user entities.User) (entities.OrganizationInvitation, error)) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	invitation, err := answer(id, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, invitation)
+}
+
+//----------------------------------------------------------------seats----------------------------------------------------------------
+
+// PurchaseSeats buys seats in a course with {"course_id": ..., "seats": ..., "payment_method": ..., "currency": ...}
+// (organization admins)
+func (h *CourseHandler) PurchaseSeats(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+	var body struct {
+		CourseID      int    `json:"course_id" binding:"required"`
+		Seats         int    `json:"seats" binding:"required"`
+		PaymentMethod string `json:"payment_method" binding:"required"`
+		Currency      string `json:"currency"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	purchase, err := h.useCase(c).PurchaseSeats(id, body.CourseID, body.Seats, body.PaymentMethod, body.Currency, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, purchase)
+}
+
+// GetSeatPurchases lists the seat purchases of an organization (organization admins)
+func (h *CourseHandler) GetSeatPurchases(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	purchases, err := h.UseCase.GetSeatPurchases(id, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, purchases)
+}
+
+// GetSeatPools lists the seats of an organization per course (its members)
+func (h *CourseHandler) GetSeatPools(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	pools, err := h.UseCase.GetSeatPools(id, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, pools)
+}
+
+// EnrollMembers enrolls members in a course with the organization's seats, {"user_ids": [...]} or
+// every member without a body (organization admins)
+func (h *CourseHandler) EnrollMembers(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+	courseID, err := strconv.Atoi(c.Param("courseID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+		return
+	}
+	var body struct {
+		UserIDs []uint `json:"user_ids"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	results, err := h.useCase(c).EnrollMembers(id, courseID, body.UserIDs, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+//----------------------------------------------------------------reporting----------------------------------------------------------------
+
+// GetOrganizationProgress reports the progress of the members in the organization's courses (organization admins)
+func (h *CourseHandler) GetOrganizationProgress(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	report, err := h.UseCase.GetOrganizationProgress(id, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
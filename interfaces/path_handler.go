@@ -0,0 +1,218 @@
+package interfaces
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+
+	"github.com/gin-gonic/gin"
+)
+
+//----------------------------------------------------------------prerequisites----------------------------------------------------------------
+
+// GetPrerequisites lists the prerequisites of a course
+func (h *CourseHandler) GetPrerequisites(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+		return
+	}
+	filter, ok := h.courseFilter(c)
+	if !ok {
+		return
+	}
+
+	prerequisites, err := h.UseCase.GetPrerequisites(id, filter)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, prerequisites)
+}
+
+// SetPrerequisite adds a prerequisite to a course, {"required": false} only recommends it
+func (h *CourseHandler) SetPrerequisite(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+		return
+	}
+	prerequisiteID, err := strconv.Atoi(c.Param("prerequisiteID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid prerequisite ID"})
+		return
+	}
+	body := struct {
+		Required *bool `json:"required"`
+	}{}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	prerequisite := entities.Prerequisite{CourseID: uint(id), PrerequisiteID: uint(prerequisiteID), Required: true}
+	if body.Required != nil {
+		prerequisite.Required = *body.Required
+	}
+	prerequisites, err := h.useCase(c).SetPrerequisite(prerequisite, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, prerequisites)
+}
+
+// DeletePrerequisite removes a prerequisite from a course
+func (h *CourseHandler) DeletePrerequisite(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+		return
+	}
+	prerequisiteID, err := strconv.Atoi(c.Param("prerequisiteID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid prerequisite ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	if err := h.useCase(c).DeletePrerequisite(id, prerequisiteID, user); err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Prerequisite deleted successfully"})
+}
+
+//----------------------------------------------------------------learning paths----------------------------------------------------------------
+
+// AddLearningPath creates a learning path, admins only
+func (h *CourseHandler) AddLearningPath(c *gin.Context) {
+	if !h.requireAdmin(c) {
+		return
+	}
+	var path entities.LearningPath
+	if err := c.ShouldBindJSON(&path); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	path, err := h.useCase(c).AddLearningPath(path)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, path)
+}
+
+func (h *CourseHandler) GetAllLearningPaths(c *gin.Context) {
+	paths, err := h.UseCase.GetAllLearningPaths()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, paths)
+}
+
+func (h *CourseHandler) GetLearningPathByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid learning path ID"})
+		return
+	}
+
+	path, err := h.UseCase.GetLearningPathByID(id)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, path)
+}
+
+// UpdateLearningPath replaces a learning path, admins only
+func (h *CourseHandler) UpdateLearningPath(c *gin.Context) {
+	if !h.requireAdmin(c) {
+		return
+	}
+	var path entities.LearningPath
+	if err := c.ShouldBindJSON(&path); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	path, err := h.useCase(c).UpdateLearningPath(path)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, path)
+}
+
+// DeleteLearningPath deletes a learning path, admins only
+func (h *CourseHandler) DeleteLearningPath(c *gin.Context) {
+	if !h.requireAdmin(c) {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid learning path ID"})
+		return
+	}
+
+	if err := h.useCase(c).DeleteLearningPath(id); err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Learning path deleted successfully"})
+}
+
+// GetLearningPathProgress returns the caller's progress through a learning path,
+// admins can pass ?user_id= to see another user's
+func (h *CourseHandler) GetLearningPathProgress(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid learning path ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+	userID := int(user.ID)
+	if str_id := c.Query("user_id"); str_id != "" {
+		userID, err = strconv.Atoi(str_id)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		if userID != int(user.ID) && user.Role != "admin" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can see the progress of other users"})
+			return
+		}
+	}
+
+	progress, err := h.UseCase.GetLearningPathProgress(id, userID)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, progress)
+}
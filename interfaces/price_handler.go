@@ -0,0 +1,119 @@
+package interfaces
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+
+	"github.com/gin-gonic/gin"
+)
+
+//----------------------------------------------------------------course prices----------------------------------------------------------------
+
+// GetCoursePrices lists the prices of a course in every currency it can be bought in
+func (h *CourseHandler) GetCoursePrices(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+		return
+	}
+
+	prices, err := h.UseCase.GetCoursePrices(id)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	locale := requestLocale(c)
+	for i := range prices {
+		prices[i].Display = prices[i].Price.Format(locale)
+	}
+	c.JSON(http.StatusOK, prices)
+}
+
+// SetCoursePrice sets the price of a course in a currency with {"price": {"amount": ..., "currency": ...}}
+// (course instructor or admin)
+func (h *CourseHandler) SetCoursePrice(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+		return
+	}
+	var body struct {
+		Price entities.Money `json:"price" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	price, err := h.useCase(c).SetCoursePrice(id, body.Price, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	price.Display = price.Price.Format(requestLocale(c))
+	c.JSON(http.StatusOK, price)
+}
+
+// DeleteCoursePrice removes the price of a course in a currency (course instructor or admin)
+func (h *CourseHandler) DeleteCoursePrice(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	if err := h.useCase(c).DeleteCoursePrice(id, c.Param("currency"), user); err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Course price deleted successfully"})
+}
+
+//----------------------------------------------------------------display----------------------------------------------------------------
+
+// requestLocale returns the locale amounts are written in, from ?locale= or else the first
+// language of the Accept-Language header
+func requestLocale(c *gin.Context) string {
+	if locale := c.Query("locale"); locale != "" {
+		return locale
+	}
+	first, _, _ := strings.Cut(c.GetHeader("Accept-Language"), ",")
+	locale, _, _ := strings.Cut(first, ";")
+	return strings.TrimSpace(locale)
+}
+
+// priceDisplay writes the totals of a cart or order for the request's locale
+func priceDisplay(c *gin.Context, subtotal, discount, total entities.Money) *entities.PriceDisplay {
+	locale := requestLocale(c)
+	return &entities.PriceDisplay{
+		Subtotal: subtotal.Format(locale),
+		Discount: discount.Format(locale),
+		Total:    total.Format(locale),
+	}
+}
+
+// writeCart responds with a cart and its totals written for the request's locale
+func writeCart(c *gin.Context, cart entities.Cart) {
+	cart.Display = priceDisplay(c, cart.Subtotal, cart.Discount, cart.Total)
+	c.JSON(http.StatusOK, cart)
+}
+
+// withDisplay adds the totals of an order written for the request's locale
+func withDisplay(c *gin.Context, order entities.Order) entities.Order {
+	order.Display = priceDisplay(c, order.Subtotal, order.Discount, order.Total)
+	return order
+}
@@ -0,0 +1,94 @@
+package interfaces
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+
+	"github.com/gin-gonic/gin"
+)
+
+//----------------------------------------------------------------publishing----------------------------------------------------------------
+
+// SubmitCourse sends a draft course for admin review
+func (h *CourseHandler) SubmitCourse(c *gin.Context) {
+	h.transitionCourse(c, func(id int, user entities.User) (entities.Course, error) {
+		return h.useCase(c).SubmitCourse(id, user)
+	})
+}
+
+// WithdrawCourse takes a course out of review
+func (h *CourseHandler) WithdrawCourse(c *gin.Context) {
+	h.transitionCourse(c, func(id int, user entities.User) (entities.Course, error) {
+		return h.useCase(c).WithdrawCourse(id, user)
+	})
+}
+
+// ApproveCourse publishes a course under review, optionally from {"publish_at": RFC 3339} on
+func (h *CourseHandler) ApproveCourse(c *gin.Context) {
+	var body struct {
+		PublishAt *time.Time `json:"publish_at"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	h.transitionCourse(c, func(id int, user entities.User) (entities.Course, error) {
+		return h.useCase(c).ApproveCourse(id, user, body.PublishAt)
+	})
+}
+
+// RejectCourse sends a course under review back to draft with {"note": ...}
+func (h *CourseHandler) RejectCourse(c *gin.Context) {
+	var body struct {
+		Note string `json:"note"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	h.transitionCourse(c, func(id int, user entities.User) (entities.Course, error) {
+		return h.useCase(c).RejectCourse(id, user, body.Note)
+	})
+}
+
+// ArchiveCourse removes a published course from the catalog
+func (h *CourseHandler) ArchiveCourse(c *gin.Context) {
+	h.transitionCourse(c, func(id int, user entities.User) (entities.Course, error) {
+		return h.useCase(c).ArchiveCourse(id, user)
+	})
+}
+
+// ReopenCourse turns an archived course back into a draft
+func (h *CourseHandler) ReopenCourse(c *gin.Context) {
+	h.transitionCourse(c, func(id int, user entities.User) (entities.Course, error) {
+		return h.useCase(c).ReopenCourse(id, user)
+	})
+}
+
+// transitionCourse parses the course ID and current user and runs a publishing transition
+func (h *CourseHandler) transitionCourse(c *gin.Context, transition func(id int, user entities.User) (entities.Course, error)) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	course, err := transition(id, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, course)
+}
@@ -0,0 +1,325 @@
+package interfaces
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+
+	"github.com/gin-gonic/gin"
+)
+
+//----------------------------------------------------------------question banks----------------------------------------------------------------
+
+// AddQuestionBank creates a question bank for a course
+func (h *CourseHandler) AddQuestionBank(c *gin.Context) {
+	var bank entities.QuestionBank
+	if err := c.ShouldBindJSON(&bank); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	bank, err := h.useCase(c).AddQuestionBank(bank, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, bank)
+}
+
+// GetQuestionBanks lists the question banks of a course
+func (h *CourseHandler) GetQuestionBanks(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	banks, err := h.UseCase.GetQuestionBanks(id, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, banks)
+}
+
+// GetBankQuestions lists the questions of a bank with their answer keys
+func (h *CourseHandler) GetBankQuestions(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid question bank ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	questions, err := h.UseCase.GetBankQuestions(id, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, questions)
+}
+
+//----------------------------------------------------------------questions----------------------------------------------------------------
+
+// AddQuestion adds a question to a bank
+func (h *CourseHandler) AddQuestion(c *gin.Context) {
+	var question entities.Question
+	if err := c.ShouldBindJSON(&question); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	question, err := h.useCase(c).AddQuestion(question, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, question)
+}
+
+// UpdateQuestion replaces a question
+func (h *CourseHandler) UpdateQuestion(c *gin.Context) {
+	var question entities.Question
+	if err := c.ShouldBindJSON(&question); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	question, err := h.useCase(c).UpdateQuestion(question, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, question)
+}
+
+// DeleteQuestion removes a question from its bank
+func (h *CourseHandler) DeleteQuestion(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid question ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	if err := h.useCase(c).DeleteQuestion(id, user); err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Question deleted successfully"})
+}
+
+//----------------------------------------------------------------quizzes----------------------------------------------------------------
+
+// AddQuiz attaches a quiz to a lesson
+func (h *CourseHandler) AddQuiz(c *gin.Context) {
+	var quiz entities.Quiz
+	if err := c.ShouldBindJSON(&quiz); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	quiz, err := h.useCase(c).AddQuiz(quiz, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), errorBody(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, quiz)
+}
+
+// UpdateQuiz changes the settings of a quiz
+func (h *CourseHandler) UpdateQuiz(c *gin.Context) {
+	var quiz entities.Quiz
+	if err := c.ShouldBindJSON(&quiz); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	quiz, err := h.useCase(c).UpdateQuiz(quiz, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, quiz)
+}
+
+// DeleteQuiz removes a quiz and its attempts
+func (h *CourseHandler) DeleteQuiz(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quiz ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	if err := h.useCase(c).DeleteQuiz(id, user); err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Quiz deleted successfully"})
+}
+
+// GetLessonQuiz returns the quiz of a lesson
+func (h *CourseHandler) GetLessonQuiz(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid lesson ID"})
+		return
+	}
+
+	quiz, err := h.UseCase.GetLessonQuiz(id)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, quiz)
+}
+
+//----------------------------------------------------------------attempts----------------------------------------------------------------
+
+// StartQuizAttempt starts an attempt at a quiz for {"enrollment_id": ...}
+func (h *CourseHandler) StartQuizAttempt(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quiz ID"})
+		return
+	}
+	var body struct {
+		EnrollmentID uint `json:"enrollment_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	attempt, err := h.useCase(c).StartQuizAttempt(id, int(body.EnrollmentID), user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), errorBody(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, attempt)
+}
+
+// SubmitQuizAttempt grades the {"answers": [...]} of an open attempt
+func (h *CourseHandler) SubmitQuizAttempt(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attempt ID"})
+		return
+	}
+	var body struct {
+		Answers []entities.AttemptAnswer `json:"answers"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	attempt, err := h.useCase(c).SubmitQuizAttempt(id, body.Answers, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), errorBody(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, attempt)
+}
+
+// GetQuizAttempt returns an attempt with its questions, answer keys are never included
+func (h *CourseHandler) GetQuizAttempt(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attempt ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	attempt, err := h.useCase(c).GetQuizAttempt(id, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, attempt)
+}
+
+// GetQuizAttempts lists the attempts of an enrollment at a quiz
+func (h *CourseHandler) GetQuizAttempts(c *gin.Context) {
+	enrollmentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid enrollment ID"})
+		return
+	}
+	quizID, err := strconv.Atoi(c.Param("quizID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quiz ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	attempts, err := h.useCase(c).GetQuizAttempts(quizID, enrollmentID, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, attempts)
+}
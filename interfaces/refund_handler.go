@@ -0,0 +1,138 @@
+package interfaces
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+
+	"github.com/gin-gonic/gin"
+)
+
+//----------------------------------------------------------------refunds----------------------------------------------------------------
+
+// RequestRefund asks for the refund of a course of an order with {"course_id": ..., "reason": ...}
+func (h *CourseHandler) RequestRefund(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	var body struct {
+		CourseID int    `json:"course_id" binding:"required"`
+		Reason   string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	refund, err := h.useCase(c).RequestRefund(id, body.CourseID, body.Reason, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), errorBody(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, refund)
+}
+
+// GetOrderRefunds lists the refunds of an order (its buyer or an admin)
+func (h *CourseHandler) GetOrderRefunds(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	refunds, err := h.UseCase.GetOrderRefunds(id, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, refunds)
+}
+
+// GetRefunds lists the refunds, ?status=requested for the ones waiting for a decision (admins)
+func (h *CourseHandler) GetRefunds(c *gin.Context) {
+	if !h.requireAdmin(c) {
+		return
+	}
+
+	refunds, err := h.UseCase.GetRefunds(c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, refunds)
+}
+
+// ApproveRefund pays back a refund, {"amount": ...} refunds less than was asked for (admins)
+func (h *CourseHandler) ApproveRefund(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid refund ID"})
+		return
+	}
+	var body struct {
+		Amount *entities.Money `json:"amount"`
+		Note   string          `json:"note"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	user, ok := h.requireUser(c)
+	if !ok || !h.requireAdmin(c) {
+		return
+	}
+
+	refund, err := h.useCase(c).ApproveRefund(id, body.Amount, body.Note, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, refund)
+}
+
+// RejectRefund turns down a refund with {"note": ...} (admins)
+func (h *CourseHandler) RejectRefund(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid refund ID"})
+		return
+	}
+	var body struct {
+		Note string `json:"note"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	user, ok := h.requireUser(c)
+	if !ok || !h.requireAdmin(c) {
+		return
+	}
+
+	refund, err := h.useCase(c).RejectRefund(id, body.Note, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, refund)
+}
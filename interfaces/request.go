@@ -0,0 +1,161 @@
+package interfaces
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+	"github.com/NaheedRayan/mini_rest_api_shikho/usecases"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuthorizationHeader carries the session token of the user making the request, as "Bearer <token>"
+const AuthorizationHeader = "Authorization"
+
+// RequestIDHeader carries the ID used to correlate a request with its audit log entries
+const RequestIDHeader = "X-Request-ID"
+
+const requestIDKey = "request_id"
+
+const currentUserKey = "current_user"
+
+// RequestID is a middleware that reuses the client's X-Request-ID or generates one,
+// and echoes it back in the response
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if id == "" {
+			buf := make([]byte, 16)
+			rand.Read(buf)
+			id = hex.EncodeToString(buf)
+		}
+		c.Set(requestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// useCase returns the use case scoped to the current request, so mutations are attributed to the caller
+func (h *CourseHandler) useCase(c *gin.Context) *usecases.CourseUseCase {
+	var actorID uint
+	if user, ok := h.currentUser(c); ok {
+		actorID = user.ID
+	}
+	return h.UseCase.WithRequest(usecases.RequestInfo{
+		ActorID:   actorID,
+		RequestID: c.GetString(requestIDKey),
+	})
+}
+
+// sessionToken returns the bearer token of the Authorization header
+func sessionToken(c *gin.Context) string {
+	scheme, token, found := strings.Cut(c.GetHeader(AuthorizationHeader), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// currentUser returns the user signed in with the session token of the request
+func (h *CourseHandler) currentUser(c *gin.Context) (entities.User, bool) {
+	if user, ok := c.Get(currentUserKey); ok {
+		return user.(entities.User), true
+	}
+	token := sessionToken(c)
+	if token == "" {
+		return entities.User{}, false
+	}
+	user, err := h.UseCase.Authenticate(token)
+	if err != nil {
+		return entities.User{}, false
+	}
+	c.Set(currentUserKey, user)
+	return user, true
+}
+
+// isAdmin reports whether the current user has the admin role
+func (h *CourseHandler) isAdmin(c *gin.Context) bool {
+	user, ok := h.currentUser(c)
+	return ok && user.Role == "admin"
+}
+
+// requireUser returns the current user, writing a 401 response when there is none
+func (h *CourseHandler) requireUser(c *gin.Context) (entities.User, bool) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing, invalid or expired session token, sign in with POST /login"})
+	}
+	return user, ok
+}
+
+// requireAdmin reports whether the current user is an admin, writing a 403 response when not
+func (h *CourseHandler) requireAdmin(c *gin.Context) bool {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can do this"})
+		return false
+	}
+	return true
+}
+
+// includeDeleted reads the include_deleted query parameter, which only admins may set.
+// It writes a 403 response and returns ok=false for anyone else.
+func (h *CourseHandler) includeDeleted(c *gin.Context) (include bool, ok bool) {
+	if c.Query("include_deleted") != "true" {
+		return false, true
+	}
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "include_deleted is only available to admins"})
+		return false, false
+	}
+	return true, true
+}
+
+// courseFilter builds the course filter for the caller: students only see the published
+// catalog, instructors also see their own courses and admins see everything
+func (h *CourseHandler) courseFilter(c *gin.Context) (entities.CourseFilter, bool) {
+	includeDeleted, ok := h.includeDeleted(c)
+	if !ok {
+		return entities.CourseFilter{}, false
+	}
+	filter := entities.CourseFilter{IncludeDeleted: includeDeleted, PublishedOnly: true}
+	if user, ok := h.currentUser(c); ok {
+		switch user.Role {
+		case "admin":
+			filter.PublishedOnly = false
+		case "instructor":
+			filter.Instructor = strings.TrimSpace(user.FirstName + " " + user.LastName)
+		}
+	}
+	return filter, true
+}
+
+// errorStatus maps use case errors to a status code, falling back to the given one
+func errorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, usecases.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, usecases.ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, usecases.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, usecases.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, usecases.ErrPaymentDeclined):
+		return http.StatusPaymentRequired
+	}
+	return fallback
+}
+
+// errorBody is the response body for an error, conflicts include the existing resource
+func errorBody(err error) gin.H {
+	body := gin.H{"error": err.Error()}
+	var conflict *usecases.ConflictError
+	if errors.As(err, &conflict) {
+		body["existing"] = conflict.Existing
+	}
+	return body
+}
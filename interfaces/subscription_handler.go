@@ -0,0 +1,279 @@
+package interfaces
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+
+	"github.com/gin-gonic/gin"
+)
+
+//----------------------------------------------------------------subscription plans----------------------------------------------------------------
+
+// CreateSubscriptionPlan adds a plan (admins)
+func (h *CourseHandler) CreateSubscriptionPlan(c *gin.Context) {
+	var plan entities.SubscriptionPlan
+	if err := c.ShouldBindJSON(&plan); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.requireAdmin(c) {
+		return
+	}
+
+	plan, err := h.useCase(c).CreateSubscriptionPlan(plan)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, plan)
+}
+
+// GetSubscriptionPlans lists the plans open to subscribers, admins can add ?include_inactive=true
+func (h *CourseHandler) GetSubscriptionPlans(c *gin.Context) {
+	includeInactive := c.Query("include_inactive") == "true"
+	if includeInactive && !h.requireAdmin(c) {
+		return
+	}
+
+	plans, err := h.UseCase.GetSubscriptionPlans(includeInactive)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, plans)
+}
+
+// GetSubscriptionPlan returns a plan
+func (h *CourseHandler) GetSubscriptionPlan(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid plan ID"})
+		return
+	}
+
+	plan, err := h.UseCase.GetSubscriptionPlan(id)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// UpdateSubscriptionPlan changes a plan (admins)
+func (h *CourseHandler) UpdateSubscriptionPlan(c *gin.Context) {
+	var plan entities.SubscriptionPlan
+	if err := c.ShouldBindJSON(&plan); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.requireAdmin(c) {
+		return
+	}
+
+	plan, err := h.useCase(c).UpdateSubscriptionPlan(plan)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+//----------------------------------------------------------------subscriptions----------------------------------------------------------------
+
+// Subscribe starts a subscription with {"plan_id": ..., "payment_method": ...}
+func (h *CourseHandler) Subscribe(c *gin.Context) {
+	var body struct {
+		PlanID        int    `json:"plan_id" binding:"required"`
+		PaymentMethod string `json:"payment_method" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	subscription, err := h.useCase(c).Subscribe(body.PlanID, body.PaymentMethod, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), errorBody(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, subscription)
+}
+
+// GetUserSubscriptions lists the subscriptions of a user, newest first (the user or an admin)
+func (h *CourseHandler) GetUserSubscriptions(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	subscriptions, err := h.UseCase.GetUserSubscriptions(id, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, subscriptions)
+}
+
+// GetSubscription returns a subscription (its subscriber or an admin)
+func (h *CourseHandler) GetSubscription(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscription ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	subscription, err := h.UseCase.GetSubscription(id, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, subscription)
+}
+
+// GetSubscriptionPayments lists the charges of a subscription (its subscriber or an admin)
+func (h *CourseHandler) GetSubscriptionPayments(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscription ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	payments, err := h.UseCase.GetSubscriptionPayments(id, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, payments)
+}
+
+// CancelSubscription stops a subscription at the end of its period (its subscriber or an admin)
+func (h *CourseHandler) CancelSubscription(c *gin.Context) {
+	h.changeSubscription(c, func(id int, user entities.User) (entities.Subscription, error) {
+		return h.useCase(c).CancelSubscription(id, user)
+	})
+}
+
+// ResumeSubscription takes back a cancellation (its subscriber or an admin)
+func (h *CourseHandler) ResumeSubscription(c *gin.Context) {
+	h.changeSubscription(c, func(id int, user entities.User) (entities.Subscription, error) {
+		return h.useCase(c).ResumeSubscription(id, user)
+	})
+}
+
+// UpdateSubscriptionPaymentMethod charges renewals to {"payment_method": ...}, retrying a failed one
+func (h *CourseHandler) UpdateSubscriptionPaymentMethod(c *gin.Context) {
+	var body struct {
+		PaymentMethod string `json:"payment_method" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.changeSubscription(c, func(id int, user entities.User) (entities.Subscription, error) {
+		return h.useCase(c).UpdateSubscriptionPaymentMethod(id, body.PaymentMethod, user)
+	})
+}
+
+// changeSubscription runs a change on the subscription in the path for the current user
+func (h *CourseHandler) changeSubscription(c *gin.Context, change func(id int, user entities.User) (entities.Subscription, error)) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscription ID"})
+		return
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	subscription, err := change(id, user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), errorBody(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, subscription)
+}
+
+//----------------------------------------------------------------billing----------------------------------------------------------------
+
+// GetBillingClock returns the time subscriptions are billed at (admins)
+func (h *CourseHandler) GetBillingClock(c *gin.Context) {
+	if !h.requireAdmin(c) {
+		return
+	}
+
+	now, simulated := h.UseCase.BillingTime()
+	c.JSON(http.StatusOK, gin.H{"now": now, "simulated": simulated})
+}
+
+// AdvanceBillingClock moves a simulated billing clock forward by {"duration": "720h"} and runs
+// billing (admins)
+func (h *CourseHandler) AdvanceBillingClock(c *gin.Context) {
+	var body struct {
+		Duration string `json:"duration" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	duration, err := time.ParseDuration(body.Duration)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid duration, use e.g. 720h"})
+		return
+	}
+	if !h.requireAdmin(c) {
+		return
+	}
+
+	now, processed, err := h.useCase(c).AdvanceBillingClock(duration)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"now": now, "processed": processed})
+}
+
+// RunBilling renews the subscriptions that are due now (admins)
+func (h *CourseHandler) RunBilling(c *gin.Context) {
+	if !h.requireAdmin(c) {
+		return
+	}
+
+	processed, err := h.useCase(c).RunBilling()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"processed": processed})
+}
@@ -0,0 +1,95 @@
+package interfaces
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+// TenantHeader selects the tenant of a request made on a host without a tenant subdomain
+const TenantHeader = "X-Tenant-ID"
+
+// TenantRouter is the tenant resolution middleware. It finds the tenant a request is for from the
+// host, e.g. acme.example.com, or the X-Tenant-ID header, and hands the request to that tenant's
+// router. Every tenant's router serves a use case and database of its own, so a request can only
+// reach the data of the tenant it was resolved to. Requests naming no tenant go to the default one.
+type TenantRouter struct {
+	routers map[string]http.Handler
+	hosts   map[string]string // tenant IDs by the host of their URL
+	domains map[string]bool   // the domains tenant subdomains are under, e.g. example.com for acme.example.com
+}
+
+// NewTenantRouter returns a router serving no tenants yet, see Add
+func NewTenantRouter() *TenantRouter {
+	return &TenantRouter{routers: map[string]http.Handler{}, hosts: map[string]string{}, domains: map[string]bool{}}
+}
+
+// Add serves a tenant's requests with its router. Requests reach it on the host of its URL, on
+// the subdomain named after its ID or with its ID in the X-Tenant-ID header, and the default
+// tenant's requests also on any host naming no tenant.
+func (t *TenantRouter) Add(tenant entities.Tenant, router http.Handler) {
+	t.routers[tenant.ID] = router
+	if u, err := url.Parse(tenant.URL); err == nil && u.Hostname() != "" {
+		host := strings.ToLower(u.Hostname())
+		if tenant.ID == entities.DefaultTenantID {
+			// the default tenant's host names no tenant, so the header can pick one on it
+			t.domains[host] = true
+		} else {
+			t.hosts[host] = tenant.ID
+		}
+		if domain, found := strings.CutPrefix(host, tenant.ID+"."); found {
+			t.domains[domain] = true
+		}
+	}
+}
+
+func (t *TenantRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tenantID, status, err := t.resolve(r)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	r.Header.Set(TenantHeader, tenantID)
+	w.Header().Set(TenantHeader, tenantID)
+	t.routers[tenantID].ServeHTTP(w, r)
+}
+
+// resolve returns the tenant a request is for. A host names a tenant when it is the host of the
+// tenant's URL or its first label is the tenant's ID, and a subdomain of the tenants' domains
+// naming no tenant is refused rather than served by the default tenant. Other hosts, such as an
+// IP address, name none. A header naming a different tenant than the host is refused rather than
+// letting one win.
+func (t *TenantRouter) resolve(r *http.Request) (string, int, error) {
+	host := strings.ToLower(r.Host)
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	fromHost := t.hosts[host]
+	if label, domain, found := strings.Cut(host, "."); found && fromHost == "" {
+		if t.routers[label] != nil {
+			fromHost = label
+		} else if t.domains[domain] {
+			return "", http.StatusNotFound, fmt.Errorf("unknown tenant %q", label)
+		}
+	}
+	fromHeader := strings.ToLower(strings.TrimSpace(r.Header.Get(TenantHeader)))
+
+	switch {
+	case fromHeader == "" && fromHost == "":
+		return entities.DefaultTenantID, 0, nil
+	case fromHeader == "":
+		return fromHost, 0, nil
+	case t.routers[fromHeader] == nil:
+		return "", http.StatusNotFound, fmt.Errorf("unknown tenant %q", fromHeader)
+	case fromHost != "" && fromHost != fromHeader:
+		return "", http.StatusBadRequest, fmt.Errorf("the %s header names tenant %q but the host belongs to %q", TenantHeader, fromHeader, fromHost)
+	}
+	return fromHeader, 0, nil
+}
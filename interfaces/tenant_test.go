@@ -0,0 +1,188 @@
+package interfaces_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/config"
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+	"github.com/NaheedRayan/mini_rest_api_shikho/infrastructure"
+	"github.com/NaheedRayan/mini_rest_api_shikho/infrastructure/database"
+	"github.com/NaheedRayan/mini_rest_api_shikho/interfaces"
+	"github.com/NaheedRayan/mini_rest_api_shikho/usecases"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tenantServer serves the acme and beta tenants, each on a database of its own in a temporary
+// directory, and gives each an admin signed in and a course
+type tenantServer struct {
+	t      *testing.T
+	server *httptest.Server
+	tokens map[string]string // session tokens of the tenants' admins
+}
+
+func newTenantServer(t *testing.T) *tenantServer {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	router := interfaces.NewTenantRouter()
+	for _, tenant := range []entities.Tenant{
+		{ID: "acme", Name: "Acme Academy", URL: "http://acme.localhost:8080"},
+		{ID: "beta", Name: "Beta School", URL: "http://beta.localhost:8080"},
+	} {
+		db := config.OpenDB(filepath.Join(dir, tenant.ID, "courses.db"))
+		t.Cleanup(func() { db.Close() })
+		useCase := usecases.NewCourseUseCase(database.NewCourseRepository(db))
+		router.Add(tenant, infrastructure.SetupRouter(interfaces.NewCourseHandler(useCase)))
+	}
+	s := &tenantServer{t: t, server: httptest.NewServer(router), tokens: map[string]string{}}
+	t.Cleanup(s.server.Close)
+
+	for _, tenant := range []string{"acme", "beta"} {
+		host := tenant + ".localhost"
+		email := "admin@" + tenant + ".example.com"
+		s.mustDo(http.StatusCreated, "POST", host, "", "", "/user",
+			map[string]string{"first_name": "Admin", "last_name": tenant, "email": email, "password": "password-" + tenant, "role": "admin"})
+		var session entities.Session
+		s.decode(s.mustDo(http.StatusCreated, "POST", host, "", "", "/login",
+			map[string]string{"email": email, "password": "password-" + tenant}), &session)
+		s.tokens[tenant] = session.Token
+		s.mustDo(http.StatusCreated, "POST", host, "", session.Token, "/course", map[string]interface{}{
+			"title": tenant + " course", "description": "Only for " + tenant, "duration": "4 weeks",
+			"price": map[string]interface{}{"amount": 1000, "currency": "USD"}, "instructor": "Admin " + tenant, "category": "programming",
+		})
+	}
+	return s
+}
+
+// do sends a request with the given host, X-Tenant-ID header and session token, any of them can be empty
+func (s *tenantServer) do(method, host, tenantHeader, token, path string, body interface{}) *http.Response {
+	s.t.Helper()
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			s.t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, s.server.URL+path, &payload)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if host != "" {
+		req.Host = host
+	}
+	if tenantHeader != "" {
+		req.Header.Set(interfaces.TenantHeader, tenantHeader)
+	}
+	if token != "" {
+		req.Header.Set(interfaces.AuthorizationHeader, "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	s.t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func (s *tenantServer) mustDo(status int, method, host, tenantHeader, token, path string, body interface{}) *http.Response {
+	s.t.Helper()
+	resp := s.do(method, host, tenantHeader, token, path, body)
+	if resp.StatusCode != status {
+		var message bytes.Buffer
+		message.ReadFrom(resp.Body)
+		s.t.Fatalf("%s %s on %q with tenant header %q: got %d, want %d: %s", method, path, host, tenantHeader, resp.StatusCode, status, message.String())
+	}
+	return resp
+}
+
+func (s *tenantServer) decode(resp *http.Response, v interface{}) {
+	s.t.Helper()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+// course reads course 1 as a tenant's admin, both tenants have one with that ID
+func (s *tenantServer) course(host, tenantHeader, token string) entities.Course {
+	s.t.Helper()
+	var course entities.Course
+	s.decode(s.mustDo(http.StatusOK, "GET", host, tenantHeader, token, "/course/1", nil), &course)
+	return course
+}
+
+func TestTenantsAreResolvedFromTheSubdomain(t *testing.T) {
+	s := newTenantServer(t)
+
+	for _, tenant := range []string{"acme", "beta"} {
+		host := tenant + ".localhost:8080"
+		resp := s.mustDo(http.StatusOK, "GET", host, "", s.tokens[tenant], "/me", nil)
+		if got := resp.Header.Get(interfaces.TenantHeader); got != tenant {
+			t.Errorf("%s: served for tenant %q", host, got)
+		}
+		var me entities.User
+		s.decode(resp, &me)
+		if want := "admin@" + tenant + ".example.com"; me.Email != want {
+			t.Errorf("%s: signed in as %q, want %q", host, me.Email, want)
+		}
+		if course := s.course(host, "", s.tokens[tenant]); course.Title != tenant+" course" {
+			t.Errorf("%s: course 1 is %q", host, course.Title)
+		}
+	}
+}
+
+func TestTenantsAreResolvedFromTheHeader(t *testing.T) {
+	s := newTenantServer(t)
+
+	for _, tenant := range []string{"acme", "beta"} {
+		if course := s.course("localhost:8080", tenant, s.tokens[tenant]); course.Title != tenant+" course" {
+			t.Errorf("header %s: course 1 is %q", tenant, course.Title)
+		}
+	}
+}
+
+func TestTenantsCannotReadEachOther(t *testing.T) {
+	s := newTenantServer(t)
+
+	// a session is only known to the tenant it was opened in
+	s.mustDo(http.StatusUnauthorized, "GET", "beta.localhost", "", s.tokens["acme"], "/me", nil)
+	s.mustDo(http.StatusUnauthorized, "GET", "localhost", "beta", s.tokens["acme"], "/me", nil)
+
+	// the other tenant's users and courses are not there
+	s.mustDo(http.StatusCreated, "POST", "acme.localhost", "", s.tokens["acme"], "/course", map[string]interface{}{
+		"title": "second acme course", "description": "Only for acme", "duration": "1 week",
+		"price": map[string]interface{}{"amount": 500, "currency": "USD"}, "instructor": "Admin acme", "category": "design",
+	})
+	s.mustDo(http.StatusOK, "GET", "acme.localhost", "", s.tokens["acme"], "/course/2", nil)
+	s.mustDo(http.StatusNotFound, "GET", "beta.localhost", "", s.tokens["beta"], "/course/2", nil)
+	s.mustDo(http.StatusNotFound, "GET", "localhost", "beta", s.tokens["beta"], "/course/2", nil)
+
+	var users []entities.User
+	s.decode(s.mustDo(http.StatusOK, "GET", "localhost", "beta", s.tokens["beta"], "/users", nil), &users)
+	for _, user := range users {
+		if user.Email != "admin@beta.example.com" {
+			t.Errorf("beta lists user %q", user.Email)
+		}
+	}
+}
+
+func TestTenantHostAndHeaderMustAgree(t *testing.T) {
+	s := newTenantServer(t)
+
+	s.mustDo(http.StatusBadRequest, "GET", "acme.localhost", "beta", s.tokens["beta"], "/course/1", nil)
+	s.mustDo(http.StatusBadRequest, "GET", "beta.localhost", "acme", s.tokens["acme"], "/course/1", nil)
+	// naming the host's own tenant is fine
+	s.mustDo(http.StatusOK, "GET", "acme.localhost", "acme", s.tokens["acme"], "/course/1", nil)
+}
+
+func TestUnknownTenantsAreRefused(t *testing.T) {
+	s := newTenantServer(t)
+
+	s.mustDo(http.StatusNotFound, "GET", "typo.localhost", "", "", "/courses", nil)
+	s.mustDo(http.StatusNotFound, "GET", "localhost", "typo", "", "/courses", nil)
+}
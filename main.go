@@ -1,29 +1,114 @@
 package main
 
 import (
+	"log"
+	"net/http"
+	"os"
+	"time"
+
 	"github.com/NaheedRayan/mini_rest_api_shikho/config"
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
 	"github.com/NaheedRayan/mini_rest_api_shikho/infrastructure"
+	"github.com/NaheedRayan/mini_rest_api_shikho/infrastructure/certificate"
+	"github.com/NaheedRayan/mini_rest_api_shikho/infrastructure/clock"
+	"github.com/NaheedRayan/mini_rest_api_shikho/infrastructure/currency"
 	"github.com/NaheedRayan/mini_rest_api_shikho/infrastructure/database"
+	"github.com/NaheedRayan/mini_rest_api_shikho/infrastructure/invoice"
+	"github.com/NaheedRayan/mini_rest_api_shikho/infrastructure/moderation"
+	"github.com/NaheedRayan/mini_rest_api_shikho/infrastructure/notify"
+	"github.com/NaheedRayan/mini_rest_api_shikho/infrastructure/payment"
+	"github.com/NaheedRayan/mini_rest_api_shikho/infrastructure/storage"
+	"github.com/NaheedRayan/mini_rest_api_shikho/infrastructure/tenant"
 	"github.com/NaheedRayan/mini_rest_api_shikho/interfaces"
 	"github.com/NaheedRayan/mini_rest_api_shikho/usecases"
 )
 
 func main() {
-	// Initialize database
-	config.InitDB()
+	// Load the schools hosted on this deployment
+	tenants, err := tenant.LoadTenants("tenants.json")
+	if err != nil {
+		log.Fatalf("Failed to load tenants: %v", err)
+	}
+	rates, err := currency.LoadRateTable("rates.json")
+	if err != nil {
+		log.Fatalf("Failed to load exchange rates: %v", err)
+	}
+
+	// Every tenant gets its own database, use case and router
+	tenantRouter := interfaces.NewTenantRouter()
+	for _, t := range tenants {
+		courseUseCase := newCourseUseCase(t, rates)
+
+		// Purge soft deleted rows after 30 days
+		infrastructure.StartPurgeScheduler(courseUseCase, 30*24*time.Hour, time.Hour)
+
+		// Renew subscriptions
+		infrastructure.StartBillingScheduler(courseUseCase, time.Minute)
+
+		// Send notification emails
+		infrastructure.StartMailer(courseUseCase, 5*time.Second)
+
+		tenantRouter.Add(t, infrastructure.SetupRouter(interfaces.NewCourseHandler(courseUseCase)))
+	}
 
+	// Start server, requests are resolved to their tenant before they are routed
+	log.Fatal(http.ListenAndServe(":8080", tenantRouter))
+}
+
+// newCourseUseCase wires the use case of a tenant. Its database and files are its own, so nothing
+// it stores can be read through another tenant.
+func newCourseUseCase(t entities.Tenant, rates usecases.ExchangeRates) *usecases.CourseUseCase {
 	// Initialize repository
-	courseRepo := database.NewCourseRepository(config.DB)
+	courseRepo := database.NewCourseRepository(config.OpenDB(tenant.Path(t, "courses.db")))
 
 	// Initialize use case
 	courseUseCase := usecases.NewCourseUseCase(courseRepo)
+	courseUseCase.Filter = moderation.NewDefaultFilter()
+	courseUseCase.Storage = storage.NewLocalStorage(tenant.Path(t, "uploads"))
+	courseUseCase.Certificates = certificate.NewPDFRenderer(t.URL + "/certificates/verify/")
+	courseUseCase.Payments = newPayments(t)
+	courseUseCase.Rates = rates
+	courseUseCase.Invoicing = usecases.InvoiceSettings{
+		Seller:  entities.InvoiceParty{Name: t.Name, Address: "Dhaka, Bangladesh", Email: "billing@shikho.local"},
+		TaxName: "VAT",
+		TaxRate: 15,
+	}
+	courseUseCase.Invoices = invoice.NewRenderer("en-US")
+	courseUseCase.Notifier = newNotifier(t)
+	courseUseCase.Templates = notify.NewTemplates(t.Name, t.URL)
+	// with BILLING_CLOCK=simulated admins can move the billing clock forward to try renewals,
+	// subscriptions are billed at the real time otherwise
+	if os.Getenv("BILLING_CLOCK") == "simulated" {
+		courseUseCase.Clock = clock.NewSimulatedClock()
+	}
+	return courseUseCase
+}
 
-	// Initialize handler
-	courseHandler := interfaces.NewCourseHandler(courseUseCase)
-
-	// Setup router
-	router := infrastructure.SetupRouter(courseHandler)
+// newPayments returns the payment gateway of a tenant, its webhooks are signed with the secret in
+// PAYMENT_WEBHOOK_SECRET. Without one payments are not configured and checkout, subscriptions,
+// seat purchases and refunds are unavailable.
+func newPayments(t entities.Tenant) usecases.PaymentGateway {
+	secret := os.Getenv("PAYMENT_WEBHOOK_SECRET")
+	if secret == "" {
+		log.Printf("PAYMENT_WEBHOOK_SECRET is not set, payments are disabled for tenant %s", t.ID)
+		return nil
+	}
+	// the fake gateway settles 3DS payments by calling back into this server, naming the tenant
+	gateway := payment.NewFakeGateway("http://localhost:8080/payments/webhook", secret, 2*time.Second)
+	gateway.SetWebhookHeader(interfaces.TenantHeader, t.ID)
+	return gateway
+}
 
-	// Start server
-	router.Run(":8080")
+// newNotifier sends a tenant's emails through the SMTP server in SMTP_ADDR, logging in with
+// SMTP_USERNAME and SMTP_PASSWORD. Without one they are printed when MAIL_OUTPUT is stdout and
+// written to files otherwise.
+func newNotifier(t entities.Tenant) usecases.Notifier {
+	from := t.Name + " <no-reply@shikho.local>"
+	if addr := os.Getenv("SMTP_ADDR"); addr != "" {
+		return notify.NewSMTPNotifier(addr, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), from)
+	}
+	if os.Getenv("MAIL_OUTPUT") == "stdout" {
+		return notify.NewStdoutNotifier(from)
+	}
+	return notify.NewFileNotifier(tenant.Path(t, "mail"), from)
 }
@@ -0,0 +1,593 @@
+package usecases
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+// limits on the files of a submission
+const (
+	MaxSubmissionFiles    = 5
+	MaxSubmissionFileSize = 10 << 20
+)
+
+// FileStorage keeps the files uploaded with submissions
+type FileStorage interface {
+	Save(key string, content io.Reader) (int64, error)
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+// FileUpload is a file sent with a submission
+type FileUpload struct {
+	Name        string
+	ContentType string
+	Size        int64
+	Content     io.Reader
+}
+
+//----------------------------------------------------------------assignments----------------------------------------------------------------
+
+// AddAssignment creates an assignment for a course
+func (uc *CourseUseCase) AddAssignment(assignment entities.Assignment, actor entities.User) (entities.Assignment, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.Assignment, error) {
+		if err := uc.validateAssignment(&assignment, actor); err != nil {
+			return entities.Assignment{}, err
+		}
+		created, err := uc.Repo.AddAssignment(assignment)
+		if err != nil {
+			return created, err
+		}
+		if err := uc.record(entities.AuditCreate, EntityAssignment, created.ID, nil, created); err != nil {
+			return entities.Assignment{}, err
+		}
+		return created, nil
+	})
+}
+
+// UpdateAssignment edits an assignment, submissions already graded keep their score
+func (uc *CourseUseCase) UpdateAssignment(assignment entities.Assignment, actor entities.User) (entities.Assignment, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.Assignment, error) {
+		before, err := uc.Repo.GetAssignmentByID(int(assignment.ID))
+		if err != nil {
+			return entities.Assignment{}, notFound(err)
+		}
+		assignment.CourseID = before.CourseID
+		if err := uc.validateAssignment(&assignment, actor); err != nil {
+			return entities.Assignment{}, err
+		}
+		updated, err := uc.Repo.UpdateAssignment(assignment)
+		if err != nil {
+			return updated, notFound(err)
+		}
+		if err := uc.record(entities.AuditUpdate, EntityAssignment, updated.ID, before, updated); err != nil {
+			return entities.Assignment{}, err
+		}
+		return updated, nil
+	})
+}
+
+// DeleteAssignment removes an assignment nobody submitted to yet
+func (uc *CourseUseCase) DeleteAssignment(id int, actor entities.User) error {
+	return uc.atomic(func(uc *CourseUseCase) error {
+		before, err := uc.Repo.GetAssignmentByID(id)
+		if err != nil {
+			return notFound(err)
+		}
+		if err := uc.requireCourseInstructor(int(before.CourseID), actor); err != nil {
+			return err
+		}
+		submissions, err := uc.Repo.GetSubmissions(id, 0)
+		if err != nil {
+			return err
+		}
+		if len(submissions) > 0 {
+			return fmt.Errorf("the assignment has %d submissions and cannot be deleted", len(submissions))
+		}
+		if err := uc.Repo.DeleteAssignment(id); err != nil {
+			return notFound(err)
+		}
+		return uc.record(entities.AuditDelete, EntityAssignment, before.ID, before, nil)
+	})
+}
+
+// GetAssignment returns an assignment of a course visible to the caller
+func (uc *CourseUseCase) GetAssignment(id int, filter entities.CourseFilter) (entities.Assignment, error) {
+	assignment, err := uc.Repo.GetAssignmentByID(id)
+	if err != nil {
+		return entities.Assignment{}, notFound(err)
+	}
+	if _, err := uc.GetCourseByID(int(assignment.CourseID), filter); err != nil {
+		return entities.Assignment{}, err
+	}
+	return assignment, nil
+}
+
+// GetCourseAssignments lists the assignments of a course visible to the caller
+func (uc *CourseUseCase) GetCourseAssignments(courseID int, filter entities.CourseFilter) ([]entities.Assignment, error) {
+	if _, err := uc.GetCourseByID(courseID, filter); err != nil {
+		return nil, err
+	}
+	return uc.Repo.GetAssignmentsByCourseID(courseID)
+}
+
+//----------------------------------------------------------------submissions----------------------------------------------------------------
+
+// SubmitAssignment stores the text and files a student hands in. A resubmission replaces the previous
+// submission when the assignment allows it. Late work is rejected unless the assignment accepts it.
+func (uc *CourseUseCase) SubmitAssignment(assignmentID int, submission entities.Submission, uploads []FileUpload, actor entities.User) (entities.Submission, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.Submission, error) {
+		assignment, err := uc.Repo.GetAssignmentByID(assignmentID)
+		if err != nil {
+			return entities.Submission{}, notFound(err)
+		}
+		enrollment, err := uc.enrollmentForStudent(int(submission.EnrollmentID), actor)
+		if err != nil {
+			return entities.Submission{}, err
+		}
+		if enrollment.CourseID != assignment.CourseID {
+			return entities.Submission{}, fmt.Errorf("the assignment does not belong to the enrolled course")
+		}
+		if assignment.LessonID != nil {
+			if err := uc.checkLessonReleased(enrollment.ID, *assignment.LessonID); err != nil {
+				return entities.Submission{}, err
+			}
+		}
+		submission.Text = strings.TrimSpace(submission.Text)
+		if submission.Text == "" && len(uploads) == 0 {
+			return entities.Submission{}, fmt.Errorf("a submission needs text or at least one file")
+		}
+		if err := uc.checkUploads(uploads); err != nil {
+			return entities.Submission{}, err
+		}
+
+		previous, err := uc.Repo.GetSubmissions(assignmentID, int(enrollment.ID))
+		if err != nil {
+			return entities.Submission{}, err
+		}
+		if latest, err := checkNewSubmission(assignment, previous); err != nil {
+			return latest, err
+		}
+
+		now := time.Now().UTC()
+		if assignment.DueAt != nil && now.After(*assignment.DueAt) {
+			if !assignment.AcceptLate {
+				return entities.Submission{}, fmt.Errorf("%w: the assignment was due at %s", ErrForbidden, assignment.DueAt.Format(time.RFC3339))
+			}
+			submission.DaysLate = uint(math.Ceil(now.Sub(*assignment.DueAt).Hours() / 24))
+		}
+
+		submission.AssignmentID = assignment.ID
+		submission.EnrollmentID = enrollment.ID
+		submission.Number = uint(len(previous)) + 1
+		submission.SubmittedAt = now
+		submission.Status = entities.SubmissionSubmitted
+		submission.Files, err = uc.storeUploads(assignment.ID, enrollment.ID, uploads)
+		if err != nil {
+			return entities.Submission{}, err
+		}
+
+		created, err := uc.Repo.AddSubmission(submission)
+		if err != nil {
+			uc.deleteFiles(submission.Files)
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			// another submission was handed in concurrently
+			previous, err := uc.Repo.GetSubmissions(assignmentID, int(enrollment.ID))
+			if err != nil {
+				return entities.Submission{}, err
+			}
+			if latest, err := checkNewSubmission(assignment, previous); err != nil {
+				return latest, err
+			}
+			if len(previous) == 0 {
+				return entities.Submission{}, ErrNotFound
+			}
+			latest := previous[len(previous)-1]
+			return latest, &ConflictError{Resource: "submission", Existing: latest}
+		}
+		if err != nil {
+			return created, err
+		}
+		if err := uc.record(entities.AuditCreate, EntitySubmission, created.ID, nil, created); err != nil {
+			return entities.Submission{}, err
+		}
+		return created, nil
+	})
+}
+
+// checkNewSubmission fails with a ConflictError carrying the latest submission when the assignment
+// takes one submission, or when all its submissions are used
+func checkNewSubmission(assignment entities.Assignment, previous []entities.Submission) (entities.Submission, error) {
+	if len(previous) > 0 && !assignment.AllowResubmit {
+		latest := previous[len(previous)-1]
+		return latest, &ConflictError{Resource: "submission", Existing: latest}
+	}
+	if assignment.MaxSubmissions > 0 && uint(len(previous)) >= assignment.MaxSubmissions {
+		return entities.Submission{}, fmt.Errorf("%w: all %d submissions to this assignment are used", ErrForbidden, assignment.MaxSubmissions)
+	}
+	return entities.Submission{}, nil
+}
+
+// GradeSubmission scores the latest submission of a student, by rubric when the assignment has one.
+// The late penalty is taken off the points, a passing grade can complete the enrollment.
+func (uc *CourseUseCase) GradeSubmission(id int, grade entities.SubmissionGrade, actor entities.User) (entities.Submission, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.Submission, error) {
+		submission, err := uc.Repo.GetSubmissionByID(id)
+		if err != nil {
+			return entities.Submission{}, notFound(err)
+		}
+		assignment, err := uc.Repo.GetAssignmentByID(int(submission.AssignmentID))
+		if err != nil {
+			return entities.Submission{}, notFound(err)
+		}
+		if err := uc.requireCourseInstructor(int(assignment.CourseID), actor); err != nil {
+			return entities.Submission{}, err
+		}
+		submissions, err := uc.Repo.GetSubmissions(int(assignment.ID), int(submission.EnrollmentID))
+		if err != nil {
+			return entities.Submission{}, err
+		}
+		if latest := submissions[len(submissions)-1]; latest.ID != submission.ID {
+			return entities.Submission{}, fmt.Errorf("submission %d was replaced by submission %d, grade that one", submission.ID, latest.ID)
+		}
+
+		points, err := gradePoints(assignment, &grade)
+		if err != nil {
+			return entities.Submission{}, err
+		}
+		before := submission
+		now := time.Now().UTC()
+		penalty := math.Min(100, float64(submission.DaysLate)*assignment.LatePenaltyPercent)
+		score := math.Round(points*(100-penalty)) / 100
+		submission.Status = entities.SubmissionGraded
+		submission.RubricScores = grade.RubricScores
+		submission.Points = &points
+		submission.PenaltyPercent = penalty
+		submission.Score = &score
+		submission.Passed = assignment.MaxPoints == 0 || score*100/assignment.MaxPoints >= assignment.PassingScore
+		submission.Feedback = strings.TrimSpace(grade.Feedback)
+		submission.GradedBy = &actor.ID
+		submission.GradedAt = &now
+
+		if err := uc.Repo.GradeSubmission(submission); err != nil {
+			return entities.Submission{}, notFound(err)
+		}
+		if err := uc.record(entities.AuditUpdate, EntitySubmission, submission.ID, before, submission); err != nil {
+			return entities.Submission{}, err
+		}
+		if err := uc.refreshCompletion(int(submission.EnrollmentID)); err != nil {
+			return submission, err
+		}
+		return submission, nil
+	})
+}
+
+// GetSubmission returns a submission to its student, the course instructor or an admin
+func (uc *CourseUseCase) GetSubmission(id int, actor entities.User) (entities.Submission, error) {
+	submission, err := uc.Repo.GetSubmissionByID(id)
+	if err != nil {
+		return entities.Submission{}, notFound(err)
+	}
+	if err := uc.canViewEnrollment(int(submission.EnrollmentID), actor); err != nil {
+		return entities.Submission{}, err
+	}
+	return submission, nil
+}
+
+// GetSubmissions lists the submissions to an assignment. Without an enrollment only the
+// course instructor and admins see every student's submissions.
+func (uc *CourseUseCase) GetSubmissions(assignmentID, enrollmentID int, actor entities.User) ([]entities.Submission, error) {
+	assignment, err := uc.Repo.GetAssignmentByID(assignmentID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if enrollmentID != 0 {
+		err = uc.canViewEnrollment(enrollmentID, actor)
+	} else {
+		err = uc.requireCourseInstructor(int(assignment.CourseID), actor)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return uc.Repo.GetSubmissions(assignmentID, enrollmentID)
+}
+
+// OpenSubmissionFile returns a file of a submission with its content, the caller closes it
+func (uc *CourseUseCase) OpenSubmissionFile(id int, actor entities.User) (entities.SubmissionFile, io.ReadCloser, error) {
+	file, err := uc.Repo.GetSubmissionFile(id)
+	if err != nil {
+		return entities.SubmissionFile{}, nil, notFound(err)
+	}
+	if _, err := uc.GetSubmission(int(file.SubmissionID), actor); err != nil {
+		return entities.SubmissionFile{}, nil, err
+	}
+	if uc.Storage == nil {
+		return entities.SubmissionFile{}, nil, fmt.Errorf("file storage is not configured")
+	}
+	content, err := uc.Storage.Open(file.StorageKey)
+	if err != nil {
+		return entities.SubmissionFile{}, nil, err
+	}
+	return file, content, nil
+}
+
+//----------------------------------------------------------------grades----------------------------------------------------------------
+
+// GetEnrollmentGrades returns the best quiz attempts and the latest assignment submissions of an enrollment
+func (uc *CourseUseCase) GetEnrollmentGrades(enrollmentID int, actor entities.User) (entities.EnrollmentGrades, error) {
+	if err := uc.canViewEnrollment(enrollmentID, actor); err != nil {
+		return entities.EnrollmentGrades{}, err
+	}
+	enrollment, err := uc.Repo.GetEnrollmentByID(enrollmentID)
+	if err != nil {
+		return entities.EnrollmentGrades{}, notFound(err)
+	}
+	grades := entities.EnrollmentGrades{EnrollmentID: enrollment.ID, CourseID: enrollment.CourseID}
+
+	quizzes, err := uc.Repo.GetQuizzesByCourseID(int(enrollment.CourseID))
+	if err != nil {
+		return entities.EnrollmentGrades{}, err
+	}
+	if grades.Quizzes, err = uc.quizGrades(enrollment, quizzes); err != nil {
+		return entities.EnrollmentGrades{}, err
+	}
+	assignments, err := uc.Repo.GetAssignmentsByCourseID(int(enrollment.CourseID))
+	if err != nil {
+		return entities.EnrollmentGrades{}, err
+	}
+	grades.Assignments, err = uc.assignmentGrades(enrollment, assignments)
+	if err != nil {
+		return entities.EnrollmentGrades{}, err
+	}
+	return grades, nil
+}
+
+// quizGrades returns the best attempt of an enrollment at every quiz
+func (uc *CourseUseCase) quizGrades(enrollment entities.Enrollment, quizzes []entities.Quiz) ([]entities.QuizGrade, error) {
+	attempts, err := uc.Repo.GetCourseQuizAttempts(int(enrollment.CourseID), int(enrollment.ID))
+	if err != nil {
+		return nil, err
+	}
+	return gradeQuizzes(quizzes, attempts, time.Now()), nil
+}
+
+// gradeQuizzes returns the best of the attempts at every quiz, the ones past their time limit count as closed
+func gradeQuizzes(quizzes []entities.Quiz, attempts []entities.QuizAttempt, now time.Time) []entities.QuizGrade {
+	grades := make([]entities.QuizGrade, 0, len(quizzes))
+	for _, quiz := range quizzes {
+		grade := entities.QuizGrade{QuizID: quiz.ID, LessonID: quiz.LessonID, Title: quiz.Title}
+		for _, attempt := range attempts {
+			if attempt.QuizID != quiz.ID {
+				continue
+			}
+			grade.Attempts++
+			attempt = closedIfExpired(quiz, attempt, now)
+			if attempt.SubmittedAt == nil {
+				continue
+			}
+			if grade.BestScore == nil || attempt.Score > *grade.BestScore {
+				score := attempt.Score
+				grade.BestScore = &score
+			}
+			grade.Passed = grade.Passed || attempt.Passed
+		}
+		grades = append(grades, grade)
+	}
+	return grades
+}
+
+// assignmentGrades returns the latest submission of an enrollment to every assignment
+func (uc *CourseUseCase) assignmentGrades(enrollment entities.Enrollment, assignments []entities.Assignment) ([]entities.AssignmentGrade, error) {
+	submissions, err := uc.Repo.GetSubmissionsByEnrollment(int(enrollment.ID))
+	if err != nil {
+		return nil, err
+	}
+	return gradeAssignments(assignments, submissions), nil
+}
+
+// gradeAssignments returns the latest of the submissions to every assignment
+func gradeAssignments(assignments []entities.Assignment, submissions []entities.Submission) []entities.AssignmentGrade {
+	latest := map[uint]entities.Submission{}
+	for _, submission := range submissions {
+		latest[submission.AssignmentID] = submission
+	}
+
+	grades := make([]entities.AssignmentGrade, 0, len(assignments))
+	for _, assignment := range assignments {
+		grade := entities.AssignmentGrade{
+			AssignmentID: assignment.ID,
+			Title:        assignment.Title,
+			Required:     assignment.Required,
+			DueAt:        assignment.DueAt,
+			MaxPoints:    assignment.MaxPoints,
+			Status:       "missing",
+		}
+		if submission, ok := latest[assignment.ID]; ok {
+			id := submission.ID
+			grade.Status = submission.Status
+			grade.SubmissionID = &id
+			grade.Score = submission.Score
+			grade.Passed = submission.Passed
+		}
+		grades = append(grades, grade)
+	}
+	return grades
+}
+
+// requiredAssignmentsPassed reports whether the latest submission to every required assignment of
+// the enrollment's course is graded as passed
+func (uc *CourseUseCase) requiredAssignmentsPassed(enrollment entities.Enrollment) (bool, error) {
+	assignments, err := uc.Repo.GetAssignmentsByCourseID(int(enrollment.CourseID))
+	if err != nil {
+		return false, err
+	}
+	grades, err := uc.assignmentGrades(enrollment, assignments)
+	if err != nil {
+		return false, err
+	}
+	for _, grade := range grades {
+		if grade.Required && !grade.Passed {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// validateAssignment checks the lesson, the rubric and the policies of an assignment
+func (uc *CourseUseCase) validateAssignment(assignment *entities.Assignment, actor entities.User) error {
+	assignment.Title = strings.TrimSpace(assignment.Title)
+	if assignment.Title == "" {
+		return fmt.Errorf("assignment title is required")
+	}
+	if err := uc.requireCourseInstructor(int(assignment.CourseID), actor); err != nil {
+		return err
+	}
+	if assignment.LessonID != nil {
+		lesson := uc.lessonSnapshot(int(*assignment.LessonID))
+		if lesson.ID == 0 || lesson.CourseID != assignment.CourseID {
+			return fmt.Errorf("lesson %d is not a lesson of the course", *assignment.LessonID)
+		}
+	}
+	if assignment.DueAt != nil {
+		due := assignment.DueAt.UTC()
+		assignment.DueAt = &due
+	}
+
+	if assignment.Rubric == nil {
+		assignment.Rubric = []entities.RubricCriterion{}
+	}
+	if len(assignment.Rubric) > 0 {
+		seen := map[string]bool{}
+		total := 0.0
+		for i := range assignment.Rubric {
+			criterion := &assignment.Rubric[i]
+			criterion.Name = strings.TrimSpace(criterion.Name)
+			if criterion.Name == "" || seen[strings.ToLower(criterion.Name)] {
+				return fmt.Errorf("rubric criteria need distinct names")
+			}
+			if criterion.Points <= 0 {
+				return fmt.Errorf("rubric criterion %q needs positive points", criterion.Name)
+			}
+			seen[strings.ToLower(criterion.Name)] = true
+			total += criterion.Points
+		}
+		assignment.MaxPoints = total
+	} else if assignment.MaxPoints == 0 {
+		assignment.MaxPoints = 100
+	}
+	if assignment.MaxPoints < 0 {
+		return fmt.Errorf("max points must be positive")
+	}
+	if assignment.PassingScore < 0 || assignment.PassingScore > 100 {
+		return fmt.Errorf("passing score must be between 0 and 100")
+	}
+	if assignment.LatePenaltyPercent < 0 || assignment.LatePenaltyPercent > 100 {
+		return fmt.Errorf("late penalty must be between 0 and 100 percent")
+	}
+	if !assignment.AllowResubmit {
+		assignment.MaxSubmissions = 0
+	}
+	return nil
+}
+
+// gradePoints checks a grade against the assignment and returns the points before the late penalty
+func gradePoints(assignment entities.Assignment, grade *entities.SubmissionGrade) (float64, error) {
+	if len(assignment.Rubric) == 0 {
+		if grade.Points == nil || *grade.Points < 0 || *grade.Points > assignment.MaxPoints {
+			return 0, fmt.Errorf("points between 0 and %g are required", assignment.MaxPoints)
+		}
+		grade.RubricScores = nil
+		return *grade.Points, nil
+	}
+
+	scores := map[string]entities.RubricScore{}
+	for _, score := range grade.RubricScores {
+		scores[strings.ToLower(strings.TrimSpace(score.Criterion))] = score
+	}
+	if len(scores) != len(grade.RubricScores) || len(scores) != len(assignment.Rubric) {
+		return 0, fmt.Errorf("score every rubric criterion exactly once")
+	}
+	ordered := make([]entities.RubricScore, 0, len(assignment.Rubric))
+	total := 0.0
+	for _, criterion := range assignment.Rubric {
+		score, ok := scores[strings.ToLower(criterion.Name)]
+		if !ok {
+			return 0, fmt.Errorf("rubric criterion %q is not scored", criterion.Name)
+		}
+		if score.Points < 0 || score.Points > criterion.Points {
+			return 0, fmt.Errorf("rubric criterion %q takes between 0 and %g points", criterion.Name, criterion.Points)
+		}
+		score.Criterion = criterion.Name
+		ordered = append(ordered, score)
+		total += score.Points
+	}
+	grade.RubricScores = ordered
+	return total, nil
+}
+
+// checkUploads rejects too many or too large files
+func (uc *CourseUseCase) checkUploads(uploads []FileUpload) error {
+	if len(uploads) == 0 {
+		return nil
+	}
+	if uc.Storage == nil {
+		return fmt.Errorf("file uploads are not available")
+	}
+	if len(uploads) > MaxSubmissionFiles {
+		return fmt.Errorf("a submission takes at most %d files", MaxSubmissionFiles)
+	}
+	for _, upload := range uploads {
+		if upload.Size > MaxSubmissionFileSize {
+			return fmt.Errorf("file %q is larger than %d MB", upload.Name, MaxSubmissionFileSize>>20)
+		}
+	}
+	return nil
+}
+
+// storeUploads saves the files of a submission under unique keys, nothing is kept when one fails
+func (uc *CourseUseCase) storeUploads(assignmentID, enrollmentID uint, uploads []FileUpload) ([]entities.SubmissionFile, error) {
+	files := []entities.SubmissionFile{}
+	for _, upload := range uploads {
+		name := path.Base(strings.ReplaceAll(upload.Name, "\\", "/"))
+		if name == "." || name == "/" {
+			name = "file"
+		}
+		contentType := upload.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		buf := make([]byte, 16)
+		rand.Read(buf)
+		key := fmt.Sprintf("submissions/%d/%d/%s", assignmentID, enrollmentID, hex.EncodeToString(buf))
+
+		size, err := uc.Storage.Save(key, io.LimitReader(upload.Content, MaxSubmissionFileSize+1))
+		file := entities.SubmissionFile{Name: name, ContentType: contentType, Size: size, StorageKey: key}
+		if err == nil && size > MaxSubmissionFileSize {
+			err = fmt.Errorf("file %q is larger than %d MB", name, MaxSubmissionFileSize>>20)
+		}
+		if err != nil {
+			uc.deleteFiles(append(files, file))
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// deleteFiles removes stored files, a failure only leaves an orphaned file behind
+func (uc *CourseUseCase) deleteFiles(files []entities.SubmissionFile) {
+	for _, file := range files {
+		uc.Storage.Delete(file.StorageKey)
+	}
+}
@@ -0,0 +1,173 @@
+package usecases
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+// audited entity types
+const (
+	EntityUser         = "user"
+	EntityCourse       = "course"
+	EntityEnrollment   = "enrollment"
+	EntityLesson       = "lesson"
+	EntityProgress     = "progress"
+	EntityReview       = "review"
+	EntitySection      = "section"
+	EntityCurriculum   = "curriculum"    // entity ID is the course ID
+	EntityPrerequisite = "prerequisites" // entity ID is the course ID
+	EntityLearningPath = "learning_path"
+	EntityQuestionBank = "question_bank"
+	EntityQuestion     = "question"
+	EntityQuiz         = "quiz"
+	EntityQuizAttempt  = "quiz_attempt"
+	EntityAssignment   = "assignment"
+	EntitySubmission   = "submission"
+	EntityGradeWeights = "grade_weights" // entity ID is the course ID
+	EntityCertificate  = "certificate"
+	EntityOrder        = "order"
+	EntityCoupon       = "coupon"
+	EntityCoursePrice  = "course_price" // entity ID is the course ID
+	EntityRefund       = "refund"
+	EntityInvoice      = "invoice"
+	EntityBilling      = "billing_details" // entity ID is the user ID
+	EntityPlan         = "subscription_plan"
+	EntitySubscription = "subscription"
+	EntityOrganization = "organization"
+	EntityOrgMember    = "organization_member" // entity ID is the organization ID
+	EntityInvitation   = "organization_invitation"
+	EntitySeatPurchase = "seat_purchase"
+	EntityPreferences  = "notification_preferences" // entity ID is the user ID
+)
+
+// fields never written to the audit log
+var redactedFields = map[string]bool{"password": true}
+
+// RequestInfo identifies who made a request, it is attached to audit log entries
+type RequestInfo struct {
+	ActorID   uint
+	RequestID string
+}
+
+// WithRequest returns a copy of the use case that attributes mutations to the given request
+func (uc *CourseUseCase) WithRequest(info RequestInfo) *CourseUseCase {
+	scoped := *uc
+	scoped.request = info
+	return &scoped
+}
+
+// ListAuditLogs returns audit log entries matching the filter, newest first
+func (uc *CourseUseCase) ListAuditLogs(filter entities.AuditFilter) ([]entities.AuditLog, error) {
+	return uc.Repo.GetAuditLogs(filter)
+}
+
+// atomically runs fn with a copy of the use case whose repository queries all belong to one
+// transaction, so that a mutation is written together with the audit log entries it records and
+// the emails it queues. The transaction is rolled back when fn fails, which it does when one of
+// its audit log entries or emails cannot be written.
+//
+// Certificates and invoices are follow-ups instead: issuing them renders documents and stores or
+// sends them outside the database, so a failure must not undo the mutation that led to them. It
+// is logged and they can be issued again later.
+func atomically[T any](uc *CourseUseCase, fn func(uc *CourseUseCase) (T, error)) (T, error) {
+	var result T
+	err := uc.Repo.Atomic(func(repo CourseRepository) error {
+		scoped := *uc
+		scoped.Repo = repo
+
+		value, err := fn(&scoped)
+		result = value // some errors come with a value, like the existing entity of a conflict
+		return err
+	})
+	return result, err
+}
+
+// atomic is atomically for mutations that return nothing but an error
+func (uc *CourseUseCase) atomic(fn func(uc *CourseUseCase) error) error {
+	_, err := atomically(uc, func(uc *CourseUseCase) (struct{}, error) {
+		return struct{}{}, fn(uc)
+	})
+	return err
+}
+
+// record appends an audit log entry for a mutation. before is nil for creates and after is nil
+// for deletes. The mutation has to fail with it, so it is called inside atomically.
+func (uc *CourseUseCase) record(action, entityType string, entityID uint, before, after interface{}) error {
+	beforeFields, err := auditFields(before)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit log for %s %d: %w", entityType, entityID, err)
+	}
+	afterFields, err := auditFields(after)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit log for %s %d: %w", entityType, entityID, err)
+	}
+
+	entry := entities.AuditLog{
+		ActorID:    uc.request.ActorID,
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Before:     encodeFields(beforeFields),
+		After:      encodeFields(afterFields),
+		RequestID:  uc.request.RequestID,
+		CreatedAt:  time.Now(),
+	}
+	if beforeFields != nil && afterFields != nil {
+		entry.Changes = encodeFields(diffFields(beforeFields, afterFields))
+	}
+
+	if _, err := uc.Repo.AddAuditLog(entry); err != nil {
+		return fmt.Errorf("failed to write audit log for %s %d: %w", entityType, entityID, err)
+	}
+	return nil
+}
+
+// auditFields converts an entity to its JSON fields without the redacted ones
+func auditFields(v interface{}) (map[string]interface{}, error) {
+	if v == nil || reflect.ValueOf(v).IsZero() {
+		return nil, nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	for name := range redactedFields {
+		delete(fields, name)
+	}
+	return fields, nil
+}
+
+// diffFields returns the fields whose value changed as field -> {before, after}
+func diffFields(before, after map[string]interface{}) map[string]interface{} {
+	changes := map[string]interface{}{}
+	for name, value := range after {
+		if !reflect.DeepEqual(before[name], value) {
+			changes[name] = map[string]interface{}{"before": before[name], "after": value}
+		}
+	}
+	for name, value := range before {
+		if _, ok := after[name]; !ok {
+			changes[name] = map[string]interface{}{"before": value, "after": nil}
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	return changes
+}
+
+func encodeFields(fields map[string]interface{}) json.RawMessage {
+	if fields == nil {
+		return nil
+	}
+	raw, _ := json.Marshal(fields)
+	return raw
+}
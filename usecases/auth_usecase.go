@@ -0,0 +1,188 @@
+package usecases
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// SessionLifetime is how long a session stays signed in
+const SessionLifetime = 7 * 24 * time.Hour
+
+// MinPasswordLength is the length new passwords must have at least
+const MinPasswordLength = 8
+
+// roles users can have
+var validRoles = map[string]bool{"student": true, "instructor": true, "admin": true}
+
+// Login signs a user in with their email and password and returns a new session with its token,
+// which authenticates their requests until it expires or they log out
+func (uc *CourseUseCase) Login(credentials entities.Credentials) (entities.Session, error) {
+	user, err := uc.Repo.GetUserCredentials(strings.TrimSpace(credentials.Email))
+	if err != nil || !uc.checkPassword(user, credentials.Password) {
+		return entities.Session{}, fmt.Errorf("%w: wrong email or password", ErrUnauthorized)
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return entities.Session{}, err
+	}
+	now := time.Now().UTC()
+	session := entities.Session{UserID: user.ID, CreatedAt: now, ExpiresAt: now.Add(SessionLifetime)}
+	if err := uc.Repo.DeleteExpiredSessions(now); err != nil {
+		return entities.Session{}, err
+	}
+	token := hex.EncodeToString(buf)
+	if err := uc.Repo.AddSession(tokenHash(token), session); err != nil {
+		return entities.Session{}, err
+	}
+	session.Token = token
+	return session, nil
+}
+
+// Authenticate returns the user signed in with a session token
+func (uc *CourseUseCase) Authenticate(token string) (entities.User, error) {
+	if token == "" {
+		return entities.User{}, fmt.Errorf("%w: no session token", ErrUnauthorized)
+	}
+	session, err := uc.Repo.GetSession(tokenHash(token))
+	if err != nil || !time.Now().Before(session.ExpiresAt) {
+		return entities.User{}, fmt.Errorf("%w: invalid or expired session token", ErrUnauthorized)
+	}
+	user, err := uc.Repo.GetUserByID(int(session.UserID), false)
+	if err != nil {
+		return entities.User{}, fmt.Errorf("%w: invalid or expired session token", ErrUnauthorized)
+	}
+	return user, nil
+}
+
+// Logout ends the session of a token
+func (uc *CourseUseCase) Logout(token string) error {
+	return uc.Repo.DeleteSession(tokenHash(token))
+}
+
+// checkPassword reports whether password is the user's. Accounts created before passwords were
+// hashed still hold the plain password, it is hashed the first time they sign in.
+func (uc *CourseUseCase) checkPassword(user entities.User, password string) bool {
+	if _, err := bcrypt.Cost([]byte(user.Password)); err == nil {
+		return bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) == nil
+	}
+	if user.Password == "" || subtle.ConstantTimeCompare([]byte(user.Password), []byte(password)) != 1 {
+		return false
+	}
+	if hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost); err == nil {
+		uc.Repo.SetUserPassword(int(user.ID), string(hash))
+	}
+	return true
+}
+
+// hashPassword checks a new password and returns its hash
+func hashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", fmt.Errorf("password must be at least %d characters long", MinPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return "", fmt.Errorf("password must be at most 72 bytes long")
+	}
+	return string(hash), err
+}
+
+// tokenHash is what a session token is stored as, so that the sessions table cannot be used to sign in
+func tokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func validateRole(role string) error {
+	if !validRoles[role] {
+		return fmt.Errorf("role must be student, instructor or admin")
+	}
+	return nil
+}
+
+// checkRole rejects signing up with a role the actor cannot give: instructors and admins are
+// created by admins, or by anyone while the school has no admin yet
+func (uc *CourseUseCase) checkRole(role string) error {
+	if err := validateRole(role); err != nil {
+		return err
+	}
+	if role == "student" || uc.actorIsAdmin() {
+		return nil
+	}
+	users, err := uc.Repo.GetAllUsers(false)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		if user.Role == "admin" {
+			return fmt.Errorf("%w: only admins can create %s accounts", ErrForbidden, role)
+		}
+	}
+	return nil
+}
+
+// prepareSignUp checks the role of a new account, students by default, and replaces the
+// password with its hash
+func (uc *CourseUseCase) prepareSignUp(user *entities.User) error {
+	if user.Role == "" {
+		user.Role = "student"
+	}
+	if err := uc.checkRole(user.Role); err != nil {
+		return err
+	}
+	hash, err := hashPassword(user.Password)
+	if err != nil {
+		return err
+	}
+	user.Password = hash
+	return nil
+}
+
+// prepareAccountChange lets users change their own account and admins anyone's, and only admins
+// change roles. A new password is replaced with its hash, an empty one keeps the old password.
+func (uc *CourseUseCase) prepareAccountChange(user *entities.User, before entities.User) error {
+	if err := uc.checkAccountOwner(before.ID, "change"); err != nil {
+		return err
+	}
+	if user.Role == "" {
+		user.Role = before.Role
+	}
+	if user.Role != before.Role {
+		if !uc.actorIsAdmin() {
+			return fmt.Errorf("%w: only admins can change roles", ErrForbidden)
+		}
+		if err := validateRole(user.Role); err != nil {
+			return err
+		}
+	}
+	if user.Password != "" {
+		hash, err := hashPassword(user.Password)
+		if err != nil {
+			return err
+		}
+		user.Password = hash
+	}
+	return nil
+}
+
+// checkAccountOwner allows the user of an account and admins
+func (uc *CourseUseCase) checkAccountOwner(userID uint, action string) error {
+	if uc.request.ActorID != userID && !uc.actorIsAdmin() {
+		return fmt.Errorf("%w: users can only %s their own account", ErrForbidden, action)
+	}
+	return nil
+}
+
+// endSessions signs a user out everywhere
+func (uc *CourseUseCase) endSessions(userID int) error {
+	return uc.Repo.DeleteUserSessions(userID, "")
+}
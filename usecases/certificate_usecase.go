@@ -0,0 +1,232 @@
+package usecases
+
+import (
+	"bytes"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+// CertificateRenderer draws the PDF of a certificate
+type CertificateRenderer interface {
+	Render(certificate entities.Certificate) ([]byte, error)
+}
+
+//----------------------------------------------------------------certificates----------------------------------------------------------------
+
+// IssueCertificate issues the certificate of a completed enrollment, or returns the one already issued.
+// Certificates are issued on completion, this covers enrollments completed before that or when it failed.
+func (uc *CourseUseCase) IssueCertificate(enrollmentID int, actor entities.User) (entities.Certificate, error) {
+	enrollment, err := uc.Repo.GetEnrollmentByID(enrollmentID)
+	if err != nil {
+		return entities.Certificate{}, notFound(err)
+	}
+	if err := uc.requireCourseInstructor(int(enrollment.CourseID), actor); err != nil {
+		return entities.Certificate{}, err
+	}
+	if !enrollment.Completed {
+		return entities.Certificate{}, fmt.Errorf("enrollment %d is not completed", enrollment.ID)
+	}
+	return uc.issueCertificate(enrollment)
+}
+
+// GetCertificate returns a certificate to its student, the course instructor or an admin
+func (uc *CourseUseCase) GetCertificate(id int, actor entities.User) (entities.Certificate, error) {
+	certificate, err := uc.Repo.GetCertificateByID(id)
+	if err != nil {
+		return entities.Certificate{}, notFound(err)
+	}
+	if err := uc.canViewCertificate(certificate, actor); err != nil {
+		return entities.Certificate{}, err
+	}
+	return certificate, nil
+}
+
+// GetEnrollmentCertificate returns the certificate issued for an enrollment
+func (uc *CourseUseCase) GetEnrollmentCertificate(enrollmentID int, actor entities.User) (entities.Certificate, error) {
+	certificate, err := uc.Repo.GetCertificateByEnrollmentID(enrollmentID)
+	if err != nil {
+		return entities.Certificate{}, notFound(err)
+	}
+	if err := uc.canViewCertificate(certificate, actor); err != nil {
+		return entities.Certificate{}, err
+	}
+	return certificate, nil
+}
+
+// OpenCertificatePDF returns the PDF of a certificate, the caller closes it. Revoked certificates cannot be downloaded.
+func (uc *CourseUseCase) OpenCertificatePDF(id int, actor entities.User) (entities.Certificate, io.ReadCloser, error) {
+	certificate, err := uc.GetCertificate(id, actor)
+	if err != nil {
+		return entities.Certificate{}, nil, err
+	}
+	if certificate.RevokedAt != nil {
+		return entities.Certificate{}, nil, fmt.Errorf("%w: the certificate was revoked", ErrForbidden)
+	}
+	if uc.Storage == nil {
+		return entities.Certificate{}, nil, fmt.Errorf("file storage is not configured")
+	}
+	content, err := uc.Storage.Open(certificate.StorageKey)
+	if err != nil {
+		return entities.Certificate{}, nil, err
+	}
+	return certificate, content, nil
+}
+
+// VerifyCertificate looks up a certificate by its code for anyone holding it
+func (uc *CourseUseCase) VerifyCertificate(code string) (entities.CertificateVerification, error) {
+	certificate, err := uc.Repo.GetCertificateByCode(normalizeCertificateCode(code))
+	if err != nil {
+		return entities.CertificateVerification{}, notFound(err)
+	}
+	return entities.CertificateVerification{
+		Valid:         certificate.RevokedAt == nil,
+		Code:          certificate.Code,
+		StudentName:   certificate.StudentName,
+		CourseTitle:   certificate.CourseTitle,
+		Instructor:    certificate.Instructor,
+		CompletedAt:   certificate.CompletedAt,
+		IssuedAt:      certificate.IssuedAt,
+		RevokedAt:     certificate.RevokedAt,
+		RevokedReason: certificate.RevokedReason,
+	}, nil
+}
+
+// RevokeCertificate invalidates a certificate, admins only
+func (uc *CourseUseCase) RevokeCertificate(id int, reason string, actor entities.User) (entities.Certificate, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.Certificate, error) {
+		if actor.Role != "admin" {
+			return entities.Certificate{}, fmt.Errorf("%w: only admins can revoke certificates", ErrForbidden)
+		}
+		reason = strings.TrimSpace(reason)
+		if reason == "" {
+			return entities.Certificate{}, fmt.Errorf("a reason is required to revoke a certificate")
+		}
+		before, err := uc.Repo.GetCertificateByID(id)
+		if err != nil {
+			return entities.Certificate{}, notFound(err)
+		}
+		if before.RevokedAt != nil {
+			return before, &ConflictError{Resource: "revocation", Existing: before}
+		}
+		if err := uc.Repo.RevokeCertificate(id, time.Now().UTC(), reason); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				// revoked concurrently
+				current, _ := uc.Repo.GetCertificateByID(id)
+				return current, &ConflictError{Resource: "revocation", Existing: current}
+			}
+			return entities.Certificate{}, err
+		}
+		after, err := uc.Repo.GetCertificateByID(id)
+		if err != nil {
+			return entities.Certificate{}, err
+		}
+		if err := uc.record(entities.AuditUpdate, EntityCertificate, after.ID, before, after); err != nil {
+			return entities.Certificate{}, err
+		}
+		return after, nil
+	})
+}
+
+// issueCertificate renders, stores and records the certificate of a completed enrollment,
+// it returns the existing certificate when there is one
+func (uc *CourseUseCase) issueCertificate(enrollment entities.Enrollment) (entities.Certificate, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.Certificate, error) {
+		if existing, err := uc.Repo.GetCertificateByEnrollmentID(int(enrollment.ID)); err == nil {
+			return existing, nil
+		}
+		if uc.Certificates == nil || uc.Storage == nil {
+			return entities.Certificate{}, fmt.Errorf("certificates are not configured")
+		}
+		course, err := uc.Repo.GetCourseByID(int(enrollment.CourseID), true)
+		if err != nil {
+			return entities.Certificate{}, notFound(err)
+		}
+		student, err := uc.Repo.GetUserByID(int(enrollment.UserID), true)
+		if err != nil {
+			return entities.Certificate{}, notFound(err)
+		}
+
+		now := time.Now().UTC()
+		certificate := entities.Certificate{
+			EnrollmentID: enrollment.ID,
+			UserID:       enrollment.UserID,
+			CourseID:     enrollment.CourseID,
+			Code:         newCertificateCode(),
+			StudentName:  strings.TrimSpace(student.FirstName + " " + student.LastName),
+			CourseTitle:  course.Title,
+			Instructor:   course.Instructor,
+			CompletedAt:  now,
+			IssuedAt:     now,
+		}
+		if enrollment.CompletedAt != nil {
+			certificate.CompletedAt = enrollment.CompletedAt.UTC()
+		}
+		certificate.StorageKey = "certificates/" + certificate.Code + ".pdf"
+
+		pdf, err := uc.Certificates.Render(certificate)
+		if err != nil {
+			return entities.Certificate{}, err
+		}
+		if _, err := uc.Storage.Save(certificate.StorageKey, bytes.NewReader(pdf)); err != nil {
+			return entities.Certificate{}, err
+		}
+		created, err := uc.Repo.AddCertificate(certificate)
+		if err != nil {
+			uc.Storage.Delete(certificate.StorageKey)
+			// lost a race against a concurrent issue, the unique index rejected ours
+			if existing, getErr := uc.Repo.GetCertificateByEnrollmentID(int(enrollment.ID)); getErr == nil {
+				return existing, nil
+			}
+			return entities.Certificate{}, err
+		}
+		if err := uc.record(entities.AuditCreate, EntityCertificate, created.ID, nil, created); err != nil {
+			return entities.Certificate{}, err
+		}
+		return created, nil
+	})
+}
+
+// issueCompletionCertificate issues the certificate of a newly completed enrollment, a follow-up
+// of the completion (see atomically)
+func (uc *CourseUseCase) issueCompletionCertificate(enrollment entities.Enrollment) {
+	if uc.Certificates == nil {
+		return
+	}
+	if _, err := uc.issueCertificate(enrollment); err != nil {
+		log.Printf("Failed to issue the certificate of enrollment %d: %v", enrollment.ID, err)
+	}
+}
+
+// canViewCertificate allows the certified student, the course instructor and admins
+func (uc *CourseUseCase) canViewCertificate(certificate entities.Certificate, actor entities.User) error {
+	if certificate.UserID == actor.ID {
+		return nil
+	}
+	return uc.requireCourseInstructor(int(certificate.CourseID), actor)
+}
+
+// newCertificateCode returns a random code like 3F9A-07C2-B1D4-E85A
+func newCertificateCode() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	code := strings.ToUpper(hex.EncodeToString(buf))
+	return code[0:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16]
+}
+
+// normalizeCertificateCode accepts codes in any case, with or without dashes and spaces
+func normalizeCertificateCode(code string) string {
+	code = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	if len(code) != 16 {
+		return code
+	}
+	return code[0:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16]
+}
@@ -0,0 +1,340 @@
+package usecases
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+// coupon codes are upper case letters, digits, dashes and underscores
+var couponCodePattern = regexp.MustCompile(`^[A-Z0-9_-]{3,32}$`)
+
+//----------------------------------------------------------------coupons----------------------------------------------------------------
+
+// CreateCoupon adds a coupon, new coupons are active
+func (uc *CourseUseCase) CreateCoupon(coupon entities.Coupon) (entities.Coupon, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.Coupon, error) {
+		coupon.Code = normalizeCouponCode(coupon.Code)
+		if err := uc.validateCoupon(coupon); err != nil {
+			return entities.Coupon{}, err
+		}
+		if existing, err := uc.Repo.GetCouponByCode(coupon.Code); err == nil {
+			return existing, &ConflictError{Resource: "coupon", Existing: existing}
+		}
+		coupon.Active = true
+		coupon.CreatedAt = time.Now().UTC()
+
+		created, err := uc.Repo.AddCoupon(coupon)
+		if err != nil {
+			return entities.Coupon{}, err
+		}
+		if err := uc.record(entities.AuditCreate, EntityCoupon, created.ID, nil, created); err != nil {
+			return entities.Coupon{}, err
+		}
+		return created, nil
+	})
+}
+
+func (uc *CourseUseCase) GetAllCoupons() ([]entities.Coupon, error) {
+	return uc.Repo.GetAllCoupons()
+}
+
+func (uc *CourseUseCase) GetCoupon(id int) (entities.Coupon, error) {
+	coupon, err := uc.Repo.GetCouponByID(id)
+	if err != nil {
+		return entities.Coupon{}, notFound(err)
+	}
+	return coupon, nil
+}
+
+// UpdateCoupon changes a coupon, orders already placed keep the discount they got
+func (uc *CourseUseCase) UpdateCoupon(coupon entities.Coupon) (entities.Coupon, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.Coupon, error) {
+		before, err := uc.Repo.GetCouponByID(int(coupon.ID))
+		if err != nil {
+			return entities.Coupon{}, notFound(err)
+		}
+		coupon.Code = normalizeCouponCode(coupon.Code)
+		if err := uc.validateCoupon(coupon); err != nil {
+			return entities.Coupon{}, err
+		}
+		if existing, err := uc.Repo.GetCouponByCode(coupon.Code); err == nil && existing.ID != coupon.ID {
+			return existing, &ConflictError{Resource: "coupon", Existing: existing}
+		}
+		coupon.CreatedAt = before.CreatedAt
+
+		if err := uc.Repo.UpdateCoupon(coupon); err != nil {
+			return entities.Coupon{}, notFound(err)
+		}
+		if err := uc.record(entities.AuditUpdate, EntityCoupon, coupon.ID, before, coupon); err != nil {
+			return entities.Coupon{}, err
+		}
+		return coupon, nil
+	})
+}
+
+// DeleteCoupon removes a coupon that was never redeemed, redeemed ones are deactivated instead
+// so their redemptions stay reportable
+func (uc *CourseUseCase) DeleteCoupon(id int) error {
+	return uc.atomic(func(uc *CourseUseCase) error {
+		before, err := uc.Repo.GetCouponByID(id)
+		if err != nil {
+			return notFound(err)
+		}
+		redemptions, err := uc.Repo.GetCouponRedemptions(id)
+		if err != nil {
+			return err
+		}
+		if len(redemptions) > 0 {
+			return fmt.Errorf("coupon %s has been redeemed, deactivate it instead", before.Code)
+		}
+		if err := uc.Repo.DeleteCoupon(id); err != nil {
+			return notFound(err)
+		}
+		return uc.record(entities.AuditDelete, EntityCoupon, before.ID, before, nil)
+	})
+}
+
+// GetCouponReport sums up the redemptions of a coupon
+func (uc *CourseUseCase) GetCouponReport(id int) (entities.CouponReport, error) {
+	coupon, err := uc.Repo.GetCouponByID(id)
+	if err != nil {
+		return entities.CouponReport{}, notFound(err)
+	}
+	history, err := uc.Repo.GetCouponRedemptions(id)
+	if err != nil {
+		return entities.CouponReport{}, err
+	}
+
+	report := entities.CouponReport{Coupon: coupon, TotalDiscount: []entities.Money{}, Revenue: []entities.Money{}, History: history}
+	users := map[uint]bool{}
+	orders := map[uint]bool{}
+	for _, redemption := range history {
+		switch redemption.OrderStatus {
+		case entities.OrderPaid:
+			report.Redemptions++
+			report.TotalDiscount = addByCurrency(report.TotalDiscount, redemption.Discount)
+			users[redemption.UserID] = true
+			orders[redemption.OrderID] = true
+		case entities.OrderPending, entities.OrderRequiresAction:
+			report.Pending++
+		}
+	}
+	for orderID := range orders {
+		order, err := uc.Repo.GetOrderByID(int(orderID))
+		if err != nil {
+			return entities.CouponReport{}, err
+		}
+		report.Revenue = addByCurrency(report.Revenue, order.Total)
+	}
+	report.UniqueUsers = len(users)
+	return report, nil
+}
+
+// addByCurrency adds an amount to the total of its currency
+func addByCurrency(totals []entities.Money, amount entities.Money) []entities.Money {
+	for i, total := range totals {
+		if total.Currency == amount.Currency {
+			totals[i] = total.Add(amount)
+			return totals
+		}
+	}
+	return append(totals, amount)
+}
+
+func (uc *CourseUseCase) validateCoupon(coupon entities.Coupon) error {
+	if !couponCodePattern.MatchString(coupon.Code) {
+		return fmt.Errorf("code must be 3 to 32 letters, digits, dashes or underscores")
+	}
+	switch coupon.Kind {
+	case entities.CouponPercent:
+		if coupon.Percent <= 0 || coupon.Percent > 100 {
+			return fmt.Errorf("a percent coupon takes between 0 and 100 percent off")
+		}
+		if coupon.AmountOff != nil {
+			return fmt.Errorf("a percent coupon has no amount_off")
+		}
+	case entities.CouponFixed:
+		if coupon.AmountOff == nil {
+			return fmt.Errorf("a fixed coupon needs an amount_off")
+		}
+		if !entities.ValidCurrency(coupon.AmountOff.Currency) {
+			return fmt.Errorf("unsupported currency %q", coupon.AmountOff.Currency)
+		}
+		if !coupon.AmountOff.IsPositive() {
+			return fmt.Errorf("amount_off must be greater than zero")
+		}
+		if coupon.Percent != 0 {
+			return fmt.Errorf("a fixed coupon has no percent")
+		}
+	default:
+		return fmt.Errorf("kind must be %q or %q", entities.CouponPercent, entities.CouponFixed)
+	}
+	if coupon.CourseID != nil {
+		if _, err := uc.Repo.GetCourseByID(int(*coupon.CourseID), false); err != nil {
+			return fmt.Errorf("course %d does not exist", *coupon.CourseID)
+		}
+	}
+	if coupon.MaxRedemptions < 0 || coupon.MaxPerUser < 0 {
+		return fmt.Errorf("redemption limits cannot be negative")
+	}
+	if coupon.StartsAt != nil && coupon.EndsAt != nil && !coupon.EndsAt.After(*coupon.StartsAt) {
+		return fmt.Errorf("ends_at must be after starts_at")
+	}
+	return nil
+}
+
+func normalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+//----------------------------------------------------------------cart coupons----------------------------------------------------------------
+
+// ApplyCoupon applies a coupon code to the actor's cart
+func (uc *CourseUseCase) ApplyCoupon(code, currency string, actor entities.User) (entities.Cart, error) {
+	coupon, err := uc.Repo.GetCouponByCode(normalizeCouponCode(code))
+	if err != nil {
+		return entities.Cart{}, fmt.Errorf("%w: coupon %s", ErrNotFound, normalizeCouponCode(code))
+	}
+	items, err := uc.Repo.GetCartItems(int(actor.ID))
+	if err != nil {
+		return entities.Cart{}, err
+	}
+	applied, err := uc.Repo.GetCartCoupons(int(actor.ID))
+	if err != nil {
+		return entities.Cart{}, err
+	}
+	others := []entities.Coupon{}
+	for _, other := range applied {
+		if other.ID == coupon.ID {
+			return uc.GetCart(currency, actor)
+		}
+		others = append(others, other)
+	}
+	if err := uc.checkCoupon(coupon, actor.ID, items, others); err != nil {
+		return entities.Cart{}, err
+	}
+	if err := uc.Repo.AddCartCoupon(int(actor.ID), int(coupon.ID), time.Now().UTC()); err != nil {
+		return entities.Cart{}, err
+	}
+	return uc.GetCart(currency, actor)
+}
+
+// RemoveCoupon takes a coupon code off the actor's cart
+func (uc *CourseUseCase) RemoveCoupon(code, currency string, actor entities.User) (entities.Cart, error) {
+	coupon, err := uc.Repo.GetCouponByCode(normalizeCouponCode(code))
+	if err != nil {
+		return entities.Cart{}, fmt.Errorf("%w: coupon %s", ErrNotFound, normalizeCouponCode(code))
+	}
+	if err := uc.Repo.RemoveCartCoupon(int(actor.ID), int(coupon.ID)); err != nil {
+		return entities.Cart{}, notFound(err)
+	}
+	return uc.GetCart(currency, actor)
+}
+
+// checkCoupon rejects a coupon the user cannot use on a cart with the given items, next to the
+// coupons already applied. A coupon that does not stack is only used on its own.
+func (uc *CourseUseCase) checkCoupon(coupon entities.Coupon, userID uint, items []entities.CartItem, others []entities.Coupon) error {
+	now := time.Now()
+	switch {
+	case !coupon.Active:
+		return fmt.Errorf("coupon %s is no longer available", coupon.Code)
+	case coupon.StartsAt != nil && now.Before(*coupon.StartsAt):
+		return fmt.Errorf("coupon %s is not valid before %s", coupon.Code, coupon.StartsAt.Format(time.RFC3339))
+	case coupon.EndsAt != nil && !now.Before(*coupon.EndsAt):
+		return fmt.Errorf("coupon %s expired on %s", coupon.Code, coupon.EndsAt.Format(time.RFC3339))
+	}
+	if coupon.CourseID != nil {
+		found := false
+		for _, item := range items {
+			found = found || item.CourseID == *coupon.CourseID
+		}
+		if !found {
+			return fmt.Errorf("coupon %s does not apply to any course in the cart", coupon.Code)
+		}
+	}
+
+	total, byUser, err := uc.Repo.CountCouponRedemptions(int(coupon.ID), int(userID))
+	if err != nil {
+		return err
+	}
+	if coupon.MaxRedemptions > 0 && total >= coupon.MaxRedemptions {
+		return fmt.Errorf("coupon %s has been fully redeemed", coupon.Code)
+	}
+	if coupon.MaxPerUser > 0 && byUser >= coupon.MaxPerUser {
+		return fmt.Errorf("coupon %s has already been used the maximum number of times", coupon.Code)
+	}
+
+	for _, other := range others {
+		if !coupon.Stackable || !other.Stackable {
+			return fmt.Errorf("coupon %s cannot be combined with %s", coupon.Code, other.Code)
+		}
+	}
+	return nil
+}
+
+// cartCoupons returns the coupons of the user's cart that still apply to its items,
+// and a warning for every one that no longer does
+func (uc *CourseUseCase) cartCoupons(userID uint, items []entities.CartItem) (usable []entities.Coupon, warnings []string, err error) {
+	coupons, err := uc.Repo.GetCartCoupons(int(userID))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, coupon := range coupons {
+		if err := uc.checkCoupon(coupon, userID, items, usable); err != nil {
+			warnings = append(warnings, err.Error())
+			continue
+		}
+		usable = append(usable, coupon)
+	}
+	return usable, warnings, nil
+}
+
+// applyCoupons works out the discount of every item priced in the currency. Coupons apply in the
+// order given, each one discounting what the earlier ones left. A fixed amount is converted to the
+// currency and split between the courses it applies to in proportion to their price, the last one
+// taking the remainder so the split adds up to the minor unit. No item is discounted below zero.
+func (uc *CourseUseCase) applyCoupons(items []entities.CartItem, coupons []entities.Coupon, currency string) ([]entities.CartItem, []entities.AppliedCoupon, error) {
+	applied := make([]entities.AppliedCoupon, 0, len(coupons))
+	for _, coupon := range coupons {
+		eligible := []int{}
+		base := entities.NewMoney(0, currency)
+		for i, item := range items {
+			if coupon.CourseID == nil || *coupon.CourseID == item.CourseID {
+				eligible = append(eligible, i)
+				base = base.Add(item.Price.Sub(item.Discount))
+			}
+		}
+
+		result := entities.AppliedCoupon{CouponID: coupon.ID, Code: coupon.Code, Discount: entities.NewMoney(0, currency)}
+		var amount int64
+		if coupon.Kind == entities.CouponFixed && coupon.AmountOff != nil {
+			off, err := uc.convert(*coupon.AmountOff, currency)
+			if err != nil {
+				return nil, nil, fmt.Errorf("coupon %s: %w", coupon.Code, err)
+			}
+			amount = off.Min(base).Amount
+		}
+		for k, i := range eligible {
+			remaining := items[i].Price.Sub(items[i].Discount).Amount
+			var discount int64
+			switch {
+			case coupon.Kind == entities.CouponPercent:
+				discount = int64(math.Round(float64(remaining) * coupon.Percent / 100))
+			case k == len(eligible)-1:
+				discount = amount - result.Discount.Amount
+			case base.IsPositive():
+				discount = amount * remaining / base.Amount
+			}
+			discount = max(0, min(discount, remaining))
+			items[i].Discount.Amount += discount
+			result.Discount.Amount += discount
+		}
+		applied = append(applied, result)
+	}
+	return items, applied, nil
+}
@@ -1,7 +1,12 @@
 package usecases
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+	"math"
+	"strings"
+	"time"
 
 	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
 )
@@ -10,171 +15,1223 @@ type CourseRepository interface {
 
 	// User
 	CreateUser(user entities.User) (entities.User, error) 
-	GetUserByID(id int) (entities.User, error)
-	GetAllUsers() ([]entities.User, error)
+	GetUserByID(id int, includeDeleted bool) (entities.User, error)
+	GetAllUsers(includeDeleted bool) ([]entities.User, error)
 	UpdateUser(user entities.User) (entities.User, error)
 	DeleteUser(id int) error
+	RestoreUser(id int) error
 
 	// Course
 	AddCourse(course entities.Course) (entities.Course, error) 
-	GetAllCourses() ([]entities.Course, error)
-	GetCourseByID(id int) (entities.Course, error)
+	GetAllCourses(filter entities.CourseFilter) ([]entities.Course, error)
+	GetCourseByID(id int, includeDeleted bool) (entities.Course, error)
 	UpdateCourse(course entities.Course) (entities.Course, error)
 	DeleteCourse(id int) error
+	RestoreCourse(id int) error
+	SetCourseStatus(id int, status string, publishAt *time.Time, note string) error
 
 	// Enroll
 	AddEnrollment(enrollment entities.Enrollment) (entities.Enrollment, error)
 	GetAllEnrollments() ([]entities.Enrollment, error)
 	GetEnrollmentByID(id int) (entities.Enrollment, error)
+	GetEnrollmentByUserAndCourse(userID, courseID int) (entities.Enrollment, error)
 	GetEnrollmentsByUserID(userID int) ([]entities.Enrollment, error)
+	GetEnrollmentsByCourseID(courseID int) ([]entities.Enrollment, error)
 	UpdateEnrollment(enrollment entities.Enrollment) (entities.Enrollment, error)
 	DeleteEnrollment(id int) error
 
 	// Lesson
 	AddLesson(lesson entities.Lesson) (entities.Lesson, error)
-	GetLessonsByCourseID(courseID int) ([]entities.Lesson, error)
-	GetLessonsByID(lessonID int) ([]entities.Lesson, error)
+	GetLessonsByCourseID(courseID int, includeDeleted bool) ([]entities.Lesson, error)
+	GetLessonsByID(lessonID int, includeDeleted bool) ([]entities.Lesson, error)
 	UpdateLesson(lesson entities.Lesson) (entities.Lesson, error)
 	DeleteLesson(id int) error
+	RestoreLesson(id int) error
+
+	// Curriculum
+	AddSection(section entities.Section) (entities.Section, error)
+	GetSectionByID(id int) (entities.Section, error)
+	GetSectionsByCourseID(courseID int) ([]entities.Section, error)
+	UpdateSection(section entities.Section) (entities.Section, error)
+	DeleteSection(id int) error
+	RenumberCurriculum(courseID int) error
+	ReorderCurriculum(courseID int, order entities.CurriculumOrder) error
 
 	// Progress
 	AddProgress(progress entities.Progress) (entities.Progress, error)
-	UpdateProgress(progress entities.Progress) (entities.Progress, error)
+	UpsertProgress(progress entities.Progress) (entities.Progress, error)
 	GetProgressByEnrollmentAndLesson(enrollmentID, lessonID int) (entities.Progress, error)
+	GetProgressByEnrollment(enrollmentID int) ([]entities.Progress, error)
 
 	// Review
 	AddReview(review entities.Review) (entities.Review, error)
 	GetReviewsByCourseID(courseID int) ([]entities.Review, error)
+	GetReviewByID(id int) (entities.Review, error)
+	GetReviewByUserAndCourse(userID, courseID int) (entities.Review, error)
+	UpdateReview(review entities.Review) (entities.Review, error)
 	DeleteReview(id int) error
+
+	// Moderation
+	GetReviewsByStatus(statuses []string) ([]entities.Review, error)
+	SetReviewReply(reviewID int, reply entities.ReviewReply) error
+	AddReviewFlag(flag entities.ReviewFlag) (entities.ReviewFlag, error)
+	GetReviewFlag(reviewID, userID int) (entities.ReviewFlag, error)
+	GetReviewFlags(reviewID int) ([]entities.ReviewFlag, error)
+
+	// Prerequisites
+	SetPrerequisite(prerequisite entities.Prerequisite) error
+	DeletePrerequisite(courseID, prerequisiteID int) error
+	GetPrerequisites(courseID int) ([]entities.Prerequisite, error)
+	GetPrerequisiteGraph() (map[uint][]uint, error)
+
+	// Learning paths
+	AddLearningPath(path entities.LearningPath) (entities.LearningPath, error)
+	GetLearningPathByID(id int) (entities.LearningPath, error)
+	GetAllLearningPaths() ([]entities.LearningPath, error)
+	GetLearningPathCourses(pathID int) ([]entities.Course, error)
+	UpdateLearningPath(path entities.LearningPath) (entities.LearningPath, error)
+	DeleteLearningPath(id int) error
+
+	// Versions
+	AddCourseVersion(version entities.CourseVersion) (entities.CourseVersion, error)
+	GetCourseVersion(courseID int, version uint) (entities.CourseVersion, error)
+	GetLatestCourseVersion(courseID int) (entities.CourseVersion, error)
+	GetCourseVersions(courseID int) ([]entities.CourseVersion, error)
+
+	// Quizzes
+	AddQuestionBank(bank entities.QuestionBank) (entities.QuestionBank, error)
+	GetQuestionBankByID(id int) (entities.QuestionBank, error)
+	GetQuestionBanksByCourseID(courseID int) ([]entities.QuestionBank, error)
+	AddQuestion(question entities.Question) (entities.Question, error)
+	GetQuestionByID(id int) (entities.Question, error)
+	GetQuestionsByBankID(bankID int) ([]entities.Question, error)
+	GetQuestionsByIDs(ids []uint) ([]entities.Question, error)
+	UpdateQuestion(question entities.Question) (entities.Question, error)
+	DeleteQuestion(id int) error
+	AddQuiz(quiz entities.Quiz) (entities.Quiz, error)
+	GetQuizByID(id int) (entities.Quiz, error)
+	GetQuizByLessonID(lessonID int) (entities.Quiz, error)
+	UpdateQuiz(quiz entities.Quiz) (entities.Quiz, error)
+	DeleteQuiz(id int) error
+	AddQuizAttempt(attempt entities.QuizAttempt) (entities.QuizAttempt, error)
+	GetQuizAttemptByID(id int) (entities.QuizAttempt, error)
+	GetQuizAttempts(quizID, enrollmentID int) ([]entities.QuizAttempt, error)
+	SubmitQuizAttempt(attempt entities.QuizAttempt) error
+	GetQuizzesByCourseID(courseID int) ([]entities.Quiz, error)
+
+	// Assignments
+	AddAssignment(assignment entities.Assignment) (entities.Assignment, error)
+	GetAssignmentByID(id int) (entities.Assignment, error)
+	GetAssignmentsByCourseID(courseID int) ([]entities.Assignment, error)
+	UpdateAssignment(assignment entities.Assignment) (entities.Assignment, error)
+	DeleteAssignment(id int) error
+	AddSubmission(submission entities.Submission) (entities.Submission, error)
+	GetSubmissionByID(id int) (entities.Submission, error)
+	GetSubmissions(assignmentID, enrollmentID int) ([]entities.Submission, error)
+	GetSubmissionsByEnrollment(enrollmentID int) ([]entities.Submission, error)
+	GradeSubmission(submission entities.Submission) error
+	GetSubmissionFile(id int) (entities.SubmissionFile, error)
+
+	// Gradebook
+	GetGradeWeights(courseID int) (entities.GradeWeights, error)
+	SetGradeWeights(weights entities.GradeWeights) error
+	GetCourseStudents(courseID int) ([]entities.User, error)
+	GetCourseProgress(courseID, enrollmentID int) ([]entities.Progress, error)
+	GetCourseQuizAttempts(courseID, enrollmentID int) ([]entities.QuizAttempt, error)
+	GetCourseSubmissions(courseID, enrollmentID int) ([]entities.Submission, error)
+
+	// Certificates
+	AddCertificate(certificate entities.Certificate) (entities.Certificate, error)
+	GetCertificateByID(id int) (entities.Certificate, error)
+	GetCertificateByEnrollmentID(enrollmentID int) (entities.Certificate, error)
+	GetCertificateByCode(code string) (entities.Certificate, error)
+	RevokeCertificate(id int, at time.Time, reason string) error
+
+	// Orders
+	GetCartItems(userID int) ([]entities.CartItem, error)
+	AddCartItem(userID, courseID int, at time.Time) error
+	RemoveCartItem(userID, courseID int) error
+	AddOrder(order entities.Order) (entities.Order, error)
+	GetOrderByID(id int) (entities.Order, error)
+	GetOrdersByUserID(userID int) ([]entities.Order, error)
+	GetOpenOrder(userID int) (entities.Order, error)
+	UpdateOrderPayment(order entities.Order) error
+	FulfilOrder(orderID int, paymentID string, paidAt time.Time, enrollments []entities.Enrollment) ([]entities.Enrollment, error)
+
+	// Coupons
+	AddCoupon(coupon entities.Coupon) (entities.Coupon, error)
+	GetAllCoupons() ([]entities.Coupon, error)
+	GetCouponByID(id int) (entities.Coupon, error)
+	GetCouponByCode(code string) (entities.Coupon, error)
+	UpdateCoupon(coupon entities.Coupon) error
+	DeleteCoupon(id int) error
+	CountCouponRedemptions(couponID, userID int) (total int, byUser int, err error)
+	GetCouponRedemptions(couponID int) ([]entities.CouponRedemption, error)
+	GetCartCoupons(userID int) ([]entities.Coupon, error)
+	AddCartCoupon(userID, couponID int, at time.Time) error
+	RemoveCartCoupon(userID, couponID int) error
+
+	// Prices
+	GetCoursePrices(courseID int) ([]entities.Money, error)
+	GetCoursePrice(courseID int, currency string) (entities.Money, error)
+	SetCoursePrice(courseID int, price entities.Money) error
+	DeleteCoursePrice(courseID int, currency string) error
+
+	// Refunds
+	AddRefund(refund entities.Refund) (entities.Refund, error)
+	GetRefundByID(id int) (entities.Refund, error)
+	GetRefundsByOrderID(orderID int) ([]entities.Refund, error)
+	GetRefunds(status string) ([]entities.Refund, error)
+	UpdateRefundStatus(refund entities.Refund, from string) error
+	SetRefundGatewayID(id int, gatewayRefundID string) error
+	CompleteRefund(refund entities.Refund, enrollmentID *uint, revokedAt time.Time, revokeReason, orderStatus string) error
+
+	// Invoices
+	AddInvoice(invoice entities.Invoice) (entities.Invoice, error)
+	GetInvoiceByID(id int) (entities.Invoice, error)
+	GetInvoiceByOrderID(orderID int) (entities.Invoice, error)
+	GetInvoicesByUserID(userID int) ([]entities.Invoice, error)
+	SetInvoiceEmailed(id int, at time.Time) error
+	GetBillingDetails(userID int) (entities.BillingDetails, error)
+	SetBillingDetails(details entities.BillingDetails) error
+
+	// Subscriptions
+	AddSubscriptionPlan(plan entities.SubscriptionPlan) (entities.SubscriptionPlan, error)
+	GetSubscriptionPlanByID(id int) (entities.SubscriptionPlan, error)
+	GetSubscriptionPlans(includeInactive bool) ([]entities.SubscriptionPlan, error)
+	UpdateSubscriptionPlan(plan entities.SubscriptionPlan) error
+	AddSubscription(subscription entities.Subscription, payment entities.SubscriptionPayment) (entities.Subscription, error)
+	GetSubscriptionByID(id int) (entities.Subscription, error)
+	GetSubscriptionsByUserID(userID int) ([]entities.Subscription, error)
+	GetDueSubscriptions(now time.Time) ([]entities.Subscription, error)
+	UpdateSubscription(subscription entities.Subscription, fromStatus string, fromPeriodEnd time.Time, payment *entities.SubscriptionPayment) error
+	GetSubscriptionPayments(subscriptionID int) ([]entities.SubscriptionPayment, error)
+
+	// Organizations
+	AddOrganization(organization entities.Organization, admin entities.OrganizationMember) (entities.Organization, error)
+	GetOrganizationByID(id int) (entities.Organization, error)
+	GetOrganizations() ([]entities.Organization, error)
+	GetOrganizationsByUserID(userID int) ([]entities.Organization, error)
+	UpdateOrganization(organization entities.Organization) error
+	AddOrganizationMember(member entities.OrganizationMember) error
+	GetOrganizationMember(organizationID, userID int) (entities.OrganizationMember, error)
+	GetOrganizationMembers(organizationID int) ([]entities.OrganizationMember, error)
+	SetOrganizationMemberRole(organizationID, userID int, role string) error
+	RemoveOrganizationMember(organizationID, userID int, at time.Time, reason string) ([]uint, error)
+	AddOrganizationInvitation(invitation entities.OrganizationInvitation) (entities.OrganizationInvitation, error)
+	GetOrganizationInvitationByID(id int) (entities.OrganizationInvitation, error)
+	GetPendingOrganizationInvitation(organizationID, userID int) (entities.OrganizationInvitation, error)
+	GetOrganizationInvitations(organizationID int) ([]entities.OrganizationInvitation, error)
+	GetUserOrganizationInvitations(userID int, status string) ([]entities.OrganizationInvitation, error)
+	SetOrganizationInvitationStatus(id int, status string, at time.Time) error
+	AddSeatPurchase(purchase entities.SeatPurchase) (entities.SeatPurchase, error)
+	GetSeatPurchases(organizationID int) ([]entities.SeatPurchase, error)
+	GetSeatPoolByID(id int) (entities.SeatPool, error)
+	GetSeatPool(organizationID, courseID int) (entities.SeatPool, error)
+	GetSeatPools(organizationID int) ([]entities.SeatPool, error)
+	AssignEnrollmentSeat(enrollmentID int, poolID uint) error
+	GetOrganizationEnrollments(organizationID, courseID int) ([]entities.Enrollment, error)
+
+	// Notifications
+	GetNotificationPreferences(userID int) (entities.NotificationPreferences, error)
+	SetNotificationPreferences(preferences entities.NotificationPreferences) error
+	GetInstructorsByName(name string) ([]entities.User, error)
+	AddOutboxEmail(email entities.OutboxEmail) (entities.OutboxEmail, error)
+	GetOutboxEmailByID(id int) (entities.OutboxEmail, error)
+	GetOutboxEmails(status string, limit int) ([]entities.OutboxEmail, error)
+	GetDueOutboxEmails(now time.Time, limit int) ([]entities.OutboxEmail, error)
+	UpdateOutboxEmail(email entities.OutboxEmail) error
+
+	// Sessions
+	GetUserCredentials(email string) (entities.User, error)
+	SetUserPassword(id int, hash string) error
+	AddSession(tokenHash string, session entities.Session) error
+	GetSession(tokenHash string) (entities.Session, error)
+	DeleteSession(tokenHash string) error
+	DeleteUserSessions(userID int, keepTokenHash string) error
+	DeleteExpiredSessions(now time.Time) error
+
+	// Transactions
+	Atomic(fn func(repo CourseRepository) error) error
+
+	// Audit
+	AddAuditLog(entry entities.AuditLog) (entities.AuditLog, error)
+	GetAuditLogs(filter entities.AuditFilter) ([]entities.AuditLog, error)
+
+	// Soft delete
+	PurgeDeleted(before time.Time) (entities.PurgedRows, error)
 }
 
 
 type CourseUseCase struct {
 	Repo CourseRepository
+	Filter ContentFilter // screens review text, optional
+	Storage FileStorage // keeps submission files and certificates, uploads are rejected without it
+	Certificates CertificateRenderer // draws certificate PDFs, no certificates are issued without it
+	Payments PaymentGateway // charges orders, checkout is unavailable without it
+	Rates ExchangeRates // converts prices, only the prices set per currency can be bought without it
+	RefundPolicy RefundPolicy // which refunds buyers can request themselves
+	Invoicing InvoiceSettings // the seller and tax printed on invoices
+	Invoices InvoiceRenderer // draws invoices as PDF and HTML, they can only be read as JSON without it
+	Notifier Notifier // sends emails, nothing is emailed without it
+	Templates EmailTemplates // words notification emails, no notifications are sent without it
+	Clock Clock // the time subscriptions are billed at, the real time without it
+	SubscriptionPolicy SubscriptionPolicy // grace period and retries of failed renewals
+
+	request RequestInfo // who is making the current request, see WithRequest
 }
 //constructor
 func NewCourseUseCase(repo CourseRepository) *CourseUseCase {
-	return &CourseUseCase{Repo: repo}
+	return &CourseUseCase{Repo: repo, RefundPolicy: DefaultRefundPolicy, SubscriptionPolicy: DefaultSubscriptionPolicy}
 }
 
 //----------------------------------------------------------------user----------------------------------------------------------------
+// CreateUser signs a user up. Students sign themselves up, instructor and admin accounts are
+// created by admins, except for the first admin of a school that has none yet.
 func (uc *CourseUseCase) CreateUser(user entities.User) (entities.User, error) {
-	return uc.Repo.CreateUser(user)
+	if err := uc.prepareSignUp(&user); err != nil {
+		return entities.User{}, err
+	}
+	return atomically(uc, func(uc *CourseUseCase) (entities.User, error) {
+		created, err := uc.Repo.CreateUser(user)
+		if err != nil {
+			return created, err
+		}
+		created.Password = ""
+		if err := uc.record(entities.AuditCreate, EntityUser, created.ID, nil, created); err != nil {
+			return entities.User{}, err
+		}
+		if err := uc.notify(entities.NotificationWelcome, NotificationData{User: created}); err != nil {
+			return entities.User{}, err
+		}
+		return created, nil
+	})
 }
 
-func (uc *CourseUseCase) GetUserByID(id int) (entities.User, error) {
-	return uc.Repo.GetUserByID(id)
+func (uc *CourseUseCase) GetUserByID(id int, includeDeleted bool) (entities.User, error) {
+	user, err := uc.Repo.GetUserByID(id, includeDeleted)
+	if err != nil {
+		return entities.User{}, notFound(err)
+	}
+	return user, nil
 }
 
-func (uc *CourseUseCase) GetAllUsers() ([]entities.User, error) {
-	return uc.Repo.GetAllUsers()
+func (uc *CourseUseCase) GetAllUsers(includeDeleted bool) ([]entities.User, error) {
+	return uc.Repo.GetAllUsers(includeDeleted)
 }
 
+// UpdateUser changes a user's account, users change their own and admins anyone's. Only admins
+// change roles. The password is kept when none is given, changing it signs out every session.
 func (uc *CourseUseCase) UpdateUser(user entities.User) (entities.User, error) {
-	return uc.Repo.UpdateUser(user)
+	return atomically(uc, func(uc *CourseUseCase) (entities.User, error) {
+		before, err := uc.Repo.GetUserByID(int(user.ID), false)
+		if err != nil {
+			return entities.User{}, notFound(err)
+		}
+		if err := uc.prepareAccountChange(&user, before); err != nil {
+			return entities.User{}, err
+		}
+		updated, err := uc.Repo.UpdateUser(user)
+		if err != nil {
+			return updated, notFound(err)
+		}
+		if updated.Password != "" {
+			if err := uc.endSessions(int(updated.ID)); err != nil {
+				return entities.User{}, err
+			}
+			updated.Password = ""
+		}
+		if err := uc.record(entities.AuditUpdate, EntityUser, user.ID, before, updated); err != nil {
+			return entities.User{}, err
+		}
+		return updated, nil
+	})
 }
+
+// DeleteUser soft deletes a user and signs out their sessions, users delete their own account
+// and admins anyone's
 func (uc *CourseUseCase) DeleteUser(id int) error {
-	return uc.Repo.DeleteUser(id)
+	if err := uc.checkAccountOwner(uint(id), "delete"); err != nil {
+		return err
+	}
+	return uc.atomic(func(uc *CourseUseCase) error {
+		before, _ := uc.Repo.GetUserByID(id, false)
+		if err := uc.Repo.DeleteUser(id); err != nil {
+			return notFound(err)
+		}
+		if err := uc.endSessions(id); err != nil {
+			return err
+		}
+		return uc.record(entities.AuditDelete, EntityUser, uint(id), before, nil)
+	})
+}
+
+func (uc *CourseUseCase) RestoreUser(id int) error {
+	return uc.atomic(func(uc *CourseUseCase) error {
+		if err := uc.Repo.RestoreUser(id); err != nil {
+			return notFound(err)
+		}
+		after, _ := uc.Repo.GetUserByID(id, false)
+		return uc.record(entities.AuditRestore, EntityUser, uint(id), nil, after)
+	})
 }
 
 
 //----------------------------------------------------------------course----------------------------------------------------------------
 func (uc *CourseUseCase) AddCourse(course entities.Course) (entities.Course ,error) {
-	// Business rule: validate course price
-	if course.Price <= 0 {
-		return entities.Course{}, fmt.Errorf("price must be greater than zero")
-	}
-	return uc.Repo.AddCourse(course)
+	return atomically(uc, func(uc *CourseUseCase) (entities.Course, error) {
+		// Business rule: validate course price
+		if err := validatePrice(course.Price); err != nil {
+			return entities.Course{}, err
+		}
+		// new courses start as drafts and go through review before students can see them
+		course.Status = entities.CourseDraft
+		course.PublishAt = nil
+		course.StatusNote = ""
+		created, err := uc.Repo.AddCourse(course)
+		if err != nil {
+			return created, err
+		}
+		if err := uc.record(entities.AuditCreate, EntityCourse, created.ID, nil, created); err != nil {
+			return entities.Course{}, err
+		}
+		return created, nil
+	})
 }
 
-func (uc *CourseUseCase) ListCourses() ([]entities.Course, error) {
-	return uc.Repo.GetAllCourses()
+func (uc *CourseUseCase) ListCourses(filter entities.CourseFilter) ([]entities.Course, error) {
+	return uc.Repo.GetAllCourses(filter)
 }
 
-func (uc *CourseUseCase) GetCourseByID(id int) (entities.Course, error) {
-	return uc.Repo.GetCourseByID(id)
+// GetCourseByID returns a course, with PublishedOnly set courses not visible in the catalog are not found
+func (uc *CourseUseCase) GetCourseByID(id int, filter entities.CourseFilter) (entities.Course, error) {
+	course, err := uc.Repo.GetCourseByID(id, filter.IncludeDeleted)
+	if err != nil {
+		return entities.Course{}, notFound(err)
+	}
+	ownCourse := filter.Instructor != "" && strings.EqualFold(course.Instructor, filter.Instructor)
+	if filter.PublishedOnly && !course.Visible(time.Now()) && !ownCourse {
+		return entities.Course{}, ErrNotFound
+	}
+	return course, nil
+}
+
+// UpdateCourse edits a course, its price included. Only the course instructor or an admin can edit it,
+// and only admins can hand it to another instructor.
+func (uc *CourseUseCase) UpdateCourse(course entities.Course, actor entities.User) (entities.Course, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.Course, error) {
+		if err := validatePrice(course.Price); err != nil {
+			return entities.Course{}, err
+		}
+		before, err := uc.Repo.GetCourseByID(int(course.ID), false)
+		if err != nil {
+			return entities.Course{}, notFound(err)
+		}
+		if !isCourseInstructor(actor, before) {
+			return entities.Course{}, fmt.Errorf("%w: only the course instructor can edit a course", ErrForbidden)
+		}
+		if !strings.EqualFold(strings.TrimSpace(course.Instructor), strings.TrimSpace(before.Instructor)) && actor.Role != "admin" {
+			return entities.Course{}, fmt.Errorf("%w: only admins can change the instructor of a course", ErrForbidden)
+		}
+		updated, err := uc.Repo.UpdateCourse(course)
+		if err != nil {
+			return updated, notFound(err)
+		}
+		if err := uc.record(entities.AuditUpdate, EntityCourse, course.ID, before, updated); err != nil {
+			return entities.Course{}, err
+		}
+		return updated, nil
+	})
 }
 
-func (uc *CourseUseCase) UpdateCourse(course entities.Course) (entities.Course, error) {
-	return uc.Repo.UpdateCourse(course)
+// DeleteCourse soft deletes a course of the actor, admins can delete any course
+func (uc *CourseUseCase) DeleteCourse(id int, actor entities.User) error {
+	return uc.atomic(func(uc *CourseUseCase) error {
+		before, err := uc.Repo.GetCourseByID(id, false)
+		if err != nil {
+			return notFound(err)
+		}
+		if !isCourseInstructor(actor, before) {
+			return fmt.Errorf("%w: only the course instructor can delete a course", ErrForbidden)
+		}
+		if err := uc.Repo.DeleteCourse(id); err != nil {
+			return notFound(err)
+		}
+		return uc.record(entities.AuditDelete, EntityCourse, uint(id), before, nil)
+	})
 }
 
-func (uc *CourseUseCase) DeleteCourse(id int) error {
-	return uc.Repo.DeleteCourse(id)
+func (uc *CourseUseCase) RestoreCourse(id int) error {
+	return uc.atomic(func(uc *CourseUseCase) error {
+		if err := uc.Repo.RestoreCourse(id); err != nil {
+			return notFound(err)
+		}
+		after, _ := uc.Repo.GetCourseByID(id, false)
+		return uc.record(entities.AuditRestore, EntityCourse, uint(id), nil, after)
+	})
 }
 
 //----------------------------------------------------------------enrollment----------------------------------------------------------------
 
+// AddEnrollment enrolls a user into a course visible in the catalog, a second enrollment into
+// the same course fails with a ConflictError carrying the existing one. Required prerequisites
+// must be completed first, missing recommended ones are returned as warnings.
 func (uc *CourseUseCase) AddEnrollment(enrollment entities.Enrollment) (entities.Enrollment, error) {
-	return uc.Repo.AddEnrollment(enrollment)
+	return atomically(uc, func(uc *CourseUseCase) (entities.Enrollment, error) {
+		if existing, err := uc.Repo.GetEnrollmentByUserAndCourse(int(enrollment.UserID), int(enrollment.CourseID)); err == nil {
+			return existing, &ConflictError{Resource: "enrollment", Existing: existing}
+		}
+		recommended, err := uc.checkEnrollable(&enrollment)
+		if err != nil {
+			return entities.Enrollment{}, err
+		}
+
+		// completion is derived from lesson progress, never set by the client
+		enrollment.Completed = false
+		enrollment.CompletedAt = nil
+		now := time.Now().UTC()
+		enrollment.EnrolledAt = &now
+		if enrollment.CourseVersion, err = uc.latestCourseVersion(int(enrollment.CourseID)); err != nil {
+			return entities.Enrollment{}, err
+		}
+		created, err := uc.Repo.AddEnrollment(enrollment)
+		if errors.Is(err, sql.ErrNoRows) {
+			return entities.Enrollment{}, fmt.Errorf("no seats left in seat pool %d, buy more seats", *enrollment.SeatPoolID)
+		}
+		if err != nil {
+			// lost a race against a concurrent enrollment, the unique index rejected ours
+			if existing, getErr := uc.Repo.GetEnrollmentByUserAndCourse(int(enrollment.UserID), int(enrollment.CourseID)); getErr == nil {
+				return existing, &ConflictError{Resource: "enrollment", Existing: existing}
+			}
+			return created, err
+		}
+		if err := uc.record(entities.AuditCreate, EntityEnrollment, created.ID, nil, created); err != nil {
+			return entities.Enrollment{}, err
+		}
+		if err := uc.notifyEnrollment(created); err != nil {
+			return entities.Enrollment{}, err
+		}
+		if len(recommended) > 0 {
+			created.Warnings = []string{fmt.Sprintf("recommended prerequisites not completed: %s", prerequisiteTitles(recommended))}
+		}
+		return created, nil
+	})
+}
+
+// checkEnrollable checks that a user may be enrolled in a course: it must be open for enrollment,
+// paid courses are enrolled through checkout, a subscription covering them or a seat an
+// organization bought and only admins can grant them directly, and required prerequisites must be
+// completed. It sets the subscription the enrollment is made through and returns the recommended
+// prerequisites not completed yet.
+func (uc *CourseUseCase) checkEnrollable(enrollment *entities.Enrollment) ([]entities.Prerequisite, error) {
+	course, err := uc.Repo.GetCourseByID(int(enrollment.CourseID), false)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if !course.Visible(time.Now()) {
+		return nil, fmt.Errorf("course is not open for enrollment")
+	}
+	enrollment.SubscriptionID = nil
+	if enrollment.SeatPoolID != nil {
+		if err := uc.checkSeat(*enrollment); err != nil {
+			return nil, err
+		}
+	} else if course.Price.IsPositive() && !uc.actorIsAdmin() {
+		subscription, err := uc.coveringSubscription(enrollment.UserID, course)
+		if err != nil {
+			return nil, err
+		}
+		if subscription == nil {
+			return nil, fmt.Errorf("%w: %q is a paid course, buy it through checkout or subscribe to a plan covering it", ErrForbidden, course.Title)
+		}
+		enrollment.SubscriptionID = &subscription.ID
+	}
+	required, recommended, err := uc.missingPrerequisites(int(enrollment.UserID), int(enrollment.CourseID))
+	if err != nil {
+		return nil, err
+	}
+	if len(required) > 0 {
+		return nil, fmt.Errorf("%w: complete %s before enrolling", ErrForbidden, prerequisiteTitles(required))
+	}
+	return recommended, nil
+}
+
+// EnsureEnrollment is the idempotent form of AddEnrollment, it returns the existing enrollment
+// if the user is already enrolled. created reports whether a new enrollment was made.
+func (uc *CourseUseCase) EnsureEnrollment(enrollment entities.Enrollment) (result entities.Enrollment, created bool, err error) {
+	result, err = uc.AddEnrollment(enrollment)
+	var conflict *ConflictError
+	if errors.As(err, &conflict) {
+		return result, false, nil
+	}
+	if err != nil {
+		return entities.Enrollment{}, false, err
+	}
+	return result, true, nil
 }
 
 func (uc *CourseUseCase) GetAllEnrollments() ([]entities.Enrollment, error) {
 	return uc.Repo.GetAllEnrollments()
 }
 
-func (uc *CourseUseCase) GetEnrollmentByID(id int) (entities.Enrollment, error) {	
-	return uc.Repo.GetEnrollmentByID(id)
+// GetEnrollmentByID returns an enrollment with its percent complete to the enrolled student,
+// the course instructor or an admin
+func (uc *CourseUseCase) GetEnrollmentByID(id int, actor entities.User) (entities.Enrollment, error) {	
+	if err := uc.canViewEnrollment(id, actor); err != nil {
+		return entities.Enrollment{}, err
+	}
+	enrollment, err := uc.Repo.GetEnrollmentByID(id)
+	if err != nil {
+		return entities.Enrollment{}, notFound(err)
+	}
+	lessons, err := uc.lessonProgress(enrollment)
+	if err != nil {
+		return entities.Enrollment{}, err
+	}
+	percent := percentComplete(len(lessons), countCompleted(lessons))
+	enrollment.PercentComplete = &percent
+	return enrollment, nil
 }
 
 func (uc *CourseUseCase) GetEnrollmentsByUserID(userID int) ([]entities.Enrollment, error) {
 	return uc.Repo.GetEnrollmentsByUserID(userID)
 }
 
-func (uc *CourseUseCase) UpdateEnrollment(enrollment entities.Enrollment) (entities.Enrollment, error) {
-	return uc.Repo.UpdateEnrollment(enrollment)
+// UpdateEnrollment moves an enrollment to another user or course. The move is checked like a new
+// enrollment, so it cannot get around checkout, subscriptions, seats or prerequisites. Enrollments
+// a refund revoked or made with an organization seat cannot move. Only the enrolled student or an
+// admin can change an enrollment.
+func (uc *CourseUseCase) UpdateEnrollment(enrollment entities.Enrollment, actor entities.User) (entities.Enrollment, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.Enrollment, error) {
+		before, err := uc.Repo.GetEnrollmentByID(int(enrollment.ID))
+		if err != nil {
+			return entities.Enrollment{}, notFound(err)
+		}
+		if actor.ID != before.UserID && actor.Role != "admin" {
+			return entities.Enrollment{}, fmt.Errorf("%w: only the enrolled student can change an enrollment", ErrForbidden)
+		}
+		// completion is derived from lesson progress, keep the stored state
+		enrollment.Completed = before.Completed
+		enrollment.CompletedAt = before.CompletedAt
+		enrollment.EnrolledAt = before.EnrolledAt
+		enrollment.SubscriptionID = before.SubscriptionID
+		enrollment.SeatPoolID = before.SeatPoolID
+		// the version only changes through UpgradeEnrollment, or when moving to another course
+		enrollment.CourseVersion = before.CourseVersion
+		if enrollment.UserID != before.UserID || enrollment.CourseID != before.CourseID {
+			if before.Revoked() {
+				return entities.Enrollment{}, fmt.Errorf("%w: enrollment %d was revoked, enroll again instead", ErrForbidden, before.ID)
+			}
+			if before.SeatPoolID != nil {
+				return entities.Enrollment{}, fmt.Errorf("%w: enrollment %d uses a seat of seat pool %d, enroll again instead", ErrForbidden, before.ID, *before.SeatPoolID)
+			}
+			if existing, err := uc.Repo.GetEnrollmentByUserAndCourse(int(enrollment.UserID), int(enrollment.CourseID)); err == nil {
+				return existing, &ConflictError{Resource: "enrollment", Existing: existing}
+			}
+			if _, err := uc.checkEnrollable(&enrollment); err != nil {
+				return entities.Enrollment{}, err
+			}
+		}
+		if enrollment.CourseID != before.CourseID {
+			// progress on the old course does not count for the new one
+			enrollment.Completed = false
+			enrollment.CompletedAt = nil
+			if enrollment.CourseVersion, err = uc.latestCourseVersion(int(enrollment.CourseID)); err != nil {
+				return entities.Enrollment{}, err
+			}
+		}
+		updated, err := uc.Repo.UpdateEnrollment(enrollment)
+		if err != nil {
+			return updated, err
+		}
+		if err := uc.record(entities.AuditUpdate, EntityEnrollment, enrollment.ID, before, updated); err != nil {
+			return entities.Enrollment{}, err
+		}
+		// the enrollment may have moved to another course
+		if err := uc.refreshCompletion(int(updated.ID)); err != nil {
+			return updated, err
+		}
+		return updated, nil
+	})
 }
 
-func (uc *CourseUseCase) DeleteEnrollment(id int) error {
-	return uc.Repo.DeleteEnrollment(id)
+// DeleteEnrollment deletes an enrollment of the actor, admins can delete any enrollment
+func (uc *CourseUseCase) DeleteEnrollment(id int, actor entities.User) error {
+	return uc.atomic(func(uc *CourseUseCase) error {
+		before, err := uc.Repo.GetEnrollmentByID(id)
+		if err != nil {
+			return notFound(err)
+		}
+		if actor.ID != before.UserID && actor.Role != "admin" {
+			return fmt.Errorf("%w: only the enrolled student can delete an enrollment", ErrForbidden)
+		}
+		if err := uc.Repo.DeleteEnrollment(id); err != nil {
+			return notFound(err)
+		}
+		return uc.record(entities.AuditDelete, EntityEnrollment, uint(id), before, nil)
+	})
 }
 
 //----------------------------------------------------------------lesson----------------------------------------------------------------
 
-func (uc *CourseUseCase) AddLesson(lesson entities.Lesson) (entities.Lesson, error) {
-	return uc.Repo.AddLesson(lesson)
+// AddLesson adds a lesson at the end of its section, or of the lessons outside any section.
+// Only the course instructor or an admin can add lessons.
+func (uc *CourseUseCase) AddLesson(lesson entities.Lesson, actor entities.User) (entities.Lesson, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.Lesson, error) {
+		if err := uc.requireCourseInstructor(int(lesson.CourseID), actor); err != nil {
+			return entities.Lesson{}, err
+		}
+		normalizeRelease(&lesson)
+		if err := uc.checkSection(lesson.CourseID, lesson.SectionID); err != nil {
+			return entities.Lesson{}, err
+		}
+		created, err := uc.Repo.AddLesson(lesson)
+		if err != nil {
+			return created, err
+		}
+		if err := uc.Repo.RenumberCurriculum(int(created.CourseID)); err != nil {
+			return created, err
+		}
+		created = uc.lessonSnapshot(int(created.ID))
+		if err := uc.record(entities.AuditCreate, EntityLesson, created.ID, nil, created); err != nil {
+			return entities.Lesson{}, err
+		}
+		return created, nil
+	})
+}
+
+// GetLessonsByCourseID returns the lessons of a course the caller can see, the ones not released
+// to the viewer yet come locked
+func (uc *CourseUseCase) GetLessonsByCourseID(courseID int, filter entities.CourseFilter, viewer entities.User) ([]entities.Lesson, error) {
+	if _, err := uc.GetCourseByID(courseID, filter); err != nil {
+		return nil, err
+	}
+	lessons, err := uc.Repo.GetLessonsByCourseID(courseID, filter.IncludeDeleted)
+	if err != nil {
+		return nil, err
+	}
+	if err := uc.releaseForViewer(courseID, lessons, viewer); err != nil {
+		return nil, err
+	}
+	return lessons, nil
 }
 
-func (uc *CourseUseCase) GetLessonsByCourseID(courseID int) ([]entities.Lesson, error) {
-	return uc.Repo.GetLessonsByCourseID(courseID)
-}	
+// GetLessonsByID returns a lesson of a course the caller can see, locked when it is not released
+// to the viewer yet
+func (uc *CourseUseCase) GetLessonsByID(lessonID int, filter entities.CourseFilter, viewer entities.User) ([]entities.Lesson, error) {
+	lessons, err := uc.Repo.GetLessonsByID(lessonID, filter.IncludeDeleted)
+	if err != nil || len(lessons) == 0 {
+		return lessons, err
+	}
+	if _, err := uc.GetCourseByID(int(lessons[0].CourseID), filter); err != nil {
+		return nil, err
+	}
+	if err := uc.releaseForViewer(int(lessons[0].CourseID), lessons, viewer); err != nil {
+		return nil, err
+	}
+	return lessons, nil
+}
 
-func (uc *CourseUseCase) GetLessonsByID(lessonID int) ([]entities.Lesson, error) {
-	return uc.Repo.GetLessonsByID(lessonID)
+// UpdateLesson edits a lesson. Its position is managed through the curriculum endpoints,
+// a lesson moved to another course goes to the end of that course outside any section.
+// The actor has to instruct both courses, or be an admin.
+func (uc *CourseUseCase) UpdateLesson(lesson entities.Lesson, actor entities.User) (entities.Lesson, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.Lesson, error) {
+		before := uc.lessonSnapshot(int(lesson.ID))
+		if before.ID == 0 {
+			return entities.Lesson{}, ErrNotFound
+		}
+		if err := uc.requireCourseInstructor(int(before.CourseID), actor); err != nil {
+			return entities.Lesson{}, err
+		}
+		if lesson.CourseID != before.CourseID {
+			if err := uc.requireCourseInstructor(int(lesson.CourseID), actor); err != nil {
+				return entities.Lesson{}, err
+			}
+		}
+		normalizeRelease(&lesson)
+		lesson.Order = before.Order
+		lesson.SectionID = before.SectionID
+		if lesson.CourseID != before.CourseID {
+			lesson.Order = math.MaxInt32
+			lesson.SectionID = nil
+		}
+		if _, err := uc.Repo.UpdateLesson(lesson); err != nil {
+			return entities.Lesson{}, err
+		}
+		if lesson.CourseID != before.CourseID {
+			if err := uc.Repo.RenumberCurriculum(int(before.CourseID)); err != nil {
+				return entities.Lesson{}, err
+			}
+			if err := uc.Repo.RenumberCurriculum(int(lesson.CourseID)); err != nil {
+				return entities.Lesson{}, err
+			}
+		}
+		updated := uc.lessonSnapshot(int(lesson.ID))
+		if err := uc.record(entities.AuditUpdate, EntityLesson, lesson.ID, before, updated); err != nil {
+			return entities.Lesson{}, err
+		}
+		return updated, nil
+	})
 }
 
-func (uc *CourseUseCase) UpdateLesson(lesson entities.Lesson) (entities.Lesson, error) {
-	return uc.Repo.UpdateLesson(lesson)
+// DeleteLesson soft deletes a lesson of a course the actor instructs, admins can delete any lesson
+func (uc *CourseUseCase) DeleteLesson(id int, actor entities.User) error {
+	return uc.atomic(func(uc *CourseUseCase) error {
+		before := uc.lessonSnapshot(id)
+		if before.ID == 0 {
+			return ErrNotFound
+		}
+		if err := uc.requireCourseInstructor(int(before.CourseID), actor); err != nil {
+			return err
+		}
+		if err := uc.Repo.DeleteLesson(id); err != nil {
+			return notFound(err)
+		}
+		if err := uc.record(entities.AuditDelete, EntityLesson, uint(id), before, nil); err != nil {
+			return err
+		}
+		if err := uc.Repo.RenumberCurriculum(int(before.CourseID)); err != nil {
+			return err
+		}
+		// the removed lesson may have been the last one a student had left
+		return uc.refreshCourseCompletion(int(before.CourseID))
+	})
 }
 
-func (uc *CourseUseCase) DeleteLesson(id int) error {
-	return uc.Repo.DeleteLesson(id)
+func (uc *CourseUseCase) RestoreLesson(id int) error {
+	return uc.atomic(func(uc *CourseUseCase) error {
+		if err := uc.Repo.RestoreLesson(id); err != nil {
+			return notFound(err)
+		}
+		// the lesson goes back to its old place
+		restored := uc.lessonSnapshot(id)
+		if err := uc.Repo.RenumberCurriculum(int(restored.CourseID)); err != nil {
+			return err
+		}
+		return uc.record(entities.AuditRestore, EntityLesson, uint(id), nil, uc.lessonSnapshot(id))
+	})
+}
+
+// lessonSnapshot returns the current state of a lesson for the audit log
+func (uc *CourseUseCase) lessonSnapshot(id int) entities.Lesson {
+	lessons, err := uc.Repo.GetLessonsByID(id, false)
+	if err != nil || len(lessons) == 0 {
+		return entities.Lesson{}
+	}
+	return lessons[0]
 }
 
 //----------------------------------------------------------------progress----------------------------------------------------------------
 
-func (uc *CourseUseCase) AddProgress(progress entities.Progress) (entities.Progress, error) {
-	return uc.Repo.AddProgress(progress)
+// AddProgress records progress on a lesson, progress that already exists for the
+// enrollment and lesson fails with a ConflictError carrying the existing row
+func (uc *CourseUseCase) AddProgress(progress entities.Progress, actor entities.User) (entities.Progress, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.Progress, error) {
+		if err := uc.checkProgressAccess(progress.EnrollmentID, actor); err != nil {
+			return entities.Progress{}, err
+		}
+		if existing, err := uc.Repo.GetProgressByEnrollmentAndLesson(int(progress.EnrollmentID), int(progress.LessonID)); err == nil {
+			return existing, &ConflictError{Resource: "progress", Existing: existing}
+		}
+		if err := uc.checkLessonReleased(progress.EnrollmentID, progress.LessonID); err != nil {
+			return entities.Progress{}, err
+		}
+		if progress.Completed {
+			if err := uc.checkQuizPassed(progress.EnrollmentID, progress.LessonID); err != nil {
+				return entities.Progress{}, err
+			}
+		}
+
+		created, err := uc.Repo.AddProgress(progress)
+		if err != nil {
+			if existing, getErr := uc.Repo.GetProgressByEnrollmentAndLesson(int(progress.EnrollmentID), int(progress.LessonID)); getErr == nil {
+				return existing, &ConflictError{Resource: "progress", Existing: existing}
+			}
+			return created, err
+		}
+		if err := uc.record(entities.AuditCreate, EntityProgress, created.ID, nil, created); err != nil {
+			return entities.Progress{}, err
+		}
+		if err := uc.refreshCompletion(int(created.EnrollmentID)); err != nil {
+			return created, err
+		}
+		return created, nil
+	})
+}
+
+// UpdateProgress sets the progress of an enrollment on a lesson, creating it if missing
+func (uc *CourseUseCase) UpdateProgress(progress entities.Progress, actor entities.User) (entities.Progress, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.Progress, error) {
+		if err := uc.checkProgressAccess(progress.EnrollmentID, actor); err != nil {
+			return entities.Progress{}, err
+		}
+		return uc.upsertProgress(progress)
+	})
+}
+
+// checkProgressAccess lets the enrolled student, the course instructor and admins record progress,
+// and only on an enrollment that still opens its course
+func (uc *CourseUseCase) checkProgressAccess(enrollmentID uint, actor entities.User) error {
+	if err := uc.canViewEnrollment(int(enrollmentID), actor); err != nil {
+		return err
+	}
+	enrollment, err := uc.Repo.GetEnrollmentByID(int(enrollmentID))
+	if err != nil {
+		return notFound(err)
+	}
+	return uc.checkEnrollmentAccess(enrollment)
 }
 
-func (uc *CourseUseCase) UpdateProgress(progress entities.Progress) (entities.Progress, error) {
-	return uc.Repo.UpdateProgress(progress)
+// upsertProgress writes the progress of an enrollment on a lesson once the caller was let through
+func (uc *CourseUseCase) upsertProgress(progress entities.Progress) (entities.Progress, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.Progress, error) {
+		if err := uc.checkLessonReleased(progress.EnrollmentID, progress.LessonID); err != nil {
+			return entities.Progress{}, err
+		}
+		if progress.Completed {
+			if err := uc.checkQuizPassed(progress.EnrollmentID, progress.LessonID); err != nil {
+				return entities.Progress{}, err
+			}
+		}
+		before, err := uc.Repo.GetProgressByEnrollmentAndLesson(int(progress.EnrollmentID), int(progress.LessonID))
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return entities.Progress{}, err
+		}
+		if before.Completed && !progress.Completed {
+			if err := uc.checkCanUnmark(progress.EnrollmentID); err != nil {
+				return entities.Progress{}, err
+			}
+		}
+		updated, err := uc.Repo.UpsertProgress(progress)
+		if err != nil {
+			return updated, err
+		}
+		if before.ID == 0 {
+			if err := uc.record(entities.AuditCreate, EntityProgress, updated.ID, nil, updated); err != nil {
+				return entities.Progress{}, err
+			}
+		} else {
+			if err := uc.record(entities.AuditUpdate, EntityProgress, updated.ID, before, updated); err != nil {
+				return entities.Progress{}, err
+			}
+		}
+		if err := uc.refreshCompletion(int(updated.EnrollmentID)); err != nil {
+			return updated, err
+		}
+		return updated, nil
+	})
+}
+
+// checkCanUnmark refuses to un-mark a lesson of a completed enrollment. Completion is kept once
+// reached and its certificate stays valid, so the progress it was reached with is kept too.
+func (uc *CourseUseCase) checkCanUnmark(enrollmentID uint) error {
+	enrollment, err := uc.Repo.GetEnrollmentByID(int(enrollmentID))
+	if err != nil {
+		return notFound(err)
+	}
+	if enrollment.Completed {
+		return fmt.Errorf("%w: enrollment %d is completed, its lessons cannot be marked incomplete", ErrForbidden, enrollment.ID)
+	}
+	return nil
+}
+
+// MarkLessonComplete idempotently marks a lesson completed for an enrollment
+func (uc *CourseUseCase) MarkLessonComplete(enrollmentID, lessonID int, actor entities.User) (entities.Progress, error) {
+	return uc.UpdateProgress(entities.Progress{EnrollmentID: uint(enrollmentID), LessonID: uint(lessonID), Completed: true}, actor)
 }
 
 func (uc *CourseUseCase) GetProgressByEnrollmentAndLesson(enrollmentID, lessonID int) (entities.Progress, error) {
 	return uc.Repo.GetProgressByEnrollmentAndLesson(enrollmentID, lessonID)
 }
 
+// GetEnrollmentProgress returns every lesson of the enrollment's course version with its progress,
+// the percent complete, the next lesson to take and the time of the last activity
+func (uc *CourseUseCase) GetEnrollmentProgress(enrollmentID int) (entities.EnrollmentProgress, error) {
+	enrollment, err := uc.Repo.GetEnrollmentByID(enrollmentID)
+	if err != nil {
+		return entities.EnrollmentProgress{}, notFound(err)
+	}
+	lessons, err := uc.lessonProgress(enrollment)
+	if err != nil {
+		return entities.EnrollmentProgress{}, err
+	}
+
+	summary := entities.EnrollmentProgress{
+		EnrollmentID: enrollment.ID,
+		CourseID:     enrollment.CourseID,
+		Completed:    enrollment.Completed,
+		Lessons:      lessons,
+	}
+	completed := 0
+	for i := range lessons {
+		lesson := &lessons[i]
+		if lesson.Completed {
+			completed++
+		} else if summary.NextLesson == nil {
+			summary.NextLesson = lesson
+		}
+		if lesson.UpdatedAt != nil && (summary.LastActivityAt == nil || lesson.UpdatedAt.After(*summary.LastActivityAt)) {
+			summary.LastActivityAt = lesson.UpdatedAt
+		}
+	}
+	summary.PercentComplete = percentComplete(len(lessons), completed)
+	return summary, nil
+}
+
+// refreshCompletion marks an enrollment completed once every lesson of its course version is completed
+// and every required assignment is passed, and issues its certificate.
+// Completion is kept once reached: lessons added to a course later lower the percent complete
+// of students who already finished it, but do not take their completion away, and the lessons of
+// a completed enrollment cannot be un-marked. Only moving the enrollment to another course resets it.
+func (uc *CourseUseCase) refreshCompletion(enrollmentID int) error {
+	return uc.atomic(func(uc *CourseUseCase) error {
+		enrollment, err := uc.Repo.GetEnrollmentByID(enrollmentID)
+		if err != nil {
+			return notFound(err)
+		}
+		if enrollment.Completed {
+			return nil
+		}
+		lessons, err := uc.lessonProgress(enrollment)
+		if err != nil {
+			return err
+		}
+		if len(lessons) == 0 || countCompleted(lessons) < len(lessons) {
+			return nil
+		}
+		if passed, err := uc.requiredAssignmentsPassed(enrollment); err != nil || !passed {
+			return err
+		}
+
+		before := enrollment
+		now := time.Now().UTC()
+		enrollment.Completed = true
+		enrollment.CompletedAt = &now
+		updated, err := uc.Repo.UpdateEnrollment(enrollment)
+		if err != nil {
+			return err
+		}
+		if err := uc.record(entities.AuditUpdate, EntityEnrollment, updated.ID, before, updated); err != nil {
+			return err
+		}
+		uc.issueCompletionCertificate(updated)
+		return uc.notifyCompletion(updated)
+	})
+}
+
+// refreshCourseCompletion runs refreshCompletion for every enrollment of a course
+func (uc *CourseUseCase) refreshCourseCompletion(courseID int) error {
+	enrollments, err := uc.Repo.GetEnrollmentsByCourseID(courseID)
+	if err != nil {
+		return err
+	}
+	for _, enrollment := range enrollments {
+		if err := uc.refreshCompletion(int(enrollment.ID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lessonProgress returns the lessons of an enrollment's course version, in order, with the
+// enrollment's progress on each of them
+func (uc *CourseUseCase) lessonProgress(enrollment entities.Enrollment) ([]entities.LessonProgress, error) {
+	lessons, err := uc.enrollmentLessons(enrollment)
+	if err != nil {
+		return nil, err
+	}
+	progresses, err := uc.Repo.GetProgressByEnrollment(int(enrollment.ID))
+	if err != nil {
+		return nil, err
+	}
+	return progressOfLessons(enrollment, lessons, progresses), nil
+}
+
+// progressOfLessons pairs the lessons of an enrollment's course version with its progress rows
+func progressOfLessons(enrollment entities.Enrollment, lessons []entities.Lesson, progresses []entities.Progress) []entities.LessonProgress {
+	// locking marks the lessons, they may be shared with other enrollments
+	lessons = append([]entities.Lesson(nil), lessons...)
+	byLesson := map[uint]entities.Progress{}
+	for _, progress := range progresses {
+		// rows written before the unique index may be duplicated, completion wins
+		if existing, ok := byLesson[progress.LessonID]; ok && existing.Completed && !progress.Completed {
+			continue
+		}
+		byLesson[progress.LessonID] = progress
+	}
+
+	completed := map[uint]bool{}
+	for lessonID, progress := range byLesson {
+		completed[lessonID] = progress.Completed
+	}
+	lockLessons(lessons, &enrollment, completed, time.Now())
+
+	result := make([]entities.LessonProgress, 0, len(lessons))
+	for _, lesson := range lessons {
+		progress := byLesson[lesson.ID]
+		result = append(result, entities.LessonProgress{
+			LessonID:    lesson.ID,
+			Title:       lesson.Title,
+			Order:       lesson.Order,
+			Completed:   progress.Completed,
+			UpdatedAt:   progress.UpdatedAt,
+			Locked:      lesson.Locked,
+			AvailableAt: lesson.AvailableAt,
+		})
+	}
+	return result
+}
+
+func countCompleted(lessons []entities.LessonProgress) int {
+	completed := 0
+	for _, lesson := range lessons {
+		if lesson.Completed {
+			completed++
+		}
+	}
+	return completed
+}
+
+// percentComplete returns completed/total as a percentage rounded to two decimals
+func percentComplete(total, completed int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(completed)*10000/float64(total)) / 100
+}
+
 //----------------------------------------------------------------review----------------------------------------------------------------
 
-func (uc *CourseUseCase) AddReview(review entities.Review) (entities.Review, error) {
-	return uc.Repo.AddReview(review)
+// AddReview adds a review by the actor, who has to be enrolled in the course. A user reviews a course once,
+// a second review fails with a ConflictError carrying the existing one, which can be edited instead.
+// New reviews wait in the moderation queue until an admin approves them.
+func (uc *CourseUseCase) AddReview(review entities.Review, actor entities.User) (entities.Review, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.Review, error) {
+		if err := validateRating(review.Rating); err != nil {
+			return entities.Review{}, err
+		}
+		review.UserID = actor.ID
+		enrollment, err := uc.Repo.GetEnrollmentByUserAndCourse(int(review.UserID), int(review.CourseID))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return entities.Review{}, fmt.Errorf("%w: only users enrolled in the course can review it", ErrForbidden)
+			}
+			return entities.Review{}, err
+		}
+		if err := uc.checkEnrollmentAccess(enrollment); err != nil {
+			return entities.Review{}, err
+		}
+		if existing, err := uc.Repo.GetReviewByUserAndCourse(int(review.UserID), int(review.CourseID)); err == nil {
+			return existing, &ConflictError{Resource: "review", Existing: existing}
+		}
+
+		review.Reply = nil
+		uc.screen(&review)
+		created, err := uc.Repo.AddReview(review)
+		if err != nil {
+			if existing, getErr := uc.Repo.GetReviewByUserAndCourse(int(review.UserID), int(review.CourseID)); getErr == nil {
+				return existing, &ConflictError{Resource: "review", Existing: existing}
+			}
+			return created, err
+		}
+		if err := uc.record(entities.AuditCreate, EntityReview, created.ID, nil, created); err != nil {
+			return entities.Review{}, err
+		}
+		return created, nil
+	})
+}
+
+// UpdateReview edits the rating and comment of a review, the course and author stay the same.
+// Only the author and admins edit a review. The edited review goes back to the moderation queue.
+func (uc *CourseUseCase) UpdateReview(review entities.Review) (entities.Review, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.Review, error) {
+		if err := validateRating(review.Rating); err != nil {
+			return entities.Review{}, err
+		}
+		before, err := uc.Repo.GetReviewByID(int(review.ID))
+		if err != nil {
+			return entities.Review{}, notFound(err)
+		}
+		if err := uc.checkReviewAuthor(before); err != nil {
+			return entities.Review{}, err
+		}
+		uc.screen(&review)
+		updated, err := uc.Repo.UpdateReview(review)
+		if err != nil {
+			return entities.Review{}, notFound(err)
+		}
+		if err := uc.record(entities.AuditUpdate, EntityReview, updated.ID, before, updated); err != nil {
+			return entities.Review{}, err
+		}
+		return updated, nil
+	})
 }
 
 func (uc *CourseUseCase) GetReviewsByCourseID(courseID int) ([]entities.Review, error) {
 	return uc.Repo.GetReviewsByCourseID(courseID)
 }
 
+// DeleteReview removes a review, only its author and admins can
 func (uc *CourseUseCase) DeleteReview(id int) error {
-	return uc.Repo.DeleteReview(id)
+	return uc.atomic(func(uc *CourseUseCase) error {
+		before, err := uc.Repo.GetReviewByID(id)
+		if err != nil {
+			return notFound(err)
+		}
+		if err := uc.checkReviewAuthor(before); err != nil {
+			return err
+		}
+		if err := uc.Repo.DeleteReview(id); err != nil {
+			return notFound(err)
+		}
+		return uc.record(entities.AuditDelete, EntityReview, uint(id), before, nil)
+	})
+}
+
+// checkReviewAuthor allows the author of a review and admins
+func (uc *CourseUseCase) checkReviewAuthor(review entities.Review) error {
+	if uc.request.ActorID != review.UserID && !uc.actorIsAdmin() {
+		return fmt.Errorf("%w: only the author of a review and admins can change it", ErrForbidden)
+	}
+	return nil
+}
+
+//----------------------------------------------------------------soft delete----------------------------------------------------------------
+
+// PurgeDeleted hard deletes users, courses and lessons that were soft deleted longer than retention
+// ago, recording each of them in the audit log
+func (uc *CourseUseCase) PurgeDeleted(retention time.Duration) (int64, error) {
+	if retention < 0 {
+		return 0, fmt.Errorf("retention must not be negative")
+	}
+	return atomically(uc, func(uc *CourseUseCase) (int64, error) {
+		purged, err := uc.Repo.PurgeDeleted(time.Now().Add(-retention))
+		if err != nil {
+			return 0, err
+		}
+		for _, id := range purged.LessonIDs {
+			if err := uc.record(entities.AuditPurge, EntityLesson, id, nil, nil); err != nil {
+				return 0, err
+			}
+		}
+		for _, id := range purged.CourseIDs {
+			if err := uc.record(entities.AuditPurge, EntityCourse, id, nil, nil); err != nil {
+				return 0, err
+			}
+		}
+		for _, id := range purged.UserIDs {
+			if err := uc.record(entities.AuditPurge, EntityUser, id, nil, nil); err != nil {
+				return 0, err
+			}
+		}
+		return purged.Count(), nil
+	})
+}
+
+func validateRating(rating int) error {
+	if rating < 1 || rating > 5 {
+		return fmt.Errorf("rating must be between 1 and 5")
+	}
+	return nil
 }
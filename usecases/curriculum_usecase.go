@@ -0,0 +1,287 @@
+package usecases
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+//----------------------------------------------------------------curriculum----------------------------------------------------------------
+
+// AddSection adds a section at the end of a course. The curriculum of a course is changed by
+// its instructor or an admin only.
+func (uc *CourseUseCase) AddSection(section entities.Section, actor entities.User) (entities.Section, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.Section, error) {
+		section.Title = strings.TrimSpace(section.Title)
+		if section.Title == "" {
+			return entities.Section{}, fmt.Errorf("section title is required")
+		}
+		if err := uc.requireCourseInstructor(int(section.CourseID), actor); err != nil {
+			return entities.Section{}, err
+		}
+		created, err := uc.Repo.AddSection(section)
+		if err != nil {
+			return created, err
+		}
+		if err := uc.record(entities.AuditCreate, EntitySection, created.ID, nil, created); err != nil {
+			return entities.Section{}, err
+		}
+		return created, nil
+	})
+}
+
+// UpdateSection renames a section, sections are moved with ReorderCurriculum
+func (uc *CourseUseCase) UpdateSection(section entities.Section, actor entities.User) (entities.Section, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.Section, error) {
+		section.Title = strings.TrimSpace(section.Title)
+		if section.Title == "" {
+			return entities.Section{}, fmt.Errorf("section title is required")
+		}
+		before, err := uc.Repo.GetSectionByID(int(section.ID))
+		if err != nil {
+			return entities.Section{}, notFound(err)
+		}
+		if err := uc.requireCourseInstructor(int(before.CourseID), actor); err != nil {
+			return entities.Section{}, err
+		}
+		updated, err := uc.Repo.UpdateSection(section)
+		if err != nil {
+			return updated, notFound(err)
+		}
+		if err := uc.record(entities.AuditUpdate, EntitySection, updated.ID, before, updated); err != nil {
+			return entities.Section{}, err
+		}
+		return updated, nil
+	})
+}
+
+// DeleteSection deletes a section without live lessons
+func (uc *CourseUseCase) DeleteSection(id int, actor entities.User) error {
+	return uc.atomic(func(uc *CourseUseCase) error {
+		before, err := uc.Repo.GetSectionByID(id)
+		if err != nil {
+			return notFound(err)
+		}
+		if err := uc.requireCourseInstructor(int(before.CourseID), actor); err != nil {
+			return err
+		}
+		lessons, err := uc.Repo.GetLessonsByCourseID(int(before.CourseID), false)
+		if err != nil {
+			return err
+		}
+		for _, lesson := range lessons {
+			if lesson.SectionID != nil && *lesson.SectionID == before.ID {
+				return fmt.Errorf("section still has lessons, move or delete them first")
+			}
+		}
+		if err := uc.Repo.DeleteSection(id); err != nil {
+			return notFound(err)
+		}
+		return uc.record(entities.AuditDelete, EntitySection, before.ID, before, nil)
+	})
+}
+
+// GetCurriculum returns the sections and live lessons of a course the caller can see, in order
+func (uc *CourseUseCase) GetCurriculum(courseID int, filter entities.CourseFilter) (entities.Curriculum, error) {
+	if _, err := uc.GetCourseByID(courseID, filter); err != nil {
+		return entities.Curriculum{}, err
+	}
+	return uc.curriculum(courseID)
+}
+
+func (uc *CourseUseCase) curriculum(courseID int) (entities.Curriculum, error) {
+	sections, err := uc.Repo.GetSectionsByCourseID(courseID)
+	if err != nil {
+		return entities.Curriculum{}, err
+	}
+	lessons, err := uc.Repo.GetLessonsByCourseID(courseID, false)
+	if err != nil {
+		return entities.Curriculum{}, err
+	}
+
+	curriculum := entities.Curriculum{
+		CourseID: uint(courseID),
+		Lessons:  []entities.Lesson{},
+		Sections: make([]entities.CurriculumSection, len(sections)),
+	}
+	index := map[uint]int{}
+	for i, section := range sections {
+		curriculum.Sections[i] = entities.CurriculumSection{Section: section, Lessons: []entities.Lesson{}}
+		index[section.ID] = i
+	}
+	for _, lesson := range lessons {
+		if lesson.SectionID == nil {
+			curriculum.Lessons = append(curriculum.Lessons, lesson)
+			continue
+		}
+		i := index[*lesson.SectionID]
+		curriculum.Sections[i].Lessons = append(curriculum.Sections[i].Lessons, lesson)
+	}
+	return curriculum, nil
+}
+
+// ReorderCurriculum rewrites the order of every section and lesson of a course at once.
+// The order must list every section and live lesson of the course exactly once.
+func (uc *CourseUseCase) ReorderCurriculum(courseID int, order entities.CurriculumOrder, actor entities.User) (entities.Curriculum, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.Curriculum, error) {
+		if err := uc.requireCourseInstructor(courseID, actor); err != nil {
+			return entities.Curriculum{}, err
+		}
+		before, err := uc.curriculum(courseID)
+		if err != nil {
+			return entities.Curriculum{}, err
+		}
+		if err := validateCurriculumOrder(before, order); err != nil {
+			return entities.Curriculum{}, err
+		}
+		if err := uc.Repo.ReorderCurriculum(courseID, order); err != nil {
+			return entities.Curriculum{}, err
+		}
+		after, err := uc.curriculum(courseID)
+		if err != nil {
+			return entities.Curriculum{}, err
+		}
+		if err := uc.record(entities.AuditUpdate, EntityCurriculum, uint(courseID), curriculumOrder(before), curriculumOrder(after)); err != nil {
+			return entities.Curriculum{}, err
+		}
+		return after, nil
+	})
+}
+
+// MoveLesson moves a lesson into a section, nil for outside any section, at a 1-based position
+// within it. Position 0 or past the end appends the lesson.
+func (uc *CourseUseCase) MoveLesson(lessonID int, sectionID *uint, position int, actor entities.User) (entities.Curriculum, error) {
+	lesson := uc.lessonSnapshot(lessonID)
+	if lesson.ID == 0 {
+		return entities.Curriculum{}, ErrNotFound
+	}
+	if err := uc.requireCourseInstructor(int(lesson.CourseID), actor); err != nil {
+		return entities.Curriculum{}, err
+	}
+	if err := uc.checkSection(lesson.CourseID, sectionID); err != nil {
+		return entities.Curriculum{}, err
+	}
+	current, err := uc.curriculum(int(lesson.CourseID))
+	if err != nil {
+		return entities.Curriculum{}, err
+	}
+
+	order := curriculumOrder(current)
+	order.Lessons = insertLesson(removeLesson(order.Lessons, lesson.ID), lesson.ID, position, sectionID == nil)
+	for i := range order.Sections {
+		target := sectionID != nil && order.Sections[i].ID == *sectionID
+		order.Sections[i].Lessons = insertLesson(removeLesson(order.Sections[i].Lessons, lesson.ID), lesson.ID, position, target)
+	}
+	return uc.ReorderCurriculum(int(lesson.CourseID), order, actor)
+}
+
+// checkSection verifies that a lesson's section belongs to the lesson's course
+func (uc *CourseUseCase) checkSection(courseID uint, sectionID *uint) error {
+	if sectionID == nil {
+		return nil
+	}
+	section, err := uc.Repo.GetSectionByID(int(*sectionID))
+	if err != nil || section.CourseID != courseID {
+		return fmt.Errorf("section %d does not belong to course %d", *sectionID, courseID)
+	}
+	return nil
+}
+
+// curriculumOrder returns the IDs making up a curriculum
+func curriculumOrder(curriculum entities.Curriculum) entities.CurriculumOrder {
+	order := entities.CurriculumOrder{Lessons: lessonIDs(curriculum.Lessons)}
+	for _, section := range curriculum.Sections {
+		order.Sections = append(order.Sections, entities.SectionOrder{ID: section.ID, Lessons: lessonIDs(section.Lessons)})
+	}
+	return order
+}
+
+func lessonIDs(lessons []entities.Lesson) []uint {
+	ids := make([]uint, 0, len(lessons))
+	for _, lesson := range lessons {
+		ids = append(ids, lesson.ID)
+	}
+	return ids
+}
+
+func removeLesson(ids []uint, lessonID uint) []uint {
+	result := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if id != lessonID {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// insertLesson inserts lessonID at a 1-based position when target is set
+func insertLesson(ids []uint, lessonID uint, position int, target bool) []uint {
+	if !target {
+		return ids
+	}
+	if position < 1 || position > len(ids) {
+		return append(ids, lessonID)
+	}
+	ids = append(ids[:position-1], append([]uint{lessonID}, ids[position-1:]...)...)
+	return ids
+}
+
+// validateCurriculumOrder checks that an order lists every section and live lesson exactly once
+func validateCurriculumOrder(current entities.Curriculum, order entities.CurriculumOrder) error {
+	expected := curriculumOrder(current)
+
+	sections := map[uint]bool{}
+	for _, section := range expected.Sections {
+		sections[section.ID] = true
+	}
+	lessons := map[uint]bool{}
+	for _, id := range expected.Lessons {
+		lessons[id] = true
+	}
+	for _, section := range expected.Sections {
+		for _, id := range section.Lessons {
+			lessons[id] = true
+		}
+	}
+
+	seenSections := map[uint]bool{}
+	for _, section := range order.Sections {
+		if !sections[section.ID] {
+			return fmt.Errorf("section %d does not belong to the course", section.ID)
+		}
+		if seenSections[section.ID] {
+			return fmt.Errorf("section %d is listed twice", section.ID)
+		}
+		seenSections[section.ID] = true
+	}
+	if len(seenSections) != len(sections) {
+		return fmt.Errorf("every section of the course must be listed")
+	}
+
+	seenLessons := map[uint]bool{}
+	check := func(ids []uint) error {
+		for _, id := range ids {
+			if !lessons[id] {
+				return fmt.Errorf("lesson %d does not belong to the course", id)
+			}
+			if seenLessons[id] {
+				return fmt.Errorf("lesson %d is listed twice", id)
+			}
+			seenLessons[id] = true
+		}
+		return nil
+	}
+	if err := check(order.Lessons); err != nil {
+		return err
+	}
+	for _, section := range order.Sections {
+		if err := check(section.Lessons); err != nil {
+			return err
+		}
+	}
+	if len(seenLessons) != len(lessons) {
+		return fmt.Errorf("every lesson of the course must be listed")
+	}
+	return nil
+}
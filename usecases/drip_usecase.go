@@ -0,0 +1,215 @@
+package usecases
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+//----------------------------------------------------------------drip release----------------------------------------------------------------
+
+// lockLessons works out which of the ordered lessons of a course are not released yet for an
+// enrollment, nil when the viewer is not enrolled. A lesson is released once every rule it has
+// is met: its release date has passed, the given number of days have passed since enrollment
+// and the previous lesson is completed. Lessons without rules are always released.
+func lockLessons(lessons []entities.Lesson, enrollment *entities.Enrollment, completed map[uint]bool, now time.Time) {
+	for i := range lessons {
+		lesson := &lessons[i]
+		lesson.Locked = false
+		lesson.AvailableAt = nil
+
+		var availableAt *time.Time
+		later := func(t time.Time) {
+			if availableAt == nil || t.After(*availableAt) {
+				availableAt = &t
+			}
+		}
+		if lesson.ReleaseAt != nil {
+			later(*lesson.ReleaseAt)
+		}
+		if lesson.ReleaseAfterDays != nil {
+			switch {
+			case enrollment == nil:
+				lesson.Locked = true
+			case enrollment.EnrolledAt != nil:
+				// enrollments made before enrolled_at was recorded have every day based lesson released
+				later(enrollment.EnrolledAt.AddDate(0, 0, int(*lesson.ReleaseAfterDays)))
+			}
+		}
+		if availableAt != nil && now.Before(*availableAt) {
+			lesson.Locked = true
+			lesson.AvailableAt = availableAt
+		}
+		if lesson.RequiresPrevious && i > 0 && (enrollment == nil || !completed[lessons[i-1].ID]) {
+			lesson.Locked = true
+		}
+	}
+}
+
+// hideLockedContent strips the content of locked lessons, leaving their metadata
+func hideLockedContent(lessons []entities.Lesson) {
+	for i := range lessons {
+		if lessons[i].Locked {
+			lessons[i].Content = ""
+			lessons[i].VideoURL = ""
+		}
+	}
+}
+
+// releaseForViewer locks and hides the lessons of a course the viewer cannot open yet.
+// Admins and the course instructor see every lesson. The lessons of a paid course stay locked
+// for viewers without an enrollment opening it or a subscription covering it, see
+// checkEnrollmentAccess and coveringSubscription. Otherwise students see them according to
+// their enrollment, and everyone else only sees lessons without a drip schedule.
+func (uc *CourseUseCase) releaseForViewer(courseID int, lessons []entities.Lesson, viewer entities.User) error {
+	if len(lessons) == 0 {
+		return nil
+	}
+	course, err := uc.Repo.GetCourseByID(courseID, true)
+	if err != nil {
+		return notFound(err)
+	}
+	if viewer.ID != 0 && isCourseInstructor(viewer, course) {
+		return nil
+	}
+
+	var enrollment *entities.Enrollment
+	if viewer.ID != 0 {
+		if found, err := uc.Repo.GetEnrollmentByUserAndCourse(int(viewer.ID), courseID); err == nil && uc.checkEnrollmentAccess(found) == nil {
+			enrollment = &found
+		}
+	}
+	if enrollment == nil && course.Price.IsPositive() {
+		var subscription *entities.Subscription
+		if viewer.ID != 0 {
+			if subscription, err = uc.coveringSubscription(viewer.ID, course); err != nil {
+				return err
+			}
+		}
+		if subscription == nil {
+			for i := range lessons {
+				lessons[i].Locked = true
+				lessons[i].AvailableAt = nil
+			}
+			hideLockedContent(lessons)
+			return nil
+		}
+	}
+	if !hasDripRules(lessons) {
+		return nil
+	}
+	completed := map[uint]bool{}
+	if enrollment != nil {
+		if completed, err = uc.completedLessons(*enrollment); err != nil {
+			return err
+		}
+	}
+	// whether a lesson is released depends on its place among the lessons the viewer follows
+	var reference []entities.Lesson
+	if enrollment != nil {
+		reference, err = uc.enrollmentLessons(*enrollment)
+	} else {
+		reference, err = uc.Repo.GetLessonsByCourseID(courseID, false)
+	}
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	lockLessons(reference, enrollment, completed, now)
+	state := map[uint]entities.Lesson{}
+	for _, lesson := range reference {
+		state[lesson.ID] = lesson
+	}
+	for i := range lessons {
+		if released, ok := state[lessons[i].ID]; ok {
+			lessons[i].Locked = released.Locked
+			lessons[i].AvailableAt = released.AvailableAt
+		} else {
+			lockLessons(lessons[i:i+1], enrollment, completed, now)
+		}
+	}
+	hideLockedContent(lessons)
+	return nil
+}
+
+// completedLessons returns the IDs of the lessons an enrollment has completed
+func (uc *CourseUseCase) completedLessons(enrollment entities.Enrollment) (map[uint]bool, error) {
+	progresses, err := uc.Repo.GetProgressByEnrollment(int(enrollment.ID))
+	if err != nil {
+		return nil, err
+	}
+	completed := map[uint]bool{}
+	for _, progress := range progresses {
+		if progress.Completed {
+			completed[progress.LessonID] = true
+		}
+	}
+	return completed, nil
+}
+
+// checkLessonReleased rejects progress on a lesson that is not part of the enrollment's course
+// version or that the enrollment has not unlocked yet, and any progress on an enrollment that no
+// longer opens its course, see checkEnrollmentAccess
+func (uc *CourseUseCase) checkLessonReleased(enrollmentID, lessonID uint) error {
+	enrollment, err := uc.Repo.GetEnrollmentByID(int(enrollmentID))
+	if err != nil {
+		return notFound(err)
+	}
+	if err := uc.checkEnrollmentAccess(enrollment); err != nil {
+		return err
+	}
+	lessons, err := uc.enrollmentLessons(enrollment)
+	if err != nil {
+		return err
+	}
+	if !hasLesson(lessons, lessonID) {
+		return fmt.Errorf("%w: lesson %d is not part of the course of enrollment %d", ErrNotFound, lessonID, enrollment.ID)
+	}
+	if !hasDripRules(lessons) {
+		return nil
+	}
+	completed, err := uc.completedLessons(enrollment)
+	if err != nil {
+		return err
+	}
+	lockLessons(lessons, &enrollment, completed, time.Now())
+	for _, lesson := range lessons {
+		if lesson.ID != lessonID || !lesson.Locked {
+			continue
+		}
+		if lesson.AvailableAt != nil {
+			return fmt.Errorf("%w: lesson %d is locked until %s", ErrForbidden, lessonID, lesson.AvailableAt.UTC().Format(time.RFC3339))
+		}
+		return fmt.Errorf("%w: lesson %d is locked until the previous lesson is completed", ErrForbidden, lessonID)
+	}
+	return nil
+}
+
+// normalizeRelease stores release dates in UTC and drops the computed fields sent by clients
+func normalizeRelease(lesson *entities.Lesson) {
+	if lesson.ReleaseAt != nil {
+		utc := lesson.ReleaseAt.UTC()
+		lesson.ReleaseAt = &utc
+	}
+	lesson.Locked = false
+	lesson.AvailableAt = nil
+}
+
+func hasLesson(lessons []entities.Lesson, lessonID uint) bool {
+	for _, lesson := range lessons {
+		if lesson.ID == lessonID {
+			return true
+		}
+	}
+	return false
+}
+
+func hasDripRules(lessons []entities.Lesson) bool {
+	for _, lesson := range lessons {
+		if lesson.ReleaseAt != nil || lesson.ReleaseAfterDays != nil || lesson.RequiresPrevious {
+			return true
+		}
+	}
+	return false
+}
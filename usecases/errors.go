@@ -0,0 +1,38 @@
+package usecases
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Errors returned by the use cases so handlers can pick a status code
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrUnauthorized    = errors.New("unauthorized")
+	ErrForbidden       = errors.New("forbidden")
+	ErrConflict        = errors.New("already exists")
+	ErrPaymentDeclined = errors.New("payment declined")
+)
+
+// ConflictError is returned when creating a resource that already exists, it carries the existing one
+type ConflictError struct {
+	Resource string
+	Existing interface{}
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s already exists", e.Resource)
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// notFound maps a missing row from the repository to ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
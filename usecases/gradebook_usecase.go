@@ -0,0 +1,314 @@
+package usecases
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+//----------------------------------------------------------------grade weights----------------------------------------------------------------
+
+// GetGradeWeights returns the grade weights of a course, every category counts the same until they are set
+func (uc *CourseUseCase) GetGradeWeights(courseID int, actor entities.User) (entities.GradeWeights, error) {
+	if err := uc.requireCourseInstructor(courseID, actor); err != nil {
+		return entities.GradeWeights{}, err
+	}
+	return uc.gradeWeights(courseID)
+}
+
+// SetGradeWeights changes how lessons, quizzes and assignments count towards the total grade
+func (uc *CourseUseCase) SetGradeWeights(weights entities.GradeWeights, actor entities.User) (entities.GradeWeights, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.GradeWeights, error) {
+		if err := uc.requireCourseInstructor(int(weights.CourseID), actor); err != nil {
+			return entities.GradeWeights{}, err
+		}
+		if weights.Lessons < 0 || weights.Quizzes < 0 || weights.Assignments < 0 {
+			return entities.GradeWeights{}, fmt.Errorf("weights cannot be negative")
+		}
+		if weights.Lessons+weights.Quizzes+weights.Assignments == 0 {
+			return entities.GradeWeights{}, fmt.Errorf("at least one weight must be positive")
+		}
+		before, err := uc.gradeWeights(int(weights.CourseID))
+		if err != nil {
+			return entities.GradeWeights{}, err
+		}
+		if err := uc.Repo.SetGradeWeights(weights); err != nil {
+			return entities.GradeWeights{}, err
+		}
+		if err := uc.record(entities.AuditUpdate, EntityGradeWeights, weights.CourseID, before, weights); err != nil {
+			return entities.GradeWeights{}, err
+		}
+		return weights, nil
+	})
+}
+
+func (uc *CourseUseCase) gradeWeights(courseID int) (entities.GradeWeights, error) {
+	weights, err := uc.Repo.GetGradeWeights(courseID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entities.GradeWeights{CourseID: uint(courseID), Lessons: 1, Quizzes: 1, Assignments: 1}, nil
+	}
+	return weights, err
+}
+
+//----------------------------------------------------------------gradebook----------------------------------------------------------------
+
+// GetGradebook returns every student of a course against its lessons, quizzes and assignments
+func (uc *CourseUseCase) GetGradebook(courseID int, actor entities.User) (entities.Gradebook, error) {
+	if err := uc.requireCourseInstructor(courseID, actor); err != nil {
+		return entities.Gradebook{}, err
+	}
+	items, err := uc.gradebookItems(courseID)
+	if err != nil {
+		return entities.Gradebook{}, err
+	}
+	enrollments, err := uc.Repo.GetEnrollmentsByCourseID(courseID)
+	if err != nil {
+		return entities.Gradebook{}, err
+	}
+	records, err := uc.gradebookRecords(courseID, 0)
+	if err != nil {
+		return entities.Gradebook{}, err
+	}
+	students, err := uc.Repo.GetCourseStudents(courseID)
+	if err != nil {
+		return entities.Gradebook{}, err
+	}
+	for _, student := range students {
+		records.students[student.ID] = student
+	}
+
+	gradebook := entities.Gradebook{CourseID: uint(courseID), Weights: items.weights, Columns: items.columns(), Rows: []entities.GradebookRow{}}
+	for _, enrollment := range enrollments {
+		student, err := uc.studentGradebook(enrollment, items, records)
+		if err != nil {
+			return entities.Gradebook{}, err
+		}
+		gradebook.Rows = append(gradebook.Rows, student.GradebookRow)
+	}
+	return gradebook, nil
+}
+
+// GetStudentGradebook returns the gradebook row of a student with the details behind it,
+// to the student, the course instructor or an admin
+func (uc *CourseUseCase) GetStudentGradebook(courseID, userID int, actor entities.User) (entities.StudentGradebook, error) {
+	enrollments, err := uc.Repo.GetEnrollmentsByUserID(userID)
+	if err != nil {
+		return entities.StudentGradebook{}, err
+	}
+	var enrollment entities.Enrollment
+	for _, candidate := range enrollments {
+		if int(candidate.CourseID) == courseID {
+			enrollment = candidate
+		}
+	}
+	if enrollment.ID == 0 {
+		return entities.StudentGradebook{}, ErrNotFound
+	}
+	if err := uc.canViewEnrollment(int(enrollment.ID), actor); err != nil {
+		return entities.StudentGradebook{}, err
+	}
+	items, err := uc.gradebookItems(courseID)
+	if err != nil {
+		return entities.StudentGradebook{}, err
+	}
+	records, err := uc.gradebookRecords(courseID, int(enrollment.ID))
+	if err != nil {
+		return entities.StudentGradebook{}, err
+	}
+	if user, err := uc.Repo.GetUserByID(userID, true); err == nil {
+		records.students[user.ID] = user
+	}
+	return uc.studentGradebook(enrollment, items, records)
+}
+
+// gradebookItems is what a course is graded on
+type gradebookItems struct {
+	weights     entities.GradeWeights
+	lessons     []entities.Lesson
+	quizzes     []entities.Quiz
+	assignments []entities.Assignment
+}
+
+func (uc *CourseUseCase) gradebookItems(courseID int) (gradebookItems, error) {
+	var items gradebookItems
+	var err error
+	if items.weights, err = uc.gradeWeights(courseID); err != nil {
+		return items, err
+	}
+	if items.lessons, err = uc.Repo.GetLessonsByCourseID(courseID, false); err != nil {
+		return items, err
+	}
+	if items.quizzes, err = uc.Repo.GetQuizzesByCourseID(courseID); err != nil {
+		return items, err
+	}
+	items.assignments, err = uc.Repo.GetAssignmentsByCourseID(courseID)
+	return items, err
+}
+
+// gradebookRecords is the work of the students of a course, read once for the whole gradebook
+type gradebookRecords struct {
+	students    map[uint]entities.User // by user ID
+	progress    map[uint][]entities.Progress
+	attempts    map[uint][]entities.QuizAttempt
+	submissions map[uint][]entities.Submission
+	lessons     map[uint][]entities.Lesson // by course version
+}
+
+// gradebookRecords reads the work of the enrollments of a course, of one enrollment when enrollmentID is not 0
+func (uc *CourseUseCase) gradebookRecords(courseID, enrollmentID int) (gradebookRecords, error) {
+	records := gradebookRecords{
+		students:    map[uint]entities.User{},
+		progress:    map[uint][]entities.Progress{},
+		attempts:    map[uint][]entities.QuizAttempt{},
+		submissions: map[uint][]entities.Submission{},
+		lessons:     map[uint][]entities.Lesson{},
+	}
+	progresses, err := uc.Repo.GetCourseProgress(courseID, enrollmentID)
+	if err != nil {
+		return records, err
+	}
+	for _, progress := range progresses {
+		records.progress[progress.EnrollmentID] = append(records.progress[progress.EnrollmentID], progress)
+	}
+	attempts, err := uc.Repo.GetCourseQuizAttempts(courseID, enrollmentID)
+	if err != nil {
+		return records, err
+	}
+	for _, attempt := range attempts {
+		records.attempts[attempt.EnrollmentID] = append(records.attempts[attempt.EnrollmentID], attempt)
+	}
+	submissions, err := uc.Repo.GetCourseSubmissions(courseID, enrollmentID)
+	if err != nil {
+		return records, err
+	}
+	for _, submission := range submissions {
+		records.submissions[submission.EnrollmentID] = append(records.submissions[submission.EnrollmentID], submission)
+	}
+	return records, nil
+}
+
+// versionLessons reads the lessons of a course version once for every enrollment pinned to it
+func (uc *CourseUseCase) versionLessons(records gradebookRecords, enrollment entities.Enrollment) ([]entities.Lesson, error) {
+	if lessons, ok := records.lessons[enrollment.CourseVersion]; ok {
+		return lessons, nil
+	}
+	lessons, err := uc.enrollmentLessons(enrollment)
+	if err != nil {
+		return nil, err
+	}
+	records.lessons[enrollment.CourseVersion] = lessons
+	return lessons, nil
+}
+
+// columns lists lessons first, then quizzes, then assignments
+func (items gradebookItems) columns() []entities.GradebookColumn {
+	columns := []entities.GradebookColumn{}
+	for _, lesson := range items.lessons {
+		columns = append(columns, entities.GradebookColumn{Type: entities.GradeLesson, ID: lesson.ID, Title: lesson.Title})
+	}
+	for _, quiz := range items.quizzes {
+		columns = append(columns, entities.GradebookColumn{Type: entities.GradeQuiz, ID: quiz.ID, Title: quiz.Title})
+	}
+	for _, assignment := range items.assignments {
+		columns = append(columns, entities.GradebookColumn{Type: entities.GradeAssignment, ID: assignment.ID, Title: assignment.Title, MaxPoints: assignment.MaxPoints})
+	}
+	return columns
+}
+
+// studentGradebook grades an enrollment: lessons by completion of its course version, quizzes by
+// their best attempt and assignments by their latest submission, missing work counts as 0
+func (uc *CourseUseCase) studentGradebook(enrollment entities.Enrollment, items gradebookItems, records gradebookRecords) (entities.StudentGradebook, error) {
+	student := entities.StudentGradebook{Weights: items.weights}
+	student.EnrollmentID = enrollment.ID
+	student.UserID = enrollment.UserID
+	if user, ok := records.students[enrollment.UserID]; ok {
+		student.StudentName = strings.TrimSpace(user.FirstName + " " + user.LastName)
+		student.Email = user.Email
+	}
+
+	lessons, err := uc.versionLessons(records, enrollment)
+	if err != nil {
+		return entities.StudentGradebook{}, err
+	}
+	student.Lessons = progressOfLessons(enrollment, lessons, records.progress[enrollment.ID])
+	student.Quizzes = gradeQuizzes(items.quizzes, records.attempts[enrollment.ID], time.Now())
+	student.Assignments = gradeAssignments(items.assignments, records.submissions[enrollment.ID])
+
+	completed := map[uint]bool{}
+	for _, lesson := range student.Lessons {
+		completed[lesson.LessonID] = lesson.Completed
+	}
+	student.Cells = []*float64{}
+	for _, lesson := range items.lessons {
+		done, ok := completed[lesson.ID]
+		switch {
+		case !ok:
+			student.Cells = append(student.Cells, nil)
+		case done:
+			student.Cells = append(student.Cells, percentPtr(100))
+		default:
+			student.Cells = append(student.Cells, percentPtr(0))
+		}
+	}
+	student.LessonsPercent = percentComplete(len(student.Lessons), countCompleted(student.Lessons))
+
+	quizTotal := 0.0
+	for _, grade := range student.Quizzes {
+		if grade.BestScore == nil {
+			student.Cells = append(student.Cells, nil)
+			continue
+		}
+		student.Cells = append(student.Cells, percentPtr(*grade.BestScore))
+		quizTotal += *grade.BestScore
+	}
+	if len(student.Quizzes) > 0 {
+		student.QuizzesPercent = roundPercent(quizTotal / float64(len(student.Quizzes)))
+	}
+
+	earned, possible := 0.0, 0.0
+	for _, grade := range student.Assignments {
+		possible += grade.MaxPoints
+		if grade.Score == nil || grade.MaxPoints == 0 {
+			student.Cells = append(student.Cells, nil)
+			continue
+		}
+		student.Cells = append(student.Cells, percentPtr(*grade.Score*100/grade.MaxPoints))
+		earned += *grade.Score
+	}
+	if possible > 0 {
+		student.AssignmentsPercent = roundPercent(earned * 100 / possible)
+	}
+
+	// categories without items do not count
+	weighted, weights := 0.0, 0.0
+	if len(student.Lessons) > 0 {
+		weighted += items.weights.Lessons * student.LessonsPercent
+		weights += items.weights.Lessons
+	}
+	if len(student.Quizzes) > 0 {
+		weighted += items.weights.Quizzes * student.QuizzesPercent
+		weights += items.weights.Quizzes
+	}
+	if len(student.Assignments) > 0 {
+		weighted += items.weights.Assignments * student.AssignmentsPercent
+		weights += items.weights.Assignments
+	}
+	if weights > 0 {
+		student.Total = roundPercent(weighted / weights)
+	}
+	return student, nil
+}
+
+func roundPercent(percent float64) float64 {
+	return math.Round(percent*100) / 100
+}
+
+func percentPtr(percent float64) *float64 {
+	rounded := roundPercent(percent)
+	return &rounded
+}
@@ -0,0 +1,314 @@
+package usecases
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+// InvoiceSettings is what invoices say about the seller and the tax included in course prices
+type InvoiceSettings struct {
+	Seller  entities.InvoiceParty
+	TaxName string  // e.g. VAT
+	TaxRate float64 // percent included in prices, 0 when no tax is charged
+}
+
+// InvoiceRenderer draws an invoice as a document
+type InvoiceRenderer interface {
+	PDF(invoice entities.Invoice) ([]byte, error)
+	HTML(invoice entities.Invoice) ([]byte, error)
+}
+
+// invoice document formats
+const (
+	InvoicePDF  = "pdf"
+	InvoiceHTML = "html"
+)
+
+//----------------------------------------------------------------invoices----------------------------------------------------------------
+
+// IssueInvoice issues the invoice of a paid order, or returns the one already issued. Invoices are
+// issued when an order is paid, this covers orders paid before that or when it failed.
+func (uc *CourseUseCase) IssueInvoice(orderID int, actor entities.User) (entities.Invoice, error) {
+	order, err := uc.GetOrder(orderID, actor)
+	if err != nil {
+		return entities.Invoice{}, err
+	}
+	if order.PaidAt == nil {
+		return entities.Invoice{}, fmt.Errorf("order %d is %s, only paid orders are invoiced", order.ID, order.Status)
+	}
+	return uc.issueInvoice(order)
+}
+
+// GetInvoice returns an invoice to its buyer or an admin
+func (uc *CourseUseCase) GetInvoice(id int, actor entities.User) (entities.Invoice, error) {
+	invoice, err := uc.Repo.GetInvoiceByID(id)
+	if err != nil {
+		return entities.Invoice{}, notFound(err)
+	}
+	if invoice.UserID != actor.ID && actor.Role != "admin" {
+		return entities.Invoice{}, fmt.Errorf("%w: invoice belongs to another user", ErrForbidden)
+	}
+	return invoice, nil
+}
+
+// GetUserInvoices returns the invoices of a user to that user or an admin, newest first
+func (uc *CourseUseCase) GetUserInvoices(userID int, actor entities.User) ([]entities.Invoice, error) {
+	if uint(userID) != actor.ID && actor.Role != "admin" {
+		return nil, fmt.Errorf("%w: only the user and admins can see their invoices", ErrForbidden)
+	}
+	if _, err := uc.Repo.GetUserByID(userID, true); err != nil {
+		return nil, notFound(err)
+	}
+	return uc.Repo.GetInvoicesByUserID(userID)
+}
+
+// RenderInvoice draws an invoice as a PDF or HTML document for its buyer or an admin
+func (uc *CourseUseCase) RenderInvoice(id int, format string, actor entities.User) (entities.Invoice, []byte, error) {
+	invoice, err := uc.GetInvoice(id, actor)
+	if err != nil {
+		return entities.Invoice{}, nil, err
+	}
+	document, err := uc.renderInvoice(invoice, format)
+	if err != nil {
+		return entities.Invoice{}, nil, err
+	}
+	return invoice, document, nil
+}
+
+// EmailInvoice sends an invoice to its buyer again
+func (uc *CourseUseCase) EmailInvoice(id int, actor entities.User) (entities.Invoice, error) {
+	invoice, err := uc.GetInvoice(id, actor)
+	if err != nil {
+		return entities.Invoice{}, err
+	}
+	return uc.emailInvoice(invoice)
+}
+
+// issueInvoice makes out the invoice of a paid order to the buyer's current billing details and
+// emails it, it returns the existing invoice when there is one
+func (uc *CourseUseCase) issueInvoice(order entities.Order) (entities.Invoice, error) {
+	if existing, err := uc.Repo.GetInvoiceByOrderID(int(order.ID)); err == nil {
+		return existing, nil
+	}
+	buyer, err := uc.invoiceBuyer(int(order.UserID))
+	if err != nil {
+		return entities.Invoice{}, err
+	}
+
+	invoice := entities.Invoice{
+		OrderID:       order.ID,
+		UserID:        order.UserID,
+		Currency:      order.Currency,
+		Seller:        uc.Invoicing.Seller,
+		Buyer:         buyer,
+		Lines:         []entities.InvoiceLine{},
+		Taxes:         []entities.TaxLine{},
+		Subtotal:      entities.NewMoney(0, order.Currency),
+		Tax:           entities.NewMoney(0, order.Currency),
+		Total:         entities.NewMoney(0, order.Currency),
+		PaymentMethod: order.PaymentMethod,
+		PaymentID:     order.PaymentID,
+		PaidAt:        *order.PaidAt,
+		IssuedAt:      time.Now().UTC(),
+	}
+	for _, item := range order.Items {
+		paid := item.Price.Sub(item.Discount)
+		tax := includedTax(paid, uc.Invoicing.TaxRate)
+		invoice.Lines = append(invoice.Lines, entities.InvoiceLine{
+			CourseID:    item.CourseID,
+			Description: item.Title,
+			Price:       item.Price,
+			Discount:    item.Discount,
+			Net:         paid.Sub(tax),
+			TaxRate:     uc.Invoicing.TaxRate,
+			Tax:         tax,
+			Total:       paid,
+		})
+		invoice.Subtotal = invoice.Subtotal.Add(paid.Sub(tax))
+		invoice.Tax = invoice.Tax.Add(tax)
+		invoice.Total = invoice.Total.Add(paid)
+	}
+	if uc.Invoicing.TaxRate > 0 {
+		invoice.Taxes = append(invoice.Taxes, entities.TaxLine{
+			Name: uc.Invoicing.TaxName,
+			Rate: uc.Invoicing.TaxRate,
+			Net:  invoice.Subtotal,
+			Tax:  invoice.Tax,
+		})
+	}
+
+	created, err := atomically(uc, func(uc *CourseUseCase) (entities.Invoice, error) {
+		created, err := uc.Repo.AddInvoice(invoice)
+		if err != nil {
+			return entities.Invoice{}, err
+		}
+		if err := uc.record(entities.AuditCreate, EntityInvoice, created.ID, nil, created); err != nil {
+			return entities.Invoice{}, err
+		}
+		return created, nil
+	})
+	if err != nil {
+		// lost a race against a concurrent issue, the unique index rejected ours
+		if existing, getErr := uc.Repo.GetInvoiceByOrderID(int(order.ID)); getErr == nil {
+			return existing, nil
+		}
+		return entities.Invoice{}, err
+	}
+
+	if uc.Notifier != nil && uc.Invoices != nil {
+		if emailed, err := uc.emailInvoice(created); err != nil {
+			log.Printf("Failed to email invoice %s: %v", created.Number, err)
+		} else {
+			created = emailed
+		}
+	}
+	return created, nil
+}
+
+// issueOrderInvoice issues the invoice of a newly paid order, a follow-up of the payment (see atomically)
+func (uc *CourseUseCase) issueOrderInvoice(order entities.Order) {
+	if _, err := uc.issueInvoice(order); err != nil {
+		log.Printf("Failed to issue the invoice of order %d: %v", order.ID, err)
+	}
+}
+
+// invoiceBuyer returns who an invoice is made out to, the user's billing details when they have any
+func (uc *CourseUseCase) invoiceBuyer(userID int) (entities.InvoiceParty, error) {
+	user, err := uc.Repo.GetUserByID(userID, true)
+	if err != nil {
+		return entities.InvoiceParty{}, notFound(err)
+	}
+	buyer := entities.InvoiceParty{
+		Name:  strings.TrimSpace(user.FirstName + " " + user.LastName),
+		Email: user.Email,
+	}
+	details, err := uc.Repo.GetBillingDetails(userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return buyer, nil
+	}
+	if err != nil {
+		return entities.InvoiceParty{}, err
+	}
+	buyer.Company = details.Company
+	buyer.Address = details.Address
+	buyer.TaxID = details.TaxID
+	if details.Email != "" {
+		buyer.Email = details.Email
+	}
+	return buyer, nil
+}
+
+// emailInvoice sends an invoice to the email it was made out to with its PDF attached
+func (uc *CourseUseCase) emailInvoice(invoice entities.Invoice) (entities.Invoice, error) {
+	if uc.Notifier == nil {
+		return entities.Invoice{}, fmt.Errorf("email is not configured")
+	}
+	if invoice.Buyer.Email == "" {
+		return entities.Invoice{}, fmt.Errorf("invoice %s has no email to send it to", invoice.Number)
+	}
+	document, err := uc.renderInvoice(invoice, InvoicePDF)
+	if err != nil {
+		return entities.Invoice{}, err
+	}
+	html, err := uc.renderInvoice(invoice, InvoiceHTML)
+	if err != nil {
+		return entities.Invoice{}, err
+	}
+	seller := invoice.Seller.Name
+	if seller == "" {
+		seller = "us"
+	}
+	err = uc.Notifier.Send(Email{
+		To:      invoice.Buyer.Email,
+		Subject: fmt.Sprintf("Invoice %s", invoice.Number),
+		Text: fmt.Sprintf("Hello %s,\n\nThank you for your purchase from %s. Your invoice %s for %s is attached.\n",
+			invoice.Buyer.Name, seller, invoice.Number, invoice.Total),
+		HTML:        string(html),
+		Attachments: []Attachment{{Filename: invoice.Number + ".pdf", ContentType: "application/pdf", Content: document}},
+	})
+	if err != nil {
+		return entities.Invoice{}, err
+	}
+	now := time.Now().UTC()
+	if err := uc.Repo.SetInvoiceEmailed(int(invoice.ID), now); err != nil {
+		return entities.Invoice{}, err
+	}
+	invoice.EmailedAt = &now
+	return invoice, nil
+}
+
+// renderInvoice draws an invoice in one of the invoice document formats
+func (uc *CourseUseCase) renderInvoice(invoice entities.Invoice, format string) ([]byte, error) {
+	if uc.Invoices == nil {
+		return nil, fmt.Errorf("invoice documents are not configured")
+	}
+	switch format {
+	case InvoicePDF:
+		return uc.Invoices.PDF(invoice)
+	case InvoiceHTML:
+		return uc.Invoices.HTML(invoice)
+	}
+	return nil, fmt.Errorf("unknown invoice format %q, use %s or %s", format, InvoicePDF, InvoiceHTML)
+}
+
+// includedTax returns the tax included in an amount at a rate, rounded to the minor unit
+func includedTax(amount entities.Money, rate float64) entities.Money {
+	if rate <= 0 || !amount.IsPositive() {
+		return entities.NewMoney(0, amount.Currency)
+	}
+	r := new(big.Rat).SetFloat64(rate)
+	tax := new(big.Rat).Mul(new(big.Rat).SetInt64(amount.Amount), r)
+	tax.Quo(tax, r.Add(r, big.NewRat(100, 1)))
+	return entities.NewMoney(entities.RoundRat(tax).Int64(), amount.Currency)
+}
+
+//----------------------------------------------------------------billing details----------------------------------------------------------------
+
+// GetBillingDetails returns the billing details of a user to that user or an admin, empty ones
+// when none are set
+func (uc *CourseUseCase) GetBillingDetails(userID int, actor entities.User) (entities.BillingDetails, error) {
+	if uint(userID) != actor.ID && actor.Role != "admin" {
+		return entities.BillingDetails{}, fmt.Errorf("%w: only the user and admins can see their billing details", ErrForbidden)
+	}
+	if _, err := uc.Repo.GetUserByID(userID, false); err != nil {
+		return entities.BillingDetails{}, notFound(err)
+	}
+	details, err := uc.Repo.GetBillingDetails(userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entities.BillingDetails{UserID: uint(userID)}, nil
+	}
+	return details, err
+}
+
+// SetBillingDetails replaces the details printed on the user's future invoices, invoices already
+// issued keep the details they were made out to
+func (uc *CourseUseCase) SetBillingDetails(details entities.BillingDetails, actor entities.User) (entities.BillingDetails, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.BillingDetails, error) {
+		before, err := uc.GetBillingDetails(int(details.UserID), actor)
+		if err != nil {
+			return entities.BillingDetails{}, err
+		}
+		details.Company = strings.TrimSpace(details.Company)
+		details.Address = strings.TrimSpace(details.Address)
+		details.TaxID = strings.TrimSpace(details.TaxID)
+		details.Email = strings.TrimSpace(details.Email)
+		if details.Email != "" && !strings.Contains(details.Email, "@") {
+			return entities.BillingDetails{}, fmt.Errorf("invalid billing email %q", details.Email)
+		}
+		details.UpdatedAt = time.Now().UTC()
+		if err := uc.Repo.SetBillingDetails(details); err != nil {
+			return entities.BillingDetails{}, err
+		}
+		if err := uc.record(entities.AuditUpdate, EntityBilling, details.UserID, before, details); err != nil {
+			return entities.BillingDetails{}, err
+		}
+		return details, nil
+	})
+}
@@ -0,0 +1,199 @@
+package usecases
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+// ReviewFlagThreshold is the number of user flags that takes an approved review off the course page
+const ReviewFlagThreshold = 3
+
+// content filter verdicts
+const (
+	FilterAllow  = "allow"  // the review waits for an admin as usual
+	FilterFlag   = "flag"   // the review goes to the moderation queue marked as suspicious
+	FilterReject = "reject" // the review is rejected without an admin
+)
+
+// FilterResult is the verdict of a ContentFilter on a piece of text
+type FilterResult struct {
+	Verdict string
+	Reason  string
+}
+
+// ContentFilter screens review text before it reaches the moderation queue
+type ContentFilter interface {
+	Check(text string) FilterResult
+}
+
+// transitions lists the states each state can move to, from -> to
+type transitions map[string][]string
+
+func (t transitions) allows(from, to string) bool {
+	for _, allowed := range t[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// allowed moderation transitions
+var reviewTransitions = transitions{
+	entities.ReviewPending:  {entities.ReviewApproved, entities.ReviewRejected},
+	entities.ReviewApproved: {entities.ReviewFlagged, entities.ReviewRejected},
+	entities.ReviewFlagged:  {entities.ReviewApproved, entities.ReviewRejected},
+	entities.ReviewRejected: {entities.ReviewApproved},
+}
+
+// screen puts a new or edited review back into moderation, letting the content filter
+// reject or flag it straight away
+func (uc *CourseUseCase) screen(review *entities.Review) {
+	review.Status = entities.ReviewPending
+	review.ModerationNote = ""
+	if uc.Filter == nil {
+		return
+	}
+	result := uc.Filter.Check(review.Comment)
+	switch result.Verdict {
+	case FilterReject:
+		review.Status = entities.ReviewRejected
+		review.ModerationNote = result.Reason
+	case FilterFlag:
+		review.Status = entities.ReviewFlagged
+		review.ModerationNote = result.Reason
+	}
+}
+
+// ModerationQueue returns reviews waiting for an admin, pending and flagged by default
+func (uc *CourseUseCase) ModerationQueue(statuses []string) ([]entities.Review, error) {
+	if len(statuses) == 0 {
+		statuses = []string{entities.ReviewPending, entities.ReviewFlagged}
+	}
+	for _, status := range statuses {
+		if _, ok := reviewTransitions[status]; !ok {
+			return nil, fmt.Errorf("unknown review status %q", status)
+		}
+	}
+	return uc.Repo.GetReviewsByStatus(statuses)
+}
+
+// ModerateReview moves a review to a new moderation state with an optional note
+func (uc *CourseUseCase) ModerateReview(id int, status, note string) (entities.Review, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.Review, error) {
+		before, err := uc.Repo.GetReviewByID(id)
+		if err != nil {
+			return entities.Review{}, notFound(err)
+		}
+		if !reviewTransitions.allows(before.Status, status) {
+			return entities.Review{}, fmt.Errorf("cannot move a review from %s to %s", before.Status, status)
+		}
+
+		review := before
+		review.Status = status
+		review.ModerationNote = note
+		updated, err := uc.Repo.UpdateReview(review)
+		if err != nil {
+			return entities.Review{}, notFound(err)
+		}
+		if err := uc.record(entities.AuditUpdate, EntityReview, updated.ID, before, updated); err != nil {
+			return entities.Review{}, err
+		}
+		// instructors hear of a review once it is published, not while it waits for moderation
+		if before.Status == entities.ReviewPending && updated.Status == entities.ReviewApproved {
+			if err := uc.notifyReview(updated); err != nil {
+				return entities.Review{}, err
+			}
+		}
+		return updated, nil
+	})
+}
+
+// FlagReview records a user reporting a review. Once ReviewFlagThreshold users flagged an
+// approved review it is hidden until an admin looks at it.
+func (uc *CourseUseCase) FlagReview(reviewID int, userID uint, reason string) (entities.ReviewFlag, error) {
+	review, err := uc.Repo.GetReviewByID(reviewID)
+	if err != nil {
+		return entities.ReviewFlag{}, notFound(err)
+	}
+	if existing, err := uc.Repo.GetReviewFlag(reviewID, int(userID)); err == nil {
+		return existing, &ConflictError{Resource: "flag", Existing: existing}
+	}
+
+	flag, err := uc.Repo.AddReviewFlag(entities.ReviewFlag{
+		ReviewID:  review.ID,
+		UserID:    userID,
+		Reason:    reason,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return entities.ReviewFlag{}, err
+	}
+
+	if review.Status != entities.ReviewApproved {
+		return flag, nil
+	}
+	flags, err := uc.Repo.GetReviewFlags(reviewID)
+	if err != nil {
+		return flag, err
+	}
+	if len(flags) >= ReviewFlagThreshold {
+		note := fmt.Sprintf("flagged by %d users", len(flags))
+		if _, err := uc.ModerateReview(reviewID, entities.ReviewFlagged, note); err != nil {
+			return flag, err
+		}
+	}
+	return flag, nil
+}
+
+// GetReviewFlags returns every flag raised on a review
+func (uc *CourseUseCase) GetReviewFlags(reviewID int) ([]entities.ReviewFlag, error) {
+	if _, err := uc.Repo.GetReviewByID(reviewID); err != nil {
+		return nil, notFound(err)
+	}
+	return uc.Repo.GetReviewFlags(reviewID)
+}
+
+// ReplyToReview sets the reply of the course instructor on a review, replacing any earlier reply
+func (uc *CourseUseCase) ReplyToReview(reviewID int, author entities.User, body string) (entities.Review, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.Review, error) {
+		if strings.TrimSpace(body) == "" {
+			return entities.Review{}, fmt.Errorf("reply must not be empty")
+		}
+		before, err := uc.Repo.GetReviewByID(reviewID)
+		if err != nil {
+			return entities.Review{}, notFound(err)
+		}
+		course, err := uc.Repo.GetCourseByID(int(before.CourseID), true)
+		if err != nil {
+			return entities.Review{}, notFound(err)
+		}
+		if !isCourseInstructor(author, course) {
+			return entities.Review{}, fmt.Errorf("%w: only the course instructor can reply to its reviews", ErrForbidden)
+		}
+
+		reply := entities.ReviewReply{UserID: author.ID, Body: body, CreatedAt: time.Now().UTC()}
+		if err := uc.Repo.SetReviewReply(reviewID, reply); err != nil {
+			return entities.Review{}, notFound(err)
+		}
+		updated := before
+		updated.Reply = &reply
+		if err := uc.record(entities.AuditUpdate, EntityReview, updated.ID, before, updated); err != nil {
+			return entities.Review{}, err
+		}
+		return updated, nil
+	})
+}
+
+// isCourseInstructor reports whether a user teaches a course. Courses store the instructor's
+// name, so instructors are matched by name; admins act as the instructor of every course.
+func isCourseInstructor(user entities.User, course entities.Course) bool {
+	if user.Role == "admin" {
+		return true
+	}
+	name := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	return user.Role == "instructor" && strings.EqualFold(name, strings.TrimSpace(course.Instructor))
+}
@@ -0,0 +1,250 @@
+package usecases
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+// Notifier delivers emails
+type Notifier interface {
+	Send(email Email) error
+}
+
+// Email is a message to one recipient, with a plain text body and an optional HTML one
+type Email struct {
+	To          string
+	Subject     string
+	Text        string
+	HTML        string
+	Attachments []Attachment
+}
+
+// Attachment is a file sent with an email
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Content     []byte
+}
+
+// EmailTemplates words the notification emails, one template per notification kind
+type EmailTemplates interface {
+	Render(kind string, data NotificationData) (Email, error)
+}
+
+// NotificationData is what a notification template can show, the fields a kind does not use are empty
+type NotificationData struct {
+	User         entities.User // the recipient
+	Course       entities.Course
+	Enrollment   entities.Enrollment
+	Review       entities.Review
+	Reviewer     entities.User
+	Organization entities.Organization
+	Invitation   entities.OrganizationInvitation
+	Inviter      entities.User
+}
+
+// outbox delivery: a failed email is retried after outboxRetryDelay, doubling every attempt,
+// until it failed maxOutboxAttempts times
+const (
+	maxOutboxAttempts = 5
+	outboxRetryDelay  = time.Minute
+	outboxBatchSize   = 50
+)
+
+//----------------------------------------------------------------notifications----------------------------------------------------------------
+
+// notify queues a notification for a user in the outbox unless they turned its kind off. Like
+// record it is called inside atomically, a failure rolls back the mutation it tells about.
+func (uc *CourseUseCase) notify(kind string, data NotificationData) error {
+	if uc.Templates == nil || data.User.Email == "" {
+		return nil
+	}
+	preferences, err := uc.notificationPreferences(int(data.User.ID))
+	if err != nil {
+		return fmt.Errorf("failed to read the notification preferences of user %d: %w", data.User.ID, err)
+	}
+	if !preferences.Wants(kind) {
+		return nil
+	}
+	email, err := uc.Templates.Render(kind, data)
+	if err != nil {
+		return fmt.Errorf("failed to render the %s email of user %d: %w", kind, data.User.ID, err)
+	}
+	now := time.Now().UTC()
+	_, err = uc.Repo.AddOutboxEmail(entities.OutboxEmail{
+		Kind:          kind,
+		UserID:        data.User.ID,
+		To:            data.User.Email,
+		Subject:       email.Subject,
+		Text:          email.Text,
+		HTML:          email.HTML,
+		Status:        entities.OutboxPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to queue the %s email of user %d: %w", kind, data.User.ID, err)
+	}
+	return nil
+}
+
+// notifyEnrollment confirms a new enrollment to its student
+func (uc *CourseUseCase) notifyEnrollment(enrollment entities.Enrollment) error {
+	return uc.notifyStudent(entities.NotificationEnrollment, enrollment)
+}
+
+// notifyCompletion congratulates the student of a newly completed enrollment
+func (uc *CourseUseCase) notifyCompletion(enrollment entities.Enrollment) error {
+	return uc.notifyStudent(entities.NotificationCompletion, enrollment)
+}
+
+func (uc *CourseUseCase) notifyStudent(kind string, enrollment entities.Enrollment) error {
+	if uc.Templates == nil {
+		return nil
+	}
+	user, err := uc.Repo.GetUserByID(int(enrollment.UserID), false)
+	if err != nil {
+		return nil
+	}
+	course, err := uc.Repo.GetCourseByID(int(enrollment.CourseID), true)
+	if err != nil {
+		return nil
+	}
+	return uc.notify(kind, NotificationData{User: user, Course: course, Enrollment: enrollment})
+}
+
+// notifyReview tells the instructors of a course that a review of it was published
+func (uc *CourseUseCase) notifyReview(review entities.Review) error {
+	if uc.Templates == nil {
+		return nil
+	}
+	course, err := uc.Repo.GetCourseByID(int(review.CourseID), true)
+	if err != nil {
+		return nil
+	}
+	reviewer, err := uc.Repo.GetUserByID(int(review.UserID), true)
+	if err != nil {
+		return nil
+	}
+	instructors, err := uc.Repo.GetInstructorsByName(course.Instructor)
+	if err != nil {
+		return fmt.Errorf("failed to find the instructors of course %d: %w", course.ID, err)
+	}
+	for _, instructor := range instructors {
+		if err := uc.notify(entities.NotificationReview, NotificationData{User: instructor, Course: course, Review: review, Reviewer: reviewer}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//----------------------------------------------------------------outbox----------------------------------------------------------------
+
+// DeliverOutbox sends the outbox emails that are due and returns how many were sent. Emails that
+// fail are retried later and given up on after maxOutboxAttempts. An email is sent again when
+// the server stops between sending it and recording that it was sent.
+func (uc *CourseUseCase) DeliverOutbox() (int, error) {
+	if uc.Notifier == nil {
+		return 0, nil
+	}
+	emails, err := uc.Repo.GetDueOutboxEmails(time.Now().UTC(), outboxBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	for _, email := range emails {
+		err := uc.Notifier.Send(Email{To: email.To, Subject: email.Subject, Text: email.Text, HTML: email.HTML})
+		now := time.Now().UTC()
+		email.Attempts++
+		if err != nil {
+			email.LastError = err.Error()
+			email.NextAttemptAt = now.Add(outboxRetryDelay << (email.Attempts - 1))
+			if email.Attempts >= maxOutboxAttempts {
+				email.Status = entities.OutboxFailed
+			}
+			log.Printf("Failed to send email %d to %s (attempt %d): %v", email.ID, email.To, email.Attempts, err)
+		} else {
+			email.Status = entities.OutboxSent
+			email.LastError = ""
+			email.SentAt = &now
+			sent++
+		}
+		if err := uc.Repo.UpdateOutboxEmail(email); err != nil {
+			return sent, err
+		}
+	}
+	return sent, nil
+}
+
+// GetOutbox lists the latest outbox emails, only those with a status when it is set
+func (uc *CourseUseCase) GetOutbox(status string) ([]entities.OutboxEmail, error) {
+	switch status {
+	case "", entities.OutboxPending, entities.OutboxSent, entities.OutboxFailed:
+	default:
+		return nil, fmt.Errorf("unknown outbox status %q", status)
+	}
+	return uc.Repo.GetOutboxEmails(status, 100)
+}
+
+// RetryOutboxEmail sends an email that was given up on again, with a fresh set of attempts
+func (uc *CourseUseCase) RetryOutboxEmail(id int) (entities.OutboxEmail, error) {
+	email, err := uc.Repo.GetOutboxEmailByID(id)
+	if err != nil {
+		return entities.OutboxEmail{}, notFound(err)
+	}
+	if email.Status != entities.OutboxFailed {
+		return entities.OutboxEmail{}, fmt.Errorf("only failed emails can be retried, email %d is %s", id, email.Status)
+	}
+	email.Status = entities.OutboxPending
+	email.Attempts = 0
+	email.NextAttemptAt = time.Now().UTC()
+	if err := uc.Repo.UpdateOutboxEmail(email); err != nil {
+		return entities.OutboxEmail{}, err
+	}
+	return email, nil
+}
+
+//----------------------------------------------------------------notification preferences----------------------------------------------------------------
+
+// notificationPreferences returns the preferences of a user, every notification when they set none
+func (uc *CourseUseCase) notificationPreferences(userID int) (entities.NotificationPreferences, error) {
+	preferences, err := uc.Repo.GetNotificationPreferences(userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entities.NotificationPreferences{UserID: uint(userID), Enrollment: true, Completion: true, Reviews: true}, nil
+	}
+	return preferences, err
+}
+
+// GetNotificationPreferences returns the notifications a user wants to that user or an admin
+func (uc *CourseUseCase) GetNotificationPreferences(userID int, actor entities.User) (entities.NotificationPreferences, error) {
+	if uint(userID) != actor.ID && actor.Role != "admin" {
+		return entities.NotificationPreferences{}, fmt.Errorf("%w: only the user and admins can see their notification preferences", ErrForbidden)
+	}
+	if _, err := uc.Repo.GetUserByID(userID, false); err != nil {
+		return entities.NotificationPreferences{}, notFound(err)
+	}
+	return uc.notificationPreferences(userID)
+}
+
+// SetNotificationPreferences replaces the notifications a user wants, emails already in the
+// outbox are still sent
+func (uc *CourseUseCase) SetNotificationPreferences(preferences entities.NotificationPreferences, actor entities.User) (entities.NotificationPreferences, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.NotificationPreferences, error) {
+		before, err := uc.GetNotificationPreferences(int(preferences.UserID), actor)
+		if err != nil {
+			return entities.NotificationPreferences{}, err
+		}
+		now := time.Now().UTC()
+		preferences.UpdatedAt = &now
+		if err := uc.Repo.SetNotificationPreferences(preferences); err != nil {
+			return entities.NotificationPreferences{}, err
+		}
+		uc.record(entities.AuditUpdate, EntityPreferences, preferences.UserID, before, preferences)
+		return preferences, nil
+	})
+}
@@ -0,0 +1,413 @@
+package usecases
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+// PaymentGateway charges orders and reports the outcome of payments that complete later
+type PaymentGateway interface {
+	// Charge starts a payment, it returns an error when the payment could not be attempted at all
+	Charge(payment PaymentRequest) (PaymentResult, error)
+	// ParseWebhook checks the signature of a webhook call and decodes its event
+	ParseWebhook(payload []byte, signature string) (PaymentEvent, error)
+	// Refund pays back part or all of a successful payment and returns the gateway's reference for it
+	Refund(refund RefundRequest) (string, error)
+}
+
+// payment statuses reported by a PaymentGateway
+const (
+	PaymentSucceeded      = "succeeded"
+	PaymentDeclined       = "declined"
+	PaymentRequiresAction = "requires_action" // the customer has to act, the outcome arrives by webhook
+)
+
+// PaymentRequest asks the gateway to charge an order or a subscription
+type PaymentRequest struct {
+	OrderID        uint
+	SubscriptionID uint
+	Amount         entities.Money
+	Method         string // a token identifying the customer's payment method
+	OffSession     bool   // the customer is not there to authenticate, methods needing it are declined
+}
+
+// PaymentResult is the gateway's answer to a charge
+type PaymentResult struct {
+	ID            string // the gateway's reference, webhook events carry it
+	Status        string
+	ActionURL     string // set when the status is PaymentRequiresAction
+	FailureReason string // set when the status is PaymentDeclined
+}
+
+// PaymentEvent is a payment outcome delivered by webhook
+type PaymentEvent struct {
+	PaymentID     string `json:"payment_id"`
+	OrderID       uint   `json:"order_id"`
+	Status        string `json:"status"`
+	FailureReason string `json:"failure_reason,omitempty"`
+}
+
+//----------------------------------------------------------------cart----------------------------------------------------------------
+
+// GetCart returns the actor's cart priced in the currency at the courses' current prices, with
+// its coupons applied
+func (uc *CourseUseCase) GetCart(currency string, actor entities.User) (entities.Cart, error) {
+	currency, err := normalizeCurrency(currency)
+	if err != nil {
+		return entities.Cart{}, err
+	}
+	items, err := uc.Repo.GetCartItems(int(actor.ID))
+	if err != nil {
+		return entities.Cart{}, err
+	}
+	if err := uc.priceCartItems(items, currency); err != nil {
+		return entities.Cart{}, err
+	}
+	coupons, warnings, err := uc.cartCoupons(actor.ID, items)
+	if err != nil {
+		return entities.Cart{}, err
+	}
+	cart := entities.Cart{UserID: actor.ID, Currency: currency, Warnings: warnings}
+	cart.Items, cart.Coupons, err = uc.applyCoupons(items, coupons, currency)
+	if err != nil {
+		return entities.Cart{}, err
+	}
+	cart.Subtotal, cart.Discount = entities.NewMoney(0, currency), entities.NewMoney(0, currency)
+	for _, item := range cart.Items {
+		cart.Subtotal = cart.Subtotal.Add(item.Price)
+		cart.Discount = cart.Discount.Add(item.Discount)
+	}
+	cart.Total = cart.Subtotal.Sub(cart.Discount)
+	return cart, nil
+}
+
+// priceCartItems changes the base prices of cart items into their prices in the currency
+func (uc *CourseUseCase) priceCartItems(items []entities.CartItem, currency string) error {
+	for i, item := range items {
+		price, err := uc.coursePrice(item.CourseID, item.Price, currency)
+		if err != nil {
+			return fmt.Errorf("%q cannot be priced in %s: %w", item.Title, currency, err)
+		}
+		items[i].Price = price
+		items[i].Discount = entities.NewMoney(0, currency)
+	}
+	return nil
+}
+
+// AddToCart puts a course the actor can buy in their cart
+func (uc *CourseUseCase) AddToCart(courseID int, currency string, actor entities.User) (entities.Cart, error) {
+	course, err := uc.Repo.GetCourseByID(courseID, false)
+	if err != nil {
+		return entities.Cart{}, notFound(err)
+	}
+	if err := uc.checkPurchasable(course, actor); err != nil {
+		return entities.Cart{}, err
+	}
+	if err := uc.Repo.AddCartItem(int(actor.ID), courseID, time.Now().UTC()); err != nil {
+		return entities.Cart{}, err
+	}
+	return uc.GetCart(currency, actor)
+}
+
+// RemoveFromCart takes a course out of the actor's cart
+func (uc *CourseUseCase) RemoveFromCart(courseID int, currency string, actor entities.User) (entities.Cart, error) {
+	if err := uc.Repo.RemoveCartItem(int(actor.ID), courseID); err != nil {
+		return entities.Cart{}, notFound(err)
+	}
+	return uc.GetCart(currency, actor)
+}
+
+// checkPurchasable rejects courses the user cannot buy: unpublished, free, already enrolled
+// or with required prerequisites left to complete. A course whose enrollment was revoked by a
+// refund can be bought again, and one enrolled in through a subscription can be bought to keep it.
+func (uc *CourseUseCase) checkPurchasable(course entities.Course, user entities.User) error {
+	if !course.Visible(time.Now()) {
+		return fmt.Errorf("%q is not open for enrollment", course.Title)
+	}
+	if !course.Price.IsPositive() {
+		return fmt.Errorf("%q is free, enroll directly", course.Title)
+	}
+	if existing, err := uc.Repo.GetEnrollmentByUserAndCourse(int(user.ID), int(course.ID)); err == nil && !existing.Revoked() && existing.SubscriptionID == nil {
+		return &ConflictError{Resource: "enrollment", Existing: existing}
+	}
+	required, _, err := uc.missingPrerequisites(int(user.ID), int(course.ID))
+	if err != nil {
+		return err
+	}
+	if len(required) > 0 {
+		return fmt.Errorf("%w: complete %s before buying %q", ErrForbidden, prerequisiteTitles(required), course.Title)
+	}
+	return nil
+}
+
+//----------------------------------------------------------------orders----------------------------------------------------------------
+
+// Checkout turns the actor's cart into an order in the currency and charges it. A paid order
+// enrolls the actor in its courses. A declined payment returns the failed order with ErrPaymentDeclined, a payment
+// that requires action returns the open order and completes by webhook. Orders that coupons make
+// free are paid without going through the gateway.
+func (uc *CourseUseCase) Checkout(paymentMethod, currency string, actor entities.User) (entities.Order, error) {
+	currency, err := normalizeCurrency(currency)
+	if err != nil {
+		return entities.Order{}, err
+	}
+	if open, err := uc.Repo.GetOpenOrder(int(actor.ID)); err == nil {
+		return entities.Order{}, &ConflictError{Resource: "order awaiting payment", Existing: open}
+	}
+	items, err := uc.Repo.GetCartItems(int(actor.ID))
+	if err != nil {
+		return entities.Order{}, err
+	}
+	if len(items) == 0 {
+		return entities.Order{}, fmt.Errorf("cart is empty")
+	}
+
+	for i, item := range items {
+		// the cart may have gone stale, check every course again at the price charged now
+		course, err := uc.Repo.GetCourseByID(int(item.CourseID), false)
+		if err != nil {
+			return entities.Order{}, notFound(err)
+		}
+		if err := uc.checkPurchasable(course, actor); err != nil {
+			return entities.Order{}, err
+		}
+		items[i].Title, items[i].Price = course.Title, course.Price
+	}
+	if err := uc.priceCartItems(items, currency); err != nil {
+		return entities.Order{}, err
+	}
+	coupons, warnings, err := uc.cartCoupons(actor.ID, items)
+	if err != nil {
+		return entities.Order{}, err
+	}
+	if len(warnings) > 0 {
+		return entities.Order{}, fmt.Errorf("%s, remove it from the cart", warnings[0])
+	}
+
+	order := entities.Order{
+		UserID:        actor.ID,
+		Status:        entities.OrderPending,
+		Currency:      currency,
+		Subtotal:      entities.NewMoney(0, currency),
+		Discount:      entities.NewMoney(0, currency),
+		PaymentMethod: paymentMethod,
+		CreatedAt:     time.Now().UTC(),
+	}
+	items, order.Coupons, err = uc.applyCoupons(items, coupons, currency)
+	if err != nil {
+		return entities.Order{}, err
+	}
+	for _, item := range items {
+		order.Items = append(order.Items, entities.OrderItem{CourseID: item.CourseID, Title: item.Title, Price: item.Price, Discount: item.Discount})
+		order.Subtotal = order.Subtotal.Add(item.Price)
+		order.Discount = order.Discount.Add(item.Discount)
+	}
+	order.Total = order.Subtotal.Sub(order.Discount)
+	if order.Total.IsPositive() {
+		if uc.Payments == nil {
+			return entities.Order{}, fmt.Errorf("payments are not configured")
+		}
+		if paymentMethod == "" {
+			return entities.Order{}, fmt.Errorf("payment_method is required")
+		}
+	}
+
+	order, err = atomically(uc, func(uc *CourseUseCase) (entities.Order, error) {
+		created, err := uc.Repo.AddOrder(order)
+		if err != nil {
+			return entities.Order{}, err
+		}
+		if err := uc.record(entities.AuditCreate, EntityOrder, created.ID, nil, created); err != nil {
+			return entities.Order{}, err
+		}
+		return created, nil
+	})
+	if err != nil {
+		return entities.Order{}, err
+	}
+	if order.Total.IsZero() {
+		return uc.fulfilOrder(order, "")
+	}
+
+	result, err := uc.Payments.Charge(PaymentRequest{OrderID: order.ID, Amount: order.Total, Method: paymentMethod})
+	if err != nil {
+		failed := order
+		failed.Status = entities.OrderFailed
+		failed.FailureReason = err.Error()
+		uc.updateOrderPayment(order, failed)
+		return entities.Order{}, fmt.Errorf("payment failed: %w", err)
+	}
+
+	switch result.Status {
+	case PaymentSucceeded:
+		return uc.fulfilOrder(order, result.ID)
+	case PaymentRequiresAction:
+		pending := order
+		pending.Status = entities.OrderRequiresAction
+		pending.PaymentID = result.ID
+		pending.ActionURL = result.ActionURL
+		return uc.updateOrderPayment(order, pending)
+	default:
+		failed := order
+		failed.Status = entities.OrderFailed
+		failed.PaymentID = result.ID
+		failed.FailureReason = result.FailureReason
+		failed, err := uc.updateOrderPayment(order, failed)
+		if err != nil {
+			return entities.Order{}, err
+		}
+		return failed, fmt.Errorf("%w: %s", ErrPaymentDeclined, result.FailureReason)
+	}
+}
+
+// HandlePaymentWebhook applies a payment outcome reported by the gateway. Events for orders
+// that are no longer open are ignored, gateways deliver the same event more than once.
+func (uc *CourseUseCase) HandlePaymentWebhook(payload []byte, signature string) error {
+	if uc.Payments == nil {
+		return fmt.Errorf("payments are not configured")
+	}
+	event, err := uc.Payments.ParseWebhook(payload, signature)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrForbidden, err)
+	}
+	order, err := uc.Repo.GetOrderByID(int(event.OrderID))
+	if err != nil {
+		return notFound(err)
+	}
+	if order.PaymentID != event.PaymentID {
+		return fmt.Errorf("payment %s does not belong to order %d", event.PaymentID, order.ID)
+	}
+	if !order.Open() {
+		if event.Status == PaymentSucceeded && order.Status != entities.OrderPaid {
+			log.Printf("payment %s succeeded for %s order %d", event.PaymentID, order.Status, order.ID)
+		}
+		return nil
+	}
+
+	switch event.Status {
+	case PaymentSucceeded:
+		_, err = uc.fulfilOrder(order, event.PaymentID)
+	case PaymentDeclined:
+		failed := order
+		failed.Status = entities.OrderFailed
+		failed.ActionURL = ""
+		failed.FailureReason = event.FailureReason
+		_, err = uc.updateOrderPayment(order, failed)
+	default:
+		return fmt.Errorf("unknown payment status %q", event.Status)
+	}
+	return err
+}
+
+// GetOrders returns the actor's orders, newest first
+func (uc *CourseUseCase) GetOrders(actor entities.User) ([]entities.Order, error) {
+	return uc.Repo.GetOrdersByUserID(int(actor.ID))
+}
+
+// GetOrder returns an order to its buyer or an admin
+func (uc *CourseUseCase) GetOrder(id int, actor entities.User) (entities.Order, error) {
+	order, err := uc.Repo.GetOrderByID(id)
+	if err != nil {
+		return entities.Order{}, notFound(err)
+	}
+	if order.UserID != actor.ID && actor.Role != "admin" {
+		return entities.Order{}, fmt.Errorf("%w: order belongs to another user", ErrForbidden)
+	}
+	return order, nil
+}
+
+// CancelOrder abandons an order that is still waiting for its payment
+func (uc *CourseUseCase) CancelOrder(id int, actor entities.User) (entities.Order, error) {
+	order, err := uc.GetOrder(id, actor)
+	if err != nil {
+		return entities.Order{}, err
+	}
+	if !order.Open() {
+		return entities.Order{}, fmt.Errorf("order %d is %s and can no longer be cancelled", order.ID, order.Status)
+	}
+	cancelled := order
+	cancelled.Status = entities.OrderCancelled
+	cancelled.ActionURL = ""
+	return uc.updateOrderPayment(order, cancelled)
+}
+
+// updateOrderPayment stores the payment state of an open order
+func (uc *CourseUseCase) updateOrderPayment(before, after entities.Order) (entities.Order, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.Order, error) {
+		if err := uc.Repo.UpdateOrderPayment(after); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return entities.Order{}, fmt.Errorf("order %d is no longer awaiting payment", before.ID)
+			}
+			return entities.Order{}, err
+		}
+		if err := uc.record(entities.AuditUpdate, EntityOrder, after.ID, before, after); err != nil {
+			return entities.Order{}, err
+		}
+		return after, nil
+	})
+}
+
+// fulfilOrder marks an order paid and enrolls its buyer in the courses bought, both happen
+// in one transaction so a paid order never lacks its enrollments. The invoice is issued once
+// they are stored.
+func (uc *CourseUseCase) fulfilOrder(order entities.Order, paymentID string) (entities.Order, error) {
+	fulfilled := false
+	paid, err := atomically(uc, func(uc *CourseUseCase) (entities.Order, error) {
+		now := time.Now().UTC()
+		enrollments := make([]entities.Enrollment, 0, len(order.Items))
+		for _, item := range order.Items {
+			version, err := uc.latestCourseVersion(int(item.CourseID))
+			if err != nil {
+				return entities.Order{}, err
+			}
+			enrollments = append(enrollments, entities.Enrollment{UserID: order.UserID, CourseID: item.CourseID, CourseVersion: version, EnrolledAt: &now})
+		}
+
+		created, err := uc.Repo.FulfilOrder(int(order.ID), paymentID, now, enrollments)
+		if errors.Is(err, sql.ErrNoRows) {
+			// confirmed twice, the first confirmation did the work
+			return uc.Repo.GetOrderByID(int(order.ID))
+		}
+		if err != nil {
+			return entities.Order{}, err
+		}
+		for _, enrollment := range created {
+			if err := uc.record(entities.AuditCreate, EntityEnrollment, enrollment.ID, nil, enrollment); err != nil {
+				return entities.Order{}, err
+			}
+			if err := uc.notifyEnrollment(enrollment); err != nil {
+				return entities.Order{}, err
+			}
+		}
+		paid, err := uc.Repo.GetOrderByID(int(order.ID))
+		if err != nil {
+			return entities.Order{}, err
+		}
+		if err := uc.record(entities.AuditUpdate, EntityOrder, paid.ID, order, paid); err != nil {
+			return entities.Order{}, err
+		}
+		fulfilled = true
+		return paid, nil
+	})
+	if err != nil {
+		return entities.Order{}, err
+	}
+	if fulfilled {
+		uc.issueOrderInvoice(paid)
+	}
+	return paid, nil
+}
+
+// actorIsAdmin reports whether the user making the current request is an admin
+func (uc *CourseUseCase) actorIsAdmin() bool {
+	if uc.request.ActorID == 0 {
+		return false
+	}
+	user, err := uc.Repo.GetUserByID(int(uc.request.ActorID), false)
+	return err == nil && user.Role == "admin"
+}
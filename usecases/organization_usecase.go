@@ -0,0 +1,640 @@
+package usecases
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+// maxSeatsPerPurchase keeps a mistyped seat count from charging a fortune
+const maxSeatsPerPurchase = 10000
+
+//----------------------------------------------------------------organizations----------------------------------------------------------------
+
+// CreateOrganization creates an organization with the actor as its first admin
+func (uc *CourseUseCase) CreateOrganization(organization entities.Organization, actor entities.User) (entities.Organization, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.Organization, error) {
+		organization.Name = strings.TrimSpace(organization.Name)
+		if organization.Name == "" {
+			return entities.Organization{}, fmt.Errorf("name is required")
+		}
+		now := time.Now().UTC()
+		organization.CreatedAt = now
+
+		created, err := uc.Repo.AddOrganization(organization, entities.OrganizationMember{UserID: actor.ID, Role: entities.OrgAdmin, JoinedAt: now})
+		if err != nil {
+			return entities.Organization{}, err
+		}
+		if err := uc.record(entities.AuditCreate, EntityOrganization, created.ID, nil, created); err != nil {
+			return entities.Organization{}, err
+		}
+		return created, nil
+	})
+}
+
+// GetOrganizations returns every organization to admins and the actor's own to anyone else
+func (uc *CourseUseCase) GetOrganizations(actor entities.User) ([]entities.Organization, error) {
+	if actor.Role == "admin" {
+		return uc.Repo.GetOrganizations()
+	}
+	return uc.Repo.GetOrganizationsByUserID(int(actor.ID))
+}
+
+// GetUserOrganizations returns the organizations a user belongs to, to that user or an admin
+func (uc *CourseUseCase) GetUserOrganizations(userID int, actor entities.User) ([]entities.Organization, error) {
+	if uint(userID) != actor.ID && actor.Role != "admin" {
+		return nil, fmt.Errorf("%w: only the user and admins can see their organizations", ErrForbidden)
+	}
+	return uc.Repo.GetOrganizationsByUserID(userID)
+}
+
+// GetOrganization returns an organization to its members and admins
+func (uc *CourseUseCase) GetOrganization(id int, actor entities.User) (entities.Organization, error) {
+	organization, err := uc.Repo.GetOrganizationByID(id)
+	if err != nil {
+		return entities.Organization{}, notFound(err)
+	}
+	if _, err := uc.organizationRole(id, actor); err != nil {
+		return entities.Organization{}, err
+	}
+	return organization, nil
+}
+
+// RenameOrganization changes the name of an organization (organization admins)
+func (uc *CourseUseCase) RenameOrganization(id int, name string, actor entities.User) (entities.Organization, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.Organization, error) {
+		before, err := uc.GetOrganization(id, actor)
+		if err != nil {
+			return entities.Organization{}, err
+		}
+		if err := uc.requireOrganizationAdmin(id, actor); err != nil {
+			return entities.Organization{}, err
+		}
+		after := before
+		after.Name = strings.TrimSpace(name)
+		if after.Name == "" {
+			return entities.Organization{}, fmt.Errorf("name is required")
+		}
+		if err := uc.Repo.UpdateOrganization(after); err != nil {
+			return entities.Organization{}, notFound(err)
+		}
+		if err := uc.record(entities.AuditUpdate, EntityOrganization, after.ID, before, after); err != nil {
+			return entities.Organization{}, err
+		}
+		return after, nil
+	})
+}
+
+// organizationRole returns the role of the actor in an organization, platform admins act as
+// organization admins. Users outside the organization get ErrForbidden.
+func (uc *CourseUseCase) organizationRole(organizationID int, actor entities.User) (string, error) {
+	if actor.Role == "admin" {
+		return entities.OrgAdmin, nil
+	}
+	member, err := uc.Repo.GetOrganizationMember(organizationID, int(actor.ID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("%w: only members of organization %d can see it", ErrForbidden, organizationID)
+	}
+	if err != nil {
+		return "", err
+	}
+	return member.Role, nil
+}
+
+// requireOrganizationAdmin rejects actors who cannot manage an organization
+func (uc *CourseUseCase) requireOrganizationAdmin(organizationID int, actor entities.User) error {
+	role, err := uc.organizationRole(organizationID, actor)
+	if err != nil {
+		return err
+	}
+	if role != entities.OrgAdmin {
+		return fmt.Errorf("%w: only admins of organization %d can manage it", ErrForbidden, organizationID)
+	}
+	return nil
+}
+
+//----------------------------------------------------------------organization members----------------------------------------------------------------
+
+// GetOrganizationMembers returns the members of an organization (organization admins)
+func (uc *CourseUseCase) GetOrganizationMembers(organizationID int, actor entities.User) ([]entities.OrganizationMember, error) {
+	if _, err := uc.GetOrganization(organizationID, actor); err != nil {
+		return nil, err
+	}
+	if err := uc.requireOrganizationAdmin(organizationID, actor); err != nil {
+		return nil, err
+	}
+	return uc.Repo.GetOrganizationMembers(organizationID)
+}
+
+// SetOrganizationMemberRole makes a member an admin or a plain member (organization admins).
+// The last admin of an organization cannot be demoted.
+func (uc *CourseUseCase) SetOrganizationMemberRole(organizationID, userID int, role string, actor entities.User) (entities.OrganizationMember, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.OrganizationMember, error) {
+		if err := validateOrganizationRole(role); err != nil {
+			return entities.OrganizationMember{}, err
+		}
+		if _, err := uc.GetOrganization(organizationID, actor); err != nil {
+			return entities.OrganizationMember{}, err
+		}
+		if err := uc.requireOrganizationAdmin(organizationID, actor); err != nil {
+			return entities.OrganizationMember{}, err
+		}
+		before, err := uc.Repo.GetOrganizationMember(organizationID, userID)
+		if err != nil {
+			return entities.OrganizationMember{}, notFound(err)
+		}
+		if err := uc.Repo.SetOrganizationMemberRole(organizationID, userID, role); errors.Is(err, sql.ErrNoRows) {
+			return entities.OrganizationMember{}, fmt.Errorf("organization %d needs at least one admin", organizationID)
+		} else if err != nil {
+			return entities.OrganizationMember{}, err
+		}
+		after := before
+		after.Role = role
+		if err := uc.record(entities.AuditUpdate, EntityOrgMember, after.OrganizationID, before, after); err != nil {
+			return entities.OrganizationMember{}, err
+		}
+		return after, nil
+	})
+}
+
+// RemoveOrganizationMember takes a user out of an organization, organization admins can remove
+// anyone and members can leave. The seats the member held are given back to the organization: the
+// enrollments made with them are revoked, keeping their progress. It returns the revoked
+// enrollments. The last admin of an organization cannot leave it.
+func (uc *CourseUseCase) RemoveOrganizationMember(organizationID, userID int, actor entities.User) ([]uint, error) {
+	return atomically(uc, func(uc *CourseUseCase) ([]uint, error) {
+		organization, err := uc.GetOrganization(organizationID, actor)
+		if err != nil {
+			return nil, err
+		}
+		if uint(userID) != actor.ID {
+			if err := uc.requireOrganizationAdmin(organizationID, actor); err != nil {
+				return nil, err
+			}
+		}
+		before, err := uc.Repo.GetOrganizationMember(organizationID, userID)
+		if err != nil {
+			return nil, notFound(err)
+		}
+
+		reason := fmt.Sprintf("left organization %q", organization.Name)
+		revoked, err := uc.Repo.RemoveOrganizationMember(organizationID, userID, time.Now().UTC(), reason)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("organization %d needs at least one admin, make someone else an admin first", organizationID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := uc.record(entities.AuditDelete, EntityOrgMember, before.OrganizationID, before, nil); err != nil {
+			return nil, err
+		}
+		for _, id := range revoked {
+			if after, err := uc.Repo.GetEnrollmentByID(int(id)); err == nil {
+				enrollmentBefore := after
+				enrollmentBefore.RevokedAt = nil
+				enrollmentBefore.RevokeReason = ""
+				if err := uc.record(entities.AuditUpdate, EntityEnrollment, after.ID, enrollmentBefore, after); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return revoked, nil
+	})
+}
+
+//----------------------------------------------------------------organization invitations----------------------------------------------------------------
+
+// InviteOrganizationMember invites a user to join an organization as a member or admin
+// (organization admins). The user is emailed and only becomes a member by accepting.
+func (uc *CourseUseCase) InviteOrganizationMember(organizationID int, invitation entities.OrganizationInvitation, actor entities.User) (entities.OrganizationInvitation, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.OrganizationInvitation, error) {
+		organization, err := uc.GetOrganization(organizationID, actor)
+		if err != nil {
+			return entities.OrganizationInvitation{}, err
+		}
+		if err := uc.requireOrganizationAdmin(organizationID, actor); err != nil {
+			return entities.OrganizationInvitation{}, err
+		}
+		if invitation.Role == "" {
+			invitation.Role = entities.OrgMember
+		}
+		if err := validateOrganizationRole(invitation.Role); err != nil {
+			return entities.OrganizationInvitation{}, err
+		}
+		user, err := uc.Repo.GetUserByID(int(invitation.UserID), false)
+		if err != nil {
+			return entities.OrganizationInvitation{}, notFound(err)
+		}
+		if existing, err := uc.Repo.GetOrganizationMember(organizationID, int(invitation.UserID)); err == nil {
+			return entities.OrganizationInvitation{}, &ConflictError{Resource: "organization member", Existing: existing}
+		}
+		if existing, err := uc.Repo.GetPendingOrganizationInvitation(organizationID, int(invitation.UserID)); err == nil {
+			return existing, &ConflictError{Resource: "organization invitation", Existing: existing}
+		}
+
+		invitation.OrganizationID = uint(organizationID)
+		invitation.Status = entities.InvitationPending
+		invitation.InvitedBy = actor.ID
+		invitation.CreatedAt = time.Now().UTC()
+		invitation.RespondedAt = nil
+		created, err := uc.Repo.AddOrganizationInvitation(invitation)
+		if err != nil {
+			return entities.OrganizationInvitation{}, err
+		}
+		if err := uc.record(entities.AuditCreate, EntityInvitation, created.ID, nil, created); err != nil {
+			return entities.OrganizationInvitation{}, err
+		}
+		if err := uc.notify(entities.NotificationInvitation, NotificationData{User: user, Organization: organization, Invitation: created, Inviter: actor}); err != nil {
+			return entities.OrganizationInvitation{}, err
+		}
+		return created, nil
+	})
+}
+
+// GetOrganizationInvitations returns the invitations of an organization, newest first (organization admins)
+func (uc *CourseUseCase) GetOrganizationInvitations(organizationID int, actor entities.User) ([]entities.OrganizationInvitation, error) {
+	if err := uc.requireOrganizationAdmin(organizationID, actor); err != nil {
+		return nil, err
+	}
+	return uc.Repo.GetOrganizationInvitations(organizationID)
+}
+
+// GetUserInvitations returns the invitations of a user with a status, or all of them, to that
+// user or an admin
+func (uc *CourseUseCase) GetUserInvitations(userID int, status string, actor entities.User) ([]entities.OrganizationInvitation, error) {
+	if uint(userID) != actor.ID && actor.Role != "admin" {
+		return nil, fmt.Errorf("%w: only the user and admins can see their invitations", ErrForbidden)
+	}
+	return uc.Repo.GetUserOrganizationInvitations(userID, status)
+}
+
+// AcceptOrganizationInvitation makes the invited user a member of the organization with the role
+// they were invited as (the invited user)
+func (uc *CourseUseCase) AcceptOrganizationInvitation(id int, actor entities.User) (entities.OrganizationMember, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.OrganizationMember, error) {
+		invitation, err := uc.answerInvitation(id, entities.InvitationAccepted, actor)
+		if err != nil {
+			return entities.OrganizationMember{}, err
+		}
+		member := entities.OrganizationMember{
+			OrganizationID: invitation.OrganizationID,
+			UserID:         invitation.UserID,
+			Role:           invitation.Role,
+			JoinedAt:       *invitation.RespondedAt,
+		}
+		if err := uc.Repo.AddOrganizationMember(member); err != nil {
+			if existing, getErr := uc.Repo.GetOrganizationMember(int(member.OrganizationID), int(member.UserID)); getErr == nil {
+				return existing, &ConflictError{Resource: "organization member", Existing: existing}
+			}
+			return entities.OrganizationMember{}, err
+		}
+		added, err := uc.Repo.GetOrganizationMember(int(member.OrganizationID), int(member.UserID))
+		if err != nil {
+			return entities.OrganizationMember{}, err
+		}
+		if err := uc.record(entities.AuditCreate, EntityOrgMember, added.OrganizationID, nil, added); err != nil {
+			return entities.OrganizationMember{}, err
+		}
+		return added, nil
+	})
+}
+
+// DeclineOrganizationInvitation turns an invitation down (the invited user)
+func (uc *CourseUseCase) DeclineOrganizationInvitation(id int, actor entities.User) (entities.OrganizationInvitation, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.OrganizationInvitation, error) {
+		return uc.answerInvitation(id, entities.InvitationDeclined, actor)
+	})
+}
+
+// CancelOrganizationInvitation withdraws a pending invitation (organization admins)
+func (uc *CourseUseCase) CancelOrganizationInvitation(id int, actor entities.User) (entities.OrganizationInvitation, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.OrganizationInvitation, error) {
+		return uc.answerInvitation(id, entities.InvitationCancelled, actor)
+	})
+}
+
+// answerInvitation moves a pending invitation to a status. Only the invited user accepts or
+// declines it, only the organization's admins cancel it.
+func (uc *CourseUseCase) answerInvitation(id int, status string, actor entities.User) (entities.OrganizationInvitation, error) {
+	before, err := uc.Repo.GetOrganizationInvitationByID(id)
+	if err != nil {
+		return entities.OrganizationInvitation{}, notFound(err)
+	}
+	if status == entities.InvitationCancelled {
+		if err := uc.requireOrganizationAdmin(int(before.OrganizationID), actor); err != nil {
+			return entities.OrganizationInvitation{}, err
+		}
+	} else if before.UserID != actor.ID {
+		return entities.OrganizationInvitation{}, fmt.Errorf("%w: only the invited user can answer an invitation", ErrForbidden)
+	}
+	if before.Status != entities.InvitationPending {
+		return entities.OrganizationInvitation{}, fmt.Errorf("invitation %d is %s", before.ID, before.Status)
+	}
+
+	now := time.Now().UTC()
+	if err := uc.Repo.SetOrganizationInvitationStatus(id, status, now); errors.Is(err, sql.ErrNoRows) {
+		return entities.OrganizationInvitation{}, fmt.Errorf("invitation %d has already been answered", before.ID)
+	} else if err != nil {
+		return entities.OrganizationInvitation{}, err
+	}
+	after := before
+	after.Status = status
+	after.RespondedAt = &now
+	if err := uc.record(entities.AuditUpdate, EntityInvitation, after.ID, before, after); err != nil {
+		return entities.OrganizationInvitation{}, err
+	}
+	return after, nil
+}
+
+// validateOrganizationRole rejects roles other than admin and member
+func validateOrganizationRole(role string) error {
+	if role != entities.OrgAdmin && role != entities.OrgMember {
+		return fmt.Errorf("role must be %s or %s", entities.OrgAdmin, entities.OrgMember)
+	}
+	return nil
+}
+
+//----------------------------------------------------------------seats----------------------------------------------------------------
+
+// PurchaseSeats buys seats in a course for an organization at the course's price in a currency,
+// adding them to the organization's pool for the course (organization admins). Seats are charged
+// in one step without a challenge, so payment methods that need the buyer to authenticate are declined.
+func (uc *CourseUseCase) PurchaseSeats(organizationID, courseID, seats int, paymentMethod, currency string, actor entities.User) (entities.SeatPurchase, error) {
+	if _, err := uc.GetOrganization(organizationID, actor); err != nil {
+		return entities.SeatPurchase{}, err
+	}
+	if err := uc.requireOrganizationAdmin(organizationID, actor); err != nil {
+		return entities.SeatPurchase{}, err
+	}
+	if uc.Payments == nil {
+		return entities.SeatPurchase{}, fmt.Errorf("payments are not configured")
+	}
+	if seats <= 0 || seats > maxSeatsPerPurchase {
+		return entities.SeatPurchase{}, fmt.Errorf("seats must be between 1 and %d", maxSeatsPerPurchase)
+	}
+	if paymentMethod == "" {
+		return entities.SeatPurchase{}, fmt.Errorf("payment_method is required")
+	}
+	currency, err := normalizeCurrency(currency)
+	if err != nil {
+		return entities.SeatPurchase{}, err
+	}
+	course, err := uc.Repo.GetCourseByID(courseID, false)
+	if err != nil {
+		return entities.SeatPurchase{}, notFound(err)
+	}
+	if !course.Visible(time.Now()) {
+		return entities.SeatPurchase{}, fmt.Errorf("%q is not open for enrollment", course.Title)
+	}
+	if !course.Price.IsPositive() {
+		return entities.SeatPurchase{}, fmt.Errorf("%q is free, members can enroll directly", course.Title)
+	}
+	price, err := uc.coursePrice(course.ID, course.Price, currency)
+	if err != nil {
+		return entities.SeatPurchase{}, err
+	}
+
+	total := price.Times(int64(seats))
+	result, err := uc.Payments.Charge(PaymentRequest{Amount: total, Method: paymentMethod, OffSession: true})
+	if err != nil {
+		return entities.SeatPurchase{}, fmt.Errorf("payment failed: %w", err)
+	}
+	switch result.Status {
+	case PaymentSucceeded:
+	case PaymentRequiresAction:
+		return entities.SeatPurchase{}, fmt.Errorf("%w: the payment method requires the buyer to authenticate, use another one", ErrPaymentDeclined)
+	default:
+		return entities.SeatPurchase{}, fmt.Errorf("%w: %s", ErrPaymentDeclined, result.FailureReason)
+	}
+
+	purchase, err := atomically(uc, func(uc *CourseUseCase) (entities.SeatPurchase, error) {
+		purchase, err := uc.Repo.AddSeatPurchase(entities.SeatPurchase{
+			OrganizationID: uint(organizationID),
+			CourseID:       course.ID,
+			Seats:          seats,
+			UnitPrice:      price,
+			Total:          total,
+			PaymentID:      result.ID,
+			PurchasedBy:    actor.ID,
+			PurchasedAt:    time.Now().UTC(),
+		})
+		if err != nil {
+			return entities.SeatPurchase{}, err
+		}
+		if err := uc.record(entities.AuditCreate, EntitySeatPurchase, purchase.ID, nil, purchase); err != nil {
+			return entities.SeatPurchase{}, err
+		}
+		return purchase, nil
+	})
+	if err != nil {
+		return entities.SeatPurchase{}, uc.payBackUnrecorded(result.ID, total, err)
+	}
+	return purchase, nil
+}
+
+// GetSeatPurchases returns the seat purchases of an organization (organization admins)
+func (uc *CourseUseCase) GetSeatPurchases(organizationID int, actor entities.User) ([]entities.SeatPurchase, error) {
+	if _, err := uc.GetOrganization(organizationID, actor); err != nil {
+		return nil, err
+	}
+	if err := uc.requireOrganizationAdmin(organizationID, actor); err != nil {
+		return nil, err
+	}
+	return uc.Repo.GetSeatPurchases(organizationID)
+}
+
+// GetSeatPools returns the seats of an organization per course with how many are in use, to its members
+func (uc *CourseUseCase) GetSeatPools(organizationID int, actor entities.User) ([]entities.SeatPool, error) {
+	if _, err := uc.GetOrganization(organizationID, actor); err != nil {
+		return nil, err
+	}
+	return uc.Repo.GetSeatPools(organizationID)
+}
+
+// EnrollMembers gives members of an organization a seat in a course, every member without one
+// when no user IDs are given (organization admins). Each member is enrolled through AddEnrollment
+// with the organization's seat pool. A member whose enrollment was revoked, or made through a
+// subscription, gets it back on a seat; members who own the course keep it without using a seat.
+// Members are enrolled one by one until the seats run out, the result tells what happened to each.
+func (uc *CourseUseCase) EnrollMembers(organizationID, courseID int, userIDs []uint, actor entities.User) ([]entities.BulkEnrollmentResult, error) {
+	if _, err := uc.GetOrganization(organizationID, actor); err != nil {
+		return nil, err
+	}
+	if err := uc.requireOrganizationAdmin(organizationID, actor); err != nil {
+		return nil, err
+	}
+	pool, err := uc.Repo.GetSeatPool(organizationID, courseID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: organization %d has no seats in course %d", ErrNotFound, organizationID, courseID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(userIDs) == 0 {
+		members, err := uc.Repo.GetOrganizationMembers(organizationID)
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			userIDs = append(userIDs, member.UserID)
+		}
+	}
+
+	results := []entities.BulkEnrollmentResult{}
+	for _, userID := range userIDs {
+		results = append(results, uc.enrollMember(pool, userID))
+	}
+	return results, nil
+}
+
+// enrollMember gives one member a seat of a pool
+func (uc *CourseUseCase) enrollMember(pool entities.SeatPool, userID uint) entities.BulkEnrollmentResult {
+	result := entities.BulkEnrollmentResult{UserID: userID, Status: entities.BulkFailed}
+	created, err := uc.AddEnrollment(entities.Enrollment{UserID: userID, CourseID: pool.CourseID, SeatPoolID: &pool.ID})
+	var conflict *ConflictError
+	switch {
+	case err == nil:
+		result.Status = entities.BulkEnrolled
+		result.EnrollmentID = &created.ID
+		return result
+	case !errors.As(err, &conflict):
+		result.Error = err.Error()
+		return result
+	}
+
+	existing := conflict.Existing.(entities.Enrollment)
+	result.EnrollmentID = &existing.ID
+	if !existing.Revoked() && existing.SubscriptionID == nil {
+		result.Status = entities.BulkAlreadyEnrolled
+		return result
+	}
+	err = uc.atomic(func(uc *CourseUseCase) error {
+		if err := uc.Repo.AssignEnrollmentSeat(int(existing.ID), pool.ID); err != nil {
+			return err
+		}
+		if after, err := uc.Repo.GetEnrollmentByID(int(existing.ID)); err == nil {
+			if err := uc.record(entities.AuditUpdate, EntityEnrollment, after.ID, existing, after); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		result.Error = fmt.Sprintf("no seats left in seat pool %d, buy more seats", pool.ID)
+		return result
+	} else if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Status = entities.BulkSeatAssigned
+	return result
+}
+
+// checkSeat rejects enrollments with a seat the actor cannot give: the pool must be for the
+// course, the student a member of its organization and the actor an admin of it
+func (uc *CourseUseCase) checkSeat(enrollment entities.Enrollment) error {
+	pool, err := uc.Repo.GetSeatPoolByID(int(*enrollment.SeatPoolID))
+	if err != nil {
+		return notFound(err)
+	}
+	if pool.CourseID != enrollment.CourseID {
+		return fmt.Errorf("seat pool %d is for course %d", pool.ID, pool.CourseID)
+	}
+	actor, err := uc.Repo.GetUserByID(int(uc.request.ActorID), false)
+	if err != nil {
+		return fmt.Errorf("%w: only organization admins can give seats", ErrForbidden)
+	}
+	if err := uc.requireOrganizationAdmin(int(pool.OrganizationID), actor); err != nil {
+		return err
+	}
+	if _, err := uc.Repo.GetOrganizationMember(int(pool.OrganizationID), int(enrollment.UserID)); err != nil {
+		return fmt.Errorf("%w: user %d is not a member of organization %d", ErrForbidden, enrollment.UserID, pool.OrganizationID)
+	}
+	return nil
+}
+
+//----------------------------------------------------------------reporting----------------------------------------------------------------
+
+// GetOrganizationProgress reports how far the members of an organization are in each course it
+// bought seats in (organization admins). Members count as enrolled while their enrollment is not
+// revoked, whether it holds a seat or not.
+func (uc *CourseUseCase) GetOrganizationProgress(organizationID int, actor entities.User) (entities.OrganizationProgress, error) {
+	organization, err := uc.GetOrganization(organizationID, actor)
+	if err != nil {
+		return entities.OrganizationProgress{}, err
+	}
+	if err := uc.requireOrganizationAdmin(organizationID, actor); err != nil {
+		return entities.OrganizationProgress{}, err
+	}
+	members, err := uc.Repo.GetOrganizationMembers(organizationID)
+	if err != nil {
+		return entities.OrganizationProgress{}, err
+	}
+	pools, err := uc.Repo.GetSeatPools(organizationID)
+	if err != nil {
+		return entities.OrganizationProgress{}, err
+	}
+
+	report := entities.OrganizationProgress{
+		OrganizationID: organization.ID,
+		Name:           organization.Name,
+		Members:        len(members),
+		Courses:        []entities.CourseProgressReport{},
+		GeneratedAt:    time.Now().UTC(),
+	}
+	for _, pool := range pools {
+		course, err := uc.Repo.GetCourseByID(int(pool.CourseID), true)
+		if err != nil {
+			return entities.OrganizationProgress{}, err
+		}
+		enrollments, err := uc.Repo.GetOrganizationEnrollments(organizationID, int(pool.CourseID))
+		if err != nil {
+			return entities.OrganizationProgress{}, err
+		}
+		byUser := map[uint]entities.Enrollment{}
+		for _, enrollment := range enrollments {
+			byUser[enrollment.UserID] = enrollment
+		}
+
+		courseReport := entities.CourseProgressReport{
+			CourseID: course.ID,
+			Title:    course.Title,
+			Seats:    pool.Seats,
+			Used:     pool.Used,
+			Members:  []entities.MemberProgress{},
+		}
+		total := 0.0
+		for _, member := range members {
+			progress := entities.MemberProgress{UserID: member.UserID, Name: member.Name, Email: member.Email}
+			enrollment, ok := byUser[member.UserID]
+			if ok && !enrollment.Revoked() {
+				summary, err := uc.GetEnrollmentProgress(int(enrollment.ID))
+				if err != nil {
+					return entities.OrganizationProgress{}, err
+				}
+				progress.EnrollmentID = &enrollment.ID
+				progress.HoldsSeat = enrollment.SeatPoolID != nil && *enrollment.SeatPoolID == pool.ID
+				progress.PercentComplete = summary.PercentComplete
+				progress.Completed = enrollment.Completed
+				progress.CompletedAt = enrollment.CompletedAt
+				progress.LastActivityAt = summary.LastActivityAt
+				courseReport.Enrolled++
+				total += summary.PercentComplete
+				if enrollment.Completed {
+					courseReport.Completed++
+				}
+			}
+			courseReport.Members = append(courseReport.Members, progress)
+		}
+		if courseReport.Enrolled > 0 {
+			courseReport.AveragePercent = math.Round(total*100/float64(courseReport.Enrolled)) / 100
+		}
+		report.Courses = append(report.Courses, courseReport)
+	}
+	return report, nil
+}
@@ -0,0 +1,278 @@
+package usecases
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+//----------------------------------------------------------------prerequisites----------------------------------------------------------------
+
+// SetPrerequisite makes prerequisiteID a prerequisite of courseID, or changes whether it is
+// required. Relations that would make a course depend on itself are rejected.
+func (uc *CourseUseCase) SetPrerequisite(prerequisite entities.Prerequisite, actor entities.User) ([]entities.Prerequisite, error) {
+	return atomically(uc, func(uc *CourseUseCase) ([]entities.Prerequisite, error) {
+		course, err := uc.Repo.GetCourseByID(int(prerequisite.CourseID), false)
+		if err != nil {
+			return nil, notFound(err)
+		}
+		if !isCourseInstructor(actor, course) {
+			return nil, fmt.Errorf("%w: only the course instructor can change its prerequisites", ErrForbidden)
+		}
+		required, err := uc.Repo.GetCourseByID(int(prerequisite.PrerequisiteID), false)
+		if err != nil {
+			return nil, notFound(err)
+		}
+		if required.ID == course.ID {
+			return nil, fmt.Errorf("a course cannot be its own prerequisite")
+		}
+		graph, err := uc.Repo.GetPrerequisiteGraph()
+		if err != nil {
+			return nil, err
+		}
+		if dependsOn(graph, required.ID, course.ID) {
+			return nil, fmt.Errorf("%q already requires %q, the prerequisite would create a cycle", required.Title, course.Title)
+		}
+
+		before, err := uc.Repo.GetPrerequisites(int(course.ID))
+		if err != nil {
+			return nil, err
+		}
+		if err := uc.Repo.SetPrerequisite(prerequisite); err != nil {
+			return nil, err
+		}
+		after, err := uc.Repo.GetPrerequisites(int(course.ID))
+		if err != nil {
+			return nil, err
+		}
+		if err := uc.record(entities.AuditUpdate, EntityPrerequisite, course.ID, before, after); err != nil {
+			return nil, err
+		}
+		return after, nil
+	})
+}
+
+// DeletePrerequisite removes a prerequisite from a course
+func (uc *CourseUseCase) DeletePrerequisite(courseID, prerequisiteID int, actor entities.User) error {
+	return uc.atomic(func(uc *CourseUseCase) error {
+		course, err := uc.Repo.GetCourseByID(courseID, false)
+		if err != nil {
+			return notFound(err)
+		}
+		if !isCourseInstructor(actor, course) {
+			return fmt.Errorf("%w: only the course instructor can change its prerequisites", ErrForbidden)
+		}
+		before, err := uc.Repo.GetPrerequisites(courseID)
+		if err != nil {
+			return err
+		}
+		if err := uc.Repo.DeletePrerequisite(courseID, prerequisiteID); err != nil {
+			return notFound(err)
+		}
+		after, err := uc.Repo.GetPrerequisites(courseID)
+		if err != nil {
+			return err
+		}
+		return uc.record(entities.AuditUpdate, EntityPrerequisite, course.ID, before, after)
+	})
+}
+
+// GetPrerequisites returns the prerequisites of a course the caller can see
+func (uc *CourseUseCase) GetPrerequisites(courseID int, filter entities.CourseFilter) ([]entities.Prerequisite, error) {
+	if _, err := uc.GetCourseByID(courseID, filter); err != nil {
+		return nil, err
+	}
+	return uc.Repo.GetPrerequisites(courseID)
+}
+
+// missingPrerequisites returns the prerequisites of a course the user has not completed,
+// split into required and recommended ones
+func (uc *CourseUseCase) missingPrerequisites(userID, courseID int) (required, recommended []entities.Prerequisite, err error) {
+	prerequisites, err := uc.Repo.GetPrerequisites(courseID)
+	if err != nil || len(prerequisites) == 0 {
+		return nil, nil, err
+	}
+	enrollments, err := uc.Repo.GetEnrollmentsByUserID(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	completed := map[uint]bool{}
+	for _, enrollment := range enrollments {
+		if enrollment.Completed {
+			completed[enrollment.CourseID] = true
+		}
+	}
+
+	for _, prerequisite := range prerequisites {
+		switch {
+		case completed[prerequisite.PrerequisiteID]:
+		case prerequisite.Required:
+			required = append(required, prerequisite)
+		default:
+			recommended = append(recommended, prerequisite)
+		}
+	}
+	return required, recommended, nil
+}
+
+// dependsOn reports whether course requires target, directly or through other prerequisites
+func dependsOn(graph map[uint][]uint, course, target uint) bool {
+	visited := map[uint]bool{}
+	stack := []uint{course}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if current == target {
+			return true
+		}
+		if visited[current] {
+			continue
+		}
+		visited[current] = true
+		stack = append(stack, graph[current]...)
+	}
+	return false
+}
+
+func prerequisiteTitles(prerequisites []entities.Prerequisite) string {
+	titles := make([]string, 0, len(prerequisites))
+	for _, prerequisite := range prerequisites {
+		titles = append(titles, fmt.Sprintf("%q", prerequisite.Title))
+	}
+	return strings.Join(titles, ", ")
+}
+
+//----------------------------------------------------------------learning paths----------------------------------------------------------------
+
+// AddLearningPath creates a learning path from existing courses
+func (uc *CourseUseCase) AddLearningPath(path entities.LearningPath) (entities.LearningPath, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.LearningPath, error) {
+		if err := uc.validateLearningPath(&path); err != nil {
+			return entities.LearningPath{}, err
+		}
+		created, err := uc.Repo.AddLearningPath(path)
+		if err != nil {
+			return created, err
+		}
+		if err := uc.record(entities.AuditCreate, EntityLearningPath, created.ID, nil, created); err != nil {
+			return entities.LearningPath{}, err
+		}
+		return created, nil
+	})
+}
+
+func (uc *CourseUseCase) GetLearningPathByID(id int) (entities.LearningPath, error) {
+	path, err := uc.Repo.GetLearningPathByID(id)
+	if err != nil {
+		return entities.LearningPath{}, notFound(err)
+	}
+	return path, nil
+}
+
+func (uc *CourseUseCase) GetAllLearningPaths() ([]entities.LearningPath, error) {
+	return uc.Repo.GetAllLearningPaths()
+}
+
+// UpdateLearningPath replaces the title, description and courses of a learning path
+func (uc *CourseUseCase) UpdateLearningPath(path entities.LearningPath) (entities.LearningPath, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.LearningPath, error) {
+		before, err := uc.Repo.GetLearningPathByID(int(path.ID))
+		if err != nil {
+			return entities.LearningPath{}, notFound(err)
+		}
+		if err := uc.validateLearningPath(&path); err != nil {
+			return entities.LearningPath{}, err
+		}
+		updated, err := uc.Repo.UpdateLearningPath(path)
+		if err != nil {
+			return updated, notFound(err)
+		}
+		if err := uc.record(entities.AuditUpdate, EntityLearningPath, updated.ID, before, updated); err != nil {
+			return entities.LearningPath{}, err
+		}
+		return updated, nil
+	})
+}
+
+func (uc *CourseUseCase) DeleteLearningPath(id int) error {
+	return uc.atomic(func(uc *CourseUseCase) error {
+		before, err := uc.Repo.GetLearningPathByID(id)
+		if err != nil {
+			return notFound(err)
+		}
+		if err := uc.Repo.DeleteLearningPath(id); err != nil {
+			return notFound(err)
+		}
+		return uc.record(entities.AuditDelete, EntityLearningPath, before.ID, before, nil)
+	})
+}
+
+// GetLearningPathProgress returns how far a user is through a learning path, based on their enrollments
+func (uc *CourseUseCase) GetLearningPathProgress(pathID, userID int) (entities.LearningPathProgress, error) {
+	if _, err := uc.Repo.GetLearningPathByID(pathID); err != nil {
+		return entities.LearningPathProgress{}, notFound(err)
+	}
+	courses, err := uc.Repo.GetLearningPathCourses(pathID)
+	if err != nil {
+		return entities.LearningPathProgress{}, err
+	}
+	enrollments, err := uc.Repo.GetEnrollmentsByUserID(userID)
+	if err != nil {
+		return entities.LearningPathProgress{}, err
+	}
+	byCourse := map[uint]entities.Enrollment{}
+	for _, enrollment := range enrollments {
+		byCourse[enrollment.CourseID] = enrollment
+	}
+
+	progress := entities.LearningPathProgress{
+		PathID:       uint(pathID),
+		UserID:       uint(userID),
+		TotalCourses: len(courses),
+		Courses:      make([]entities.PathCourseProgress, 0, len(courses)),
+	}
+	for i, course := range courses {
+		enrollment, enrolled := byCourse[course.ID]
+		progress.Courses = append(progress.Courses, entities.PathCourseProgress{
+			CourseID:  course.ID,
+			Title:     course.Title,
+			Position:  uint(i + 1),
+			Enrolled:  enrolled,
+			Completed: enrolled && enrollment.Completed,
+		})
+	}
+	for i := range progress.Courses {
+		course := &progress.Courses[i]
+		if course.Completed {
+			progress.CompletedCourses++
+		} else if progress.NextCourse == nil {
+			progress.NextCourse = course
+		}
+	}
+	progress.PercentComplete = percentComplete(progress.TotalCourses, progress.CompletedCourses)
+	progress.Completed = progress.TotalCourses > 0 && progress.CompletedCourses == progress.TotalCourses
+	return progress, nil
+}
+
+// validateLearningPath checks the title and that every course exists and is listed once
+func (uc *CourseUseCase) validateLearningPath(path *entities.LearningPath) error {
+	path.Title = strings.TrimSpace(path.Title)
+	if path.Title == "" {
+		return fmt.Errorf("learning path title is required")
+	}
+	if path.CourseIDs == nil {
+		path.CourseIDs = []uint{}
+	}
+	seen := map[uint]bool{}
+	for _, courseID := range path.CourseIDs {
+		if seen[courseID] {
+			return fmt.Errorf("course %d is listed twice", courseID)
+		}
+		seen[courseID] = true
+		if _, err := uc.Repo.GetCourseByID(int(courseID), false); err != nil {
+			return fmt.Errorf("course %d does not exist", courseID)
+		}
+	}
+	return nil
+}
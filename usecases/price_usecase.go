@@ -0,0 +1,157 @@
+package usecases
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+// ExchangeRates converts money between currencies
+type ExchangeRates interface {
+	// Convert returns the amount in another currency, rounded to that currency's minor unit
+	Convert(amount entities.Money, currency string) (entities.Money, error)
+	// Currencies lists the currencies the rates can convert between
+	Currencies() []string
+}
+
+//----------------------------------------------------------------course prices----------------------------------------------------------------
+
+// GetCoursePrices returns the price of a course in its base currency, in every currency it has
+// a price set for, and converted into every other currency the exchange rates know
+func (uc *CourseUseCase) GetCoursePrices(courseID int) ([]entities.CoursePrice, error) {
+	course, err := uc.Repo.GetCourseByID(courseID, false)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	set, err := uc.Repo.GetCoursePrices(courseID)
+	if err != nil {
+		return nil, err
+	}
+
+	prices := []entities.CoursePrice{{CourseID: course.ID, Price: course.Price}}
+	priced := map[string]bool{course.Price.Currency: true}
+	for _, price := range set {
+		if !priced[price.Currency] {
+			prices = append(prices, entities.CoursePrice{CourseID: course.ID, Price: price})
+			priced[price.Currency] = true
+		}
+	}
+	if uc.Rates == nil {
+		return prices, nil
+	}
+	for _, currency := range uc.Rates.Currencies() {
+		if priced[currency] {
+			continue
+		}
+		converted, err := uc.Rates.Convert(course.Price, currency)
+		if err != nil {
+			continue
+		}
+		prices = append(prices, entities.CoursePrice{CourseID: course.ID, Price: converted, Converted: true})
+	}
+	return prices, nil
+}
+
+// SetCoursePrice sets the price of a course in a currency other than its base one, it is
+// charged instead of the converted base price
+func (uc *CourseUseCase) SetCoursePrice(courseID int, price entities.Money, actor entities.User) (entities.CoursePrice, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.CoursePrice, error) {
+		if err := uc.requireCourseInstructor(courseID, actor); err != nil {
+			return entities.CoursePrice{}, err
+		}
+		course, err := uc.Repo.GetCourseByID(courseID, false)
+		if err != nil {
+			return entities.CoursePrice{}, notFound(err)
+		}
+		if err := validatePrice(price); err != nil {
+			return entities.CoursePrice{}, err
+		}
+		if price.Currency == course.Price.Currency {
+			return entities.CoursePrice{}, fmt.Errorf("%s is the base currency of the course, update the course price instead", price.Currency)
+		}
+
+		var before any
+		if existing, err := uc.Repo.GetCoursePrice(courseID, price.Currency); err == nil {
+			before = existing
+		}
+		if err := uc.Repo.SetCoursePrice(courseID, price); err != nil {
+			return entities.CoursePrice{}, err
+		}
+		if err := uc.record(entities.AuditUpdate, EntityCoursePrice, course.ID, before, price); err != nil {
+			return entities.CoursePrice{}, err
+		}
+		return entities.CoursePrice{CourseID: course.ID, Price: price}, nil
+	})
+}
+
+// DeleteCoursePrice removes the price set for a course in a currency, the course is then sold
+// in that currency at its converted base price
+func (uc *CourseUseCase) DeleteCoursePrice(courseID int, currency string, actor entities.User) error {
+	return uc.atomic(func(uc *CourseUseCase) error {
+		if err := uc.requireCourseInstructor(courseID, actor); err != nil {
+			return err
+		}
+		currency = strings.ToUpper(currency)
+		before, err := uc.Repo.GetCoursePrice(courseID, currency)
+		if err != nil {
+			return fmt.Errorf("%w: no %s price is set for course %d", ErrNotFound, currency, courseID)
+		}
+		if err := uc.Repo.DeleteCoursePrice(courseID, currency); err != nil {
+			return notFound(err)
+		}
+		return uc.record(entities.AuditDelete, EntityCoursePrice, uint(courseID), before, nil)
+	})
+}
+
+// coursePrice returns what a course costs in a currency: its base price, the price set for the
+// currency, or else the base price converted with the exchange rates
+func (uc *CourseUseCase) coursePrice(courseID uint, base entities.Money, currency string) (entities.Money, error) {
+	if base.Currency == currency {
+		return base, nil
+	}
+	price, err := uc.Repo.GetCoursePrice(int(courseID), currency)
+	if err == nil {
+		return price, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return entities.Money{}, err
+	}
+	return uc.convert(base, currency)
+}
+
+// convert changes an amount into another currency with the exchange rates
+func (uc *CourseUseCase) convert(amount entities.Money, currency string) (entities.Money, error) {
+	if amount.Currency == currency {
+		return amount, nil
+	}
+	if uc.Rates == nil {
+		return entities.Money{}, fmt.Errorf("exchange rates are not configured, %s cannot be converted to %s", amount.Currency, currency)
+	}
+	return uc.Rates.Convert(amount, currency)
+}
+
+// validatePrice rejects prices that are not positive or in an unsupported currency
+func validatePrice(price entities.Money) error {
+	if !entities.ValidCurrency(price.Currency) {
+		return fmt.Errorf("unsupported currency %q", price.Currency)
+	}
+	if !price.IsPositive() {
+		return fmt.Errorf("price must be greater than zero")
+	}
+	return nil
+}
+
+// normalizeCurrency reads a currency chosen by the caller, DefaultCurrency when none is given
+func normalizeCurrency(currency string) (string, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		return entities.DefaultCurrency, nil
+	}
+	if !entities.ValidCurrency(currency) {
+		return "", fmt.Errorf("unsupported currency %q", currency)
+	}
+	return currency, nil
+}
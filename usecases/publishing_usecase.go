@@ -0,0 +1,90 @@
+package usecases
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+// allowed publishing transitions
+var courseTransitions = transitions{
+	entities.CourseDraft:     {entities.CourseInReview},
+	entities.CourseInReview:  {entities.CoursePublished, entities.CourseDraft},
+	entities.CoursePublished: {entities.CourseArchived},
+	entities.CourseArchived:  {entities.CourseDraft},
+}
+
+// SubmitCourse sends a draft course to the admins for review
+func (uc *CourseUseCase) SubmitCourse(id int, actor entities.User) (entities.Course, error) {
+	return uc.transitionCourse(id, actor, false, entities.CourseInReview, nil, "")
+}
+
+// WithdrawCourse takes a course out of review back to draft
+func (uc *CourseUseCase) WithdrawCourse(id int, actor entities.User) (entities.Course, error) {
+	return uc.transitionCourse(id, actor, false, entities.CourseDraft, nil, "")
+}
+
+// ApproveCourse publishes a course under review as a new version. With publishAt in the future
+// the course only shows up in the catalog from then on.
+func (uc *CourseUseCase) ApproveCourse(id int, actor entities.User, publishAt *time.Time) (entities.Course, error) {
+	if publishAt != nil {
+		utc := publishAt.UTC()
+		publishAt = &utc
+	}
+	return uc.transitionCourse(id, actor, true, entities.CoursePublished, publishAt, "")
+}
+
+// RejectCourse sends a course under review back to draft with a note for the instructor
+func (uc *CourseUseCase) RejectCourse(id int, actor entities.User, note string) (entities.Course, error) {
+	return uc.transitionCourse(id, actor, true, entities.CourseDraft, nil, note)
+}
+
+// ArchiveCourse removes a published course from the catalog, enrolled students keep access
+func (uc *CourseUseCase) ArchiveCourse(id int, actor entities.User) (entities.Course, error) {
+	return uc.transitionCourse(id, actor, false, entities.CourseArchived, nil, "")
+}
+
+// ReopenCourse turns an archived course back into a draft
+func (uc *CourseUseCase) ReopenCourse(id int, actor entities.User) (entities.Course, error) {
+	return uc.transitionCourse(id, actor, false, entities.CourseDraft, nil, "")
+}
+
+// transitionCourse moves a course to another publishing state. Admin-only transitions need
+// an admin, the others the course instructor or an admin. Publishing snapshots the course in
+// the same transaction, so a course is never published without a version to enroll into.
+func (uc *CourseUseCase) transitionCourse(id int, actor entities.User, adminOnly bool, to string, publishAt *time.Time, note string) (entities.Course, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.Course, error) {
+		before, err := uc.Repo.GetCourseByID(id, false)
+		if err != nil {
+			return entities.Course{}, notFound(err)
+		}
+		if adminOnly && actor.Role != "admin" {
+			return entities.Course{}, fmt.Errorf("%w: only admins can review courses", ErrForbidden)
+		}
+		if !isCourseInstructor(actor, before) {
+			return entities.Course{}, fmt.Errorf("%w: only the course instructor can change its status", ErrForbidden)
+		}
+		if !courseTransitions.allows(before.Status, to) {
+			return entities.Course{}, fmt.Errorf("cannot move a course from %s to %s", before.Status, to)
+		}
+
+		if err := uc.Repo.SetCourseStatus(id, to, publishAt, note); err != nil {
+			return entities.Course{}, notFound(err)
+		}
+		after, err := uc.Repo.GetCourseByID(id, false)
+		if err != nil {
+			return entities.Course{}, err
+		}
+		// every publish freezes the content students enroll into
+		if to == entities.CoursePublished {
+			if _, err := uc.snapshotCourse(after); err != nil {
+				return entities.Course{}, fmt.Errorf("snapshotting course %d: %w", id, err)
+			}
+		}
+		if err := uc.record(entities.AuditUpdate, EntityCourse, after.ID, before, after); err != nil {
+			return entities.Course{}, err
+		}
+		return after, nil
+	})
+}
@@ -0,0 +1,689 @@
+package usecases
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+//----------------------------------------------------------------question banks----------------------------------------------------------------
+
+// AddQuestionBank creates a question bank for a course
+func (uc *CourseUseCase) AddQuestionBank(bank entities.QuestionBank, actor entities.User) (entities.QuestionBank, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.QuestionBank, error) {
+		bank.Title = strings.TrimSpace(bank.Title)
+		if bank.Title == "" {
+			return entities.QuestionBank{}, fmt.Errorf("question bank title is required")
+		}
+		if err := uc.requireCourseInstructor(int(bank.CourseID), actor); err != nil {
+			return entities.QuestionBank{}, err
+		}
+		created, err := uc.Repo.AddQuestionBank(bank)
+		if err != nil {
+			return created, err
+		}
+		if err := uc.record(entities.AuditCreate, EntityQuestionBank, created.ID, nil, created); err != nil {
+			return entities.QuestionBank{}, err
+		}
+		return created, nil
+	})
+}
+
+// GetQuestionBanks lists the question banks of a course
+func (uc *CourseUseCase) GetQuestionBanks(courseID int, actor entities.User) ([]entities.QuestionBank, error) {
+	if err := uc.requireCourseInstructor(courseID, actor); err != nil {
+		return nil, err
+	}
+	return uc.Repo.GetQuestionBanksByCourseID(courseID)
+}
+
+// GetBankQuestions lists the questions of a bank with their answer keys
+func (uc *CourseUseCase) GetBankQuestions(bankID int, actor entities.User) ([]entities.Question, error) {
+	if _, err := uc.bankForInstructor(bankID, actor); err != nil {
+		return nil, err
+	}
+	return uc.Repo.GetQuestionsByBankID(bankID)
+}
+
+// AddQuestion adds a question to a bank
+func (uc *CourseUseCase) AddQuestion(question entities.Question, actor entities.User) (entities.Question, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.Question, error) {
+		if _, err := uc.bankForInstructor(int(question.BankID), actor); err != nil {
+			return entities.Question{}, err
+		}
+		if err := validateQuestion(&question); err != nil {
+			return entities.Question{}, err
+		}
+		created, err := uc.Repo.AddQuestion(question)
+		if err != nil {
+			return created, err
+		}
+		if err := uc.record(entities.AuditCreate, EntityQuestion, created.ID, nil, created); err != nil {
+			return entities.Question{}, err
+		}
+		return created, nil
+	})
+}
+
+// UpdateQuestion replaces a question, attempts already graded keep their score
+func (uc *CourseUseCase) UpdateQuestion(question entities.Question, actor entities.User) (entities.Question, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.Question, error) {
+		before, err := uc.Repo.GetQuestionByID(int(question.ID))
+		if err != nil || before.DeletedAt != nil {
+			return entities.Question{}, ErrNotFound
+		}
+		if _, err := uc.bankForInstructor(int(before.BankID), actor); err != nil {
+			return entities.Question{}, err
+		}
+		question.BankID = before.BankID
+		if err := validateQuestion(&question); err != nil {
+			return entities.Question{}, err
+		}
+		updated, err := uc.Repo.UpdateQuestion(question)
+		if err != nil {
+			return updated, notFound(err)
+		}
+		if err := uc.record(entities.AuditUpdate, EntityQuestion, updated.ID, before, updated); err != nil {
+			return entities.Question{}, err
+		}
+		return updated, nil
+	})
+}
+
+// DeleteQuestion removes a question from its bank
+func (uc *CourseUseCase) DeleteQuestion(id int, actor entities.User) error {
+	return uc.atomic(func(uc *CourseUseCase) error {
+		before, err := uc.Repo.GetQuestionByID(id)
+		if err != nil || before.DeletedAt != nil {
+			return ErrNotFound
+		}
+		if _, err := uc.bankForInstructor(int(before.BankID), actor); err != nil {
+			return err
+		}
+		if err := uc.Repo.DeleteQuestion(id); err != nil {
+			return notFound(err)
+		}
+		return uc.record(entities.AuditDelete, EntityQuestion, before.ID, before, nil)
+	})
+}
+
+//----------------------------------------------------------------quizzes----------------------------------------------------------------
+
+// AddQuiz attaches a quiz drawing from a bank of the same course to a lesson, a lesson has one quiz
+func (uc *CourseUseCase) AddQuiz(quiz entities.Quiz, actor entities.User) (entities.Quiz, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.Quiz, error) {
+		if err := uc.validateQuiz(&quiz, actor); err != nil {
+			return entities.Quiz{}, err
+		}
+		if existing, err := uc.Repo.GetQuizByLessonID(int(quiz.LessonID)); err == nil {
+			return existing, &ConflictError{Resource: "quiz", Existing: existing}
+		}
+		created, err := uc.Repo.AddQuiz(quiz)
+		if err != nil {
+			return created, err
+		}
+		if err := uc.record(entities.AuditCreate, EntityQuiz, created.ID, nil, created); err != nil {
+			return entities.Quiz{}, err
+		}
+		return created, nil
+	})
+}
+
+// UpdateQuiz changes the settings of a quiz
+func (uc *CourseUseCase) UpdateQuiz(quiz entities.Quiz, actor entities.User) (entities.Quiz, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.Quiz, error) {
+		before, err := uc.Repo.GetQuizByID(int(quiz.ID))
+		if err != nil {
+			return entities.Quiz{}, notFound(err)
+		}
+		quiz.LessonID = before.LessonID
+		if err := uc.validateQuiz(&quiz, actor); err != nil {
+			return entities.Quiz{}, err
+		}
+		updated, err := uc.Repo.UpdateQuiz(quiz)
+		if err != nil {
+			return updated, notFound(err)
+		}
+		if err := uc.record(entities.AuditUpdate, EntityQuiz, updated.ID, before, updated); err != nil {
+			return entities.Quiz{}, err
+		}
+		return updated, nil
+	})
+}
+
+// DeleteQuiz removes a quiz and its attempts from a lesson
+func (uc *CourseUseCase) DeleteQuiz(id int, actor entities.User) error {
+	return uc.atomic(func(uc *CourseUseCase) error {
+		before, err := uc.Repo.GetQuizByID(id)
+		if err != nil {
+			return notFound(err)
+		}
+		lesson := uc.lessonSnapshot(int(before.LessonID))
+		if err := uc.requireCourseInstructor(int(lesson.CourseID), actor); err != nil {
+			return err
+		}
+		if err := uc.Repo.DeleteQuiz(id); err != nil {
+			return notFound(err)
+		}
+		return uc.record(entities.AuditDelete, EntityQuiz, before.ID, before, nil)
+	})
+}
+
+// GetLessonQuiz returns the quiz of a lesson
+func (uc *CourseUseCase) GetLessonQuiz(lessonID int) (entities.Quiz, error) {
+	quiz, err := uc.Repo.GetQuizByLessonID(lessonID)
+	if err != nil {
+		return entities.Quiz{}, notFound(err)
+	}
+	return quiz, nil
+}
+
+//----------------------------------------------------------------attempts----------------------------------------------------------------
+
+// StartQuizAttempt draws the questions of a new attempt for an enrollment. A student has one open
+// attempt at a time, starting another one fails with a ConflictError carrying the open attempt.
+func (uc *CourseUseCase) StartQuizAttempt(quizID, enrollmentID int, actor entities.User) (entities.QuizAttempt, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.QuizAttempt, error) {
+		quiz, err := uc.Repo.GetQuizByID(quizID)
+		if err != nil {
+			return entities.QuizAttempt{}, notFound(err)
+		}
+		enrollment, err := uc.enrollmentForStudent(enrollmentID, actor)
+		if err != nil {
+			return entities.QuizAttempt{}, err
+		}
+		lesson := uc.lessonSnapshot(int(quiz.LessonID))
+		if lesson.CourseID != enrollment.CourseID {
+			return entities.QuizAttempt{}, fmt.Errorf("the quiz does not belong to the enrolled course")
+		}
+		if err := uc.checkLessonReleased(enrollment.ID, lesson.ID); err != nil {
+			return entities.QuizAttempt{}, err
+		}
+
+		if open, err := uc.checkNewAttempt(quiz, enrollmentID); err != nil {
+			return open, err
+		}
+
+		questions, err := uc.Repo.GetQuestionsByBankID(int(quiz.BankID))
+		if err != nil {
+			return entities.QuizAttempt{}, err
+		}
+		if len(questions) == 0 {
+			return entities.QuizAttempt{}, fmt.Errorf("the quiz has no questions yet")
+		}
+		questions = drawQuestions(questions, quiz)
+
+		now := time.Now().UTC()
+		attempt := entities.QuizAttempt{QuizID: quiz.ID, EnrollmentID: enrollment.ID, Answers: []entities.AttemptAnswer{}, StartedAt: now}
+		for _, question := range questions {
+			attempt.QuestionIDs = append(attempt.QuestionIDs, question.ID)
+		}
+		if quiz.TimeLimitSeconds > 0 {
+			deadline := now.Add(time.Duration(quiz.TimeLimitSeconds) * time.Second)
+			attempt.DeadlineAt = &deadline
+		}
+		created, err := uc.Repo.AddQuizAttempt(attempt)
+		if errors.Is(err, sql.ErrNoRows) {
+			// another attempt was started concurrently
+			if open, err := uc.checkNewAttempt(quiz, enrollmentID); err != nil {
+				return open, err
+			}
+		}
+		if err != nil {
+			return created, err
+		}
+		if err := uc.record(entities.AuditCreate, EntityQuizAttempt, created.ID, nil, created); err != nil {
+			return entities.QuizAttempt{}, err
+		}
+		created.Questions = studentQuestions(questions)
+		return created, nil
+	})
+}
+
+// checkNewAttempt fails with a ConflictError carrying the open attempt of an enrollment at a quiz,
+// or when all its attempts are used
+func (uc *CourseUseCase) checkNewAttempt(quiz entities.Quiz, enrollmentID int) (entities.QuizAttempt, error) {
+	attempts, err := uc.quizAttempts(quiz, enrollmentID)
+	if err != nil {
+		return entities.QuizAttempt{}, err
+	}
+	for _, attempt := range attempts {
+		if attempt.SubmittedAt == nil {
+			if open, err := uc.attemptWithQuestions(attempt); err == nil {
+				attempt = open
+			}
+			return attempt, &ConflictError{Resource: "quiz attempt", Existing: attempt}
+		}
+	}
+	if quiz.MaxAttempts > 0 && uint(len(attempts)) >= quiz.MaxAttempts {
+		return entities.QuizAttempt{}, fmt.Errorf("%w: all %d attempts at this quiz are used", ErrForbidden, quiz.MaxAttempts)
+	}
+	return entities.QuizAttempt{}, nil
+}
+
+// SubmitQuizAttempt grades the answers of an open attempt. Passing the quiz completes its lesson.
+// Answers submitted after the time limit are not accepted, the attempt is closed with a score of 0.
+func (uc *CourseUseCase) SubmitQuizAttempt(attemptID int, answers []entities.AttemptAnswer, actor entities.User) (entities.QuizAttempt, error) {
+	attempt, err := uc.Repo.GetQuizAttemptByID(attemptID)
+	if err != nil {
+		return entities.QuizAttempt{}, notFound(err)
+	}
+	if _, err := uc.enrollmentForStudent(int(attempt.EnrollmentID), actor); err != nil {
+		return entities.QuizAttempt{}, err
+	}
+	quiz, err := uc.Repo.GetQuizByID(int(attempt.QuizID))
+	if err != nil {
+		return entities.QuizAttempt{}, notFound(err)
+	}
+	if attempt.SubmittedAt != nil {
+		if submitted, err := uc.attemptWithQuestions(attempt); err == nil {
+			attempt = submitted
+		}
+		return attempt, &ConflictError{Resource: "quiz attempt", Existing: attempt}
+	}
+
+	now := time.Now().UTC()
+	if attempt.DeadlineAt != nil && now.After(*attempt.DeadlineAt) {
+		if _, err := uc.gradeAttempt(quiz, attempt, nil, *attempt.DeadlineAt); err != nil {
+			return entities.QuizAttempt{}, err
+		}
+		return entities.QuizAttempt{}, fmt.Errorf("%w: the time limit of the attempt has passed", ErrForbidden)
+	}
+	graded, err := uc.gradeAttempt(quiz, attempt, answers, now)
+	if err != nil {
+		return entities.QuizAttempt{}, err
+	}
+	if graded.Passed {
+		progress := entities.Progress{EnrollmentID: graded.EnrollmentID, LessonID: quiz.LessonID, Completed: true}
+		if _, err := uc.upsertProgress(progress); err != nil {
+			return graded, err
+		}
+	}
+	return uc.attemptWithQuestions(graded)
+}
+
+// GetQuizAttempt returns an attempt to its student, the course instructor or an admin
+func (uc *CourseUseCase) GetQuizAttempt(attemptID int, actor entities.User) (entities.QuizAttempt, error) {
+	attempt, err := uc.Repo.GetQuizAttemptByID(attemptID)
+	if err != nil {
+		return entities.QuizAttempt{}, notFound(err)
+	}
+	if err := uc.canViewEnrollment(int(attempt.EnrollmentID), actor); err != nil {
+		return entities.QuizAttempt{}, err
+	}
+	quiz, err := uc.Repo.GetQuizByID(int(attempt.QuizID))
+	if err != nil {
+		return entities.QuizAttempt{}, notFound(err)
+	}
+	return uc.attemptWithQuestions(closedIfExpired(quiz, attempt, time.Now()))
+}
+
+// GetQuizAttempts lists the attempts of an enrollment at a quiz
+func (uc *CourseUseCase) GetQuizAttempts(quizID, enrollmentID int, actor entities.User) ([]entities.QuizAttempt, error) {
+	quiz, err := uc.Repo.GetQuizByID(quizID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if err := uc.canViewEnrollment(enrollmentID, actor); err != nil {
+		return nil, err
+	}
+	attempts, err := uc.Repo.GetQuizAttempts(quizID, enrollmentID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for i := range attempts {
+		attempts[i] = closedIfExpired(quiz, attempts[i], now)
+		attempts[i].Questions = []entities.QuizQuestion{}
+	}
+	return attempts, nil
+}
+
+// checkQuizPassed rejects completing a lesson whose quiz the enrollment has not passed
+func (uc *CourseUseCase) checkQuizPassed(enrollmentID, lessonID uint) error {
+	quiz, err := uc.Repo.GetQuizByLessonID(int(lessonID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	attempts, err := uc.Repo.GetQuizAttempts(int(quiz.ID), int(enrollmentID))
+	if err != nil {
+		return err
+	}
+	for _, attempt := range attempts {
+		if attempt.Passed {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: pass the quiz %q with at least %g%% to complete the lesson", ErrForbidden, quiz.Title, quiz.PassingScore)
+}
+
+// quizAttempts returns the attempts of an enrollment at a quiz, closing the ones past their time limit
+func (uc *CourseUseCase) quizAttempts(quiz entities.Quiz, enrollmentID int) ([]entities.QuizAttempt, error) {
+	attempts, err := uc.Repo.GetQuizAttempts(int(quiz.ID), enrollmentID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range attempts {
+		if attempts[i], err = uc.expireAttempt(quiz, attempts[i]); err != nil {
+			return nil, err
+		}
+	}
+	return attempts, nil
+}
+
+// expireAttempt closes an open attempt past its time limit with a score of 0
+func (uc *CourseUseCase) expireAttempt(quiz entities.Quiz, attempt entities.QuizAttempt) (entities.QuizAttempt, error) {
+	if attempt.SubmittedAt != nil || attempt.DeadlineAt == nil || time.Now().Before(*attempt.DeadlineAt) {
+		return attempt, nil
+	}
+	graded, err := uc.gradeAttempt(quiz, attempt, nil, *attempt.DeadlineAt)
+	if errors.Is(err, ErrNotFound) {
+		// closed concurrently
+		return uc.Repo.GetQuizAttemptByID(int(attempt.ID))
+	}
+	return graded, err
+}
+
+// closedIfExpired shows an open attempt past its time limit the way expireAttempt closes it, without
+// storing anything. Reads use it, the attempt is closed the next time the student starts or submits one.
+func closedIfExpired(quiz entities.Quiz, attempt entities.QuizAttempt, now time.Time) entities.QuizAttempt {
+	if attempt.SubmittedAt != nil || attempt.DeadlineAt == nil || now.Before(*attempt.DeadlineAt) {
+		return attempt
+	}
+	attempt.Answers = make([]entities.AttemptAnswer, 0, len(attempt.QuestionIDs))
+	for _, id := range attempt.QuestionIDs {
+		attempt.Answers = append(attempt.Answers, entities.AttemptAnswer{QuestionID: id})
+	}
+	attempt.Score = 0
+	attempt.Passed = attempt.Score >= quiz.PassingScore
+	attempt.SubmittedAt = attempt.DeadlineAt
+	return attempt
+}
+
+// gradeAttempt scores the answers of an attempt and stores them
+func (uc *CourseUseCase) gradeAttempt(quiz entities.Quiz, attempt entities.QuizAttempt, answers []entities.AttemptAnswer, submittedAt time.Time) (entities.QuizAttempt, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.QuizAttempt, error) {
+		questions, err := uc.Repo.GetQuestionsByIDs(attempt.QuestionIDs)
+		if err != nil {
+			return entities.QuizAttempt{}, err
+		}
+		byID := map[uint]entities.Question{}
+		for _, question := range questions {
+			byID[question.ID] = question
+		}
+		given := map[uint]entities.AttemptAnswer{}
+		for _, answer := range answers {
+			given[answer.QuestionID] = answer
+		}
+
+		before := attempt
+		var earned, total float64
+		attempt.Answers = make([]entities.AttemptAnswer, 0, len(attempt.QuestionIDs))
+		for _, id := range attempt.QuestionIDs {
+			question, ok := byID[id]
+			if !ok {
+				continue
+			}
+			total += question.Points
+			answer, answered := given[id]
+			answer.QuestionID = id
+			answer.Correct = answered && gradeAnswer(question, answer)
+			answer.Points = 0
+			if answer.Correct {
+				answer.Points = question.Points
+				earned += question.Points
+			}
+			attempt.Answers = append(attempt.Answers, answer)
+		}
+		if total > 0 {
+			attempt.Score = math.Round(earned*10000/total) / 100
+		}
+		attempt.Passed = attempt.Score >= quiz.PassingScore
+		attempt.SubmittedAt = &submittedAt
+
+		if err := uc.Repo.SubmitQuizAttempt(attempt); err != nil {
+			return entities.QuizAttempt{}, notFound(err)
+		}
+		if err := uc.record(entities.AuditUpdate, EntityQuizAttempt, attempt.ID, before, attempt); err != nil {
+			return entities.QuizAttempt{}, err
+		}
+		return attempt, nil
+	})
+}
+
+// attemptWithQuestions fills the questions of an attempt in the order they were drawn
+func (uc *CourseUseCase) attemptWithQuestions(attempt entities.QuizAttempt) (entities.QuizAttempt, error) {
+	questions, err := uc.Repo.GetQuestionsByIDs(attempt.QuestionIDs)
+	if err != nil {
+		return entities.QuizAttempt{}, err
+	}
+	byID := map[uint]entities.Question{}
+	for _, question := range questions {
+		byID[question.ID] = question
+	}
+	ordered := make([]entities.Question, 0, len(attempt.QuestionIDs))
+	for _, id := range attempt.QuestionIDs {
+		if question, ok := byID[id]; ok {
+			ordered = append(ordered, question)
+		}
+	}
+	attempt.Questions = studentQuestions(ordered)
+	return attempt, nil
+}
+
+// enrollmentForStudent returns an enrollment the actor may take quizzes for: their own, or any for admins
+func (uc *CourseUseCase) enrollmentForStudent(enrollmentID int, actor entities.User) (entities.Enrollment, error) {
+	enrollment, err := uc.Repo.GetEnrollmentByID(enrollmentID)
+	if err != nil {
+		return entities.Enrollment{}, notFound(err)
+	}
+	if enrollment.UserID != actor.ID && actor.Role != "admin" {
+		return entities.Enrollment{}, fmt.Errorf("%w: only the enrolled student can take the quiz", ErrForbidden)
+	}
+	if err := uc.checkEnrollmentAccess(enrollment); err != nil {
+		return entities.Enrollment{}, err
+	}
+	return enrollment, nil
+}
+
+// canViewEnrollment allows the enrolled student, the course instructor and admins
+func (uc *CourseUseCase) canViewEnrollment(enrollmentID int, actor entities.User) error {
+	enrollment, err := uc.Repo.GetEnrollmentByID(enrollmentID)
+	if err != nil {
+		return notFound(err)
+	}
+	if enrollment.UserID == actor.ID {
+		return nil
+	}
+	return uc.requireCourseInstructor(int(enrollment.CourseID), actor)
+}
+
+// requireCourseInstructor allows the instructor of a course and admins
+func (uc *CourseUseCase) requireCourseInstructor(courseID int, actor entities.User) error {
+	course, err := uc.Repo.GetCourseByID(courseID, false)
+	if err != nil {
+		return notFound(err)
+	}
+	if !isCourseInstructor(actor, course) {
+		return fmt.Errorf("%w: only the course instructor can do this", ErrForbidden)
+	}
+	return nil
+}
+
+// bankForInstructor returns a question bank the actor manages
+func (uc *CourseUseCase) bankForInstructor(bankID int, actor entities.User) (entities.QuestionBank, error) {
+	bank, err := uc.Repo.GetQuestionBankByID(bankID)
+	if err != nil {
+		return entities.QuestionBank{}, notFound(err)
+	}
+	if err := uc.requireCourseInstructor(int(bank.CourseID), actor); err != nil {
+		return entities.QuestionBank{}, err
+	}
+	return bank, nil
+}
+
+// validateQuiz checks the lesson, the bank and the settings of a quiz
+func (uc *CourseUseCase) validateQuiz(quiz *entities.Quiz, actor entities.User) error {
+	quiz.Title = strings.TrimSpace(quiz.Title)
+	if quiz.Title == "" {
+		return fmt.Errorf("quiz title is required")
+	}
+	if quiz.PassingScore < 0 || quiz.PassingScore > 100 {
+		return fmt.Errorf("passing score must be between 0 and 100")
+	}
+	lesson := uc.lessonSnapshot(int(quiz.LessonID))
+	if lesson.ID == 0 {
+		return fmt.Errorf("lesson %d does not exist", quiz.LessonID)
+	}
+	bank, err := uc.bankForInstructor(int(quiz.BankID), actor)
+	if err != nil {
+		return err
+	}
+	if bank.CourseID != lesson.CourseID {
+		return fmt.Errorf("question bank %d does not belong to the lesson's course", bank.ID)
+	}
+	return nil
+}
+
+// validateQuestion checks that a question's answer key fits its type
+func validateQuestion(question *entities.Question) error {
+	question.Prompt = strings.TrimSpace(question.Prompt)
+	if question.Prompt == "" {
+		return fmt.Errorf("question prompt is required")
+	}
+	if question.Points == 0 {
+		question.Points = 1
+	}
+	if question.Points < 0 {
+		return fmt.Errorf("points must be positive")
+	}
+	key := question.Answer
+
+	switch question.Type {
+	case entities.QuestionSingleChoice, entities.QuestionMultipleChoice:
+		if len(question.Choices) < 2 {
+			return fmt.Errorf("%s questions need at least two choices", question.Type)
+		}
+		if question.Type == entities.QuestionSingleChoice && len(key.Choices) != 1 {
+			return fmt.Errorf("single_choice questions need exactly one correct choice")
+		}
+		if len(key.Choices) == 0 {
+			return fmt.Errorf("multiple_choice questions need at least one correct choice")
+		}
+		seen := map[int]bool{}
+		for _, choice := range key.Choices {
+			if choice < 0 || choice >= len(question.Choices) || seen[choice] {
+				return fmt.Errorf("correct choice %d is out of range or repeated", choice)
+			}
+			seen[choice] = true
+		}
+		question.Answer = entities.AnswerKey{Choices: key.Choices}
+	case entities.QuestionTrueFalse:
+		if key.Bool == nil {
+			return fmt.Errorf("true_false questions need answer.bool")
+		}
+		question.Choices = nil
+		question.Answer = entities.AnswerKey{Bool: key.Bool}
+	case entities.QuestionNumeric:
+		if key.Number == nil || key.Tolerance < 0 {
+			return fmt.Errorf("numeric questions need answer.number and a tolerance of at least 0")
+		}
+		question.Choices = nil
+		question.Answer = entities.AnswerKey{Number: key.Number, Tolerance: key.Tolerance}
+	case entities.QuestionShortAnswer:
+		var texts []string
+		for _, text := range key.Texts {
+			if text = normalizeAnswer(text); text != "" {
+				texts = append(texts, text)
+			}
+		}
+		if len(texts) == 0 {
+			return fmt.Errorf("short_answer questions need at least one accepted answer in answer.texts")
+		}
+		question.Choices = nil
+		question.Answer = entities.AnswerKey{Texts: texts}
+	default:
+		return fmt.Errorf("unknown question type %q", question.Type)
+	}
+	return nil
+}
+
+// gradeAnswer reports whether an answer is correct, multiple choice answers must pick exactly the correct choices
+func gradeAnswer(question entities.Question, answer entities.AttemptAnswer) bool {
+	key := question.Answer
+	switch question.Type {
+	case entities.QuestionSingleChoice, entities.QuestionMultipleChoice:
+		given := append([]int(nil), answer.Choices...)
+		correct := append([]int(nil), key.Choices...)
+		sort.Ints(given)
+		sort.Ints(correct)
+		if len(given) != len(correct) {
+			return false
+		}
+		for i := range given {
+			if given[i] != correct[i] {
+				return false
+			}
+		}
+		return true
+	case entities.QuestionTrueFalse:
+		return answer.Bool != nil && key.Bool != nil && *answer.Bool == *key.Bool
+	case entities.QuestionNumeric:
+		return answer.Number != nil && key.Number != nil && math.Abs(*answer.Number-*key.Number) <= key.Tolerance
+	case entities.QuestionShortAnswer:
+		given := normalizeAnswer(answer.Text)
+		for _, text := range key.Texts {
+			if given == normalizeAnswer(text) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// normalizeAnswer lowercases a short answer and collapses its whitespace
+func normalizeAnswer(text string) string {
+	return strings.ToLower(strings.Join(strings.Fields(text), " "))
+}
+
+// drawQuestions picks the questions of an attempt: a random sample when the quiz uses fewer
+// questions than its bank has, in random order when the quiz shuffles
+func drawQuestions(questions []entities.Question, quiz entities.Quiz) []entities.Question {
+	drawn := append([]entities.Question(nil), questions...)
+	if quiz.QuestionCount > 0 && int(quiz.QuestionCount) < len(drawn) {
+		rand.Shuffle(len(drawn), func(i, j int) { drawn[i], drawn[j] = drawn[j], drawn[i] })
+		drawn = drawn[:quiz.QuestionCount]
+		if !quiz.Shuffle {
+			sort.Slice(drawn, func(i, j int) bool { return drawn[i].ID < drawn[j].ID })
+		}
+	}
+	if quiz.Shuffle {
+		rand.Shuffle(len(drawn), func(i, j int) { drawn[i], drawn[j] = drawn[j], drawn[i] })
+	}
+	return drawn
+}
+
+// studentQuestions strips the answer keys from questions
+func studentQuestions(questions []entities.Question) []entities.QuizQuestion {
+	result := make([]entities.QuizQuestion, 0, len(questions))
+	for _, question := range questions {
+		result = append(result, entities.QuizQuestion{
+			ID:      question.ID,
+			Type:    question.Type,
+			Prompt:  question.Prompt,
+			Choices: question.Choices,
+			Points:  question.Points,
+		})
+	}
+	return result
+}
@@ -0,0 +1,312 @@
+package usecases
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+// RefundPolicy decides which refunds buyers can request themselves, admins can request any
+type RefundPolicy struct {
+	Window      time.Duration // how long after the payment a refund can be requested
+	MaxProgress float64       // percent of the course completed from which refunds are refused
+}
+
+// DefaultRefundPolicy allows refunds within 14 days of paying while less than 30% of the course is completed
+var DefaultRefundPolicy = RefundPolicy{Window: 14 * 24 * time.Hour, MaxProgress: 30}
+
+// RefundRequest asks the gateway to pay back part or all of a payment
+type RefundRequest struct {
+	PaymentID      string
+	Amount         entities.Money
+	IdempotencyKey string // the gateway pays a key back once, repeating it returns the first refund
+}
+
+//----------------------------------------------------------------refunds----------------------------------------------------------------
+
+// RequestRefund asks for the refund of a course bought in an order. Buyers can only request
+// refunds the policy allows, an admin can request one for anything and the reason the policy
+// would refuse it is kept on the request. The refund waits for an admin to approve it.
+func (uc *CourseUseCase) RequestRefund(orderID, courseID int, reason string, actor entities.User) (entities.Refund, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.Refund, error) {
+		order, err := uc.GetOrder(orderID, actor)
+		if err != nil {
+			return entities.Refund{}, err
+		}
+		if order.Status != entities.OrderPaid {
+			return entities.Refund{}, fmt.Errorf("order %d is %s, only paid orders can be refunded", order.ID, order.Status)
+		}
+		var item *entities.OrderItem
+		for i := range order.Items {
+			if order.Items[i].CourseID == uint(courseID) {
+				item = &order.Items[i]
+			}
+		}
+		if item == nil {
+			return entities.Refund{}, fmt.Errorf("%w: course %d is not part of order %d", ErrNotFound, courseID, order.ID)
+		}
+		paid := item.Price.Sub(item.Discount)
+		if !paid.IsPositive() {
+			return entities.Refund{}, fmt.Errorf("nothing was paid for %q", item.Title)
+		}
+		refunds, err := uc.Repo.GetRefundsByOrderID(int(order.ID))
+		if err != nil {
+			return entities.Refund{}, err
+		}
+		for _, existing := range refunds {
+			if existing.OrderItemID == item.ID && existing.Status != entities.RefundRejected {
+				return existing, &ConflictError{Resource: "refund", Existing: existing}
+			}
+		}
+
+		progress := 0.0
+		if item.EnrollmentID != nil {
+			summary, err := uc.GetEnrollmentProgress(int(*item.EnrollmentID))
+			if err != nil {
+				return entities.Refund{}, err
+			}
+			progress = summary.PercentComplete
+		}
+		now := time.Now().UTC()
+		note := uc.refundPolicyViolation(order, progress, now)
+		if note != "" && actor.Role != "admin" {
+			return entities.Refund{}, fmt.Errorf("%w: %s", ErrForbidden, note)
+		}
+
+		refund, err := uc.Repo.AddRefund(entities.Refund{
+			OrderID:     order.ID,
+			OrderItemID: item.ID,
+			CourseID:    item.CourseID,
+			UserID:      order.UserID,
+			Status:      entities.RefundRequested,
+			Amount:      paid,
+			Reason:      reason,
+			Progress:    progress,
+			PolicyNote:  note,
+			RequestedAt: now,
+		})
+		if err != nil {
+			return entities.Refund{}, err
+		}
+		if err := uc.record(entities.AuditCreate, EntityRefund, refund.ID, nil, refund); err != nil {
+			return entities.Refund{}, err
+		}
+		return refund, nil
+	})
+}
+
+// refundPolicyViolation returns why the policy refuses a refund, or an empty string
+func (uc *CourseUseCase) refundPolicyViolation(order entities.Order, progress float64, now time.Time) string {
+	policy := uc.RefundPolicy
+	if policy.Window > 0 && order.PaidAt != nil && now.Sub(*order.PaidAt) > policy.Window {
+		return fmt.Sprintf("refunds must be requested within %d days of paying", int(policy.Window.Hours()/24))
+	}
+	if policy.MaxProgress > 0 && progress >= policy.MaxProgress {
+		return fmt.Sprintf("%g%% of the course is completed, refunds are only given below %g%%", progress, policy.MaxProgress)
+	}
+	return ""
+}
+
+// GetOrderRefunds returns the refunds of an order to its buyer or an admin
+func (uc *CourseUseCase) GetOrderRefunds(orderID int, actor entities.User) ([]entities.Refund, error) {
+	if _, err := uc.GetOrder(orderID, actor); err != nil {
+		return nil, err
+	}
+	return uc.Repo.GetRefundsByOrderID(orderID)
+}
+
+// GetRefunds returns the refunds with a status, or all of them
+func (uc *CourseUseCase) GetRefunds(status string) ([]entities.Refund, error) {
+	return uc.Repo.GetRefunds(status)
+}
+
+// ApproveRefund pays back a requested refund through the gateway, the whole amount asked for or
+// the smaller amount given, and revokes the enrollment the course was bought with. The
+// enrollment and its progress are kept, buying the course again reinstates it.
+// Approving a refund left processing by an approval that failed part way finishes it as it was
+// approved.
+func (uc *CourseUseCase) ApproveRefund(id int, amount *entities.Money, note string, actor entities.User) (entities.Refund, error) {
+	if uc.Payments == nil {
+		return entities.Refund{}, fmt.Errorf("payments are not configured")
+	}
+	before, err := uc.Repo.GetRefundByID(id)
+	if err != nil {
+		return entities.Refund{}, notFound(err)
+	}
+	if before.Status == entities.RefundProcessing {
+		return uc.completeRefund(before, before)
+	}
+	if before.Status != entities.RefundRequested {
+		return entities.Refund{}, fmt.Errorf("refund %d is %s", before.ID, before.Status)
+	}
+
+	processing := before
+	if amount != nil {
+		if amount.Currency != before.Amount.Currency {
+			return entities.Refund{}, fmt.Errorf("order %d was paid in %s", before.OrderID, before.Amount.Currency)
+		}
+		if !amount.IsPositive() || amount.Amount > before.Amount.Amount {
+			return entities.Refund{}, fmt.Errorf("amount must be greater than zero and at most %s", before.Amount)
+		}
+		processing.Amount = *amount
+	}
+	now := time.Now().UTC()
+	processing.Status = entities.RefundProcessing
+	processing.ReviewerID = &actor.ID
+	processing.ReviewNote = note
+	processing.ReviewedAt = &now
+	if err := uc.updateRefundStatus(before, processing); err != nil {
+		return entities.Refund{}, err
+	}
+	return uc.completeRefund(before, processing)
+}
+
+// completeRefund pays back a processing refund through the gateway and records it. The gateway's
+// refund ID is stored before the refund is completed and the refund ID is its idempotency key,
+// so when any step fails the refund stays processing and approving it again finishes it without
+// paying back twice.
+func (uc *CourseUseCase) completeRefund(before, processing entities.Refund) (entities.Refund, error) {
+	order, err := uc.Repo.GetOrderByID(int(processing.OrderID))
+	if err != nil {
+		return entities.Refund{}, err
+	}
+	if processing.GatewayRefundID == "" {
+		refundID, err := uc.Payments.Refund(RefundRequest{PaymentID: order.PaymentID, Amount: processing.Amount, IdempotencyKey: refundKey(processing.ID)})
+		if err != nil {
+			// give a new request back so it can be approved again
+			if before.Status == entities.RefundRequested {
+				if err := uc.Repo.UpdateRefundStatus(before, entities.RefundProcessing); err != nil {
+					return entities.Refund{}, err
+				}
+			}
+			return entities.Refund{}, fmt.Errorf("refund failed: %w", err)
+		}
+		if err := uc.Repo.SetRefundGatewayID(int(processing.ID), refundID); err != nil {
+			return entities.Refund{}, fmt.Errorf("refund %d was paid back as %s but not recorded, approve it again to finish: %w", processing.ID, refundID, err)
+		}
+		processing.GatewayRefundID = refundID
+	}
+
+	refunded := processing
+	refunded.Status = entities.RefundRefunded
+	orderStatus, enrollmentID, err := uc.refundOutcome(order, refunded)
+	if err != nil {
+		return entities.Refund{}, err
+	}
+	err = uc.atomic(func(uc *CourseUseCase) error {
+		var enrollmentBefore entities.Enrollment
+		if enrollmentID != nil {
+			enrollmentBefore, _ = uc.Repo.GetEnrollmentByID(int(*enrollmentID))
+		}
+		reason := fmt.Sprintf("refund %d", refunded.ID)
+		if err := uc.Repo.CompleteRefund(refunded, enrollmentID, time.Now().UTC(), reason, orderStatus); err != nil {
+			return err
+		}
+
+		if err := uc.record(entities.AuditUpdate, EntityRefund, refunded.ID, before, refunded); err != nil {
+			return err
+		}
+		if enrollmentID != nil && !enrollmentBefore.Revoked() {
+			if revoked, err := uc.Repo.GetEnrollmentByID(int(*enrollmentID)); err == nil {
+				if err := uc.record(entities.AuditUpdate, EntityEnrollment, revoked.ID, enrollmentBefore, revoked); err != nil {
+					return err
+				}
+			}
+		}
+		if orderStatus != order.Status {
+			if after, err := uc.Repo.GetOrderByID(int(order.ID)); err == nil {
+				if err := uc.record(entities.AuditUpdate, EntityOrder, order.ID, order, after); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return entities.Refund{}, fmt.Errorf("refund %d was paid back as %s but not completed, approve it again to finish: %w", refunded.ID, refunded.GatewayRefundID, err)
+	}
+	return refunded, nil
+}
+
+// refundKey is the idempotency key of the gateway refund paying back a refund
+func refundKey(refundID uint) string {
+	return fmt.Sprintf("refund-%d", refundID)
+}
+
+// payBackUnrecorded pays back a successful charge whose purchase could not be stored, so the
+// customer is not charged for nothing, and returns the error to report. A charge that cannot be
+// paid back is logged with its payment ID to be refunded by hand.
+func (uc *CourseUseCase) payBackUnrecorded(paymentID string, amount entities.Money, err error) error {
+	_, refundErr := uc.Payments.Refund(RefundRequest{PaymentID: paymentID, Amount: amount, IdempotencyKey: "unrecorded-" + paymentID})
+	if refundErr != nil {
+		log.Printf("Failed to pay back payment %s of %s after its purchase was not stored: %v", paymentID, amount, refundErr)
+		return fmt.Errorf("%w, paying back payment %s failed too: %v", err, paymentID, refundErr)
+	}
+	return fmt.Errorf("%w, the payment was paid back", err)
+}
+
+// refundOutcome returns the status of an order once a refund is paid back, refunded when every
+// course it paid for is, and the enrollment of the refunded course
+func (uc *CourseUseCase) refundOutcome(order entities.Order, refund entities.Refund) (string, *uint, error) {
+	refunds, err := uc.Repo.GetRefundsByOrderID(int(order.ID))
+	if err != nil {
+		return "", nil, err
+	}
+	refunded := map[uint]bool{refund.OrderItemID: true}
+	for _, other := range refunds {
+		if other.Status == entities.RefundRefunded {
+			refunded[other.OrderItemID] = true
+		}
+	}
+
+	status := entities.OrderRefunded
+	var enrollmentID *uint
+	for _, item := range order.Items {
+		if item.ID == refund.OrderItemID {
+			enrollmentID = item.EnrollmentID
+		}
+		if !refunded[item.ID] && item.Price.Sub(item.Discount).IsPositive() {
+			status = entities.OrderPaid
+		}
+	}
+	return status, enrollmentID, nil
+}
+
+// RejectRefund turns down a requested refund
+func (uc *CourseUseCase) RejectRefund(id int, note string, actor entities.User) (entities.Refund, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.Refund, error) {
+		before, err := uc.Repo.GetRefundByID(id)
+		if err != nil {
+			return entities.Refund{}, notFound(err)
+		}
+		if before.Status != entities.RefundRequested {
+			return entities.Refund{}, fmt.Errorf("refund %d is %s", before.ID, before.Status)
+		}
+		now := time.Now().UTC()
+		rejected := before
+		rejected.Status = entities.RefundRejected
+		rejected.ReviewerID = &actor.ID
+		rejected.ReviewNote = note
+		rejected.ReviewedAt = &now
+		if err := uc.updateRefundStatus(before, rejected); err != nil {
+			return entities.Refund{}, err
+		}
+		if err := uc.record(entities.AuditUpdate, EntityRefund, rejected.ID, before, rejected); err != nil {
+			return entities.Refund{}, err
+		}
+		return rejected, nil
+	})
+}
+
+// updateRefundStatus moves a refund on from the status it had when it was read
+func (uc *CourseUseCase) updateRefundStatus(before, after entities.Refund) error {
+	err := uc.Repo.UpdateRefundStatus(after, before.Status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("refund %d has already been decided", before.ID)
+	}
+	return err
+}
@@ -0,0 +1,465 @@
+package usecases
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+// Clock tells the time subscriptions are billed and their access is checked at
+type Clock interface {
+	Now() time.Time
+}
+
+// AdjustableClock is a clock that can be moved forward, to try renewals and grace periods
+// without waiting for them
+type AdjustableClock interface {
+	Clock
+	Advance(d time.Duration)
+}
+
+// SubscriptionPolicy decides what happens when a renewal fails
+type SubscriptionPolicy struct {
+	GracePeriod   time.Duration // how long access continues after the period that could not be renewed
+	RetryInterval time.Duration // how often the renewal is tried again during the grace period
+}
+
+// DefaultSubscriptionPolicy keeps access for 7 days after a failed renewal, retrying it daily
+var DefaultSubscriptionPolicy = SubscriptionPolicy{GracePeriod: 7 * 24 * time.Hour, RetryInterval: 24 * time.Hour}
+
+// maxRenewalsPerRun stops a billing run from looping when the clock jumps far ahead
+const maxRenewalsPerRun = 1000
+
+//----------------------------------------------------------------subscription plans----------------------------------------------------------------
+
+func (uc *CourseUseCase) CreateSubscriptionPlan(plan entities.SubscriptionPlan) (entities.SubscriptionPlan, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.SubscriptionPlan, error) {
+		if err := normalizePlan(&plan); err != nil {
+			return entities.SubscriptionPlan{}, err
+		}
+		plan.Active = true
+		plan.CreatedAt = time.Now().UTC()
+
+		created, err := uc.Repo.AddSubscriptionPlan(plan)
+		if err != nil {
+			return entities.SubscriptionPlan{}, err
+		}
+		if err := uc.record(entities.AuditCreate, EntityPlan, created.ID, nil, created); err != nil {
+			return entities.SubscriptionPlan{}, err
+		}
+		return created, nil
+	})
+}
+
+func (uc *CourseUseCase) GetSubscriptionPlans(includeInactive bool) ([]entities.SubscriptionPlan, error) {
+	return uc.Repo.GetSubscriptionPlans(includeInactive)
+}
+
+func (uc *CourseUseCase) GetSubscriptionPlan(id int) (entities.SubscriptionPlan, error) {
+	plan, err := uc.Repo.GetSubscriptionPlanByID(id)
+	if err != nil {
+		return entities.SubscriptionPlan{}, notFound(err)
+	}
+	return plan, nil
+}
+
+// UpdateSubscriptionPlan changes a plan, subscribers pay its new price from their next renewal.
+// Deactivating a plan stops new subscriptions, existing ones carry on.
+func (uc *CourseUseCase) UpdateSubscriptionPlan(plan entities.SubscriptionPlan) (entities.SubscriptionPlan, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.SubscriptionPlan, error) {
+		before, err := uc.Repo.GetSubscriptionPlanByID(int(plan.ID))
+		if err != nil {
+			return entities.SubscriptionPlan{}, notFound(err)
+		}
+		if err := normalizePlan(&plan); err != nil {
+			return entities.SubscriptionPlan{}, err
+		}
+		plan.CreatedAt = before.CreatedAt
+
+		if err := uc.Repo.UpdateSubscriptionPlan(plan); err != nil {
+			return entities.SubscriptionPlan{}, notFound(err)
+		}
+		if err := uc.record(entities.AuditUpdate, EntityPlan, plan.ID, before, plan); err != nil {
+			return entities.SubscriptionPlan{}, err
+		}
+		return plan, nil
+	})
+}
+
+// normalizePlan validates a plan and tidies its categories
+func normalizePlan(plan *entities.SubscriptionPlan) error {
+	plan.Name = strings.TrimSpace(plan.Name)
+	if plan.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if plan.Interval != entities.IntervalMonth && plan.Interval != entities.IntervalYear {
+		return fmt.Errorf("interval must be %s or %s", entities.IntervalMonth, entities.IntervalYear)
+	}
+	if err := validatePrice(plan.Price); err != nil {
+		return err
+	}
+	categories := []string{}
+	seen := map[string]bool{}
+	for _, category := range plan.Categories {
+		category = strings.TrimSpace(category)
+		if category != "" && !seen[strings.ToLower(category)] {
+			seen[strings.ToLower(category)] = true
+			categories = append(categories, category)
+		}
+	}
+	if len(categories) == 0 {
+		return fmt.Errorf("a plan needs at least one category")
+	}
+	plan.Categories = categories
+	return nil
+}
+
+//----------------------------------------------------------------subscriptions----------------------------------------------------------------
+
+// Subscribe starts a subscription to a plan, charging its first period right away. Renewals are
+// charged without the customer present, so payment methods that need them to authenticate are declined.
+func (uc *CourseUseCase) Subscribe(planID int, paymentMethod string, actor entities.User) (entities.Subscription, error) {
+	if uc.Payments == nil {
+		return entities.Subscription{}, fmt.Errorf("payments are not configured")
+	}
+	plan, err := uc.Repo.GetSubscriptionPlanByID(planID)
+	if err != nil {
+		return entities.Subscription{}, notFound(err)
+	}
+	if !plan.Active {
+		return entities.Subscription{}, fmt.Errorf("plan %q no longer takes subscriptions", plan.Name)
+	}
+	existing, err := uc.Repo.GetSubscriptionsByUserID(int(actor.ID))
+	if err != nil {
+		return entities.Subscription{}, err
+	}
+	for _, subscription := range existing {
+		if subscription.PlanID == plan.ID && !subscription.Ended() {
+			return subscription, &ConflictError{Resource: "subscription", Existing: subscription}
+		}
+	}
+
+	now := uc.now()
+	subscription := entities.Subscription{
+		UserID:             actor.ID,
+		PlanID:             plan.ID,
+		Status:             entities.SubscriptionActive,
+		PaymentMethod:      paymentMethod,
+		CurrentPeriodStart: now,
+		CurrentPeriodEnd:   plan.NextPeriodEnd(now),
+		CreatedAt:          now,
+	}
+	payment := uc.chargeSubscription(subscription, plan, subscription.CurrentPeriodStart, subscription.CurrentPeriodEnd, now)
+	if payment.Status != entities.SubscriptionPaymentSucceeded {
+		return entities.Subscription{}, fmt.Errorf("%w: %s", ErrPaymentDeclined, payment.FailureReason)
+	}
+	created, err := atomically(uc, func(uc *CourseUseCase) (entities.Subscription, error) {
+		created, err := uc.Repo.AddSubscription(subscription, payment)
+		if err != nil {
+			return entities.Subscription{}, err
+		}
+		if err := uc.record(entities.AuditCreate, EntitySubscription, created.ID, nil, created); err != nil {
+			return entities.Subscription{}, err
+		}
+		return created, nil
+	})
+	if err != nil {
+		return entities.Subscription{}, uc.payBackUnrecorded(payment.PaymentID, payment.Amount, err)
+	}
+	return created, nil
+}
+
+// GetUserSubscriptions returns the subscriptions of a user to that user or an admin, newest first
+func (uc *CourseUseCase) GetUserSubscriptions(userID int, actor entities.User) ([]entities.Subscription, error) {
+	if uint(userID) != actor.ID && actor.Role != "admin" {
+		return nil, fmt.Errorf("%w: only the user and admins can see their subscriptions", ErrForbidden)
+	}
+	return uc.Repo.GetSubscriptionsByUserID(userID)
+}
+
+// GetSubscription returns a subscription to its subscriber or an admin
+func (uc *CourseUseCase) GetSubscription(id int, actor entities.User) (entities.Subscription, error) {
+	subscription, err := uc.Repo.GetSubscriptionByID(id)
+	if err != nil {
+		return entities.Subscription{}, notFound(err)
+	}
+	if subscription.UserID != actor.ID && actor.Role != "admin" {
+		return entities.Subscription{}, fmt.Errorf("%w: subscription belongs to another user", ErrForbidden)
+	}
+	return subscription, nil
+}
+
+// GetSubscriptionPayments returns the charges made for a subscription, oldest first
+func (uc *CourseUseCase) GetSubscriptionPayments(id int, actor entities.User) ([]entities.SubscriptionPayment, error) {
+	if _, err := uc.GetSubscription(id, actor); err != nil {
+		return nil, err
+	}
+	return uc.Repo.GetSubscriptionPayments(id)
+}
+
+// CancelSubscription stops a subscription from renewing, it keeps its access until the end of the
+// period already paid for. A past due subscription has nothing paid left and ends right away.
+func (uc *CourseUseCase) CancelSubscription(id int, actor entities.User) (entities.Subscription, error) {
+	before, err := uc.GetSubscription(id, actor)
+	if err != nil {
+		return entities.Subscription{}, err
+	}
+	if before.Ended() {
+		return entities.Subscription{}, fmt.Errorf("subscription %d has already ended", before.ID)
+	}
+	if before.CancelAtPeriodEnd {
+		return before, &ConflictError{Resource: "cancellation", Existing: before}
+	}
+	now := uc.now()
+	cancelled := before
+	cancelled.CancelAtPeriodEnd = true
+	cancelled.CancelledAt = &now
+	if before.Status == entities.SubscriptionPastDue {
+		cancelled.Status = entities.SubscriptionCancelled
+		cancelled.EndedAt = &now
+		cancelled.GraceUntil = nil
+		cancelled.NextAttemptAt = nil
+	}
+	return uc.updateSubscription(before, cancelled, nil)
+}
+
+// ResumeSubscription takes back a cancellation before the subscription has ended
+func (uc *CourseUseCase) ResumeSubscription(id int, actor entities.User) (entities.Subscription, error) {
+	before, err := uc.GetSubscription(id, actor)
+	if err != nil {
+		return entities.Subscription{}, err
+	}
+	if before.Ended() {
+		return entities.Subscription{}, fmt.Errorf("subscription %d has already ended, subscribe again", before.ID)
+	}
+	if !before.CancelAtPeriodEnd {
+		return entities.Subscription{}, fmt.Errorf("subscription %d is not cancelled", before.ID)
+	}
+	resumed := before
+	resumed.CancelAtPeriodEnd = false
+	resumed.CancelledAt = nil
+	return uc.updateSubscription(before, resumed, nil)
+}
+
+// UpdateSubscriptionPaymentMethod changes the payment method renewals are charged to. A past due
+// subscription is renewed with it right away.
+func (uc *CourseUseCase) UpdateSubscriptionPaymentMethod(id int, paymentMethod string, actor entities.User) (entities.Subscription, error) {
+	before, err := uc.GetSubscription(id, actor)
+	if err != nil {
+		return entities.Subscription{}, err
+	}
+	if before.Ended() {
+		return entities.Subscription{}, fmt.Errorf("subscription %d has already ended", before.ID)
+	}
+	updated := before
+	updated.PaymentMethod = paymentMethod
+	updated, err = uc.updateSubscription(before, updated, nil)
+	if err != nil || updated.Status != entities.SubscriptionPastDue {
+		return updated, err
+	}
+	return uc.renewSubscription(updated, uc.now())
+}
+
+//----------------------------------------------------------------billing----------------------------------------------------------------
+
+// RunBilling renews the subscriptions whose period is over, retries failed renewals and ends the
+// subscriptions that were cancelled or ran out of grace. It returns how many subscriptions it moved on.
+func (uc *CourseUseCase) RunBilling() (int, error) {
+	processed := 0
+	for processed < maxRenewalsPerRun {
+		now := uc.now()
+		due, err := uc.Repo.GetDueSubscriptions(now)
+		if err != nil {
+			return processed, err
+		}
+		if len(due) == 0 {
+			break
+		}
+		for _, subscription := range due {
+			if _, err := uc.renewSubscription(subscription, now); err != nil {
+				return processed, err
+			}
+			processed++
+		}
+	}
+	return processed, nil
+}
+
+// BillingTime returns the time of the billing clock and whether it can be moved
+func (uc *CourseUseCase) BillingTime() (time.Time, bool) {
+	_, adjustable := uc.Clock.(AdjustableClock)
+	return uc.now(), adjustable
+}
+
+// AdvanceBillingClock moves a simulated billing clock forward and runs billing at the new time
+func (uc *CourseUseCase) AdvanceBillingClock(d time.Duration) (time.Time, int, error) {
+	clock, ok := uc.Clock.(AdjustableClock)
+	if !ok {
+		return time.Time{}, 0, fmt.Errorf("the billing clock is not simulated")
+	}
+	if d <= 0 {
+		return time.Time{}, 0, fmt.Errorf("the clock only moves forward")
+	}
+	clock.Advance(d)
+	processed, err := uc.RunBilling()
+	return uc.now(), processed, err
+}
+
+// renewSubscription moves a subscription on at a time: an active one whose period is over ends if
+// it was cancelled and is charged for its next period otherwise, a past due one is charged again
+// or expires once its grace period is over
+func (uc *CourseUseCase) renewSubscription(before entities.Subscription, now time.Time) (entities.Subscription, error) {
+	after := before
+	switch {
+	case before.Status == entities.SubscriptionActive && before.CancelAtPeriodEnd:
+		end := before.CurrentPeriodEnd
+		after.Status = entities.SubscriptionCancelled
+		after.EndedAt = &end
+		return uc.updateSubscription(before, after, nil)
+
+	case before.Status == entities.SubscriptionPastDue && before.GraceUntil != nil && !now.Before(*before.GraceUntil):
+		end := *before.GraceUntil
+		after.Status = entities.SubscriptionExpired
+		after.EndedAt = &end
+		after.NextAttemptAt = nil
+		return uc.updateSubscription(before, after, nil)
+	}
+
+	plan, err := uc.Repo.GetSubscriptionPlanByID(int(before.PlanID))
+	if err != nil {
+		return entities.Subscription{}, err
+	}
+	// the period that could not be paid for is the one charged on a retry
+	start := before.CurrentPeriodEnd
+	end := plan.NextPeriodEnd(start)
+	payment := uc.chargeSubscription(before, plan, start, end, now)
+	if payment.Status == entities.SubscriptionPaymentSucceeded {
+		after.Status = entities.SubscriptionActive
+		after.CurrentPeriodStart = start
+		after.CurrentPeriodEnd = end
+		after.GraceUntil = nil
+		after.NextAttemptAt = nil
+		after.FailureReason = ""
+	} else {
+		next := now.Add(uc.SubscriptionPolicy.RetryInterval)
+		after.Status = entities.SubscriptionPastDue
+		after.FailureReason = payment.FailureReason
+		after.NextAttemptAt = &next
+		if after.GraceUntil == nil {
+			grace := before.CurrentPeriodEnd.Add(uc.SubscriptionPolicy.GracePeriod)
+			after.GraceUntil = &grace
+		}
+	}
+	return uc.updateSubscription(before, after, &payment)
+}
+
+// chargeSubscription charges a period of a subscription at the plan's current price, failures are
+// returned as a failed payment
+func (uc *CourseUseCase) chargeSubscription(subscription entities.Subscription, plan entities.SubscriptionPlan, start, end, now time.Time) entities.SubscriptionPayment {
+	payment := entities.SubscriptionPayment{
+		SubscriptionID: subscription.ID,
+		Amount:         plan.Price,
+		Status:         entities.SubscriptionPaymentFailed,
+		PeriodStart:    start,
+		PeriodEnd:      end,
+		AttemptedAt:    now,
+	}
+	if uc.Payments == nil {
+		payment.FailureReason = "payments are not configured"
+		return payment
+	}
+	result, err := uc.Payments.Charge(PaymentRequest{SubscriptionID: subscription.ID, Amount: plan.Price, Method: subscription.PaymentMethod, OffSession: true})
+	switch {
+	case err != nil:
+		payment.FailureReason = err.Error()
+	case result.Status == PaymentSucceeded:
+		payment.Status = entities.SubscriptionPaymentSucceeded
+	case result.Status == PaymentRequiresAction:
+		payment.FailureReason = "the payment method requires the customer to authenticate"
+	default:
+		payment.FailureReason = result.FailureReason
+	}
+	payment.PaymentID = result.ID
+	return payment
+}
+
+// updateSubscription stores a subscription unless it changed since it was read
+func (uc *CourseUseCase) updateSubscription(before, after entities.Subscription, payment *entities.SubscriptionPayment) (entities.Subscription, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.Subscription, error) {
+		err := uc.Repo.UpdateSubscription(after, before.Status, before.CurrentPeriodEnd, payment)
+		if errors.Is(err, sql.ErrNoRows) {
+			return entities.Subscription{}, fmt.Errorf("%w: subscription %d changed meanwhile, try again", ErrConflict, before.ID)
+		}
+		if err != nil {
+			return entities.Subscription{}, err
+		}
+		if err := uc.record(entities.AuditUpdate, EntitySubscription, after.ID, before, after); err != nil {
+			return entities.Subscription{}, err
+		}
+		if payment != nil && payment.Status == entities.SubscriptionPaymentFailed {
+			log.Printf("Renewal of subscription %d failed: %s", after.ID, payment.FailureReason)
+		}
+		return after, nil
+	})
+}
+
+// now is the time on the billing clock
+func (uc *CourseUseCase) now() time.Time {
+	if uc.Clock != nil {
+		return uc.Clock.Now().UTC()
+	}
+	return time.Now().UTC()
+}
+
+//----------------------------------------------------------------access----------------------------------------------------------------
+
+// coveringSubscription returns a subscription of the user that gives access to a course now
+func (uc *CourseUseCase) coveringSubscription(userID uint, course entities.Course) (*entities.Subscription, error) {
+	subscriptions, err := uc.Repo.GetSubscriptionsByUserID(int(userID))
+	if err != nil {
+		return nil, err
+	}
+	now := uc.now()
+	for _, subscription := range subscriptions {
+		if !subscription.GrantsAccess(now) {
+			continue
+		}
+		plan, err := uc.Repo.GetSubscriptionPlanByID(int(subscription.PlanID))
+		if err != nil {
+			return nil, err
+		}
+		if plan.Covers(course) {
+			return &subscription, nil
+		}
+	}
+	return nil, nil
+}
+
+// checkEnrollmentAccess rejects enrollments that no longer open their course: revoked ones, and
+// ones made through a subscription when no subscription of the student covers the course any more
+func (uc *CourseUseCase) checkEnrollmentAccess(enrollment entities.Enrollment) error {
+	if enrollment.Revoked() {
+		return fmt.Errorf("%w: enrollment %d has been revoked", ErrForbidden, enrollment.ID)
+	}
+	if enrollment.SubscriptionID == nil {
+		return nil
+	}
+	course, err := uc.Repo.GetCourseByID(int(enrollment.CourseID), true)
+	if err != nil {
+		return notFound(err)
+	}
+	subscription, err := uc.coveringSubscription(enrollment.UserID, course)
+	if err != nil {
+		return err
+	}
+	if subscription == nil {
+		return fmt.Errorf("%w: enrollment %d was made through a subscription that no longer covers %q, subscribe again or buy the course",
+			ErrForbidden, enrollment.ID, course.Title)
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package usecases
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/NaheedRayan/mini_rest_api_shikho/entities"
+)
+
+// EntityCourseVersion is the audit entity type of course versions
+const EntityCourseVersion = "course_version"
+
+// snapshotCourse stores an immutable version of a course and its curriculum,
+// taken every time the course is published
+func (uc *CourseUseCase) snapshotCourse(course entities.Course) (entities.CourseVersion, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.CourseVersion, error) {
+		lessons, err := uc.Repo.GetLessonsByCourseID(int(course.ID), false)
+		if err != nil {
+			return entities.CourseVersion{}, err
+		}
+		sections, err := uc.Repo.GetSectionsByCourseID(int(course.ID))
+		if err != nil {
+			return entities.CourseVersion{}, err
+		}
+		// ratings keep changing after publishing, they are not part of the content
+		course.Rating = entities.RatingSummary{}
+		version, err := uc.Repo.AddCourseVersion(entities.CourseVersion{
+			CourseID:  course.ID,
+			Course:    course,
+			Sections:  sections,
+			Lessons:   lessons,
+			CreatedAt: time.Now().UTC(),
+		})
+		if err != nil {
+			return entities.CourseVersion{}, err
+		}
+		if err := uc.record(entities.AuditCreate, EntityCourseVersion, version.ID, nil, version); err != nil {
+			return entities.CourseVersion{}, err
+		}
+		return version, nil
+	})
+}
+
+// latestCourseVersion returns the number of the latest version of a course,
+// 0 if it was never published since versioning exists
+func (uc *CourseUseCase) latestCourseVersion(courseID int) (uint, error) {
+	version, err := uc.Repo.GetLatestCourseVersion(courseID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return version.Version, nil
+}
+
+// enrollmentLessons returns the lessons an enrollment follows: those of its pinned version,
+// or the live lessons of the course for enrollments without a version
+func (uc *CourseUseCase) enrollmentLessons(enrollment entities.Enrollment) ([]entities.Lesson, error) {
+	if enrollment.CourseVersion == 0 {
+		return uc.Repo.GetLessonsByCourseID(int(enrollment.CourseID), false)
+	}
+	version, err := uc.Repo.GetCourseVersion(int(enrollment.CourseID), enrollment.CourseVersion)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return version.Lessons, nil
+}
+
+// GetEnrollmentLessons returns the lessons of the course version an enrollment is pinned to,
+// the ones not released yet come locked. Only the enrolled student, the course instructor and
+// admins can read them, and only while the enrollment still opens the course.
+func (uc *CourseUseCase) GetEnrollmentLessons(enrollmentID int, actor entities.User) ([]entities.Lesson, error) {
+	if err := uc.canViewEnrollment(enrollmentID, actor); err != nil {
+		return nil, err
+	}
+	enrollment, err := uc.Repo.GetEnrollmentByID(enrollmentID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if err := uc.checkEnrollmentAccess(enrollment); err != nil {
+		return nil, err
+	}
+	lessons, err := uc.enrollmentLessons(enrollment)
+	if err != nil {
+		return nil, err
+	}
+	completed, err := uc.completedLessons(enrollment)
+	if err != nil {
+		return nil, err
+	}
+	lockLessons(lessons, &enrollment, completed, time.Now())
+	hideLockedContent(lessons)
+	return lessons, nil
+}
+
+// UpgradeEnrollment moves an enrollment to the latest version of its course. Progress on lessons
+// kept by the new version carries over. Only the enrolled student or an admin can upgrade.
+func (uc *CourseUseCase) UpgradeEnrollment(enrollmentID int, actor entities.User) (entities.Enrollment, error) {
+	return atomically(uc, func(uc *CourseUseCase) (entities.Enrollment, error) {
+		before, err := uc.Repo.GetEnrollmentByID(enrollmentID)
+		if err != nil {
+			return entities.Enrollment{}, notFound(err)
+		}
+		if actor.ID != before.UserID && actor.Role != "admin" {
+			return entities.Enrollment{}, fmt.Errorf("%w: only the enrolled student can upgrade an enrollment", ErrForbidden)
+		}
+		latest, err := uc.latestCourseVersion(int(before.CourseID))
+		if err != nil {
+			return entities.Enrollment{}, err
+		}
+		if latest == before.CourseVersion {
+			return before, nil
+		}
+
+		enrollment := before
+		enrollment.CourseVersion = latest
+		updated, err := uc.Repo.UpdateEnrollment(enrollment)
+		if err != nil {
+			return entities.Enrollment{}, err
+		}
+		if err := uc.record(entities.AuditUpdate, EntityEnrollment, updated.ID, before, updated); err != nil {
+			return entities.Enrollment{}, err
+		}
+		if err := uc.refreshCompletion(enrollmentID); err != nil {
+			return updated, err
+		}
+		return uc.Repo.GetEnrollmentByID(enrollmentID)
+	})
+}
+
+// ListCourseVersions returns every version of a course. The snapshots hold the content of every
+// lesson, so only the course instructor and admins can read them.
+func (uc *CourseUseCase) ListCourseVersions(courseID int, actor entities.User) ([]entities.CourseVersion, error) {
+	if err := uc.requireCourseInstructor(courseID, actor); err != nil {
+		return nil, err
+	}
+	return uc.Repo.GetCourseVersions(courseID)
+}
+
+// GetCourseVersion returns one version of a course to its instructor or an admin
+func (uc *CourseUseCase) GetCourseVersion(courseID int, version uint, actor entities.User) (entities.CourseVersion, error) {
+	if err := uc.requireCourseInstructor(courseID, actor); err != nil {
+		return entities.CourseVersion{}, err
+	}
+	result, err := uc.Repo.GetCourseVersion(courseID, version)
+	if err != nil {
+		return entities.CourseVersion{}, notFound(err)
+	}
+	return result, nil
+}
+
+// DiffCourseVersions returns the changes to the course fields and lessons between two versions
+func (uc *CourseUseCase) DiffCourseVersions(courseID int, from, to uint, actor entities.User) (entities.CourseVersionDiff, error) {
+	before, err := uc.GetCourseVersion(courseID, from, actor)
+	if err != nil {
+		return entities.CourseVersionDiff{}, err
+	}
+	after, err := uc.GetCourseVersion(courseID, to, actor)
+	if err != nil {
+		return entities.CourseVersionDiff{}, err
+	}
+
+	diff := entities.CourseVersionDiff{
+		CourseID:       uint(courseID),
+		From:           from,
+		To:             to,
+		LessonsAdded:   []entities.Lesson{},
+		LessonsRemoved: []entities.Lesson{},
+		LessonsChanged: []entities.LessonChange{},
+	}
+	if diff.Course, err = fieldChanges(before.Course, after.Course); err != nil {
+		return entities.CourseVersionDiff{}, err
+	}
+
+	previous := map[uint]entities.Lesson{}
+	for _, lesson := range before.Lessons {
+		previous[lesson.ID] = lesson
+	}
+	for _, lesson := range after.Lessons {
+		old, ok := previous[lesson.ID]
+		if !ok {
+			diff.LessonsAdded = append(diff.LessonsAdded, lesson)
+			continue
+		}
+		delete(previous, lesson.ID)
+		changes, err := fieldChanges(old, lesson)
+		if err != nil {
+			return entities.CourseVersionDiff{}, err
+		}
+		if changes != nil {
+			diff.LessonsChanged = append(diff.LessonsChanged, entities.LessonChange{LessonID: lesson.ID, Changes: changes})
+		}
+	}
+	for _, lesson := range before.Lessons {
+		if _, removed := previous[lesson.ID]; removed {
+			diff.LessonsRemoved = append(diff.LessonsRemoved, lesson)
+		}
+	}
+	return diff, nil
+}
+
+// fieldChanges returns the changed JSON fields of two values as field -> {before, after}, nil when equal
+func fieldChanges(before, after interface{}) (json.RawMessage, error) {
+	beforeFields, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+	return encodeFields(diffFields(beforeFields, afterFields)), nil
+}